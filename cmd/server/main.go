@@ -11,10 +11,19 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ViktorBystrov72/go-metrics/internal/alerting"
 	"github.com/ViktorBystrov72/go-metrics/internal/config"
+	"github.com/ViktorBystrov72/go-metrics/internal/export"
+	"github.com/ViktorBystrov72/go-metrics/internal/influx"
 	"github.com/ViktorBystrov72/go-metrics/internal/logger"
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/otlp"
+	"github.com/ViktorBystrov72/go-metrics/internal/rates"
+	"github.com/ViktorBystrov72/go-metrics/internal/rollup"
 	"github.com/ViktorBystrov72/go-metrics/internal/server"
 	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+	"github.com/ViktorBystrov72/go-metrics/internal/webhook"
 )
 
 var (
@@ -28,6 +37,14 @@ type ServerComponents struct {
 	StorageManager *server.StorageManager
 	HTTPServer     *http.Server
 	PProfServer    *http.Server
+	Broker         *stream.Broker
+	WebSocketHub   *server.WebSocketHub
+	Listeners      *server.Listeners
+	Exporters      *export.Manager
+	Webhooks       *webhook.Manager
+	Alerts         *alerting.Engine
+	Rates          *rates.Tracker
+	Rollups        *rollup.Manager
 }
 
 func printBuildInfo() {
@@ -79,14 +96,56 @@ func setupStorageManager(storageInstance storage.Storage, cfg *config.Config) *s
 		StoreInterval:   cfg.StoreInterval,
 		FileStoragePath: cfg.FileStoragePath,
 		Restore:         cfg.Restore,
+		GaugeTTL:        cfg.GaugeTTL,
+		CounterTTL:      cfg.CounterTTL,
 	}
 	storageManager := server.NewStorageManager(storageInstance, storageConfig)
 	storageManager.Start()
 	return storageManager
 }
 
-func setupHTTPServer(cfg *config.Config, storageInstance storage.Storage) (*http.Server, error) {
-	router := server.NewRouter(storageInstance, cfg.Key, cfg.CryptoKey)
+func setupHTTPServer(cfg *config.Config, storageInstance storage.Storage, storageManager *server.StorageManager, broker *stream.Broker, wsHub *server.WebSocketHub, alerts *alerting.Engine, tracker *rates.Tracker, rollups *rollup.Manager) (*http.Server, error) {
+	opts := []server.RouterOption{
+		server.WithStream(broker),
+		server.WithWebSocket(wsHub),
+		server.WithExpiryStats(storageManager),
+		server.WithBatchMode(cfg.BatchMode),
+		server.WithOTLP(otlp.Config{
+			ResourceMode:       cfg.OTLPResourceMode,
+			ResourceAttributes: cfg.OTLPResourceAttributes,
+		}),
+		server.WithInflux(influx.Config{IntegerMode: cfg.InfluxIntegerMode}),
+		server.WithRates(tracker),
+	}
+	rateLimit := middleware.RateLimitConfig{
+		Rate:        cfg.IngestRateLimit,
+		Burst:       cfg.IngestBurst,
+		MaxInFlight: cfg.MaxInFlight,
+		Identity:    cfg.RateLimitIdentity,
+		Key:         cfg.Key,
+	}
+	if rateLimit.Enabled() {
+		if rateLimit.Identity == middleware.IdentityKey && rateLimit.Key == "" {
+			log.Printf("Rate limiting по ключу без ключа подписи (-k): клиенты определяются по IP адресу")
+		}
+		log.Printf("Rate limiting приёма метрик: %v запросов/с на клиента, burst %d, одновременно %d, клиент по %s",
+			rateLimit.Rate, rateLimit.Burst, rateLimit.MaxInFlight, rateLimit.Identity)
+		opts = append(opts, server.WithRateLimiter(middleware.NewRateLimiter(rateLimit)))
+	}
+	if alerts != nil {
+		opts = append(opts, server.WithAlerts(alerts))
+	}
+	if rollups != nil {
+		opts = append(opts, server.WithRollups(rollups))
+	}
+	if cfg.AdminToken != "" {
+		log.Printf("Административный API удаления и сброса метрик включён")
+		opts = append(opts, server.WithAdminToken(cfg.AdminToken))
+	}
+
+	// Обновления, принятые через HTTP, публикуются подписчикам /api/v1/stream
+	publishing := stream.NewPublishingStorage(storageInstance, broker)
+	router := server.NewRouter(publishing, cfg.Key, cfg.CryptoKey, opts...)
 
 	zapLogger, err := logger.NewZapLogger()
 	if err != nil {
@@ -102,6 +161,75 @@ func setupHTTPServer(cfg *config.Config, storageInstance storage.Storage) (*http
 	}, nil
 }
 
+// setupListeners запускает приём метрик по Graphite и StatsD, если он настроен.
+// Принятые метрики, как и HTTP обновления, публикуются подписчикам /api/v1/stream.
+func setupListeners(cfg *config.Config, storageInstance storage.Storage, broker *stream.Broker) (*server.Listeners, error) {
+	listeners := server.NewListeners(stream.NewPublishingStorage(storageInstance, broker), server.ListenersConfig{
+		GraphiteAddress: cfg.GraphiteAddress,
+		StatsDAddress:   cfg.StatsDAddress,
+		FlushInterval:   cfg.ListenerFlushInterval,
+	})
+	if err := listeners.Start(); err != nil {
+		return nil, err
+	}
+	return listeners, nil
+}
+
+// setupAlerting запускает проверку правил алертов, если задан файл правил
+func setupAlerting(cfg *config.Config, storageInstance storage.Storage) (*alerting.Engine, error) {
+	if cfg.RulesFile == "" {
+		return nil, nil
+	}
+	engine, err := alerting.NewEngineFromFile(storageInstance, cfg.RulesFile, cfg.RulesInterval)
+	if err != nil {
+		return nil, err
+	}
+	engine.Start()
+	return engine, nil
+}
+
+// setupRates запускает периодическое снятие значений counter метрик для расчёта rate и increase
+func setupRates(cfg *config.Config, storageInstance storage.Storage) *rates.Tracker {
+	tracker := rates.NewTracker(storageInstance, cfg.RateSampleInterval, cfg.RateRetention)
+	tracker.Start()
+	return tracker
+}
+
+// setupRollups запускает агрегацию метрик по tier, если она включена и хранилище
+// поддерживает хранение агрегатов
+func setupRollups(cfg *config.Config, storageInstance storage.Storage) *rollup.Manager {
+	if !cfg.Rollups.Enabled {
+		return nil
+	}
+	store, ok := storageInstance.(storage.RollupStore)
+	if !ok {
+		return nil
+	}
+	manager := rollup.NewManager(storageInstance, store, cfg.Rollups)
+	manager.Start()
+	return manager
+}
+
+// setupExporters запускает периодическую выгрузку метрик во внешние системы
+func setupExporters(cfg *config.Config, storageInstance storage.Storage) (*export.Manager, error) {
+	exporters, err := export.NewManager(storageInstance, cfg.Exporters)
+	if err != nil {
+		return nil, err
+	}
+	exporters.Start()
+	return exporters, nil
+}
+
+// setupWebhooks подписывает webhooks на обновления, опубликованные всеми обработчиками приёма
+func setupWebhooks(cfg *config.Config, broker *stream.Broker) (*webhook.Manager, error) {
+	webhooks, err := webhook.NewManager(broker, cfg.Webhooks)
+	if err != nil {
+		return nil, err
+	}
+	webhooks.Start()
+	return webhooks, nil
+}
+
 func setupPProfServer() *http.Server {
 	return &http.Server{
 		Addr: "127.0.0.1:6060",
@@ -135,6 +263,21 @@ func performGracefulShutdown(components *ServerComponents) {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
+	// Останавливаем приём Graphite и StatsD до хранилища: при закрытии
+	// записываются значения, накопленные с последнего интервала
+	log.Printf("Остановка приёма Graphite и StatsD...")
+	components.Listeners.Close()
+
+	// Закрываем SSE подписки до остановки HTTP сервера, иначе Shutdown будет ждать
+	// завершения потоковых соединений до истечения таймаута. Брокер продолжает
+	// рассылать обновления webhooks, пока завершаются принятые запросы
+	log.Printf("Закрытие подписок на поток метрик...")
+	components.Broker.CloseClients()
+
+	// WebSocket соединения перехвачены у HTTP сервера, Shutdown их не закрывает
+	log.Printf("Закрытие WebSocket соединений агентов...")
+	components.WebSocketHub.Close()
+
 	// Останавливаем HTTP сервер
 	log.Printf("Остановка HTTP сервера...")
 	if err := components.HTTPServer.Shutdown(shutdownCtx); err != nil {
@@ -151,6 +294,26 @@ func performGracefulShutdown(components *ServerComponents) {
 		log.Printf("pprof сервер остановлен")
 	}
 
+	// Останавливаем проверку правил и отправляем оставшиеся уведомления
+	if components.Alerts != nil {
+		components.Alerts.Stop(shutdownCtx)
+	}
+
+	// Останавливаем снятие значений counter метрик
+	components.Rates.Stop()
+
+	// Останавливаем агрегацию до закрытия хранилища
+	if components.Rollups != nil {
+		components.Rollups.Stop()
+	}
+
+	// Новых обновлений больше нет: закрываем брокер и отправляем накопленные батчи webhooks
+	components.Broker.Close()
+	components.Webhooks.Stop(shutdownCtx)
+
+	// Экспортёры выгружают финальные значения, пока хранилище ещё открыто
+	components.Exporters.Stop(shutdownCtx)
+
 	// Останавливаем StorageManager
 	components.StorageManager.Stop()
 
@@ -182,7 +345,34 @@ func main() {
 
 	storageManager := setupStorageManager(storageInstance, cfg)
 
-	httpServer, err := setupHTTPServer(cfg, storageInstance)
+	broker := stream.NewBroker(cfg.StreamBuffer, cfg.StreamDropPolicy)
+
+	webhooks, err := setupWebhooks(cfg, broker)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wsHub := server.NewWebSocketHub()
+
+	alerts, err := setupAlerting(cfg, storageInstance)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tracker := setupRates(cfg, storageInstance)
+	rollups := setupRollups(cfg, storageInstance)
+
+	httpServer, err := setupHTTPServer(cfg, storageInstance, storageManager, broker, wsHub, alerts, tracker, rollups)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	listeners, err := setupListeners(cfg, storageInstance, broker)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	exporters, err := setupExporters(cfg, storageInstance)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -194,6 +384,14 @@ func main() {
 		StorageManager: storageManager,
 		HTTPServer:     httpServer,
 		PProfServer:    pprofServer,
+		Broker:         broker,
+		WebSocketHub:   wsHub,
+		Listeners:      listeners,
+		Exporters:      exporters,
+		Webhooks:       webhooks,
+		Alerts:         alerts,
+		Rates:          tracker,
+		Rollups:        rollups,
 	}
 
 	startServers(httpServer, pprofServer)
@@ -10,15 +10,20 @@ import (
 	"compress/gzip"
 	"io"
 
+	"net"
 	"os"
 	"os/exec"
+	"sync"
+	"time"
 
 	"github.com/ViktorBystrov72/go-metrics/internal/config"
 	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
 	"github.com/ViktorBystrov72/go-metrics/internal/models"
 	"github.com/ViktorBystrov72/go-metrics/internal/server"
 	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
 	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+	"github.com/ViktorBystrov72/go-metrics/internal/webhook"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -551,3 +556,95 @@ func TestMainServerSmoke(t *testing.T) {
 		t.Logf("main.go (server) завершился с ошибкой (ожидаемо для -h): %s", string(out))
 	}
 }
+
+// TestPerformGracefulShutdown_WebhookReceivesInFlightUpdates проверяет, что обновления запросов,
+// завершающихся во время остановки HTTP сервера, доходят до webhooks.
+func TestPerformGracefulShutdown_WebhookReceivesInFlightUpdates(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []models.Metrics
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []models.Metrics
+		if err := json.NewDecoder(r.Body).Decode(&batch); err == nil {
+			mu.Lock()
+			received = append(received, batch...)
+			mu.Unlock()
+		}
+	}))
+	defer hook.Close()
+
+	cfg := &config.Config{Webhooks: []webhook.Config{{URL: hook.URL, BatchInterval: time.Hour}}}
+	storageInstance := storage.NewMemStorage()
+	storageManager := setupStorageManager(storageInstance, cfg)
+	broker := stream.NewBroker(0, "")
+	webhooks, err := setupWebhooks(cfg, broker)
+	require.NoError(t, err)
+	wsHub := server.NewWebSocketHub()
+	tracker := setupRates(cfg, storageInstance)
+	httpServer, err := setupHTTPServer(cfg, storageInstance, storageManager, broker, wsHub, nil, tracker, nil)
+	require.NoError(t, err)
+	listeners, err := setupListeners(cfg, storageInstance, broker)
+	require.NoError(t, err)
+	exporters, err := setupExporters(cfg, storageInstance)
+	require.NoError(t, err)
+
+	// Запрос обновления задерживается до начала остановки сервера
+	started, release := make(chan struct{}), make(chan struct{})
+	router := httpServer.Handler
+	httpServer.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		router.ServeHTTP(w, r)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go httpServer.Serve(ln)
+
+	requestDone := make(chan error, 1)
+	go func() {
+		resp, err := http.Post("http://"+ln.Addr().String()+"/update/gauge/InFlight/7", "text/plain", nil)
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestDone <- err
+	}()
+	<-started
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		performGracefulShutdown(&ServerComponents{
+			Storage:        storageInstance,
+			StorageManager: storageManager,
+			HTTPServer:     httpServer,
+			PProfServer:    setupPProfServer(),
+			Broker:         broker,
+			WebSocketHub:   wsHub,
+			Listeners:      listeners,
+			Exporters:      exporters,
+			Webhooks:       webhooks,
+			Rates:          tracker,
+		})
+		close(shutdownDone)
+	}()
+
+	// Остановка HTTP сервера началась, когда он перестал принимать соединения
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	}, 5*time.Second, 5*time.Millisecond)
+	close(release)
+
+	require.NoError(t, <-requestDone)
+	<-shutdownDone
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 1, "обновление, принятое во время остановки, должно дойти до webhook")
+	assert.Equal(t, "InFlight", received[0].ID)
+}
@@ -0,0 +1,35 @@
+package alerting
+
+import "time"
+
+// State состояние алерта
+type State string
+
+// Состояния алерта
+const (
+	// StatePending условие выполняется, но меньше длительности for правила
+	StatePending State = "pending"
+	// StateFiring условие выполняется дольше длительности for, отправлено уведомление
+	StateFiring State = "firing"
+	// StateResolved условие сработавшего алерта перестало выполняться
+	StateResolved State = "resolved"
+)
+
+// Alert алерт правила для одной серии метрики
+type Alert struct {
+	Rule      string  `json:"rule"`
+	Metric    string  `json:"metric"`
+	Type      string  `json:"type"`
+	State     State   `json:"state"`
+	Severity  string  `json:"severity"`
+	Value     float64 `json:"value"`
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+	Summary   string  `json:"summary,omitempty"`
+	// ActiveAt момент, с которого условие выполняется непрерывно
+	ActiveAt time.Time `json:"active_at"`
+	// FiredAt момент перехода в firing
+	FiredAt *time.Time `json:"fired_at,omitempty"`
+	// ResolvedAt момент восстановления
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
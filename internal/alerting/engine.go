@@ -0,0 +1,327 @@
+package alerting
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// DefaultInterval период проверки правил по умолчанию
+const DefaultInterval = 15 * time.Second
+
+const (
+	// notifyQueueSize количество изменений состояния, ожидающих отправки уведомлений
+	notifyQueueSize = 100
+	// notifyTimeout таймаут отправки уведомлений одним способом
+	notifyTimeout = 30 * time.Second
+)
+
+// alertKey идентифицирует алерт правила для серии
+type alertKey struct {
+	rule   string
+	metric string
+}
+
+// Engine периодически проверяет правила по хранилищу и отслеживает состояние алертов
+type Engine struct {
+	storage   storage.Storage
+	rules     []Rule
+	notifiers []Notifier
+	interval  time.Duration
+
+	mu     sync.RWMutex
+	alerts map[alertKey]*Alert
+
+	notify chan []Alert
+
+	// Поля для graceful shutdown
+	ctx       context.Context
+	cancel    context.CancelFunc
+	evalWG    sync.WaitGroup
+	notifyWG  sync.WaitGroup
+	runMu     sync.Mutex
+	started   bool
+	stopped   bool
+	now       func() time.Time
+	notifyCtx context.Context
+	abort     context.CancelFunc
+}
+
+// NewEngine создаёт движок правил. interval 0 - DefaultInterval.
+func NewEngine(s storage.Storage, rules []Rule, notifiers []Notifier, interval time.Duration) *Engine {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	e := &Engine{
+		storage:   s,
+		rules:     rules,
+		notifiers: notifiers,
+		interval:  interval,
+		alerts:    make(map[alertKey]*Alert),
+		notify:    make(chan []Alert, notifyQueueSize),
+		now:       time.Now,
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.notifyCtx, e.abort = context.WithCancel(context.Background())
+	return e
+}
+
+// NewEngineFromFile создаёт движок по файлу правил
+func NewEngineFromFile(s storage.Storage, path string, interval time.Duration) (*Engine, error) {
+	file, err := LoadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	notifiers := make([]Notifier, 0, len(file.Notifiers))
+	for _, cfg := range file.Notifiers {
+		n, err := NewNotifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if len(notifiers) == 0 {
+		notifiers = append(notifiers, LogNotifier{})
+	}
+	return NewEngine(s, file.Rules, notifiers, interval), nil
+}
+
+// Start запускает периодическую проверку правил
+func (e *Engine) Start() {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	if e.started || e.stopped {
+		return
+	}
+	e.started = true
+	log.Printf("Проверка %d правил алертов каждые %v", len(e.rules), e.interval)
+
+	e.evalWG.Add(1)
+	go func() {
+		defer e.evalWG.Done()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-ticker.C:
+				e.Evaluate()
+			}
+		}
+	}()
+
+	e.notifyWG.Add(1)
+	go func() {
+		defer e.notifyWG.Done()
+		for alerts := range e.notify {
+			e.send(alerts)
+		}
+	}()
+}
+
+// Stop останавливает проверку правил и ожидает отправки уведомлений до истечения ctx
+func (e *Engine) Stop(ctx context.Context) {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	if !e.started || e.stopped {
+		e.stopped = true
+		return
+	}
+	e.stopped = true
+
+	e.cancel()
+	e.evalWG.Wait()
+	close(e.notify)
+
+	done := make(chan struct{})
+	go func() {
+		e.notifyWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Уведомления об алертах не отправлены до остановки: %v", ctx.Err())
+		e.abort()
+		<-done
+	}
+	e.abort()
+}
+
+// Evaluate проверяет все правила по текущим значениям метрик и ставит
+// изменения состояния firing и resolved в очередь уведомлений
+func (e *Engine) Evaluate() {
+	now := e.now()
+	var changed []Alert
+
+	for _, rule := range e.rules {
+		metrics, err := e.selectMetrics(rule)
+		if err != nil {
+			// Без данных состояние алертов правила не меняется
+			log.Printf("Правило %s: ошибка чтения метрик: %v", rule.Name, err)
+			continue
+		}
+		changed = append(changed, e.evaluateRule(rule, metrics, now)...)
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+	select {
+	case e.notify <- changed:
+	default:
+		log.Printf("Очередь уведомлений об алертах переполнена, отброшено изменений: %d", len(changed))
+	}
+}
+
+// evaluateRule обновляет состояние алертов правила и возвращает алерты, сменившие состояние
+// на firing или resolved
+func (e *Engine) evaluateRule(rule Rule, metrics []models.Metrics, now time.Time) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changed []Alert
+	seen := make(map[string]bool, len(metrics))
+
+	for _, m := range metrics {
+		value := m.FloatValue()
+		key := alertKey{rule: rule.Name, metric: m.ID}
+		seen[m.ID] = true
+
+		alert, active := e.alerts[key]
+		if !rule.Match(value) {
+			if active {
+				if r, ok := e.resolve(key, alert, value, now); ok {
+					changed = append(changed, r)
+				}
+			}
+			continue
+		}
+
+		if !active {
+			alert = &Alert{
+				Rule:      rule.Name,
+				Metric:    m.ID,
+				Type:      m.MType,
+				State:     StatePending,
+				Severity:  rule.Severity,
+				Op:        rule.Op,
+				Threshold: rule.Threshold,
+				Summary:   rule.Summary,
+				ActiveAt:  now,
+			}
+			e.alerts[key] = alert
+		}
+		alert.Value = value
+		if alert.State == StatePending && now.Sub(alert.ActiveAt) >= rule.For {
+			firedAt := now
+			alert.State = StateFiring
+			alert.FiredAt = &firedAt
+			changed = append(changed, *alert)
+		}
+	}
+
+	// Серия пропала из хранилища (удалена или устарела) - алерт восстановлен
+	for key, alert := range e.alerts {
+		if key.rule == rule.Name && !seen[key.metric] {
+			if r, ok := e.resolve(key, alert, alert.Value, now); ok {
+				changed = append(changed, r)
+			}
+		}
+	}
+	return changed
+}
+
+// resolve удаляет алерт. Для сработавшего алерта возвращает его в состоянии resolved,
+// алерт в состоянии pending удаляется без уведомления.
+func (e *Engine) resolve(key alertKey, alert *Alert, value float64, now time.Time) (Alert, bool) {
+	delete(e.alerts, key)
+	if alert.State != StateFiring {
+		return Alert{}, false
+	}
+	resolvedAt := now
+	resolved := *alert
+	resolved.State = StateResolved
+	resolved.Value = value
+	resolved.ResolvedAt = &resolvedAt
+	return resolved, true
+}
+
+// selectMetrics возвращает серии, отобранные селектором правила
+func (e *Engine) selectMetrics(rule Rule) ([]models.Metrics, error) {
+	query := rule.Selector.query()
+	query.Limit = storage.MaxListLimit
+
+	var metrics []models.Metrics
+	for {
+		page, err := e.storage.ListMetrics(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range page.Metrics {
+			if rule.Selector.matchName(m.ID) {
+				metrics = append(metrics, m)
+			}
+		}
+		if page.NextCursor == "" {
+			return metrics, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// send отправляет уведомления всеми способами, ошибки пишутся в лог
+func (e *Engine) send(alerts []Alert) {
+	for _, n := range e.notifiers {
+		ctx, cancel := context.WithTimeout(e.notifyCtx, notifyTimeout)
+		if err := n.Notify(ctx, alerts); err != nil {
+			log.Printf("Ошибка отправки уведомления об алертах (%T): %v", n, err)
+		}
+		cancel()
+	}
+}
+
+// Alerts возвращает активные алерты (pending и firing): сначала firing,
+// затем по важности, правилу и метрике
+func (e *Engine) Alerts() []Alert {
+	e.mu.RLock()
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		alerts = append(alerts, *a)
+	}
+	e.mu.RUnlock()
+
+	sort.Slice(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if a.State != b.State {
+			return a.State == StateFiring
+		}
+		if severityRank(a.Severity) != severityRank(b.Severity) {
+			return severityRank(a.Severity) > severityRank(b.Severity)
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Metric < b.Metric
+	})
+	return alerts
+}
+
+// severityRank возвращает порядок уровня важности для сортировки
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,184 @@
+package alerting
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// recordingNotifier запоминает отправленные уведомления
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, alerts []Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alerts...)
+	return nil
+}
+
+func (n *recordingNotifier) received() []Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Alert(nil), n.alerts...)
+}
+
+// testEngine создаёт движок с управляемым временем
+func testEngine(s storage.Storage, rules ...Rule) (*Engine, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	e := NewEngine(s, rules, nil, time.Hour)
+	e.now = func() time.Time { return now }
+	return e, &now
+}
+
+// takeChanges возвращает изменения состояния из очереди уведомлений
+func takeChanges(e *Engine) []Alert {
+	select {
+	case alerts := <-e.notify:
+		return alerts
+	default:
+		return nil
+	}
+}
+
+func TestEngine_StateTransitions(t *testing.T) {
+	s := storage.NewMemStorage()
+	rule := Rule{Name: "HighHeap", Selector: Selector{Name: "HeapAlloc"}, Op: OpGreater, Threshold: 100,
+		For: time.Minute, Severity: SeverityCritical}
+	e, now := testEngine(s, rule)
+
+	s.UpdateGauge("HeapAlloc", 150)
+	e.Evaluate()
+	alerts := e.Alerts()
+	if len(alerts) != 1 || alerts[0].State != StatePending || alerts[0].Value != 150 {
+		t.Fatalf("Ожидался pending алерт, получено %+v", alerts)
+	}
+	if changes := takeChanges(e); changes != nil {
+		t.Errorf("Переход в pending не должен уведомлять: %+v", changes)
+	}
+
+	*now = now.Add(time.Minute)
+	s.UpdateGauge("HeapAlloc", 170)
+	e.Evaluate()
+	changes := takeChanges(e)
+	if len(changes) != 1 || changes[0].State != StateFiring || changes[0].FiredAt == nil || changes[0].Value != 170 {
+		t.Fatalf("Ожидался переход в firing, получено %+v", changes)
+	}
+
+	*now = now.Add(time.Minute)
+	e.Evaluate()
+	if changes := takeChanges(e); changes != nil {
+		t.Errorf("Повторная проверка firing алерта не должна уведомлять: %+v", changes)
+	}
+
+	s.UpdateGauge("HeapAlloc", 50)
+	e.Evaluate()
+	changes = takeChanges(e)
+	if len(changes) != 1 || changes[0].State != StateResolved || changes[0].ResolvedAt == nil || changes[0].Value != 50 {
+		t.Fatalf("Ожидался переход в resolved, получено %+v", changes)
+	}
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Errorf("Восстановленный алерт не должен быть активным: %+v", alerts)
+	}
+}
+
+func TestEngine_PendingResetsSilently(t *testing.T) {
+	s := storage.NewMemStorage()
+	e, now := testEngine(s, Rule{Name: "r", Op: OpGreater, Threshold: 1, For: time.Minute, Severity: SeverityWarning})
+
+	s.UpdateGauge("Load", 2)
+	e.Evaluate()
+	s.UpdateGauge("Load", 0)
+	*now = now.Add(30 * time.Second)
+	e.Evaluate()
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Fatalf("Pending алерт должен сбрасываться, получено %+v", alerts)
+	}
+
+	// Отсчёт for начинается заново
+	s.UpdateGauge("Load", 2)
+	e.Evaluate()
+	*now = now.Add(59 * time.Second)
+	e.Evaluate()
+	if changes := takeChanges(e); changes != nil {
+		t.Errorf("Алерт не должен срабатывать раньше for: %+v", changes)
+	}
+}
+
+func TestEngine_PerSeriesAndCounters(t *testing.T) {
+	s := storage.NewMemStorage()
+	e, _ := testEngine(s, Rule{Name: "errors", Selector: Selector{Types: []string{"counter"}, Labels: map[string]string{"env": "prod"}},
+		Op: OpGreaterEqual, Threshold: 10, Severity: SeverityWarning})
+
+	s.UpdateCounter(`errors{env="prod",host="a"}`, 12)
+	s.UpdateCounter(`errors{env="prod",host="b"}`, 3)
+	s.UpdateCounter(`errors{env="dev",host="c"}`, 50)
+	s.UpdateGauge(`errors{env="prod",host="d"}`, 50)
+	e.Evaluate()
+
+	changes := takeChanges(e)
+	if len(changes) != 1 || changes[0].Metric != `errors{env="prod",host="a"}` || changes[0].Type != "counter" {
+		t.Fatalf("Ожидался один firing алерт для host=a, получено %+v", changes)
+	}
+}
+
+func TestEngine_SeriesRemovedResolves(t *testing.T) {
+	s := storage.NewMemStorage()
+	e, _ := testEngine(s, Rule{Name: "r", Op: OpGreater, Threshold: 1, Severity: SeverityInfo})
+
+	s.UpdateGauge("Load", 5)
+	e.Evaluate()
+	takeChanges(e)
+
+	if err := s.DeleteMetric("gauge", "Load"); err != nil {
+		t.Fatal(err)
+	}
+	e.Evaluate()
+	changes := takeChanges(e)
+	if len(changes) != 1 || changes[0].State != StateResolved || changes[0].Value != 5 {
+		t.Fatalf("Удалённая серия должна восстанавливать алерт, получено %+v", changes)
+	}
+}
+
+func TestEngine_AlertsOrder(t *testing.T) {
+	s := storage.NewMemStorage()
+	e, _ := testEngine(s,
+		Rule{Name: "b_warning", Op: OpGreater, Threshold: 0, Severity: SeverityWarning},
+		Rule{Name: "a_critical", Op: OpGreater, Threshold: 0, Severity: SeverityCritical},
+		Rule{Name: "pending", Op: OpGreater, Threshold: 0, For: time.Hour, Severity: SeverityCritical},
+	)
+	s.UpdateGauge("Load", 1)
+	e.Evaluate()
+
+	alerts := e.Alerts()
+	if len(alerts) != 3 || alerts[0].Rule != "a_critical" || alerts[1].Rule != "b_warning" || alerts[2].Rule != "pending" {
+		t.Errorf("Неожиданный порядок алертов: %+v", alerts)
+	}
+}
+
+func TestEngine_StartStopNotifies(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Load", 5)
+	notifier := &recordingNotifier{}
+	e := NewEngine(s, []Rule{{Name: "r", Op: OpGreater, Threshold: 1, Severity: SeverityWarning}},
+		[]Notifier{notifier}, 10*time.Millisecond)
+	e.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(notifier.received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Уведомление не отправлено")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	e.Stop(context.Background())
+
+	if got := notifier.received(); got[0].State != StateFiring || got[0].Metric != "Load" {
+		t.Errorf("Неожиданное уведомление: %+v", got)
+	}
+}
@@ -0,0 +1,236 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// Типы уведомлений
+const (
+	NotifierLog     = "log"
+	NotifierWebhook = "webhook"
+	NotifierSMTP    = "smtp"
+)
+
+// HashHeader заголовок с подписью тела webhook уведомления, как у агента
+const HashHeader = "HashSHA256"
+
+// Notifier отправляет уведомления об изменении состояния алертов
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// NotifierConfig настройки уведомлений в файле правил
+type NotifierConfig struct {
+	// Type тип уведомлений: log, webhook или smtp
+	Type string `json:"type"`
+
+	// URL адрес webhook
+	URL string `json:"url,omitempty"`
+	// Secret ключ подписи тела webhook utils.CalculateHash, пустой - без подписи
+	Secret string `json:"secret,omitempty"`
+
+	// Address адрес SMTP сервера host:port
+	Address string `json:"address,omitempty"`
+	// From адрес отправителя письма
+	From string `json:"from,omitempty"`
+	// To адреса получателей
+	To []string `json:"to,omitempty"`
+	// Username и Password учётные данные SMTP, пустой Username - без авторизации
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Validate проверяет настройки уведомлений
+func (c NotifierConfig) Validate() error {
+	switch c.Type {
+	case NotifierLog:
+	case NotifierWebhook:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("уведомления webhook: некорректный URL %q", c.URL)
+		}
+	case NotifierSMTP:
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return fmt.Errorf("уведомления smtp: некорректный адрес %q: %w", c.Address, err)
+		}
+		if c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("уведомления smtp: не заданы отправитель или получатели")
+		}
+	default:
+		return fmt.Errorf("неизвестный тип уведомлений %q, ожидается %s, %s или %s",
+			c.Type, NotifierLog, NotifierWebhook, NotifierSMTP)
+	}
+	return nil
+}
+
+// NewNotifier создаёт уведомления по проверенным настройкам
+func NewNotifier(cfg NotifierConfig) (Notifier, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Type {
+	case NotifierWebhook:
+		return &WebhookNotifier{URL: cfg.URL, Secret: cfg.Secret, client: &http.Client{}}, nil
+	case NotifierSMTP:
+		return &SMTPNotifier{Address: cfg.Address, From: cfg.From, To: cfg.To,
+			Username: cfg.Username, Password: cfg.Password}, nil
+	}
+	return LogNotifier{}, nil
+}
+
+// describe возвращает строку с описанием алерта
+func describe(a Alert) string {
+	s := fmt.Sprintf("[%s] %s %s: %s = %g (%s %g)", a.Severity, strings.ToUpper(string(a.State)),
+		a.Rule, a.Metric, a.Value, a.Op, a.Threshold)
+	if a.Summary != "" {
+		s += " - " + a.Summary
+	}
+	return s
+}
+
+// LogNotifier пишет уведомления в лог сервера
+type LogNotifier struct{}
+
+// Notify пишет каждый алерт отдельной строкой
+func (LogNotifier) Notify(_ context.Context, alerts []Alert) error {
+	for _, a := range alerts {
+		log.Printf("Алерт %s", describe(a))
+	}
+	return nil
+}
+
+// WebhookNotifier отправляет уведомления POST запросом {"alerts": [...]}
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	client *http.Client
+}
+
+// Notify отправляет алерты с подписью и повторами
+func (n *WebhookNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(struct {
+		Alerts []Alert `json:"alerts"`
+	}{alerts})
+	if err != nil {
+		return err
+	}
+
+	return utils.Retry(ctx, utils.DefaultBackoffRetryConfig(), func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+		if err != nil {
+			return utils.NewPermanentError(fmt.Errorf("failed to create request: %w", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if n.Secret != "" {
+			req.Header.Set(HashHeader, utils.CalculateHash(body, n.Secret))
+		}
+
+		resp, err := n.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		return utils.CheckHTTPResponse(resp)
+	})
+}
+
+// SMTPNotifier отправляет уведомления письмом
+type SMTPNotifier struct {
+	Address  string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+// Notify отправляет одно письмо со всеми алертами. STARTTLS используется,
+// если сервер его поддерживает, авторизация PLAIN - если задан Username.
+func (n *SMTPNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.Address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	host, _, _ := net.SplitHostPort(n.Address)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(nil); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(alerts)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message формирует письмо в формате RFC 5322
+func (n *SMTPNotifier) message(alerts []Alert) []byte {
+	subject := fmt.Sprintf("Алерты метрик: %d", len(alerts))
+	if len(alerts) == 1 {
+		subject = fmt.Sprintf("Алерт %s: %s", alerts[0].Rule, alerts[0].State)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	for _, a := range alerts {
+		b.WriteString(describe(a))
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,166 @@
+package alerting
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+var testAlert = Alert{
+	Rule: "HighHeap", Metric: "HeapAlloc", Type: "gauge", State: StateFiring, Severity: SeverityCritical,
+	Value: 150, Op: OpGreater, Threshold: 100, Summary: "Много памяти",
+}
+
+// smtpStub минимальный SMTP сервер, принимающий одно письмо
+type smtpStub struct {
+	ln   net.Listener
+	mail chan smtpMail
+}
+
+type smtpMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{ln: ln, mail: make(chan smtpMail, 1)}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpStub) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	reply("220 stub ESMTP")
+
+	var mail smtpMail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-stub")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			// Параметры после адреса, например BODY=8BITMIME, не нужны
+			addr, _, _ := strings.Cut(strings.TrimPrefix(cmd, "MAIL FROM:"), " ")
+			mail.from = strings.Trim(addr, "<>")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			mail.to = append(mail.to, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			mail.data = data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			s.mail <- mail
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	stub := newSMTPStub(t)
+	n, err := NewNotifier(NotifierConfig{Type: NotifierSMTP, Address: stub.ln.Addr().String(),
+		From: "metrics@example.com", To: []string{"ops@example.com", "dev@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, []Alert{testAlert}); err != nil {
+		t.Fatalf("Ошибка отправки письма: %v", err)
+	}
+
+	mail := <-stub.mail
+	if mail.from != "metrics@example.com" || len(mail.to) != 2 || mail.to[1] != "dev@example.com" {
+		t.Errorf("Неверный конверт письма: %+v", mail)
+	}
+	if !strings.Contains(mail.data, "Subject: =?utf-8?q?") ||
+		!strings.Contains(mail.data, "[critical] FIRING HighHeap: HeapAlloc = 150 (> 100) - Много памяти") {
+		t.Errorf("Неверное письмо:\n%s", mail.data)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var body []byte
+	var hash string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		hash = r.Header.Get(HashHeader)
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(NotifierConfig{Type: NotifierWebhook, URL: srv.URL, Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), []Alert{testAlert}); err != nil {
+		t.Fatalf("Ошибка отправки webhook: %v", err)
+	}
+
+	if !utils.VerifyHash(body, "secret", hash) {
+		t.Errorf("Неверная подпись %q", hash)
+	}
+	var payload struct {
+		Alerts []Alert `json:"alerts"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || len(payload.Alerts) != 1 || payload.Alerts[0].Rule != "HighHeap" {
+		t.Errorf("Неверное тело webhook: %s", body)
+	}
+}
+
+func TestWebhookNotifier_PermanentError(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n, _ := NewNotifier(NotifierConfig{Type: NotifierWebhook, URL: srv.URL})
+	if err := n.Notify(context.Background(), []Alert{testAlert}); err == nil || requests != 1 {
+		t.Errorf("Ошибка 4xx не должна повторяться: err=%v, запросов %d", err, requests)
+	}
+}
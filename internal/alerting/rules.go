@@ -0,0 +1,201 @@
+// Package alerting периодически проверяет значения метрик по правилам с порогами
+// и отправляет уведомления о срабатывании и восстановлении.
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// Операции сравнения значения метрики с порогом
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+)
+
+// Уровни важности алертов
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Selector отбирает серии метрик, к которым применяется правило.
+// Пустые поля не ограничивают выборку.
+type Selector struct {
+	// Name точное имя метрики без меток
+	Name string `json:"name,omitempty"`
+	// Types типы метрик: gauge, counter
+	Types []string `json:"types,omitempty"`
+	// Prefix префикс идентификатора серии
+	Prefix string `json:"prefix,omitempty"`
+	// Regex регулярное выражение для идентификатора серии
+	Regex string `json:"regex,omitempty"`
+	// Labels метки, которые должны быть у серии с точно такими значениями
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// query возвращает запрос списка метрик для селектора без пагинации
+func (s Selector) query() storage.MetricsQuery {
+	return storage.MetricsQuery{
+		Types:      s.Types,
+		NamePrefix: s.Prefix,
+		NameRegex:  s.Regex,
+		Labels:     s.Labels,
+	}
+}
+
+// matchName проверяет точное имя метрики, если оно задано
+func (s Selector) matchName(id string) bool {
+	if s.Name == "" {
+		return true
+	}
+	name, _ := models.ParseSeriesID(id)
+	return name == s.Name
+}
+
+// Rule правило алерта: алерт срабатывает для серии, значение которой удовлетворяет
+// сравнению с порогом непрерывно в течение For
+type Rule struct {
+	Name      string
+	Selector  Selector
+	Op        string
+	Threshold float64
+	For       time.Duration
+	Severity  string
+	// Summary описание алерта для уведомлений
+	Summary string
+}
+
+// Match сравнивает значение с порогом
+func (r Rule) Match(value float64) bool {
+	switch r.Op {
+	case OpGreater:
+		return value > r.Threshold
+	case OpGreaterEqual:
+		return value >= r.Threshold
+	case OpLess:
+		return value < r.Threshold
+	case OpLessEqual:
+		return value <= r.Threshold
+	case OpEqual:
+		return value == r.Threshold
+	case OpNotEqual:
+		return value != r.Threshold
+	}
+	return false
+}
+
+// Validate проверяет правило
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("у правила не задано имя")
+	}
+	switch r.Op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+	default:
+		return fmt.Errorf("правило %s: неизвестная операция сравнения %q", r.Name, r.Op)
+	}
+	switch r.Severity {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("правило %s: неизвестный уровень важности %q, ожидается %s, %s или %s",
+			r.Name, r.Severity, SeverityInfo, SeverityWarning, SeverityCritical)
+	}
+	if r.For < 0 {
+		return fmt.Errorf("правило %s: длительность for не может быть отрицательной", r.Name)
+	}
+	query := r.Selector.query()
+	if err := query.Normalize(); err != nil {
+		return fmt.Errorf("правило %s: некорректный селектор: %w", r.Name, err)
+	}
+	return nil
+}
+
+// RulesFile содержимое файла правил
+type RulesFile struct {
+	Rules     []Rule
+	Notifiers []NotifierConfig
+}
+
+// ruleJSON правило в файле, длительность for задаётся строкой time.ParseDuration
+type ruleJSON struct {
+	Name      string   `json:"name"`
+	Selector  Selector `json:"selector"`
+	Op        string   `json:"op"`
+	Threshold float64  `json:"threshold"`
+	For       string   `json:"for,omitempty"`
+	Severity  string   `json:"severity,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+}
+
+// rulesFileJSON формат файла правил
+type rulesFileJSON struct {
+	Rules     []ruleJSON       `json:"rules"`
+	Notifiers []NotifierConfig `json:"notifiers,omitempty"`
+}
+
+// LoadRules читает и проверяет файл правил в формате JSON
+func LoadRules(path string) (*RulesFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл правил %s: %w", path, err)
+	}
+	return ParseRules(data)
+}
+
+// ParseRules разбирает и проверяет правила и настройки уведомлений.
+// Уровень важности по умолчанию - warning.
+func ParseRules(data []byte) (*RulesFile, error) {
+	var raw rulesFileJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать файл правил: %w", err)
+	}
+
+	file := &RulesFile{Notifiers: raw.Notifiers}
+	names := make(map[string]bool)
+	for _, r := range raw.Rules {
+		rule := Rule{
+			Name:      r.Name,
+			Selector:  r.Selector,
+			Op:        r.Op,
+			Threshold: r.Threshold,
+			Severity:  r.Severity,
+			Summary:   r.Summary,
+		}
+		if rule.Severity == "" {
+			rule.Severity = SeverityWarning
+		}
+		if r.For != "" {
+			d, err := time.ParseDuration(r.For)
+			if err != nil {
+				return nil, fmt.Errorf("правило %s: некорректная длительность for: %w", r.Name, err)
+			}
+			rule.For = d
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("правило %s задано несколько раз", rule.Name)
+		}
+		names[rule.Name] = true
+		file.Rules = append(file.Rules, rule)
+	}
+
+	for _, n := range file.Notifiers {
+		if err := n.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
@@ -0,0 +1,100 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRule_Match(t *testing.T) {
+	tests := []struct {
+		op    string
+		value float64
+		want  bool
+	}{
+		{OpGreater, 11, true},
+		{OpGreater, 10, false},
+		{OpGreaterEqual, 10, true},
+		{OpLess, 9, true},
+		{OpLess, 10, false},
+		{OpLessEqual, 10, true},
+		{OpEqual, 10, true},
+		{OpNotEqual, 10, false},
+		{"~", 10, false},
+	}
+	for _, tt := range tests {
+		rule := Rule{Op: tt.op, Threshold: 10}
+		if got := rule.Match(tt.value); got != tt.want {
+			t.Errorf("%v %s 10: ожидалось %v, получено %v", tt.value, tt.op, tt.want, got)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	data := `{
+		"rules": [
+			{"name": "HighHeap", "selector": {"name": "HeapAlloc", "types": ["gauge"]}, "op": ">", "threshold": 1e9,
+			 "for": "5m", "severity": "critical", "summary": "Много памяти"},
+			{"name": "NoPolls", "selector": {"prefix": "PollCount", "labels": {"env": "prod"}}, "op": "==", "threshold": 0}
+		],
+		"notifiers": [{"type": "log"}, {"type": "webhook", "url": "http://hooks/alerts", "secret": "s"}]
+	}`
+	file, err := ParseRules([]byte(data))
+	if err != nil {
+		t.Fatalf("Ошибка разбора правил: %v", err)
+	}
+	if len(file.Rules) != 2 || len(file.Notifiers) != 2 {
+		t.Fatalf("Ожидалось 2 правила и 2 способа уведомления, получено %d и %d", len(file.Rules), len(file.Notifiers))
+	}
+	heap := file.Rules[0]
+	if heap.For != 5*time.Minute || heap.Severity != SeverityCritical || heap.Selector.Name != "HeapAlloc" {
+		t.Errorf("Неверное правило: %+v", heap)
+	}
+	if file.Rules[1].Severity != SeverityWarning || file.Rules[1].For != 0 {
+		t.Errorf("Ожидались значения по умолчанию, получено %+v", file.Rules[1])
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"некорректный JSON":       `{"rules": [`,
+		"без имени":               `{"rules": [{"op": ">", "threshold": 1}]}`,
+		"неизвестная операция":    `{"rules": [{"name": "a", "op": "=>", "threshold": 1}]}`,
+		"неизвестная важность":    `{"rules": [{"name": "a", "op": ">", "severity": "fatal"}]}`,
+		"некорректный for":        `{"rules": [{"name": "a", "op": ">", "for": "soon"}]}`,
+		"некорректный селектор":   `{"rules": [{"name": "a", "op": ">", "selector": {"regex": "("}}]}`,
+		"повтор имени":            `{"rules": [{"name": "a", "op": ">"}, {"name": "a", "op": "<"}]}`,
+		"неизвестные уведомления": `{"rules": [], "notifiers": [{"type": "sms"}]}`,
+		"smtp без получателей":    `{"rules": [], "notifiers": [{"type": "smtp", "address": "mail:25", "from": "a@b"}]}`,
+	}
+	for name, data := range tests {
+		if _, err := ParseRules([]byte(data)); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`{"rules": [{"name": "a", "op": ">", "threshold": 1}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := LoadRules(path)
+	if err != nil || len(file.Rules) != 1 {
+		t.Fatalf("Ошибка загрузки правил: %v", err)
+	}
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Ожидалась ошибка для отсутствующего файла")
+	}
+}
+
+func TestLoadRules_Example(t *testing.T) {
+	file, err := LoadRules(filepath.Join("..", "..", "configs", "rules_example.json"))
+	if err != nil {
+		t.Fatalf("Пример правил не загружается: %v", err)
+	}
+	if len(file.Rules) == 0 || len(file.Notifiers) == 0 {
+		t.Errorf("Пример должен содержать правила и уведомления: %+v", file)
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -14,14 +14,16 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
-	"runtime"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/ViktorBystrov72/go-metrics/internal/crypto"
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
 	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/statsd"
 	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+	"github.com/ViktorBystrov72/go-metrics/internal/wsproto"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/mem"
 )
@@ -126,6 +128,15 @@ type MetricsCollector struct {
 	wg           sync.WaitGroup
 	pollInterval time.Duration
 	key          string
+	scraper      *PrometheusScraper
+
+	// Чтение runtime метрик без остановки мира
+	runtimeReader *RuntimeMetricsReader
+
+	// Приём метрик StatsD, агрегированных за интервал отправки
+	reportInterval time.Duration
+	statsdListener *statsd.Listener
+	statsdAgg      *statsd.Aggregator
 
 	// Поля для graceful shutdown
 	ctx    context.Context
@@ -135,13 +146,26 @@ type MetricsCollector struct {
 // NewMetricsCollector создаёт новый Collector с учётом конфига
 func NewMetricsCollector(cfg *AgentConfig) Collector {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &MetricsCollector{
-		metricsChan:  make(chan []models.Metrics, 100),
-		pollInterval: time.Duration(cfg.PollInterval) * time.Second,
-		key:          cfg.Key,
-		ctx:          ctx,
-		cancel:       cancel,
+	mc := &MetricsCollector{
+		metricsChan:   make(chan []models.Metrics, 100),
+		pollInterval:  time.Duration(cfg.PollInterval) * time.Second,
+		key:           cfg.Key,
+		runtimeReader: NewRuntimeMetricsReader(cfg.RuntimeMetrics),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
+	if len(cfg.ScrapeTargets) > 0 {
+		mc.scraper = NewPrometheusScraper(cfg.ScrapeTargets, cfg.Key)
+	}
+	if cfg.StatsDAddress != "" {
+		mc.reportInterval = time.Duration(cfg.ReportInterval) * time.Second
+		if mc.reportInterval <= 0 {
+			mc.reportInterval = mc.pollInterval
+		}
+		mc.statsdAgg = statsd.NewAggregator()
+		mc.statsdListener = statsd.NewListener(cfg.StatsDAddress, mc.statsdAgg.Add)
+	}
+	return mc
 }
 
 // Start запускает сбор метрик
@@ -151,6 +175,20 @@ func (mc *MetricsCollector) Start(ctx context.Context) {
 
 	mc.wg.Add(1)
 	go mc.collectSystemMetrics(mc.ctx)
+
+	if mc.scraper != nil {
+		mc.wg.Add(1)
+		go mc.collectScrapeMetrics(mc.ctx)
+	}
+
+	if mc.statsdListener != nil {
+		if err := mc.statsdListener.Start(); err != nil {
+			log.Printf("Ошибка запуска StatsD listener: %v", err)
+		} else {
+			mc.wg.Add(1)
+			go mc.collectStatsDMetrics(mc.ctx)
+		}
+	}
 }
 
 // Stop останавливает сбор метрик
@@ -231,41 +269,83 @@ func (mc *MetricsCollector) collectSystemMetrics(ctx context.Context) {
 	}
 }
 
-// CollectRuntimeMetricsData собирает runtime метрики
+// collectScrapeMetrics периодически опрашивает Prometheus цели
+func (mc *MetricsCollector) collectScrapeMetrics(ctx context.Context) {
+	defer mc.wg.Done()
+
+	ticker := time.NewTicker(mc.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			metrics := mc.scraper.Scrape(ctx)
+			if len(metrics) == 0 {
+				continue
+			}
+
+			select {
+			case mc.metricsChan <- metrics:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// collectStatsDMetrics передаёт агрегированные метрики StatsD раз в интервал отправки
+func (mc *MetricsCollector) collectStatsDMetrics(ctx context.Context) {
+	defer mc.wg.Done()
+
+	ticker := time.NewTicker(mc.reportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := mc.statsdListener.Close(); err != nil {
+				log.Printf("Ошибка остановки StatsD listener: %v", err)
+			}
+			// Отдаём накопленное за неполный интервал, если в канале есть место
+			if metrics := mc.statsdMetrics(); len(metrics) > 0 {
+				select {
+				case mc.metricsChan <- metrics:
+				default:
+					log.Printf("Не удалось передать %d метрик StatsD при остановке", len(metrics))
+				}
+			}
+			return
+		case <-ticker.C:
+			metrics := mc.statsdMetrics()
+			if len(metrics) == 0 {
+				continue
+			}
+
+			select {
+			case mc.metricsChan <- metrics:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// statsdMetrics забирает агрегированные метрики StatsD и подписывает их ключом
+func (mc *MetricsCollector) statsdMetrics() []models.Metrics {
+	aggregated := mc.statsdAgg.Flush()
+	metrics := make([]models.Metrics, 0, len(aggregated))
+	for _, m := range aggregated {
+		metrics = append(metrics, NewMetric(m.ID, m.MType, m.Value, m.Delta, mc.key))
+	}
+	return metrics
+}
+
+// CollectRuntimeMetricsData собирает runtime метрики через runtime/metrics без остановки мира
 func (mc *MetricsCollector) CollectRuntimeMetricsData() []models.Metrics {
 	var metrics []models.Metrics
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-
-	gaugeMetrics := map[string]float64{
-		"Alloc":         float64(m.Alloc),
-		"BuckHashSys":   float64(m.BuckHashSys),
-		"Frees":         float64(m.Frees),
-		"GCCPUFraction": m.GCCPUFraction,
-		"GCSys":         float64(m.GCSys),
-		"HeapAlloc":     float64(m.HeapAlloc),
-		"HeapIdle":      float64(m.HeapIdle),
-		"HeapInuse":     float64(m.HeapInuse),
-		"HeapObjects":   float64(m.HeapObjects),
-		"HeapReleased":  float64(m.HeapReleased),
-		"HeapSys":       float64(m.HeapSys),
-		"LastGC":        float64(m.LastGC),
-		"Lookups":       float64(m.Lookups),
-		"MCacheInuse":   float64(m.MCacheInuse),
-		"MCacheSys":     float64(m.MCacheSys),
-		"MSpanInuse":    float64(m.MSpanInuse),
-		"MSpanSys":      float64(m.MSpanSys),
-		"Mallocs":       float64(m.Mallocs),
-		"NextGC":        float64(m.NextGC),
-		"NumForcedGC":   float64(m.NumForcedGC),
-		"NumGC":         float64(m.NumGC),
-		"OtherSys":      float64(m.OtherSys),
-		"PauseTotalNs":  float64(m.PauseTotalNs),
-		"StackInuse":    float64(m.StackInuse),
-		"StackSys":      float64(m.StackSys),
-		"Sys":           float64(m.Sys),
-		"TotalAlloc":    float64(m.TotalAlloc),
-	}
+	gaugeMetrics := mc.runtimeReader.Read()
 
 	for name, value := range gaugeMetrics {
 		v := value
@@ -367,9 +447,10 @@ func (wp *WorkerPool) Stop() {
 type MetricsSender struct {
 	metricsChan chan []models.Metrics
 	pool        Pool
-	address     string
+	endpoints   []*endpoint
+	mode        string
 	key         string
-	publicKey   *rsa.PublicKey
+	retryConfig utils.RetryConfig
 
 	// Поля для graceful shutdown
 	ctx    context.Context
@@ -378,27 +459,28 @@ type MetricsSender struct {
 
 // NewMetricsSender создаёт новый Sender с учётом конфига
 func NewMetricsSender(cfg *AgentConfig) Sender {
-	var publicKey *rsa.PublicKey
-
-	// Загружаем публичный ключ, если путь указан
-	if cfg.CryptoKey != "" {
-		var err error
-		publicKey, err = crypto.LoadPublicKey(cfg.CryptoKey)
-		if err != nil {
-			log.Printf("Ошибка загрузки публичного ключа: %v", err)
-			// Продолжаем работу без шифрования
-		} else {
-			log.Printf("Публичный ключ загружен из: %s", cfg.CryptoKey)
+	var endpoints []*endpoint
+	for _, epCfg := range buildEndpointConfigs(cfg) {
+		ep := newEndpoint(epCfg)
+		if cfg.Transport == TransportWebSocket {
+			ep.ws = newWSClient(ep.address, ep.agentToken)
 		}
+		endpoints = append(endpoints, ep)
+	}
+
+	mode := cfg.SendMode
+	if mode == "" {
+		mode = SendModeFailover
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	return &MetricsSender{
 		metricsChan: make(chan []models.Metrics, 100),
 		pool:        NewWorkerPool(cfg.RateLimit),
-		address:     fmt.Sprintf("http://%s", cfg.Address),
+		endpoints:   endpoints,
+		mode:        mode,
 		key:         cfg.Key,
-		publicKey:   publicKey,
+		retryConfig: utils.DefaultBackoffRetryConfig(),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
@@ -432,6 +514,12 @@ func (ms *MetricsSender) Stop() {
 		log.Printf("MetricsSender.Stop() timeout exceeded, WorkerPool may not have stopped completely")
 	}
 
+	for _, ep := range ms.endpoints {
+		if ep.ws != nil {
+			ep.ws.Close()
+		}
+	}
+
 	close(ms.metricsChan)
 }
 
@@ -451,24 +539,105 @@ var gzipPool = sync.Pool{
 	},
 }
 
-// SendMetricsBatch отправляет множество метрик одним запросом
+// EndpointStatuses возвращает состояние всех серверов
+func (ms *MetricsSender) EndpointStatuses() []EndpointStatus {
+	statuses := make([]EndpointStatus, 0, len(ms.endpoints))
+	for _, ep := range ms.endpoints {
+		statuses = append(statuses, ep.status())
+	}
+	return statuses
+}
+
+// SendMetricsBatch отправляет множество метрик одним запросом.
+// В режиме failover батч отправляется первому доступному серверу по порядку,
+// в режиме fanout - всем серверам параллельно.
 func (ms *MetricsSender) SendMetricsBatch(metrics []models.Metrics) error {
 	if len(metrics) == 0 {
 		return nil
 	}
 
-	url, err := url.JoinPath(ms.address, "updates/")
+	if ms.mode == SendModeFanout {
+		return ms.sendFanout(metrics)
+	}
+	return ms.sendFailover(metrics)
+}
+
+// sendFailover перебирает серверы по порядку, пропуская находящиеся в backoff.
+// Если в backoff находятся все серверы, попытка выполняется для каждого из них.
+func (ms *MetricsSender) sendFailover(metrics []models.Metrics) error {
+	now := time.Now()
+	candidates := make([]*endpoint, 0, len(ms.endpoints))
+	for _, ep := range ms.endpoints {
+		if ep.available(now) {
+			candidates = append(candidates, ep)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = ms.endpoints
+	}
+
+	var errs []error
+	for _, ep := range candidates {
+		err := ms.sendToEndpoint(ep, metrics)
+		ep.markResult(err)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ep.address, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendFanout отправляет батч на все серверы параллельно
+func (ms *MetricsSender) sendFanout(metrics []models.Metrics) error {
+	errs := make([]error, len(ms.endpoints))
+
+	var wg sync.WaitGroup
+	for i, ep := range ms.endpoints {
+		wg.Add(1)
+		go func(i int, ep *endpoint) {
+			defer wg.Done()
+			err := ms.sendToEndpoint(ep, metrics)
+			ep.markResult(err)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", ep.address, err)
+			}
+		}(i, ep)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// sendToEndpoint отправляет батч на один сервер с его ключом подписи и ключом шифрования
+func (ms *MetricsSender) sendToEndpoint(ep *endpoint, metrics []models.Metrics) error {
+	url, err := url.JoinPath(ep.address, "updates/")
 	if err != nil {
 		return fmt.Errorf("error joining URL: %w", err)
 	}
 
+	// Сервер ранее ответил 429: ждём окончания указанной им задержки,
+	// чтобы снизить частоту отправки вместо повторных отказов
+	if err := ep.waitThrottle(ms.ctx); err != nil {
+		return fmt.Errorf("throttle wait cancelled: %w", err)
+	}
+
+	// Коллектор подписывает метрики общим ключом, для сервера с собственным ключом подписываем заново
+	if ep.key != ms.key {
+		metrics = signMetrics(metrics, ep.key)
+	}
+
 	body, err := json.Marshal(metrics)
 	if err != nil {
 		return fmt.Errorf("marshal error: %w", err)
 	}
 
 	// Сжимаем данные
-	buf := bufPool.Get().(*bytes.Buffer)
+	buf, ok := bufPool.Get().(*bytes.Buffer)
+	if !ok {
+		return fmt.Errorf("gzip buffer error: unexpected value in buffer pool")
+	}
 	buf.Reset()
 	defer bufPool.Put(buf)
 
@@ -490,42 +659,62 @@ func (ms *MetricsSender) SendMetricsBatch(metrics []models.Metrics) error {
 	var contentEncoding string
 
 	// Шифруем данные, если есть публичный ключ
-	if ms.publicKey != nil {
-		encryptedData, err := crypto.EncryptLargeData(compressedData, ms.publicKey)
+	if ep.publicKey != nil {
+		encryptedData, err := crypto.EncryptLargeData(compressedData, ep.publicKey)
 		if err != nil {
 			return fmt.Errorf("encryption error: %w", err)
 		}
 
-		// Кодируем в Base64 для передачи
-		finalData = []byte(base64.StdEncoding.EncodeToString(encryptedData))
-		contentEncoding = "encrypted"
+		finalData = encryptedData
+		contentEncoding = wsproto.EncodingEncrypted
 	} else {
 		finalData = compressedData
-		contentEncoding = "gzip"
+		contentEncoding = wsproto.EncodingGzip
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(finalData))
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Content-Encoding", contentEncoding)
-	req.Header.Set("Accept-Encoding", "gzip")
-
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	return utils.Retry(ctx, utils.DefaultRetryConfig(), func() error {
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
+	if ep.ws != nil {
+		batch := wsproto.Batch{
+			Encoding: contentEncoding,
+			Hash:     utils.CalculateHash(body, ep.key),
+			Payload:  finalData,
+		}
+		// Батч повторяется с новым порядковым номером, при обрыве соединение
+		// устанавливается заново при следующей попытке
+		return utils.Retry(ctx, ms.retryConfig.WithBreaker(ep.breaker), func() error {
+			return ep.ws.send(ctx, batch)
+		})
+	}
+
+	if ep.publicKey != nil {
+		// Кодируем в Base64 для передачи по HTTP
+		finalData = []byte(base64.StdEncoding.EncodeToString(finalData))
+	}
+
+	// Jitter разносит повторы агентов во времени, а breaker сервера
+	// завершает отправку сразу, пока сервер недоступен
+	return utils.Retry(ctx, ms.retryConfig.WithBreaker(ep.breaker), func() error {
+		// Запрос создаётся на каждую попытку, так как тело читается при отправке
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(finalData))
+		if err != nil {
+			return fmt.Errorf("error creating request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", contentEncoding)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if ep.agentToken != "" {
+			req.Header.Set(middleware.AgentIDHeader, ep.agentToken)
+		}
+
+		resp, err := ep.client.Do(req)
 		if err != nil {
 			return fmt.Errorf("error sending batch request: %w", err)
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-		}
-		return nil
+		// 5xx повторяются, 429 и 503 с Retry-After - после указанной задержки, 4xx - нет
+		return utils.CheckHTTPResponse(resp)
 	})
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/ViktorBystrov72/go-metrics/internal/config"
 )
@@ -16,6 +17,14 @@ type AgentConfig struct {
 	Key            string
 	RateLimit      int
 	CryptoKey      string
+	ScrapeTargets  []string
+	StatsDAddress  string
+	RuntimeMetrics []string
+	Endpoints      []EndpointConfig
+	SendMode       string
+	Transport      string
+	// AgentID идентификатор агента для rate limiting на сервере, по умолчанию имя хоста
+	AgentID string
 }
 
 type flagValues struct {
@@ -25,6 +34,13 @@ type flagValues struct {
 	key            string
 	rateLimit      int
 	cryptoKey      string
+	scrapeTargets  string
+	statsdAddress  string
+	runtimeMetrics string
+	addresses      string
+	sendMode       string
+	transport      string
+	agentID        string
 	configFile     string
 }
 
@@ -38,6 +54,13 @@ func parseFlags() (*flagValues, error) {
 	fs.StringVar(&flags.key, "k", "", "signature key")
 	fs.IntVar(&flags.rateLimit, "l", 1, "rate limit for concurrent requests")
 	fs.StringVar(&flags.cryptoKey, "crypto-key", "", "path to public key file for encryption")
+	fs.StringVar(&flags.scrapeTargets, "scrape-targets", "", "comma-separated list of Prometheus targets to scrape")
+	fs.StringVar(&flags.statsdAddress, "statsd-address", "", "UDP address to receive StatsD metrics")
+	fs.StringVar(&flags.runtimeMetrics, "runtime-metrics", "", "comma-separated allow-list of runtime/metrics names or prefixes, \"none\" for MemStats-compatible only")
+	fs.StringVar(&flags.addresses, "addresses", "", "comma-separated list of server addresses")
+	fs.StringVar(&flags.sendMode, "send-mode", "", "sending mode for multiple servers: failover or fanout")
+	fs.StringVar(&flags.transport, "transport", "", "transport for sending batches: http or websocket")
+	fs.StringVar(&flags.agentID, "agent-id", "", "agent identifier for server-side rate limiting, hostname by default")
 	fs.StringVar(&flags.configFile, "c", "", "config file path")
 	fs.StringVar(&flags.configFile, "config", "", "config file path")
 
@@ -95,6 +118,34 @@ func applyEnvironmentVariables(jsonConfig *config.AgentJSONConfig, flags *flagVa
 		jsonConfig.CryptoKey = stringPtr(env)
 	}
 
+	if env := os.Getenv("SCRAPE_TARGETS"); env != "" {
+		jsonConfig.ScrapeTargets = splitList(env)
+	}
+
+	if env := os.Getenv("STATSD_ADDRESS"); env != "" {
+		jsonConfig.StatsDAddress = stringPtr(env)
+	}
+
+	if env := os.Getenv("RUNTIME_METRICS"); env != "" {
+		jsonConfig.RuntimeMetrics = splitList(env)
+	}
+
+	if env := os.Getenv("ADDRESSES"); env != "" {
+		jsonConfig.Endpoints = endpointsFromAddresses(splitList(env))
+	}
+
+	if env := os.Getenv("SEND_MODE"); env != "" {
+		jsonConfig.SendMode = stringPtr(env)
+	}
+
+	if env := os.Getenv("TRANSPORT"); env != "" {
+		jsonConfig.Transport = stringPtr(env)
+	}
+
+	if env := os.Getenv("AGENT_ID"); env != "" {
+		jsonConfig.AgentID = stringPtr(env)
+	}
+
 	// KEY и RATE_LIMIT не поддерживаются в JSON, применяем к флагам
 	if env := os.Getenv("KEY"); env != "" {
 		flags.key = env
@@ -127,6 +178,27 @@ func applyFlags(flags *flagValues) *config.AgentJSONConfig {
 	if flags.cryptoKey != "" {
 		finalConfig.CryptoKey = stringPtr(flags.cryptoKey)
 	}
+	if flags.scrapeTargets != "" {
+		finalConfig.ScrapeTargets = splitList(flags.scrapeTargets)
+	}
+	if flags.statsdAddress != "" {
+		finalConfig.StatsDAddress = stringPtr(flags.statsdAddress)
+	}
+	if flags.runtimeMetrics != "" {
+		finalConfig.RuntimeMetrics = splitList(flags.runtimeMetrics)
+	}
+	if flags.addresses != "" {
+		finalConfig.Endpoints = endpointsFromAddresses(splitList(flags.addresses))
+	}
+	if flags.sendMode != "" {
+		finalConfig.SendMode = stringPtr(flags.sendMode)
+	}
+	if flags.transport != "" {
+		finalConfig.Transport = stringPtr(flags.transport)
+	}
+	if flags.agentID != "" {
+		finalConfig.AgentID = stringPtr(flags.agentID)
+	}
 
 	return finalConfig
 }
@@ -168,6 +240,39 @@ func buildFinalConfig(finalConfig *config.AgentJSONConfig, flags *flagValues) (*
 		result.CryptoKey = *finalConfig.CryptoKey
 	}
 
+	result.ScrapeTargets = finalConfig.ScrapeTargets
+	result.RuntimeMetrics = finalConfig.RuntimeMetrics
+
+	for _, ep := range finalConfig.Endpoints {
+		result.Endpoints = append(result.Endpoints, EndpointConfig{
+			Address:   ep.Address,
+			Key:       ep.Key,
+			CryptoKey: ep.CryptoKey,
+		})
+	}
+
+	if finalConfig.SendMode != nil {
+		result.SendMode = *finalConfig.SendMode
+	} else {
+		result.SendMode = SendModeFailover
+	}
+
+	if finalConfig.Transport != nil {
+		result.Transport = *finalConfig.Transport
+	} else {
+		result.Transport = TransportHTTP
+	}
+
+	if finalConfig.StatsDAddress != nil {
+		result.StatsDAddress = *finalConfig.StatsDAddress
+	}
+
+	if finalConfig.AgentID != nil {
+		result.AgentID = *finalConfig.AgentID
+	} else if hostname, err := os.Hostname(); err == nil {
+		result.AgentID = hostname
+	}
+
 	return result, nil
 }
 
@@ -181,6 +286,17 @@ func validateConfig(cfg *AgentConfig) error {
 	if cfg.RateLimit <= 0 {
 		return fmt.Errorf("RATE_LIMIT должен быть больше 0")
 	}
+	if cfg.SendMode != SendModeFailover && cfg.SendMode != SendModeFanout {
+		return fmt.Errorf("SEND_MODE должен быть %s или %s, получен %q", SendModeFailover, SendModeFanout, cfg.SendMode)
+	}
+	if cfg.Transport != TransportHTTP && cfg.Transport != TransportWebSocket {
+		return fmt.Errorf("TRANSPORT должен быть %s или %s, получен %q", TransportHTTP, TransportWebSocket, cfg.Transport)
+	}
+	for i, ep := range cfg.Endpoints {
+		if ep.Address == "" {
+			return fmt.Errorf("не задан адрес сервера #%d", i+1)
+		}
+	}
 	return nil
 }
 
@@ -229,3 +345,23 @@ func stringPtr(s string) *string {
 	}
 	return &s
 }
+
+// splitList разбивает строку со значениями через запятую, отбрасывая пустые элементы
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// endpointsFromAddresses формирует список серверов с общими ключами агента
+func endpointsFromAddresses(addresses []string) []config.EndpointJSONConfig {
+	endpoints := make([]config.EndpointJSONConfig, 0, len(addresses))
+	for _, addr := range addresses {
+		endpoints = append(endpoints, config.EndpointJSONConfig{Address: addr})
+	}
+	return endpoints
+}
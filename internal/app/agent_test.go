@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"net"
 	"testing"
 	"time"
 
@@ -296,6 +297,7 @@ func TestSendMetricsBatch_MarshalError(t *testing.T) {
 // TestSendMetricsBatch_GzipError тестирует ошибку gzip (например, закрытый буфер).
 func TestSendMetricsBatch_GzipError(t *testing.T) {
 	sender := NewMetricsSender(&AgentConfig{Address: "localhost:8080", RateLimit: 1, Key: "test"}).(*MetricsSender)
+	sender.retryConfig = noRetry
 	metrics := []models.Metrics{{ID: "test", MType: "gauge", Value: func() *float64 { v := 1.0; return &v }()}}
 
 	originalNew := bufPool.New
@@ -307,3 +309,44 @@ func TestSendMetricsBatch_GzipError(t *testing.T) {
 		t.Error("Ожидалась ошибка при ошибке gzip")
 	}
 }
+
+// TestMetricsCollectorStatsD тестирует передачу агрегированных метрик StatsD в канал коллектора.
+func TestMetricsCollectorStatsD(t *testing.T) {
+	// Занимаем свободный порт, чтобы узнать адрес для listener
+	probe, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось получить свободный порт: %v", err)
+	}
+	addr := probe.LocalAddr().String()
+	probe.Close()
+
+	collector := NewMetricsCollector(&AgentConfig{PollInterval: 60, ReportInterval: 1, StatsDAddress: addr}).(*MetricsCollector)
+	collector.Start(context.Background())
+	defer collector.Stop()
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("Не удалось подключиться: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("jobs:3|c\njobs:4|c")); err != nil {
+		t.Fatalf("Не удалось отправить пакет: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case batch := <-collector.Metrics():
+			for _, m := range batch {
+				if m.ID == "jobs" {
+					if m.MType != "counter" || *m.Delta != 7 {
+						t.Errorf("Ожидалась сумма 7, получено %+v", m)
+					}
+					return
+				}
+			}
+		case <-timeout:
+			t.Fatal("Метрика jobs не получена")
+		}
+	}
+}
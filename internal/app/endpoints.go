@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/crypto"
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// Режимы отправки метрик на несколько серверов
+const (
+	// SendModeFailover отправляет батч первому доступному серверу по порядку
+	SendModeFailover = "failover"
+	// SendModeFanout отправляет каждый батч на все серверы
+	SendModeFanout = "fanout"
+)
+
+// Транспорт отправки батчей на сервер
+const (
+	// TransportHTTP отправляет каждый батч отдельным POST /updates/
+	TransportHTTP = "http"
+	// TransportWebSocket отправляет батчи через долгоживущее WebSocket соединение
+	TransportWebSocket = "websocket"
+)
+
+const (
+	endpointBaseBackoff = 1 * time.Second
+	endpointMaxBackoff  = 1 * time.Minute
+	// endpointRequestTimeout таймаут одного HTTP запроса к серверу
+	endpointRequestTimeout = 10 * time.Second
+)
+
+// EndpointConfig настройки одного сервера. Пустые Key и CryptoKey наследуются
+// из общих настроек агента.
+type EndpointConfig struct {
+	Address   string
+	Key       string
+	CryptoKey string
+	// AgentID идентификатор агента, передаётся серверу с подписью Key для rate limiting
+	AgentID string
+}
+
+// endpoint сервер с собственными ключами и состоянием доступности
+type endpoint struct {
+	address string
+	key     string
+	// agentToken значение заголовка X-Agent-ID, пустое без ключа
+	agentToken string
+	publicKey  *rsa.PublicKey
+	// client HTTP клиент сервера, соединения переиспользуются между отправками
+	client *http.Client
+	// breaker прекращает отправку на сервер, пока он недоступен
+	breaker *utils.CircuitBreaker
+	// ws клиент WebSocket соединения, nil при отправке через HTTP
+	ws *wsClient
+
+	mu          sync.Mutex
+	failures    int
+	nextAttempt time.Time
+	// throttledUntil время, до которого сервер просил не отправлять запросы (429, Retry-After)
+	throttledUntil time.Time
+}
+
+// newEndpoint создаёт endpoint и загружает публичный ключ, если путь указан
+func newEndpoint(cfg EndpointConfig) *endpoint {
+	ep := &endpoint{
+		address:    cfg.Address,
+		key:        cfg.Key,
+		agentToken: utils.AgentToken(cfg.AgentID, cfg.Key),
+		client:     &http.Client{Timeout: endpointRequestTimeout},
+	}
+	if !strings.Contains(ep.address, "://") {
+		ep.address = fmt.Sprintf("http://%s", ep.address)
+	}
+	ep.breaker = utils.NewCircuitBreaker(utils.DefaultCircuitBreakerConfig(ep.address))
+
+	if cfg.CryptoKey != "" {
+		publicKey, err := crypto.LoadPublicKey(cfg.CryptoKey)
+		if err != nil {
+			log.Printf("Ошибка загрузки публичного ключа для %s: %v", cfg.Address, err)
+			// Продолжаем работу без шифрования
+		} else {
+			log.Printf("Публичный ключ для %s загружен из: %s", cfg.Address, cfg.CryptoKey)
+			ep.publicKey = publicKey
+		}
+	}
+
+	return ep
+}
+
+// available возвращает true, если период backoff после ошибок истёк
+// и circuit breaker сервера не открыт
+func (ep *endpoint) available(now time.Time) bool {
+	if ep.breaker.State() == utils.CircuitOpen {
+		return false
+	}
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	return !now.Before(ep.nextAttempt)
+}
+
+// markSuccess сбрасывает счётчик ошибок
+func (ep *endpoint) markSuccess() {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if ep.failures > 0 {
+		log.Printf("Сервер %s снова доступен", ep.address)
+	}
+	ep.failures = 0
+	ep.nextAttempt = time.Time{}
+}
+
+// markFailure увеличивает счётчик ошибок и откладывает следующую попытку
+// с экспоненциально растущим интервалом
+func (ep *endpoint) markFailure(now time.Time) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.failures++
+
+	backoff := endpointBaseBackoff << min(ep.failures-1, 6)
+	if backoff > endpointMaxBackoff {
+		backoff = endpointMaxBackoff
+	}
+	ep.nextAttempt = now.Add(backoff)
+	log.Printf("Сервер %s недоступен (ошибок подряд: %d), следующая попытка через %v", ep.address, ep.failures, backoff)
+}
+
+// markThrottled откладывает отправку на сервер, запросивший снижение частоты запросов.
+// Ограничение частоты не считается ошибкой доступности сервера.
+func (ep *endpoint) markThrottled(until time.Time) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if until.After(ep.throttledUntil) {
+		ep.throttledUntil = until
+		log.Printf("Сервер %s ограничил частоту запросов, следующая отправка не раньше %s", ep.address, until.Format(time.RFC3339))
+	}
+}
+
+// waitThrottle ожидает окончания ограничения частоты запросов, заданного сервером
+func (ep *endpoint) waitThrottle(ctx context.Context) error {
+	ep.mu.Lock()
+	wait := time.Until(ep.throttledUntil)
+	ep.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// EndpointStatus состояние сервера для диагностики
+type EndpointStatus struct {
+	Address        string
+	Failures       int
+	NextAttempt    time.Time
+	ThrottledUntil time.Time
+	Circuit        utils.CircuitState
+}
+
+// status возвращает текущее состояние сервера
+func (ep *endpoint) status() EndpointStatus {
+	circuit := ep.breaker.State()
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	return EndpointStatus{
+		Address:        ep.address,
+		Failures:       ep.failures,
+		NextAttempt:    ep.nextAttempt,
+		ThrottledUntil: ep.throttledUntil,
+		Circuit:        circuit,
+	}
+}
+
+// markResult учитывает результат отправки: успех, ограничение частоты или ошибку доступности.
+// Остальные ошибки, например ошибки кодирования батча или отказ сервера в приёме данных,
+// на состояние доступности сервера не влияют.
+func (ep *endpoint) markResult(err error) {
+	switch {
+	case err == nil:
+		ep.markSuccess()
+	case utils.ClassifyError(err) == utils.ErrorClassThrottled:
+		// Задержка сервера ограничена, как и backoff после ошибок доступности
+		retryAfter := min(utils.RetryAfter(err), endpointMaxBackoff)
+		if retryAfter <= 0 {
+			retryAfter = endpointBaseBackoff
+		}
+		ep.markThrottled(time.Now().Add(retryAfter))
+	case unavailable(err):
+		ep.markFailure(time.Now())
+	}
+}
+
+// unavailable сообщает, что ошибка вызвана недоступностью сервера: транспортной ошибкой,
+// повторяемым ответом сервера или открытым circuit breaker
+func unavailable(err error) bool {
+	var urlErr *url.Error
+	return utils.ClassifyError(err) == utils.ErrorClassRetriable ||
+		errors.Is(err, utils.ErrCircuitOpen) ||
+		errors.As(err, &urlErr)
+}
+
+// signMetrics возвращает копию метрик, подписанных ключом сервера
+func signMetrics(metrics []models.Metrics, key string) []models.Metrics {
+	signed := make([]models.Metrics, len(metrics))
+	for i, m := range metrics {
+		signed[i] = NewMetric(m.ID, m.MType, m.Value, m.Delta, key)
+	}
+	return signed
+}
+
+// buildEndpointConfigs формирует список серверов из конфигурации агента.
+// Если список не задан, используется единственный сервер из Address.
+func buildEndpointConfigs(cfg *AgentConfig) []EndpointConfig {
+	if len(cfg.Endpoints) == 0 {
+		return []EndpointConfig{{Address: cfg.Address, Key: cfg.Key, CryptoKey: cfg.CryptoKey, AgentID: cfg.AgentID}}
+	}
+
+	configs := make([]EndpointConfig, 0, len(cfg.Endpoints))
+	for _, ep := range cfg.Endpoints {
+		if ep.Key == "" {
+			ep.Key = cfg.Key
+		}
+		if ep.CryptoKey == "" {
+			ep.CryptoKey = cfg.CryptoKey
+		}
+		ep.AgentID = cfg.AgentID
+		configs = append(configs, ep)
+	}
+	return configs
+}
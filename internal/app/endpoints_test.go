@@ -0,0 +1,317 @@
+package app
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// batchServer тестовый сервер, который считает принятые батчи и запоминает последний
+type batchServer struct {
+	*httptest.Server
+	received atomic.Int64
+	last     atomic.Value
+	status   atomic.Int64
+}
+
+func newBatchServer(t *testing.T) *batchServer {
+	t.Helper()
+	bs := &batchServer{}
+	bs.status.Store(http.StatusOK)
+	bs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := int(bs.status.Load()); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var metrics []models.Metrics
+		if err := json.NewDecoder(gz).Decode(&metrics); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bs.last.Store(metrics)
+		bs.received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(bs.Close)
+	return bs
+}
+
+// noRetry отключает повторы, чтобы тесты failover не ждали задержек backoff
+var noRetry = utils.RetryConfig{MaxAttempts: 1}
+
+func testBatch() []models.Metrics {
+	v := 1.5
+	return []models.Metrics{NewMetric("test", "gauge", &v, nil, "common-key")}
+}
+
+// TestSendMetricsBatchFailover тестирует переключение на резервный сервер.
+func TestSendMetricsBatchFailover(t *testing.T) {
+	primary := newBatchServer(t)
+	secondary := newBatchServer(t)
+	primary.status.Store(http.StatusInternalServerError)
+
+	sender := NewMetricsSender(&AgentConfig{
+		RateLimit: 1,
+		Key:       "common-key",
+		SendMode:  SendModeFailover,
+		Endpoints: []EndpointConfig{{Address: primary.URL}, {Address: secondary.URL}},
+	}).(*MetricsSender)
+	sender.retryConfig = noRetry
+
+	if err := sender.SendMetricsBatch(testBatch()); err != nil {
+		t.Fatalf("Ожидалась успешная отправка на резервный сервер: %v", err)
+	}
+	if secondary.received.Load() != 1 {
+		t.Errorf("Резервный сервер должен получить батч")
+	}
+
+	statuses := sender.EndpointStatuses()
+	if statuses[0].Failures != 1 || !statuses[0].NextAttempt.After(time.Now()) {
+		t.Errorf("Основной сервер должен быть помечен недоступным: %+v", statuses[0])
+	}
+
+	// Пока основной сервер в backoff, запросы к нему не отправляются
+	primary.status.Store(http.StatusOK)
+	if err := sender.SendMetricsBatch(testBatch()); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if primary.received.Load() != 0 || secondary.received.Load() != 2 {
+		t.Errorf("Ожидалась отправка на резервный сервер, primary=%d secondary=%d",
+			primary.received.Load(), secondary.received.Load())
+	}
+}
+
+// TestSendMetricsBatchFailoverAllDown тестирует ошибку, когда недоступны все серверы.
+func TestSendMetricsBatchFailoverAllDown(t *testing.T) {
+	first := newBatchServer(t)
+	second := newBatchServer(t)
+	first.status.Store(http.StatusInternalServerError)
+	second.status.Store(http.StatusInternalServerError)
+
+	sender := NewMetricsSender(&AgentConfig{
+		RateLimit: 1,
+		Endpoints: []EndpointConfig{{Address: first.URL}, {Address: second.URL}},
+	}).(*MetricsSender)
+	sender.retryConfig = noRetry
+
+	if err := sender.SendMetricsBatch(testBatch()); err == nil {
+		t.Fatal("Ожидалась ошибка, когда недоступны все серверы")
+	}
+
+	// Когда в backoff находятся все серверы, попытка всё равно выполняется
+	second.status.Store(http.StatusOK)
+	if err := sender.SendMetricsBatch(testBatch()); err != nil {
+		t.Errorf("Ожидалась попытка отправки при всех серверах в backoff: %v", err)
+	}
+	if failures := sender.EndpointStatuses()[1].Failures; failures != 0 {
+		t.Errorf("Успешная отправка должна сбрасывать счётчик ошибок, получено %d", failures)
+	}
+}
+
+// TestSendMetricsBatchFanout тестирует отправку на все серверы с собственными ключами.
+func TestSendMetricsBatchFanout(t *testing.T) {
+	first := newBatchServer(t)
+	second := newBatchServer(t)
+
+	sender := NewMetricsSender(&AgentConfig{
+		RateLimit: 1,
+		Key:       "common-key",
+		SendMode:  SendModeFanout,
+		Endpoints: []EndpointConfig{
+			{Address: first.URL},
+			{Address: second.URL, Key: "second-key"},
+		},
+	}).(*MetricsSender)
+	sender.retryConfig = noRetry
+
+	if err := sender.SendMetricsBatch(testBatch()); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if first.received.Load() != 1 || second.received.Load() != 1 {
+		t.Fatalf("Каждый сервер должен получить батч, first=%d second=%d", first.received.Load(), second.received.Load())
+	}
+
+	hashFor := func(key string) string {
+		return utils.CalculateHash([]byte("test:gauge:1.500000"), key)
+	}
+	if got := first.last.Load().([]models.Metrics)[0].Hash; got != hashFor("common-key") {
+		t.Errorf("Первый сервер должен получить подпись общим ключом")
+	}
+	if got := second.last.Load().([]models.Metrics)[0].Hash; got != hashFor("second-key") {
+		t.Errorf("Второй сервер должен получить подпись собственным ключом")
+	}
+
+	// Ошибка одного сервера возвращается, но не мешает доставке на остальные
+	second.status.Store(http.StatusInternalServerError)
+	if err := sender.SendMetricsBatch(testBatch()); err == nil {
+		t.Error("Ожидалась ошибка от второго сервера")
+	}
+	if first.received.Load() != 2 {
+		t.Error("Первый сервер должен получить второй батч")
+	}
+}
+
+// TestSendMetricsBatchStatusClassification тестирует повторы в зависимости от кода ответа.
+func TestSendMetricsBatchStatusClassification(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int64
+	}{
+		{name: "server error is retried", status: http.StatusBadGateway, attempts: 3},
+		{name: "throttled is retried", status: http.StatusTooManyRequests, attempts: 3},
+		{name: "client error is not retried", status: http.StatusBadRequest, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sender := NewMetricsSender(&AgentConfig{Address: server.URL, RateLimit: 1}).(*MetricsSender)
+			sender.retryConfig = utils.RetryConfig{MaxAttempts: 3, Delays: []time.Duration{time.Millisecond, time.Millisecond}}
+
+			if err := sender.SendMetricsBatch(testBatch()); err == nil {
+				t.Fatal("Ожидалась ошибка")
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("Ожидалось попыток: %d, выполнено %d", tt.attempts, got)
+			}
+		})
+	}
+}
+
+// TestSendMetricsBatchLocalErrors тестирует, что ошибки кодирования батча и отказ сервера
+// в приёме данных не помечают сервер недоступным, а транспортные ошибки помечают.
+func TestSendMetricsBatchLocalErrors(t *testing.T) {
+	server := newBatchServer(t)
+	sender := NewMetricsSender(&AgentConfig{Address: server.URL, RateLimit: 1}).(*MetricsSender)
+	sender.retryConfig = noRetry
+
+	nan := math.NaN()
+	if err := sender.SendMetricsBatch([]models.Metrics{{ID: "bad", MType: "gauge", Value: &nan}}); err == nil {
+		t.Fatal("Ожидалась ошибка кодирования батча")
+	}
+	if status := sender.EndpointStatuses()[0]; status.Failures != 0 {
+		t.Errorf("Ошибка кодирования не должна считаться ошибкой доступности: %+v", status)
+	}
+
+	server.status.Store(http.StatusBadRequest)
+	if err := sender.SendMetricsBatch(testBatch()); err == nil {
+		t.Fatal("Ожидалась ошибка при ответе 400")
+	}
+	if status := sender.EndpointStatuses()[0]; status.Failures != 0 {
+		t.Errorf("Ответ 400 не должен считаться ошибкой доступности: %+v", status)
+	}
+
+	server.Close()
+	if err := sender.SendMetricsBatch(testBatch()); err == nil {
+		t.Fatal("Ожидалась ошибка при недоступном сервере")
+	}
+	if status := sender.EndpointStatuses()[0]; status.Failures != 1 {
+		t.Errorf("Транспортная ошибка должна помечать сервер недоступным: %+v", status)
+	}
+}
+
+// TestSendMetricsBatchThrottled тестирует снижение частоты отправки после ответа 429.
+func TestSendMetricsBatchThrottled(t *testing.T) {
+	var attempts atomic.Int64
+	var agentToken atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agentToken.Store(r.Header.Get(middleware.AgentIDHeader))
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewMetricsSender(&AgentConfig{Address: server.URL, RateLimit: 1, Key: "agent-key", AgentID: "agent-1"}).(*MetricsSender)
+	sender.retryConfig = noRetry
+
+	if err := sender.SendMetricsBatch(testBatch()); err == nil {
+		t.Fatal("Ожидалась ошибка при ответе 429")
+	}
+	status := sender.EndpointStatuses()[0]
+	if status.Failures != 0 {
+		t.Errorf("Ответ 429 не должен считаться ошибкой доступности, ошибок %d", status.Failures)
+	}
+	if !status.ThrottledUntil.After(time.Now()) {
+		t.Fatal("Сервер должен быть помечен как ограничивший частоту запросов")
+	}
+	if got := agentToken.Load(); got != utils.AgentToken("agent-1", "agent-key") {
+		t.Errorf("Ожидался заголовок %s, получено %v", middleware.AgentIDHeader, got)
+	}
+
+	// Следующая отправка выполняется только после Retry-After
+	start := time.Now()
+	if err := sender.SendMetricsBatch(testBatch()); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("Отправка должна ждать окончания Retry-After, прошло %v", elapsed)
+	}
+}
+
+// TestEndpointThrottleLimit тестирует ограничение задержки Retry-After сервера.
+func TestEndpointThrottleLimit(t *testing.T) {
+	ep := newEndpoint(EndpointConfig{Address: "localhost:8080"})
+	ep.markResult(utils.NewThrottledError(errors.New("rate limited"), 24*time.Hour))
+
+	until := ep.status().ThrottledUntil
+	if until.After(time.Now().Add(endpointMaxBackoff)) {
+		t.Errorf("Задержка должна быть не больше %v, сервер ограничен до %v", endpointMaxBackoff, until)
+	}
+	if ep.status().Failures != 0 {
+		t.Errorf("Ограничение частоты не должно считаться ошибкой доступности")
+	}
+}
+
+// countingTransport считает запросы, выполненные через HTTP клиент
+type countingTransport struct {
+	requests atomic.Int64
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// TestSendMetricsBatchEndpointClient тестирует отправку через HTTP клиент сервера.
+func TestSendMetricsBatchEndpointClient(t *testing.T) {
+	server := newBatchServer(t)
+	sender := NewMetricsSender(&AgentConfig{Address: server.URL, RateLimit: 1}).(*MetricsSender)
+	sender.retryConfig = noRetry
+
+	transport := &countingTransport{}
+	sender.endpoints[0].client.Transport = transport
+	for range 3 {
+		if err := sender.SendMetricsBatch(testBatch()); err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+	}
+	if got := transport.requests.Load(); got != 3 {
+		t.Errorf("Все запросы должны выполняться клиентом сервера, выполнено %d из 3", got)
+	}
+}
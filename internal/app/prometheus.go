@@ -0,0 +1,327 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// PromSample одно значение из текстового формата экспозиции Prometheus
+type PromSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	// Type тип семейства метрик из комментария # TYPE (counter, gauge, histogram, summary, untyped)
+	Type string
+}
+
+// ParsePrometheusText разбирает текстовый формат экспозиции Prometheus (version 0.0.4)
+func ParsePrometheusText(r io.Reader) ([]PromSample, error) {
+	var samples []PromSample
+	types := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		sample, err := parsePromLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		sample.Type = promFamilyType(sample.Name, types)
+		samples = append(samples, sample)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read error: %w", err)
+	}
+
+	return samples, nil
+}
+
+// parsePromLine разбирает строку вида name{label="value"} 1.5 [timestamp]
+func parsePromLine(line string) (PromSample, error) {
+	sample := PromSample{Labels: make(map[string]string)}
+
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd <= 0 {
+		return sample, fmt.Errorf("invalid sample %q", line)
+	}
+	sample.Name = line[:nameEnd]
+	rest := line[nameEnd:]
+
+	if rest[0] == '{' {
+		var err error
+		rest, err = parsePromLabels(rest[1:], sample.Labels)
+		if err != nil {
+			return sample, err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return sample, fmt.Errorf("invalid value in %q", line)
+	}
+
+	value, err := parsePromValue(fields[0])
+	if err != nil {
+		return sample, fmt.Errorf("invalid value %q: %w", fields[0], err)
+	}
+	sample.Value = value
+
+	return sample, nil
+}
+
+// parsePromLabels разбирает метки до закрывающей скобки и возвращает остаток строки
+func parsePromLabels(s string, labels map[string]string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return "", fmt.Errorf("unterminated label set")
+		}
+		if s[0] == '}' {
+			return s[1:], nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return "", fmt.Errorf("invalid label in %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if s == "" || s[0] != '"' {
+			return "", fmt.Errorf("label %s: value must be quoted", key)
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			if c == '"' {
+				break
+			}
+			value.WriteByte(c)
+		}
+		if i >= len(s) {
+			return "", fmt.Errorf("label %s: unterminated value", key)
+		}
+		labels[key] = value.String()
+		s = s[i+1:]
+	}
+}
+
+// parsePromValue разбирает значение с учётом специальных значений +Inf, -Inf и NaN
+func parsePromValue(s string) (float64, error) {
+	switch s {
+	case "+Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// promFamilyType определяет тип семейства для значения с учётом суффиксов гистограмм и summary
+func promFamilyType(name string, types map[string]string) string {
+	if t, ok := types[name]; ok {
+		return t
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			if t, ok := types[base]; ok && (t == "histogram" || t == "summary") {
+				return t
+			}
+		}
+	}
+	return "untyped"
+}
+
+// isPromCumulative возвращает true для значений, которые монотонно растут между опросами
+func isPromCumulative(s PromSample) bool {
+	switch s.Type {
+	case "counter":
+		return true
+	case "histogram":
+		return true
+	case "summary":
+		// Квантили summary - мгновенные значения, накапливаются только _sum и _count
+		return strings.HasSuffix(s.Name, "_sum") || strings.HasSuffix(s.Name, "_count")
+	}
+	return false
+}
+
+// PrometheusScraper опрашивает HTTP цели в формате Prometheus и превращает их в метрики агента.
+// Counter значения передаются как дельты между опросами, остальные - как gauge.
+type PrometheusScraper struct {
+	targets []string
+	key     string
+	client  *http.Client
+
+	mu sync.Mutex
+	// lastCounters последние значения counter по целям, содержит только серии последнего
+	// успешного опроса цели
+	lastCounters map[string]map[string]float64
+}
+
+// NewPrometheusScraper создаёт scraper для списка целей
+func NewPrometheusScraper(targets []string, key string) *PrometheusScraper {
+	normalized := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if t = strings.TrimSpace(t); t != "" {
+			normalized = append(normalized, normalizeScrapeTarget(t))
+		}
+	}
+
+	return &PrometheusScraper{
+		targets:      normalized,
+		key:          key,
+		client:       &http.Client{Timeout: 5 * time.Second},
+		lastCounters: make(map[string]map[string]float64),
+	}
+}
+
+// normalizeScrapeTarget дополняет адрес вида host:port схемой и путём /metrics
+func normalizeScrapeTarget(target string) string {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/metrics"
+	}
+	return u.String()
+}
+
+// Targets возвращает список опрашиваемых целей
+func (ps *PrometheusScraper) Targets() []string {
+	return ps.targets
+}
+
+// Scrape опрашивает все цели и возвращает метрики. Ошибки отдельных целей логируются
+// и не мешают опросу остальных.
+func (ps *PrometheusScraper) Scrape(ctx context.Context) []models.Metrics {
+	var metrics []models.Metrics
+	for _, target := range ps.targets {
+		samples, err := ps.fetch(ctx, target)
+		if err != nil {
+			log.Printf("Ошибка опроса Prometheus цели %s: %v", target, err)
+			continue
+		}
+		metrics = append(metrics, ps.convert(target, samples)...)
+	}
+	return metrics
+}
+
+// fetch загружает и разбирает ответ одной цели
+func (ps *PrometheusScraper) fetch(ctx context.Context, target string) ([]PromSample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	resp, err := ps.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return ParsePrometheusText(resp.Body)
+}
+
+// convert превращает значения одной цели в метрики агента. Значения counter, которых
+// нет в ответе цели, забываются: серия, пропавшая и вернувшаяся позже, начинается заново
+// с базового значения.
+func (ps *PrometheusScraper) convert(target string, samples []PromSample) []models.Metrics {
+	instance := target
+	if u, err := url.Parse(target); err == nil {
+		instance = u.Host
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	lastCounters := ps.lastCounters[target]
+	counters := make(map[string]float64, len(lastCounters))
+
+	metrics := make([]models.Metrics, 0, len(samples))
+	for _, s := range samples {
+		// JSON не поддерживает NaN и Inf, такие значения пропускаем
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+
+		if _, ok := s.Labels["instance"]; !ok {
+			s.Labels["instance"] = instance
+		}
+		id := models.FormatSeriesID(s.Name, s.Labels)
+
+		if !isPromCumulative(s) {
+			v := s.Value
+			metrics = append(metrics, NewMetric(id, "gauge", &v, nil, ps.key))
+			continue
+		}
+
+		// Для первого опроса значение запоминается как базовое, чтобы при рестарте
+		// агента не учитывать накопленный целью итог повторно
+		var delta int64
+		if last, ok := lastCounters[id]; ok {
+			if s.Value >= last {
+				delta = int64(math.Floor(s.Value)) - int64(math.Floor(last))
+			} else {
+				// Сброс счётчика на стороне цели
+				delta = int64(math.Floor(s.Value))
+			}
+		}
+		counters[id] = s.Value
+		metrics = append(metrics, NewMetric(id, "counter", nil, &delta, ps.key))
+	}
+	ps.lastCounters[target] = counters
+
+	return metrics
+}
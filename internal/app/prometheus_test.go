@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+const promExposition = `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} %d
+http_requests_total{method="post",code="400"} 3 1395066363000
+# TYPE temperature gauge
+temperature{room="a \"big\" one"} 21.5
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.05
+rpc_duration_seconds_sum 17.5
+rpc_duration_seconds_count 100
+# TYPE broken gauge
+broken NaN
+untyped_metric 7
+`
+
+// TestParsePrometheusText тестирует разбор текстового формата Prometheus.
+func TestParsePrometheusText(t *testing.T) {
+	samples, err := ParsePrometheusText(strings.NewReader(fmt.Sprintf(promExposition, 10)))
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if len(samples) != 8 {
+		t.Fatalf("Ожидалось 8 значений, получено %d", len(samples))
+	}
+
+	expectedTypes := map[string]string{
+		"http_requests_total":        "counter",
+		"temperature":                "gauge",
+		"rpc_duration_seconds":       "summary",
+		"rpc_duration_seconds_sum":   "summary",
+		"rpc_duration_seconds_count": "summary",
+		"untyped_metric":             "untyped",
+	}
+	for _, s := range samples {
+		if want, ok := expectedTypes[s.Name]; ok && s.Type != want {
+			t.Errorf("%s: ожидался тип %s, получен %s", s.Name, want, s.Type)
+		}
+	}
+
+	if samples[2].Labels["room"] != `a "big" one` {
+		t.Errorf("Метка с экранированием разобрана некорректно: %q", samples[2].Labels["room"])
+	}
+}
+
+// TestParsePrometheusTextInvalid тестирует ошибки разбора.
+func TestParsePrometheusTextInvalid(t *testing.T) {
+	inputs := []string{
+		"metric{label=\"unterminated} 1",
+		"metric{label=value} 1",
+		"metric not_a_number",
+		"metric",
+	}
+	for _, in := range inputs {
+		if _, err := ParsePrometheusText(strings.NewReader(in)); err == nil {
+			t.Errorf("Ожидалась ошибка для %q", in)
+		}
+	}
+}
+
+// TestPrometheusScraperCounterDeltas тестирует преобразование counter в дельты между опросами.
+func TestPrometheusScraperCounterDeltas(t *testing.T) {
+	var total atomic.Int64
+	total.Store(10)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, promExposition, total.Load())
+	}))
+	defer target.Close()
+
+	// Адрес без схемы и пути должен дополняться до http://host/metrics
+	scraper := NewPrometheusScraper([]string{strings.TrimPrefix(target.URL, "http://")}, "")
+	instance := strings.TrimPrefix(target.URL, "http://")
+	okID := models.FormatSeriesID("http_requests_total", map[string]string{"method": "post", "code": "200", "instance": instance})
+
+	find := func(metrics []models.Metrics, id string) *models.Metrics {
+		for i := range metrics {
+			if metrics[i].ID == id {
+				return &metrics[i]
+			}
+		}
+		return nil
+	}
+
+	first := scraper.Scrape(context.Background())
+	m := find(first, okID)
+	if m == nil || m.MType != "counter" || *m.Delta != 0 {
+		t.Fatalf("Первый опрос должен вернуть нулевую дельту, получено %+v", m)
+	}
+
+	gauge := find(first, models.FormatSeriesID("temperature", map[string]string{"room": `a "big" one`, "instance": instance}))
+	if gauge == nil || gauge.MType != "gauge" || *gauge.Value != 21.5 {
+		t.Errorf("Gauge должен передаваться как есть, получено %+v", gauge)
+	}
+
+	quantile := find(first, models.FormatSeriesID("rpc_duration_seconds", map[string]string{"quantile": "0.5", "instance": instance}))
+	if quantile == nil || quantile.MType != "gauge" {
+		t.Errorf("Квантиль summary должен быть gauge, получено %+v", quantile)
+	}
+
+	if find(first, models.FormatSeriesID("broken", map[string]string{"instance": instance})) != nil {
+		t.Error("NaN значения должны пропускаться")
+	}
+
+	total.Store(25)
+	second := scraper.Scrape(context.Background())
+	if m := find(second, okID); m == nil || *m.Delta != 15 {
+		t.Errorf("Ожидалась дельта 15, получено %+v", m)
+	}
+
+	// Сброс счётчика на стороне цели
+	total.Store(4)
+	third := scraper.Scrape(context.Background())
+	if m := find(third, okID); m == nil || *m.Delta != 4 {
+		t.Errorf("После сброса ожидалась дельта 4, получено %+v", m)
+	}
+}
+
+// TestPrometheusScraperForgetsMissingSeries тестирует удаление значений counter,
+// которых нет в последнем ответе цели.
+func TestPrometheusScraperForgetsMissingSeries(t *testing.T) {
+	var body atomic.Value
+	body.Store("jobs_total{id=\"1\"} 5\njobs_total{id=\"2\"} 7\n")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# TYPE jobs_total counter\n", body.Load())
+	}))
+	defer target.Close()
+
+	scraper := NewPrometheusScraper([]string{target.URL}, "")
+	instance := strings.TrimPrefix(target.URL, "http://")
+	scraper.Scrape(context.Background())
+
+	// Серия id="2" пропала из ответа цели
+	body.Store("jobs_total{id=\"1\"} 6\n")
+	scraper.Scrape(context.Background())
+	counters := scraper.lastCounters[target.URL+"/metrics"]
+	if len(counters) != 1 {
+		t.Fatalf("Ожидалась 1 серия counter, получено %v", counters)
+	}
+
+	// Вернувшаяся серия начинается с базового значения
+	body.Store("jobs_total{id=\"1\"} 6\njobs_total{id=\"2\"} 9\n")
+	id := models.FormatSeriesID("jobs_total", map[string]string{"id": "2", "instance": instance})
+	found := false
+	for _, m := range scraper.Scrape(context.Background()) {
+		if m.ID == id {
+			found = true
+			if *m.Delta != 0 {
+				t.Errorf("Вернувшаяся серия должна начинаться с нулевой дельты, получено %d", *m.Delta)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Серия %s не найдена", id)
+	}
+}
+
+// TestPrometheusScraperUnavailableTarget тестирует пропуск недоступной цели.
+func TestPrometheusScraperUnavailableTarget(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	scraper := NewPrometheusScraper([]string{target.URL + "/metrics"}, "")
+	if metrics := scraper.Scrape(context.Background()); len(metrics) != 0 {
+		t.Errorf("Ожидался пустой результат, получено %d метрик", len(metrics))
+	}
+}
+
+// TestMetricsCollectorScrape тестирует отправку метрик Prometheus целей в канал коллектора.
+func TestMetricsCollectorScrape(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "# TYPE queue_size gauge")
+		fmt.Fprintln(w, "queue_size 42")
+	}))
+	defer target.Close()
+
+	collector := NewMetricsCollector(&AgentConfig{PollInterval: 1, ScrapeTargets: []string{target.URL}}).(*MetricsCollector)
+	collector.Start(context.Background())
+	defer collector.Stop()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case batch := <-collector.Metrics():
+			for _, m := range batch {
+				if name, _ := models.ParseSeriesID(m.ID); name == "queue_size" {
+					return
+				}
+			}
+		case <-timeout:
+			t.Fatal("Метрика queue_size не получена")
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"sync"
+)
+
+// histogramPercentiles перцентили, которые рассчитываются для гистограмм runtime/metrics
+var histogramPercentiles = []struct {
+	suffix string
+	p      float64
+}{
+	{"_p50", 0.5},
+	{"_p90", 0.9},
+	{"_p99", 0.99},
+}
+
+// memStatsSources имена runtime/metrics, необходимые для расчёта полей в стиле runtime.MemStats
+var memStatsSources = []string{
+	"/cpu/classes/gc/total:cpu-seconds",
+	"/cpu/classes/total:cpu-seconds",
+	"/gc/cycles/forced:gc-cycles",
+	"/gc/cycles/total:gc-cycles",
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/allocs:objects",
+	"/gc/heap/frees:objects",
+	"/gc/heap/goal:bytes",
+	"/gc/heap/objects:objects",
+	"/gc/heap/tiny/allocs:objects",
+	"/memory/classes/heap/free:bytes",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/heap/released:bytes",
+	"/memory/classes/heap/stacks:bytes",
+	"/memory/classes/heap/unused:bytes",
+	"/memory/classes/metadata/mcache/free:bytes",
+	"/memory/classes/metadata/mcache/inuse:bytes",
+	"/memory/classes/metadata/mspan/free:bytes",
+	"/memory/classes/metadata/mspan/inuse:bytes",
+	"/memory/classes/metadata/other:bytes",
+	"/memory/classes/os-stacks:bytes",
+	"/memory/classes/other:bytes",
+	"/memory/classes/profiling/buckets:bytes",
+	"/memory/classes/total:bytes",
+}
+
+// RuntimeMetricsReader читает метрики Go runtime через пакет runtime/metrics.
+// В отличие от runtime.ReadMemStats чтение не останавливает мир.
+type RuntimeMetricsReader struct {
+	mu      sync.Mutex
+	samples []metrics.Sample
+	// exported индексы значений, которые экспортируются под собственными именами
+	exported []int
+	index    map[string]int
+}
+
+// NewRuntimeMetricsReader создаёт reader. allow задаёт список имён или префиксов
+// runtime/metrics (например, "/gc/" или "/sched/latencies:seconds"), которые экспортируются
+// под собственными именами. Пустой список означает все поддерживаемые метрики,
+// значение "none" - только поля, совместимые с runtime.MemStats.
+func NewRuntimeMetricsReader(allow []string) *RuntimeMetricsReader {
+	r := &RuntimeMetricsReader{index: make(map[string]int)}
+
+	add := func(name string) int {
+		if i, ok := r.index[name]; ok {
+			return i
+		}
+		r.samples = append(r.samples, metrics.Sample{Name: name})
+		r.index[name] = len(r.samples) - 1
+		return len(r.samples) - 1
+	}
+
+	for _, name := range memStatsSources {
+		add(name)
+	}
+
+	for _, desc := range metrics.All() {
+		if desc.Kind == metrics.KindBad || !runtimeMetricAllowed(desc.Name, allow) {
+			continue
+		}
+		r.exported = append(r.exported, add(desc.Name))
+	}
+
+	return r
+}
+
+// runtimeMetricAllowed проверяет имя по списку разрешённых имён и префиксов
+func runtimeMetricAllowed(name string, allow []string) bool {
+	if len(allow) == 0 {
+		return true
+	}
+	for _, a := range allow {
+		if a == "none" {
+			return false
+		}
+		if name == a || (strings.HasSuffix(a, "/") && strings.HasPrefix(name, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Read возвращает значения gauge метрик: поля в стиле runtime.MemStats
+// и разрешённые метрики runtime/metrics под именами вида go_gc_heap_allocs_bytes.
+func (r *RuntimeMetricsReader) Read() map[string]float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	metrics.Read(r.samples)
+
+	result := make(map[string]float64, len(memStatsSources)+len(r.exported))
+	r.fillMemStats(result)
+
+	for _, i := range r.exported {
+		s := r.samples[i]
+		name := RuntimeMetricName(s.Name)
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			result[name] = float64(s.Value.Uint64())
+		case metrics.KindFloat64:
+			result[name] = s.Value.Float64()
+		case metrics.KindFloat64Histogram:
+			for suffix, v := range summarizeHistogram(s.Value.Float64Histogram()) {
+				result[name+suffix] = v
+			}
+		}
+	}
+
+	return result
+}
+
+// value возвращает числовое значение метрики или 0, если метрика не поддерживается
+func (r *RuntimeMetricsReader) value(name string) float64 {
+	i, ok := r.index[name]
+	if !ok {
+		return 0
+	}
+	v := r.samples[i].Value
+	switch v.Kind() {
+	case metrics.KindUint64:
+		return float64(v.Uint64())
+	case metrics.KindFloat64:
+		return v.Float64()
+	}
+	return 0
+}
+
+// fillMemStats рассчитывает поля runtime.MemStats по соответствию,
+// описанному в документации runtime/metrics, для совместимости с существующими дашбордами
+func (r *RuntimeMetricsReader) fillMemStats(result map[string]float64) {
+	heapObjects := r.value("/memory/classes/heap/objects:bytes")
+	heapUnused := r.value("/memory/classes/heap/unused:bytes")
+	heapFree := r.value("/memory/classes/heap/free:bytes")
+	heapReleased := r.value("/memory/classes/heap/released:bytes")
+	tinyAllocs := r.value("/gc/heap/tiny/allocs:objects")
+	stackInuse := r.value("/memory/classes/heap/stacks:bytes")
+	mspanInuse := r.value("/memory/classes/metadata/mspan/inuse:bytes")
+	mcacheInuse := r.value("/memory/classes/metadata/mcache/inuse:bytes")
+
+	result["Alloc"] = heapObjects
+	result["HeapAlloc"] = heapObjects
+	result["TotalAlloc"] = r.value("/gc/heap/allocs:bytes")
+	result["Sys"] = r.value("/memory/classes/total:bytes")
+	result["Lookups"] = 0
+	result["Mallocs"] = r.value("/gc/heap/allocs:objects") + tinyAllocs
+	result["Frees"] = r.value("/gc/heap/frees:objects") + tinyAllocs
+	result["HeapObjects"] = r.value("/gc/heap/objects:objects")
+	result["HeapInuse"] = heapObjects + heapUnused
+	result["HeapIdle"] = heapFree + heapReleased
+	result["HeapReleased"] = heapReleased
+	result["HeapSys"] = heapObjects + heapUnused + heapFree + heapReleased
+	result["StackInuse"] = stackInuse
+	result["StackSys"] = stackInuse + r.value("/memory/classes/os-stacks:bytes")
+	result["MSpanInuse"] = mspanInuse
+	result["MSpanSys"] = mspanInuse + r.value("/memory/classes/metadata/mspan/free:bytes")
+	result["MCacheInuse"] = mcacheInuse
+	result["MCacheSys"] = mcacheInuse + r.value("/memory/classes/metadata/mcache/free:bytes")
+	result["BuckHashSys"] = r.value("/memory/classes/profiling/buckets:bytes")
+	result["GCSys"] = r.value("/memory/classes/metadata/other:bytes")
+	result["OtherSys"] = r.value("/memory/classes/other:bytes")
+	result["NextGC"] = r.value("/gc/heap/goal:bytes")
+	result["NumGC"] = r.value("/gc/cycles/total:gc-cycles")
+	result["NumForcedGC"] = r.value("/gc/cycles/forced:gc-cycles")
+
+	result["GCCPUFraction"] = 0
+	if total := r.value("/cpu/classes/total:cpu-seconds"); total > 0 {
+		result["GCCPUFraction"] = r.value("/cpu/classes/gc/total:cpu-seconds") / total
+	}
+
+	// Время последней сборки и суммарная пауза недоступны в runtime/metrics,
+	// debug.ReadGCStats возвращает их без остановки мира
+	var gcStats debug.GCStats
+	debug.ReadGCStats(&gcStats)
+	result["LastGC"] = 0
+	if !gcStats.LastGC.IsZero() {
+		result["LastGC"] = float64(gcStats.LastGC.UnixNano())
+	}
+	result["PauseTotalNs"] = float64(gcStats.PauseTotal.Nanoseconds())
+}
+
+// RuntimeMetricName преобразует имя runtime/metrics в имя метрики:
+// "/gc/heap/allocs:bytes" -> "go_gc_heap_allocs_bytes"
+func RuntimeMetricName(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			b.WriteRune(c)
+			continue
+		}
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+			b.WriteByte('_')
+		}
+	}
+	return "go_" + strings.TrimSuffix(b.String(), "_")
+}
+
+// summarizeHistogram сводит гистограмму к набору gauge значений:
+// количество наблюдений, перцентили и верхняя граница последнего непустого бакета.
+// Для перцентилей используется верхняя граница бакета, в который попадает ранг.
+func summarizeHistogram(h *metrics.Float64Histogram) map[string]float64 {
+	result := make(map[string]float64, len(histogramPercentiles)+2)
+
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	result["_count"] = float64(total)
+
+	if total == 0 {
+		for _, p := range histogramPercentiles {
+			result[p.suffix] = 0
+		}
+		result["_max"] = 0
+		return result
+	}
+
+	for _, p := range histogramPercentiles {
+		rank := uint64(math.Ceil(p.p * float64(total)))
+		var cumulative uint64
+		for i, c := range h.Counts {
+			cumulative += c
+			if cumulative >= rank {
+				result[p.suffix] = bucketBound(h.Buckets, i)
+				break
+			}
+		}
+	}
+
+	for i := len(h.Counts) - 1; i >= 0; i-- {
+		if h.Counts[i] > 0 {
+			result["_max"] = bucketBound(h.Buckets, i)
+			break
+		}
+	}
+
+	return result
+}
+
+// bucketBound возвращает конечную границу бакета i: верхнюю, а для бакета до +Inf - нижнюю
+func bucketBound(buckets []float64, i int) float64 {
+	upper := buckets[i+1]
+	if !math.IsInf(upper, 0) {
+		return upper
+	}
+	lower := buckets[i]
+	if math.IsInf(lower, 0) {
+		return 0
+	}
+	return lower
+}
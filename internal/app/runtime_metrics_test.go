@@ -0,0 +1,95 @@
+package app
+
+import (
+	"runtime/metrics"
+	"strings"
+	"testing"
+)
+
+// TestRuntimeMetricName тестирует преобразование имён runtime/metrics.
+func TestRuntimeMetricName(t *testing.T) {
+	tests := map[string]string{
+		"/gc/heap/allocs:bytes":             "go_gc_heap_allocs_bytes",
+		"/sched/latencies:seconds":          "go_sched_latencies_seconds",
+		"/cpu/classes/gc/total:cpu-seconds": "go_cpu_classes_gc_total_cpu_seconds",
+	}
+	for in, want := range tests {
+		if got := RuntimeMetricName(in); got != want {
+			t.Errorf("%s: ожидалось %s, получено %s", in, want, got)
+		}
+	}
+}
+
+// TestRuntimeMetricsReaderMemStatsNames тестирует наличие имён, совместимых с runtime.MemStats.
+func TestRuntimeMetricsReaderMemStatsNames(t *testing.T) {
+	values := NewRuntimeMetricsReader([]string{"none"}).Read()
+
+	required := []string{
+		"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys",
+		"HeapAlloc", "HeapIdle", "HeapInuse", "HeapObjects", "HeapReleased",
+		"HeapSys", "LastGC", "Lookups", "MCacheInuse", "MCacheSys",
+		"MSpanInuse", "MSpanSys", "Mallocs", "NextGC", "NumForcedGC",
+		"NumGC", "OtherSys", "PauseTotalNs", "StackInuse", "StackSys",
+		"Sys", "TotalAlloc",
+	}
+	for _, name := range required {
+		if _, ok := values[name]; !ok {
+			t.Errorf("Метрика %s отсутствует", name)
+		}
+	}
+
+	if len(values) != len(required) {
+		t.Errorf("С allow-list \"none\" ожидалось %d метрик, получено %d", len(required), len(values))
+	}
+
+	if values["HeapAlloc"] <= 0 || values["Sys"] < values["HeapSys"] {
+		t.Errorf("Некорректные значения памяти: HeapAlloc=%v Sys=%v HeapSys=%v", values["HeapAlloc"], values["Sys"], values["HeapSys"])
+	}
+}
+
+// TestRuntimeMetricsReaderAllowList тестирует экспорт разрешённого подмножества и гистограмм.
+func TestRuntimeMetricsReaderAllowList(t *testing.T) {
+	values := NewRuntimeMetricsReader([]string{"/gc/", "/sched/goroutines:goroutines"}).Read()
+
+	if values["go_sched_goroutines_goroutines"] < 1 {
+		t.Error("Ожидалась метрика go_sched_goroutines_goroutines")
+	}
+	for _, suffix := range []string{"_count", "_p50", "_p90", "_p99", "_max"} {
+		if _, ok := values["go_gc_pauses_seconds"+suffix]; !ok {
+			t.Errorf("Ожидалась сводка гистограммы go_gc_pauses_seconds%s", suffix)
+		}
+	}
+	for name := range values {
+		if strings.HasPrefix(name, "go_memory_") {
+			t.Errorf("Метрика %s не входит в allow-list", name)
+		}
+	}
+}
+
+// TestRuntimeMetricsReaderAll тестирует экспорт всех поддерживаемых метрик по умолчанию.
+func TestRuntimeMetricsReaderAll(t *testing.T) {
+	values := NewRuntimeMetricsReader(nil).Read()
+	for _, desc := range metrics.All() {
+		if desc.Kind != metrics.KindUint64 && desc.Kind != metrics.KindFloat64 {
+			continue
+		}
+		if _, ok := values[RuntimeMetricName(desc.Name)]; !ok {
+			t.Errorf("Метрика %s не экспортирована", desc.Name)
+		}
+	}
+}
+
+// TestSummarizeHistogram тестирует сводку гистограммы.
+func TestSummarizeHistogram(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{5, 4, 1, 0},
+		Buckets: []float64{0, 1, 2, 3, 4},
+	}
+	summary := summarizeHistogram(h)
+	expected := map[string]float64{"_count": 10, "_p50": 1, "_p90": 2, "_p99": 3, "_max": 3}
+	for suffix, want := range expected {
+		if summary[suffix] != want {
+			t.Errorf("%s: ожидалось %v, получено %v", suffix, want, summary[suffix])
+		}
+	}
+}
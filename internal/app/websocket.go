@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+	"github.com/ViktorBystrov72/go-metrics/internal/wsproto"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsAckTimeout время ожидания подтверждения батча
+	wsAckTimeout = 10 * time.Second
+	// wsDialTimeout таймаут установки соединения
+	wsDialTimeout = 10 * time.Second
+)
+
+// errWSClosed возвращается при отправке через закрытый клиент
+var errWSClosed = errors.New("websocket client closed")
+
+// wsClient держит одно WebSocket соединение с сервером и сопоставляет батчи
+// с подтверждениями по порядковому номеру. При обрыве соединения ожидающие батчи
+// завершаются retriable ошибкой, а следующая отправка устанавливает соединение заново.
+type wsClient struct {
+	url    string
+	header http.Header
+	dialer *websocket.Dialer
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	seq     uint64
+	pending map[uint64]chan wsproto.Ack
+	closed  bool
+}
+
+// newWSClient создаёт клиент для сервера с адресом вида http://host:port
+func newWSClient(address, agentToken string) *wsClient {
+	url := strings.TrimSuffix(address, "/") + wsproto.Path
+	switch {
+	case strings.HasPrefix(url, "https://"):
+		url = "wss://" + strings.TrimPrefix(url, "https://")
+	case strings.HasPrefix(url, "http://"):
+		url = "ws://" + strings.TrimPrefix(url, "http://")
+	}
+
+	header := http.Header{}
+	if agentToken != "" {
+		header.Set(middleware.AgentIDHeader, agentToken)
+	}
+
+	return &wsClient{
+		url:     url,
+		header:  header,
+		dialer:  &websocket.Dialer{HandshakeTimeout: wsDialTimeout},
+		pending: make(map[uint64]chan wsproto.Ack),
+	}
+}
+
+// connect возвращает текущее соединение или устанавливает новое
+func (c *wsClient) connect(ctx context.Context) (*websocket.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errWSClosed
+	}
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	conn, resp, err := c.dialer.DialContext(ctx, c.url, c.header)
+	if err != nil {
+		if resp != nil {
+			// Сервер ответил, но не перешёл на WebSocket: классифицируем по коду ответа
+			if statusErr := utils.CheckHTTPResponse(resp); statusErr != nil {
+				return nil, fmt.Errorf("websocket dial: %w", statusErr)
+			}
+		}
+		return nil, utils.NewRetriableError(fmt.Errorf("websocket dial: %w", err))
+	}
+
+	c.conn = conn
+	go c.readLoop(conn)
+	return conn, nil
+}
+
+// readLoop читает подтверждения и передаёт их ожидающим отправкам
+func (c *wsClient) readLoop(conn *websocket.Conn) {
+	for {
+		var ack wsproto.Ack
+		if err := conn.ReadJSON(&ack); err != nil {
+			c.drop(conn, err)
+			return
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[ack.Seq]
+		delete(c.pending, ack.Seq)
+		c.mu.Unlock()
+		if ok {
+			ch <- ack
+		}
+	}
+}
+
+// drop закрывает соединение и завершает ожидающие отправки ошибкой
+func (c *wsClient) drop(conn *websocket.Conn, reason error) {
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+		for seq, ch := range c.pending {
+			ch <- wsproto.Ack{Seq: seq, Status: wsproto.StatusError, Error: fmt.Sprintf("connection lost: %v", reason)}
+			delete(c.pending, seq)
+		}
+	}
+	c.mu.Unlock()
+	conn.Close()
+}
+
+// send отправляет батч и ожидает подтверждение
+func (c *wsClient) send(ctx context.Context, batch wsproto.Batch) error {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan wsproto.Ack, 1)
+	c.mu.Lock()
+	c.seq++
+	batch.Seq = c.seq
+	c.pending[batch.Seq] = ch
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	_ = conn.SetWriteDeadline(time.Now().Add(wsAckTimeout))
+	err = conn.WriteJSON(batch)
+	c.writeMu.Unlock()
+	if err != nil {
+		c.drop(conn, err)
+		return utils.NewRetriableError(fmt.Errorf("websocket write: %w", err))
+	}
+
+	timer := time.NewTimer(wsAckTimeout)
+	defer timer.Stop()
+
+	select {
+	case ack := <-ch:
+		return ackError(ack)
+	case <-timer.C:
+		c.forget(batch.Seq)
+		return utils.NewRetriableError(fmt.Errorf("websocket: no ack for batch %d within %v", batch.Seq, wsAckTimeout))
+	case <-ctx.Done():
+		c.forget(batch.Seq)
+		return fmt.Errorf("websocket: %w", ctx.Err())
+	}
+}
+
+// forget удаляет ожидание подтверждения
+func (c *wsClient) forget(seq uint64) {
+	c.mu.Lock()
+	delete(c.pending, seq)
+	c.mu.Unlock()
+}
+
+// ackError преобразует подтверждение сервера в классифицированную ошибку
+func ackError(ack wsproto.Ack) error {
+	switch ack.Status {
+	case wsproto.StatusOK:
+		return nil
+	case wsproto.StatusThrottled:
+		return utils.NewThrottledError(fmt.Errorf("batch %d throttled", ack.Seq), time.Duration(ack.RetryAfterMs)*time.Millisecond)
+	case wsproto.StatusRejected:
+		return utils.NewPermanentError(fmt.Errorf("batch %d rejected: %s", ack.Seq, ack.Error))
+	default:
+		return utils.NewRetriableError(fmt.Errorf("batch %d failed: %s", ack.Seq, ack.Error))
+	}
+}
+
+// Close закрывает соединение. Последующие отправки завершаются ошибкой.
+func (c *wsClient) Close() {
+	c.mu.Lock()
+	c.closed = true
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+	c.writeMu.Lock()
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	c.drop(conn, errWSClosed)
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+	"github.com/ViktorBystrov72/go-metrics/internal/wsproto"
+	"github.com/gorilla/websocket"
+)
+
+// wsBatchServer тестовый WebSocket сервер, подтверждающий батчи статусом из ackStatus.
+// Если dropNext установлен, соединение закрывается вместо подтверждения очередного батча.
+type wsBatchServer struct {
+	*httptest.Server
+	received    atomic.Int64
+	connections atomic.Int64
+	dropNext    atomic.Bool
+	ackStatus   atomic.Value
+	agentToken  atomic.Value
+}
+
+func newWSBatchServer(t *testing.T) *wsBatchServer {
+	t.Helper()
+	ws := &wsBatchServer{}
+	ws.ackStatus.Store(wsproto.StatusOK)
+	upgrader := websocket.Upgrader{}
+
+	ws.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != wsproto.Path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ws.agentToken.Store(r.Header.Get(middleware.AgentIDHeader))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ws.connections.Add(1)
+
+		for {
+			var batch wsproto.Batch
+			if err := conn.ReadJSON(&batch); err != nil {
+				return
+			}
+			if ws.dropNext.CompareAndSwap(true, false) {
+				return
+			}
+
+			ack := wsproto.Ack{Seq: batch.Seq, Status: ws.ackStatus.Load().(string)}
+			body, err := wsproto.DecodePayload(batch, nil)
+			var metrics []models.Metrics
+			if err == nil {
+				err = json.Unmarshal(body, &metrics)
+			}
+			if err != nil {
+				ack.Status = wsproto.StatusRejected
+				ack.Error = err.Error()
+			}
+			if ack.Status == wsproto.StatusThrottled {
+				ack.RetryAfterMs = 2000
+			}
+			if ack.Status == wsproto.StatusOK {
+				ws.received.Add(1)
+			}
+			if err := conn.WriteJSON(ack); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ws.Close)
+	return ws
+}
+
+func newWSSender(address string) *MetricsSender {
+	return NewMetricsSender(&AgentConfig{
+		RateLimit: 1,
+		Key:       "common-key",
+		AgentID:   "agent-1",
+		Address:   address,
+		Transport: TransportWebSocket,
+	}).(*MetricsSender)
+}
+
+// TestSendMetricsBatchWebSocket тестирует отправку батчей через одно соединение.
+func TestSendMetricsBatchWebSocket(t *testing.T) {
+	server := newWSBatchServer(t)
+	sender := newWSSender(server.URL)
+	sender.retryConfig = noRetry
+	defer sender.Stop()
+
+	for i := 0; i < 3; i++ {
+		if err := sender.SendMetricsBatch(testBatch()); err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+	}
+	if server.received.Load() != 3 {
+		t.Errorf("Ожидалось 3 батча, получено %d", server.received.Load())
+	}
+	if server.connections.Load() != 1 {
+		t.Errorf("Батчи должны отправляться через одно соединение, открыто %d", server.connections.Load())
+	}
+	if token := server.agentToken.Load(); token != utils.AgentToken("agent-1", "common-key") {
+		t.Errorf("Ожидался заголовок %s с подписанным идентификатором агента, получено %v", middleware.AgentIDHeader, token)
+	}
+}
+
+// TestSendMetricsBatchWebSocketReconnect тестирует повторную отправку после обрыва соединения.
+func TestSendMetricsBatchWebSocketReconnect(t *testing.T) {
+	server := newWSBatchServer(t)
+	sender := newWSSender(server.URL)
+	sender.retryConfig = utils.RetryConfig{MaxAttempts: 2, Delays: []time.Duration{10 * time.Millisecond}}
+	defer sender.Stop()
+
+	if err := sender.SendMetricsBatch(testBatch()); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+
+	server.dropNext.Store(true)
+	if err := sender.SendMetricsBatch(testBatch()); err != nil {
+		t.Fatalf("Батч должен быть отправлен после переподключения: %v", err)
+	}
+	if server.received.Load() != 2 {
+		t.Errorf("Ожидалось 2 подтверждённых батча, получено %d", server.received.Load())
+	}
+	if server.connections.Load() != 2 {
+		t.Errorf("Ожидалось переподключение, открыто соединений %d", server.connections.Load())
+	}
+}
+
+// TestSendMetricsBatchWebSocketAckStatus тестирует классификацию подтверждений сервера.
+func TestSendMetricsBatchWebSocketAckStatus(t *testing.T) {
+	tests := []struct {
+		status    string
+		wantClass utils.ErrorClass
+	}{
+		{status: wsproto.StatusRejected, wantClass: utils.ErrorClassPermanent},
+		{status: wsproto.StatusError, wantClass: utils.ErrorClassRetriable},
+		{status: wsproto.StatusThrottled, wantClass: utils.ErrorClassThrottled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			server := newWSBatchServer(t)
+			server.ackStatus.Store(tt.status)
+			sender := newWSSender(server.URL)
+			sender.retryConfig = noRetry
+			defer sender.Stop()
+
+			err := sender.sendToEndpoint(sender.endpoints[0], testBatch())
+			if err == nil {
+				t.Fatal("Ожидалась ошибка")
+			}
+			if class := utils.ClassifyError(err); class != tt.wantClass {
+				t.Errorf("Ожидался класс %v, получен %v: %v", tt.wantClass, class, err)
+			}
+			if tt.status == wsproto.StatusThrottled && utils.RetryAfter(err) != 2*time.Second {
+				t.Errorf("Ожидалась задержка 2s, получено %v", utils.RetryAfter(err))
+			}
+		})
+	}
+}
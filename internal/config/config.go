@@ -5,6 +5,19 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/alerting"
+	"github.com/ViktorBystrov72/go-metrics/internal/export"
+	"github.com/ViktorBystrov72/go-metrics/internal/influx"
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/otlp"
+	"github.com/ViktorBystrov72/go-metrics/internal/rates"
+	"github.com/ViktorBystrov72/go-metrics/internal/rollup"
+	"github.com/ViktorBystrov72/go-metrics/internal/server"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+	"github.com/ViktorBystrov72/go-metrics/internal/webhook"
 )
 
 // Config содержит конфигурацию сервера
@@ -16,6 +29,58 @@ type Config struct {
 	DatabaseDSN     string
 	Key             string
 	CryptoKey       string
+	// AdminToken токен административного API, отдельный от ключа подписи метрик
+	AdminToken string
+
+	// Ограничение частоты приёма метрик
+	IngestRateLimit   float64
+	IngestBurst       int
+	MaxInFlight       int
+	RateLimitIdentity string
+
+	// Поток обновлений метрик /api/v1/stream
+	StreamBuffer     int
+	StreamDropPolicy string
+
+	// TTL метрик по типам, 0 - метрики не удаляются
+	GaugeTTL   time.Duration
+	CounterTTL time.Duration
+
+	// BatchMode режим применения батча /updates/: atomic или best-effort
+	BatchMode string
+
+	// Приём метрик OTLP: перенос атрибутов ресурса метками (labels) или префиксом имени (prefix)
+	OTLPResourceMode string
+	// OTLPResourceAttributes ключи переносимых атрибутов ресурса, nil - набор по умолчанию
+	OTLPResourceAttributes []string
+
+	// InfluxIntegerMode запись целочисленных полей line protocol: gauge или counter
+	InfluxIntegerMode string
+
+	// Приём метрик вне HTTP: Graphite plaintext по TCP и StatsD по UDP, пустой адрес - выключен
+	GraphiteAddress string
+	StatsDAddress   string
+	// ListenerFlushInterval интервал записи метрик Graphite и StatsD в хранилище
+	ListenerFlushInterval time.Duration
+
+	// RulesFile файл правил алертов в формате JSON, пустой - алерты выключены
+	RulesFile string
+	// RulesInterval период проверки правил алертов
+	RulesInterval time.Duration
+
+	// RateSampleInterval период снятия значений counter метрик для расчёта rate и increase
+	RateSampleInterval time.Duration
+	// RateRetention время хранения значений, ограничивает максимальное окно расчёта
+	RateRetention time.Duration
+
+	// Rollups снятие значений метрик и агрегация по tier 1m, 1h и 1d
+	Rollups rollup.Config
+
+	// Exporters периодическая выгрузка метрик во внешние системы, задаётся только в JSON файле
+	Exporters []export.Config
+
+	// Webhooks отправка принятых обновлений метрик на HTTP адреса, задаётся только в JSON файле
+	Webhooks []webhook.Config
 }
 
 type serverFlagValues struct {
@@ -26,7 +91,40 @@ type serverFlagValues struct {
 	databaseDSN     string
 	key             string
 	cryptoKey       string
+	adminToken      string
 	configFile      string
+
+	ingestRateLimit   float64
+	ingestBurst       int
+	maxInFlight       int
+	rateLimitIdentity string
+
+	streamBuffer     int
+	streamDropPolicy string
+
+	gaugeTTL   string
+	counterTTL string
+
+	batchMode string
+
+	otlpResourceMode       string
+	otlpResourceAttributes string
+
+	influxIntegerMode string
+
+	graphiteAddress       string
+	statsdAddress         string
+	listenerFlushInterval string
+
+	rulesFile     string
+	rulesInterval string
+
+	rateSampleInterval string
+	rateRetention      string
+
+	rollupsEnabled       bool
+	rollupSampleInterval string
+	rollupRetention      string
 }
 
 func parseServerFlags() (*serverFlagValues, error) {
@@ -40,6 +138,29 @@ func parseServerFlags() (*serverFlagValues, error) {
 	fs.StringVar(&flags.databaseDSN, "d", "", "database DSN")
 	fs.StringVar(&flags.key, "k", "", "signature key")
 	fs.StringVar(&flags.cryptoKey, "crypto-key", "", "path to private key file for decryption")
+	fs.StringVar(&flags.adminToken, "admin-token", "", "bearer token for admin API (delete and reset metrics)")
+	fs.Float64Var(&flags.ingestRateLimit, "ingest-rate", 0, "ingestion requests per second per client (0 - unlimited)")
+	fs.IntVar(&flags.ingestBurst, "ingest-burst", 0, "ingestion burst size per client")
+	fs.IntVar(&flags.maxInFlight, "max-inflight", 0, "max concurrent ingestion requests (0 - unlimited)")
+	fs.StringVar(&flags.rateLimitIdentity, "rate-limit-identity", "", "client identity for rate limiting: ip, key or header:<Name>")
+	fs.IntVar(&flags.streamBuffer, "stream-buffer", 0, "per-subscriber buffer size for /api/v1/stream")
+	fs.StringVar(&flags.streamDropPolicy, "stream-drop-policy", "", "stream buffer overflow policy: drop-oldest, drop-newest or disconnect")
+	fs.StringVar(&flags.gaugeTTL, "gauge-ttl", "", "remove gauges not updated for this duration, e.g. 1h (empty - never)")
+	fs.StringVar(&flags.counterTTL, "counter-ttl", "", "remove counters not updated for this duration, e.g. 24h (empty - never)")
+	fs.StringVar(&flags.batchMode, "batch-mode", "", "how /updates/ applies batches with invalid metrics: atomic or best-effort")
+	fs.StringVar(&flags.otlpResourceMode, "otlp-resource-mode", "", "how OTLP resource attributes are added to metrics: labels or prefix")
+	fs.StringVar(&flags.otlpResourceAttributes, "otlp-resource-attributes", "", "comma-separated OTLP resource attributes added to metrics")
+	fs.StringVar(&flags.influxIntegerMode, "influx-integer-mode", "", "how integer line protocol fields are stored: gauge or counter")
+	fs.StringVar(&flags.graphiteAddress, "graphite-address", "", "TCP address to receive Graphite plaintext metrics (empty - disabled)")
+	fs.StringVar(&flags.statsdAddress, "statsd-address", "", "UDP address to receive StatsD metrics (empty - disabled)")
+	fs.StringVar(&flags.listenerFlushInterval, "listener-flush-interval", "", "how often Graphite and StatsD metrics are written to storage, e.g. 10s")
+	fs.StringVar(&flags.rulesFile, "rules-file", "", "alerting rules file in JSON (empty - alerting disabled)")
+	fs.StringVar(&flags.rulesInterval, "rules-interval", "", "how often alerting rules are evaluated, e.g. 15s")
+	fs.StringVar(&flags.rateSampleInterval, "rate-sample-interval", "", "how often counter values are sampled for rate and increase, e.g. 10s")
+	fs.StringVar(&flags.rateRetention, "rate-retention", "", "how long counter samples are kept, limits the rate window, e.g. 1h")
+	fs.BoolVar(&flags.rollupsEnabled, "rollups", false, "enable metric sampling and rollups by tier 1m, 1h and 1d")
+	fs.StringVar(&flags.rollupSampleInterval, "rollup-sample-interval", "", "how often metric values are sampled for rollups, e.g. 10s")
+	fs.StringVar(&flags.rollupRetention, "rollup-retention", "", "retention per rollup tier, e.g. raw=1h,1m=24h,1h=720h,1d=8760h")
 	fs.StringVar(&flags.configFile, "c", "", "config file path")
 	fs.StringVar(&flags.configFile, "config", "", "config file path")
 
@@ -107,9 +228,114 @@ func applyServerEnvironmentVariables(jsonConfig *ServerJSONConfig, flags *server
 		flags.key = envKey
 	}
 
+	if envAdminToken := os.Getenv("ADMIN_TOKEN"); envAdminToken != "" {
+		// ADMIN_TOKEN, как и KEY, не поддерживается в JSON, применяем к флагам
+		flags.adminToken = envAdminToken
+	}
+
 	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
 		jsonConfig.CryptoKey = stringPtr(envCryptoKey)
 	}
+
+	if envRate := os.Getenv("INGEST_RATE_LIMIT"); envRate != "" {
+		if rate, err := strconv.ParseFloat(envRate, 64); err == nil {
+			jsonConfig.IngestRateLimit = &rate
+		}
+	}
+
+	if envBurst := os.Getenv("INGEST_BURST"); envBurst != "" {
+		if burst, err := strconv.Atoi(envBurst); err == nil {
+			jsonConfig.IngestBurst = &burst
+		}
+	}
+
+	if envMaxInFlight := os.Getenv("MAX_INFLIGHT"); envMaxInFlight != "" {
+		if maxInFlight, err := strconv.Atoi(envMaxInFlight); err == nil {
+			jsonConfig.MaxInFlight = &maxInFlight
+		}
+	}
+
+	if envIdentity := os.Getenv("RATE_LIMIT_IDENTITY"); envIdentity != "" {
+		jsonConfig.RateLimitIdentity = stringPtr(envIdentity)
+	}
+
+	if envStreamBuffer := os.Getenv("STREAM_BUFFER"); envStreamBuffer != "" {
+		if buffer, err := strconv.Atoi(envStreamBuffer); err == nil {
+			jsonConfig.StreamBuffer = &buffer
+		}
+	}
+
+	if envDropPolicy := os.Getenv("STREAM_DROP_POLICY"); envDropPolicy != "" {
+		jsonConfig.StreamDropPolicy = stringPtr(envDropPolicy)
+	}
+
+	if envGaugeTTL := os.Getenv("GAUGE_TTL"); envGaugeTTL != "" {
+		jsonConfig.GaugeTTL = stringPtr(envGaugeTTL)
+	}
+
+	if envCounterTTL := os.Getenv("COUNTER_TTL"); envCounterTTL != "" {
+		jsonConfig.CounterTTL = stringPtr(envCounterTTL)
+	}
+
+	if envBatchMode := os.Getenv("BATCH_MODE"); envBatchMode != "" {
+		jsonConfig.BatchMode = stringPtr(envBatchMode)
+	}
+
+	if envMode := os.Getenv("OTLP_RESOURCE_MODE"); envMode != "" {
+		jsonConfig.OTLPResourceMode = stringPtr(envMode)
+	}
+
+	if envAttributes, ok := os.LookupEnv("OTLP_RESOURCE_ATTRIBUTES"); ok {
+		jsonConfig.OTLPResourceAttributes = splitList(envAttributes)
+	}
+
+	if envIntegerMode := os.Getenv("INFLUX_INTEGER_MODE"); envIntegerMode != "" {
+		jsonConfig.InfluxIntegerMode = stringPtr(envIntegerMode)
+	}
+
+	if envGraphite := os.Getenv("GRAPHITE_ADDRESS"); envGraphite != "" {
+		jsonConfig.GraphiteAddress = stringPtr(envGraphite)
+	}
+
+	if envStatsD := os.Getenv("STATSD_ADDRESS"); envStatsD != "" {
+		jsonConfig.StatsDAddress = stringPtr(envStatsD)
+	}
+
+	if envFlush := os.Getenv("LISTENER_FLUSH_INTERVAL"); envFlush != "" {
+		jsonConfig.ListenerFlushInterval = stringPtr(envFlush)
+	}
+
+	if envRules := os.Getenv("RULES_FILE"); envRules != "" {
+		jsonConfig.RulesFile = stringPtr(envRules)
+	}
+
+	if envRulesInterval := os.Getenv("RULES_INTERVAL"); envRulesInterval != "" {
+		jsonConfig.RulesInterval = stringPtr(envRulesInterval)
+	}
+
+	if envRateSample := os.Getenv("RATE_SAMPLE_INTERVAL"); envRateSample != "" {
+		jsonConfig.RateSampleInterval = stringPtr(envRateSample)
+	}
+
+	if envRateRetention := os.Getenv("RATE_RETENTION"); envRateRetention != "" {
+		jsonConfig.RateRetention = stringPtr(envRateRetention)
+	}
+
+	if envRollups := os.Getenv("ROLLUPS_ENABLED"); envRollups != "" {
+		if envRollups == "true" || envRollups == "1" {
+			jsonConfig.RollupsEnabled = boolPtr(true)
+		} else if envRollups == "false" || envRollups == "0" {
+			jsonConfig.RollupsEnabled = boolPtr(false)
+		}
+	}
+
+	if envRollupSample := os.Getenv("ROLLUP_SAMPLE_INTERVAL"); envRollupSample != "" {
+		jsonConfig.RollupSampleInterval = stringPtr(envRollupSample)
+	}
+
+	if envRollupRetention := os.Getenv("ROLLUP_RETENTION"); envRollupRetention != "" {
+		jsonConfig.RollupRetention = splitPairs(envRollupRetention)
+	}
 }
 
 func applyServerFlags(flags *serverFlagValues) *ServerJSONConfig {
@@ -141,13 +367,80 @@ func applyServerFlags(flags *serverFlagValues) *ServerJSONConfig {
 	if flags.cryptoKey != "" {
 		finalConfig.CryptoKey = stringPtr(flags.cryptoKey)
 	}
+	if flags.ingestRateLimit != 0 {
+		finalConfig.IngestRateLimit = &flags.ingestRateLimit
+	}
+	if flags.ingestBurst != 0 {
+		finalConfig.IngestBurst = &flags.ingestBurst
+	}
+	if flags.maxInFlight != 0 {
+		finalConfig.MaxInFlight = &flags.maxInFlight
+	}
+	if flags.rateLimitIdentity != "" {
+		finalConfig.RateLimitIdentity = stringPtr(flags.rateLimitIdentity)
+	}
+	if flags.streamBuffer != 0 {
+		finalConfig.StreamBuffer = &flags.streamBuffer
+	}
+	if flags.streamDropPolicy != "" {
+		finalConfig.StreamDropPolicy = stringPtr(flags.streamDropPolicy)
+	}
+	if flags.gaugeTTL != "" {
+		finalConfig.GaugeTTL = stringPtr(flags.gaugeTTL)
+	}
+	if flags.counterTTL != "" {
+		finalConfig.CounterTTL = stringPtr(flags.counterTTL)
+	}
+	if flags.batchMode != "" {
+		finalConfig.BatchMode = stringPtr(flags.batchMode)
+	}
+	if flags.otlpResourceMode != "" {
+		finalConfig.OTLPResourceMode = stringPtr(flags.otlpResourceMode)
+	}
+	if flags.otlpResourceAttributes != "" {
+		finalConfig.OTLPResourceAttributes = splitList(flags.otlpResourceAttributes)
+	}
+	if flags.influxIntegerMode != "" {
+		finalConfig.InfluxIntegerMode = stringPtr(flags.influxIntegerMode)
+	}
+	if flags.graphiteAddress != "" {
+		finalConfig.GraphiteAddress = stringPtr(flags.graphiteAddress)
+	}
+	if flags.statsdAddress != "" {
+		finalConfig.StatsDAddress = stringPtr(flags.statsdAddress)
+	}
+	if flags.listenerFlushInterval != "" {
+		finalConfig.ListenerFlushInterval = stringPtr(flags.listenerFlushInterval)
+	}
+	if flags.rulesFile != "" {
+		finalConfig.RulesFile = stringPtr(flags.rulesFile)
+	}
+	if flags.rulesInterval != "" {
+		finalConfig.RulesInterval = stringPtr(flags.rulesInterval)
+	}
+	if flags.rateSampleInterval != "" {
+		finalConfig.RateSampleInterval = stringPtr(flags.rateSampleInterval)
+	}
+	if flags.rateRetention != "" {
+		finalConfig.RateRetention = stringPtr(flags.rateRetention)
+	}
+	if flags.rollupsEnabled {
+		finalConfig.RollupsEnabled = boolPtr(true)
+	}
+	if flags.rollupSampleInterval != "" {
+		finalConfig.RollupSampleInterval = stringPtr(flags.rollupSampleInterval)
+	}
+	if flags.rollupRetention != "" {
+		finalConfig.RollupRetention = splitPairs(flags.rollupRetention)
+	}
 
 	return finalConfig
 }
 
 func buildServerConfig(finalConfig *ServerJSONConfig, flags *serverFlagValues) (*Config, error) {
 	result := &Config{
-		Key: flags.key, // KEY не поддерживается в JSON
+		Key:        flags.key,        // KEY не поддерживается в JSON
+		AdminToken: flags.adminToken, // ADMIN_TOKEN не поддерживается в JSON
 	}
 
 	// Обрабатываем значения с дефолтами
@@ -187,13 +480,269 @@ func buildServerConfig(finalConfig *ServerJSONConfig, flags *serverFlagValues) (
 		result.CryptoKey = *finalConfig.CryptoKey
 	}
 
+	if finalConfig.IngestRateLimit != nil {
+		result.IngestRateLimit = *finalConfig.IngestRateLimit
+	}
+	if finalConfig.IngestBurst != nil {
+		result.IngestBurst = *finalConfig.IngestBurst
+	}
+	if finalConfig.MaxInFlight != nil {
+		result.MaxInFlight = *finalConfig.MaxInFlight
+	}
+	if finalConfig.RateLimitIdentity != nil {
+		result.RateLimitIdentity = *finalConfig.RateLimitIdentity
+	}
+
+	if finalConfig.StreamBuffer != nil {
+		result.StreamBuffer = *finalConfig.StreamBuffer
+	}
+	if finalConfig.StreamDropPolicy != nil {
+		result.StreamDropPolicy = *finalConfig.StreamDropPolicy
+	}
+
+	if finalConfig.GaugeTTL != nil {
+		ttl, err := time.ParseDuration(*finalConfig.GaugeTTL)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный gauge_ttl: %w", err)
+		}
+		result.GaugeTTL = ttl
+	}
+	if finalConfig.CounterTTL != nil {
+		ttl, err := time.ParseDuration(*finalConfig.CounterTTL)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный counter_ttl: %w", err)
+		}
+		result.CounterTTL = ttl
+	}
+
+	if finalConfig.BatchMode != nil {
+		result.BatchMode = *finalConfig.BatchMode
+	} else {
+		result.BatchMode = server.BatchModeAtomic
+	}
+
+	if finalConfig.OTLPResourceMode != nil {
+		result.OTLPResourceMode = *finalConfig.OTLPResourceMode
+	} else {
+		result.OTLPResourceMode = otlp.ResourceLabels
+	}
+	result.OTLPResourceAttributes = finalConfig.OTLPResourceAttributes
+
+	if finalConfig.InfluxIntegerMode != nil {
+		result.InfluxIntegerMode = *finalConfig.InfluxIntegerMode
+	} else {
+		result.InfluxIntegerMode = influx.IntegerGauge
+	}
+
+	if finalConfig.GraphiteAddress != nil {
+		result.GraphiteAddress = *finalConfig.GraphiteAddress
+	}
+	if finalConfig.StatsDAddress != nil {
+		result.StatsDAddress = *finalConfig.StatsDAddress
+	}
+	result.ListenerFlushInterval = server.DefaultListenerFlushInterval
+	if finalConfig.ListenerFlushInterval != nil {
+		interval, err := time.ParseDuration(*finalConfig.ListenerFlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный listener_flush_interval: %w", err)
+		}
+		result.ListenerFlushInterval = interval
+	}
+
+	if finalConfig.RulesFile != nil {
+		result.RulesFile = *finalConfig.RulesFile
+	}
+	result.RulesInterval = alerting.DefaultInterval
+	if finalConfig.RulesInterval != nil {
+		interval, err := time.ParseDuration(*finalConfig.RulesInterval)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный rules_interval: %w", err)
+		}
+		result.RulesInterval = interval
+	}
+
+	result.RateSampleInterval = rates.DefaultSampleInterval
+	if finalConfig.RateSampleInterval != nil {
+		interval, err := time.ParseDuration(*finalConfig.RateSampleInterval)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный rate_sample_interval: %w", err)
+		}
+		result.RateSampleInterval = interval
+	}
+	result.RateRetention = rates.DefaultRetention
+	if finalConfig.RateRetention != nil {
+		retention, err := time.ParseDuration(*finalConfig.RateRetention)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный rate_retention: %w", err)
+		}
+		result.RateRetention = retention
+	}
+
+	if finalConfig.RollupsEnabled != nil {
+		result.Rollups.Enabled = *finalConfig.RollupsEnabled
+	}
+	result.Rollups.SampleInterval = rollup.DefaultSampleInterval
+	if finalConfig.RollupSampleInterval != nil {
+		interval, err := time.ParseDuration(*finalConfig.RollupSampleInterval)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный rollup_sample_interval: %w", err)
+		}
+		result.Rollups.SampleInterval = interval
+	}
+	for tier, value := range finalConfig.RollupRetention {
+		retention, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный rollup_retention.%s: %w", tier, err)
+		}
+		if result.Rollups.Retention == nil {
+			result.Rollups.Retention = make(map[string]time.Duration)
+		}
+		result.Rollups.Retention[tier] = retention
+	}
+
+	for i, e := range finalConfig.Exporters {
+		exporter, err := exporterConfig(e)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный exporters[%d]: %w", i, err)
+		}
+		result.Exporters = append(result.Exporters, exporter)
+	}
+
+	for i, w := range finalConfig.Webhooks {
+		hook, err := webhookConfig(w)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный webhooks[%d]: %w", i, err)
+		}
+		result.Webhooks = append(result.Webhooks, hook)
+	}
+
 	return result, nil
 }
 
+// exporterConfig преобразует JSON настройки экспортёра, разбирая длительности
+func exporterConfig(e ExporterJSONConfig) (export.Config, error) {
+	cfg := export.Config{
+		Name:    e.Name,
+		Type:    e.Type,
+		Address: e.Address,
+		Prefix:  e.Prefix,
+		Filter: storage.MetricsQuery{
+			Types:      e.Filter.Types,
+			NamePrefix: e.Filter.Prefix,
+			NameRegex:  e.Filter.Regex,
+			Labels:     e.Filter.Labels,
+		},
+		QueueSize:   e.QueueSize,
+		MaxAttempts: e.MaxAttempts,
+	}
+
+	var err error
+	if e.Interval != "" {
+		if cfg.Interval, err = time.ParseDuration(e.Interval); err != nil {
+			return cfg, fmt.Errorf("interval: %w", err)
+		}
+	}
+	if e.Timeout != "" {
+		if cfg.Timeout, err = time.ParseDuration(e.Timeout); err != nil {
+			return cfg, fmt.Errorf("timeout: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// webhookConfig преобразует JSON настройки webhook, разбирая длительности
+func webhookConfig(w WebhookJSONConfig) (webhook.Config, error) {
+	cfg := webhook.Config{
+		Name:           w.Name,
+		URL:            w.URL,
+		Filter:         stream.Filter{Names: w.Filter.Names, Types: w.Filter.Types},
+		Secret:         w.Secret,
+		BatchSize:      w.BatchSize,
+		QueueSize:      w.QueueSize,
+		MaxAttempts:    w.MaxAttempts,
+		DeadLetterFile: w.DeadLetterFile,
+	}
+
+	var err error
+	if w.BatchInterval != "" {
+		if cfg.BatchInterval, err = time.ParseDuration(w.BatchInterval); err != nil {
+			return cfg, fmt.Errorf("batch_interval: %w", err)
+		}
+	}
+	if w.Timeout != "" {
+		if cfg.Timeout, err = time.ParseDuration(w.Timeout); err != nil {
+			return cfg, fmt.Errorf("timeout: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
 func validateServerConfig(cfg *Config) error {
 	if cfg.StoreInterval < 0 {
 		return fmt.Errorf("STORE_INTERVAL must be non-negative, got %d", cfg.StoreInterval)
 	}
+	if cfg.AdminToken != "" && cfg.AdminToken == cfg.Key {
+		return fmt.Errorf("ADMIN_TOKEN must differ from KEY: agents holding the signing key must not get admin access")
+	}
+	if cfg.IngestRateLimit < 0 {
+		return fmt.Errorf("INGEST_RATE_LIMIT must be non-negative, got %v", cfg.IngestRateLimit)
+	}
+	if cfg.IngestBurst < 0 {
+		return fmt.Errorf("INGEST_BURST must be non-negative, got %d", cfg.IngestBurst)
+	}
+	if cfg.MaxInFlight < 0 {
+		return fmt.Errorf("MAX_INFLIGHT must be non-negative, got %d", cfg.MaxInFlight)
+	}
+	if err := middleware.ValidateIdentity(cfg.RateLimitIdentity); err != nil {
+		return err
+	}
+	if cfg.StreamBuffer < 0 {
+		return fmt.Errorf("STREAM_BUFFER must be non-negative, got %d", cfg.StreamBuffer)
+	}
+	if err := stream.ValidateDropPolicy(cfg.StreamDropPolicy); err != nil {
+		return err
+	}
+	if cfg.GaugeTTL < 0 {
+		return fmt.Errorf("GAUGE_TTL must be non-negative, got %v", cfg.GaugeTTL)
+	}
+	if cfg.CounterTTL < 0 {
+		return fmt.Errorf("COUNTER_TTL must be non-negative, got %v", cfg.CounterTTL)
+	}
+	if err := server.ValidateBatchMode(cfg.BatchMode); err != nil {
+		return err
+	}
+	if err := otlp.ValidateResourceMode(cfg.OTLPResourceMode); err != nil {
+		return err
+	}
+	if err := influx.ValidateIntegerMode(cfg.InfluxIntegerMode); err != nil {
+		return err
+	}
+	if cfg.ListenerFlushInterval <= 0 {
+		return fmt.Errorf("LISTENER_FLUSH_INTERVAL must be positive, got %v", cfg.ListenerFlushInterval)
+	}
+	if cfg.RulesInterval <= 0 {
+		return fmt.Errorf("RULES_INTERVAL must be positive, got %v", cfg.RulesInterval)
+	}
+	if cfg.RateSampleInterval <= 0 {
+		return fmt.Errorf("RATE_SAMPLE_INTERVAL must be positive, got %v", cfg.RateSampleInterval)
+	}
+	if cfg.RateRetention < cfg.RateSampleInterval {
+		return fmt.Errorf("RATE_RETENTION must not be shorter than RATE_SAMPLE_INTERVAL, got %v < %v",
+			cfg.RateRetention, cfg.RateSampleInterval)
+	}
+	if err := cfg.Rollups.Validate(); err != nil {
+		return err
+	}
+	for _, e := range cfg.Exporters {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, w := range cfg.Webhooks {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
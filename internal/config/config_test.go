@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -20,3 +22,304 @@ func TestLoad(t *testing.T) {
 		t.Errorf("Load() неверно парсит переменные окружения")
 	}
 }
+
+func TestLoadAdminToken(t *testing.T) {
+	t.Setenv("KEY", "testkey")
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.AdminToken != "admin-secret" {
+		t.Errorf("Ожидался ADMIN_TOKEN admin-secret, получено %q", cfg.AdminToken)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "testkey")
+	if _, err := Load(); err == nil {
+		t.Error("Ожидалась ошибка, если ADMIN_TOKEN совпадает с KEY")
+	}
+}
+
+func TestLoadBatchMode(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.BatchMode != "atomic" {
+		t.Errorf("Ожидался режим батча по умолчанию atomic, получено %q", cfg.BatchMode)
+	}
+
+	t.Setenv("BATCH_MODE", "best-effort")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.BatchMode != "best-effort" {
+		t.Errorf("Ожидался режим best-effort, получено %q", cfg.BatchMode)
+	}
+
+	t.Setenv("BATCH_MODE", "partial")
+	if _, err := Load(); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного режима батча")
+	}
+}
+
+func TestLoadOTLP(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.OTLPResourceMode != "labels" || cfg.OTLPResourceAttributes != nil {
+		t.Errorf("Ожидались настройки OTLP по умолчанию, получено %q %v", cfg.OTLPResourceMode, cfg.OTLPResourceAttributes)
+	}
+
+	t.Setenv("OTLP_RESOURCE_MODE", "prefix")
+	t.Setenv("OTLP_RESOURCE_ATTRIBUTES", "service.name, host.name")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.OTLPResourceMode != "prefix" {
+		t.Errorf("Ожидался режим prefix, получено %q", cfg.OTLPResourceMode)
+	}
+	if len(cfg.OTLPResourceAttributes) != 2 || cfg.OTLPResourceAttributes[1] != "host.name" {
+		t.Errorf("Ожидались атрибуты service.name и host.name, получено %v", cfg.OTLPResourceAttributes)
+	}
+
+	t.Setenv("OTLP_RESOURCE_MODE", "suffix")
+	if _, err := Load(); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного режима атрибутов ресурса")
+	}
+}
+
+func TestLoadInfluxIntegerMode(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.InfluxIntegerMode != "gauge" {
+		t.Errorf("Ожидался режим по умолчанию gauge, получено %q", cfg.InfluxIntegerMode)
+	}
+
+	t.Setenv("INFLUX_INTEGER_MODE", "counter")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.InfluxIntegerMode != "counter" {
+		t.Errorf("Ожидался режим counter, получено %q", cfg.InfluxIntegerMode)
+	}
+
+	t.Setenv("INFLUX_INTEGER_MODE", "histogram")
+	if _, err := Load(); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного режима целочисленных полей")
+	}
+}
+
+func TestLoadListeners(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.GraphiteAddress != "" || cfg.StatsDAddress != "" || cfg.ListenerFlushInterval != 10*time.Second {
+		t.Errorf("По умолчанию listeners выключены с интервалом 10s, получено %q %q %v",
+			cfg.GraphiteAddress, cfg.StatsDAddress, cfg.ListenerFlushInterval)
+	}
+
+	t.Setenv("GRAPHITE_ADDRESS", ":2003")
+	t.Setenv("STATSD_ADDRESS", ":8125")
+	t.Setenv("LISTENER_FLUSH_INTERVAL", "5s")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.GraphiteAddress != ":2003" || cfg.StatsDAddress != ":8125" || cfg.ListenerFlushInterval != 5*time.Second {
+		t.Errorf("Неверные настройки listeners: %q %q %v", cfg.GraphiteAddress, cfg.StatsDAddress, cfg.ListenerFlushInterval)
+	}
+
+	t.Setenv("LISTENER_FLUSH_INTERVAL", "0s")
+	if _, err := Load(); err == nil {
+		t.Error("Ожидалась ошибка для нулевого интервала записи")
+	}
+}
+
+func TestLoadExporters(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "server.json")
+	data := `{"exporters": [
+		{"type": "graphite", "address": "graphite:2003", "interval": "30s", "prefix": "srv.",
+		 "filter": {"types": ["gauge"], "labels": {"env": "prod"}}},
+		{"name": "prom", "type": "remote_write", "address": "http://prometheus:9090/api/v1/write", "timeout": "5s"}
+	]}`
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG", file)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(cfg.Exporters) != 2 {
+		t.Fatalf("Ожидалось 2 экспортёра, получено %d", len(cfg.Exporters))
+	}
+	graphite := cfg.Exporters[0]
+	if graphite.Interval != 30*time.Second || graphite.Prefix != "srv." ||
+		graphite.Filter.Types[0] != "gauge" || graphite.Filter.Labels["env"] != "prod" {
+		t.Errorf("Неверные настройки graphite: %+v", graphite)
+	}
+	if prom := cfg.Exporters[1]; prom.Name != "prom" || prom.Timeout != 5*time.Second {
+		t.Errorf("Неверные настройки remote_write: %+v", prom)
+	}
+
+	for _, bad := range []string{
+		`{"exporters": [{"type": "graphite", "address": "graphite:2003", "interval": "soon"}]}`,
+		`{"exporters": [{"type": "influx", "address": "influx:8086"}]}`,
+		`{"exporters": [{"type": "opentsdb", "address": "tsdb:4242"}]}`,
+	} {
+		if err := os.WriteFile(file, []byte(bad), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(); err == nil {
+			t.Errorf("Ожидалась ошибка для %s", bad)
+		}
+	}
+}
+
+func TestLoadWebhooks(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "server.json")
+	data := `{"webhooks": [
+		{"name": "alerts", "url": "https://hooks.example.com/metrics", "secret": "s3cret",
+		 "filter": {"names": ["Heap*"], "types": ["gauge"]}, "batch_size": 10, "batch_interval": "2s",
+		 "dead_letter_file": "/var/log/metrics/webhooks.jsonl"}
+	]}`
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG", file)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(cfg.Webhooks) != 1 {
+		t.Fatalf("Ожидался один webhook, получено %d", len(cfg.Webhooks))
+	}
+	hook := cfg.Webhooks[0]
+	if hook.Name != "alerts" || hook.Secret != "s3cret" || hook.BatchSize != 10 || hook.BatchInterval != 2*time.Second ||
+		hook.Filter.Names[0] != "Heap*" || hook.Filter.Types[0] != "gauge" || hook.DeadLetterFile == "" {
+		t.Errorf("Неверные настройки webhook: %+v", hook)
+	}
+
+	for _, bad := range []string{
+		`{"webhooks": [{"url": "https://hooks.example.com", "batch_interval": "often"}]}`,
+		`{"webhooks": [{"url": "hooks.example.com"}]}`,
+	} {
+		if err := os.WriteFile(file, []byte(bad), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(); err == nil {
+			t.Errorf("Ожидалась ошибка для %s", bad)
+		}
+	}
+}
+
+func TestLoadRulesSettings(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.RulesFile != "" || cfg.RulesInterval != 15*time.Second {
+		t.Errorf("По умолчанию алерты выключены с интервалом 15s, получено %q %v", cfg.RulesFile, cfg.RulesInterval)
+	}
+
+	t.Setenv("RULES_FILE", "/etc/metrics/rules.json")
+	t.Setenv("RULES_INTERVAL", "30s")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.RulesFile != "/etc/metrics/rules.json" || cfg.RulesInterval != 30*time.Second {
+		t.Errorf("Неверные настройки алертов: %q %v", cfg.RulesFile, cfg.RulesInterval)
+	}
+
+	t.Setenv("RULES_INTERVAL", "-1s")
+	if _, err := Load(); err == nil {
+		t.Error("Ожидалась ошибка для отрицательного интервала проверки правил")
+	}
+}
+
+func TestLoadRateSettings(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.RateSampleInterval != 10*time.Second || cfg.RateRetention != time.Hour {
+		t.Errorf("По умолчанию ожидались 10s и 1h, получено %v %v", cfg.RateSampleInterval, cfg.RateRetention)
+	}
+
+	t.Setenv("RATE_SAMPLE_INTERVAL", "5s")
+	t.Setenv("RATE_RETENTION", "30m")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.RateSampleInterval != 5*time.Second || cfg.RateRetention != 30*time.Minute {
+		t.Errorf("Неверные настройки скоростей: %v %v", cfg.RateSampleInterval, cfg.RateRetention)
+	}
+
+	t.Setenv("RATE_RETENTION", "1s")
+	if _, err := Load(); err == nil {
+		t.Error("Ожидалась ошибка для времени хранения короче периода снятия")
+	}
+
+	t.Setenv("RATE_SAMPLE_INTERVAL", "0s")
+	if _, err := Load(); err == nil {
+		t.Error("Ожидалась ошибка для нулевого периода снятия")
+	}
+}
+
+func TestLoadRollupSettings(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Rollups.Enabled || cfg.Rollups.SampleInterval != 10*time.Second || cfg.Rollups.Retention != nil {
+		t.Errorf("По умолчанию ожидалась выключенная агрегация с периодом 10s, получено %+v", cfg.Rollups)
+	}
+
+	file := filepath.Join(t.TempDir(), "server.json")
+	data := `{"rollups_enabled": true, "rollup_sample_interval": "15s", "rollup_retention": {"raw": "2h", "1d": "17520h"}}`
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG", file)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if !cfg.Rollups.Enabled || cfg.Rollups.SampleInterval != 15*time.Second || cfg.Rollups.Retention["raw"] != 2*time.Hour ||
+		cfg.Rollups.Retention["1d"] != 17520*time.Hour || len(cfg.Rollups.Retention) != 2 {
+		t.Errorf("Неверные настройки агрегации из JSON: %+v", cfg.Rollups)
+	}
+
+	// Переменная окружения заменяет список из JSON целиком
+	t.Setenv("ROLLUP_RETENTION", "1m=48h")
+	t.Setenv("ROLLUPS_ENABLED", "false")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Rollups.Enabled {
+		t.Error("ROLLUPS_ENABLED=false должна выключать агрегацию из JSON")
+	}
+	if len(cfg.Rollups.Retention) != 1 || cfg.Rollups.Retention["1m"] != 48*time.Hour {
+		t.Errorf("Неверное время хранения из окружения: %v", cfg.Rollups.Retention)
+	}
+
+	for _, bad := range []string{"raw", "5m=1h", "1m=day", "raw=1m"} {
+		t.Setenv("ROLLUP_RETENTION", bad)
+		if _, err := Load(); err == nil {
+			t.Errorf("%q: ожидалась ошибка", bad)
+		}
+	}
+}
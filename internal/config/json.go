@@ -4,15 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 )
 
 // AgentJSONConfig представляет конфигурацию агента в JSON формате
 type AgentJSONConfig struct {
-	Address        *string `json:"address,omitempty"`
-	ReportInterval *string `json:"report_interval,omitempty"`
-	PollInterval   *string `json:"poll_interval,omitempty"`
-	CryptoKey      *string `json:"crypto_key,omitempty"`
+	Address        *string              `json:"address,omitempty"`
+	ReportInterval *string              `json:"report_interval,omitempty"`
+	PollInterval   *string              `json:"poll_interval,omitempty"`
+	CryptoKey      *string              `json:"crypto_key,omitempty"`
+	ScrapeTargets  []string             `json:"scrape_targets,omitempty"`
+	StatsDAddress  *string              `json:"statsd_address,omitempty"`
+	RuntimeMetrics []string             `json:"runtime_metrics,omitempty"`
+	Endpoints      []EndpointJSONConfig `json:"endpoints,omitempty"`
+	SendMode       *string              `json:"send_mode,omitempty"`
+	Transport      *string              `json:"transport,omitempty"`
+	AgentID        *string              `json:"agent_id,omitempty"`
+}
+
+// EndpointJSONConfig представляет настройки одного сервера для агента.
+// Пустые key и crypto_key наследуются из общих настроек агента.
+type EndpointJSONConfig struct {
+	Address   string `json:"address"`
+	Key       string `json:"key,omitempty"`
+	CryptoKey string `json:"crypto_key,omitempty"`
 }
 
 // ServerJSONConfig представляет конфигурацию сервера в JSON формате
@@ -23,6 +39,84 @@ type ServerJSONConfig struct {
 	StoreFile     *string `json:"store_file,omitempty"`
 	DatabaseDSN   *string `json:"database_dsn,omitempty"`
 	CryptoKey     *string `json:"crypto_key,omitempty"`
+
+	IngestRateLimit   *float64 `json:"ingest_rate_limit,omitempty"`
+	IngestBurst       *int     `json:"ingest_burst,omitempty"`
+	MaxInFlight       *int     `json:"max_inflight,omitempty"`
+	RateLimitIdentity *string  `json:"rate_limit_identity,omitempty"`
+
+	StreamBuffer     *int    `json:"stream_buffer,omitempty"`
+	StreamDropPolicy *string `json:"stream_drop_policy,omitempty"`
+
+	GaugeTTL   *string `json:"gauge_ttl,omitempty"`
+	CounterTTL *string `json:"counter_ttl,omitempty"`
+
+	BatchMode *string `json:"batch_mode,omitempty"`
+
+	OTLPResourceMode       *string  `json:"otlp_resource_mode,omitempty"`
+	OTLPResourceAttributes []string `json:"otlp_resource_attributes,omitempty"`
+
+	InfluxIntegerMode *string `json:"influx_integer_mode,omitempty"`
+
+	GraphiteAddress       *string `json:"graphite_address,omitempty"`
+	StatsDAddress         *string `json:"statsd_address,omitempty"`
+	ListenerFlushInterval *string `json:"listener_flush_interval,omitempty"`
+
+	RulesFile     *string `json:"rules_file,omitempty"`
+	RulesInterval *string `json:"rules_interval,omitempty"`
+
+	RateSampleInterval *string `json:"rate_sample_interval,omitempty"`
+	RateRetention      *string `json:"rate_retention,omitempty"`
+
+	RollupsEnabled       *bool             `json:"rollups_enabled,omitempty"`
+	RollupSampleInterval *string           `json:"rollup_sample_interval,omitempty"`
+	RollupRetention      map[string]string `json:"rollup_retention,omitempty"`
+
+	Exporters []ExporterJSONConfig `json:"exporters,omitempty"`
+	Webhooks  []WebhookJSONConfig  `json:"webhooks,omitempty"`
+}
+
+// ExporterJSONConfig представляет настройки одного экспортёра метрик во внешнюю систему.
+// Длительности задаются строками time.ParseDuration, пустые значения заменяются значениями по умолчанию.
+type ExporterJSONConfig struct {
+	Name        string                   `json:"name,omitempty"`
+	Type        string                   `json:"type"`
+	Address     string                   `json:"address"`
+	Interval    string                   `json:"interval,omitempty"`
+	Prefix      string                   `json:"prefix,omitempty"`
+	Filter      ExporterFilterJSONConfig `json:"filter,omitempty"`
+	QueueSize   int                      `json:"queue_size,omitempty"`
+	MaxAttempts int                      `json:"max_attempts,omitempty"`
+	Timeout     string                   `json:"timeout,omitempty"`
+}
+
+// WebhookJSONConfig представляет настройки одного webhook.
+// Длительности задаются строками time.ParseDuration, пустые значения заменяются значениями по умолчанию.
+type WebhookJSONConfig struct {
+	Name           string                  `json:"name,omitempty"`
+	URL            string                  `json:"url"`
+	Filter         WebhookFilterJSONConfig `json:"filter,omitempty"`
+	Secret         string                  `json:"secret,omitempty"`
+	BatchSize      int                     `json:"batch_size,omitempty"`
+	BatchInterval  string                  `json:"batch_interval,omitempty"`
+	QueueSize      int                     `json:"queue_size,omitempty"`
+	MaxAttempts    int                     `json:"max_attempts,omitempty"`
+	Timeout        string                  `json:"timeout,omitempty"`
+	DeadLetterFile string                  `json:"dead_letter_file,omitempty"`
+}
+
+// WebhookFilterJSONConfig отбор обновлений для webhook, поля соответствуют фильтрам /api/v1/stream
+type WebhookFilterJSONConfig struct {
+	Names []string `json:"names,omitempty"`
+	Types []string `json:"types,omitempty"`
+}
+
+// ExporterFilterJSONConfig отбор выгружаемых метрик, поля соответствуют фильтрам /api/v1/metrics
+type ExporterFilterJSONConfig struct {
+	Types  []string          `json:"types,omitempty"`
+	Prefix string            `json:"prefix,omitempty"`
+	Regex  string            `json:"regex,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // LoadJSONFile загружает и парсит JSON файл конфигурации
@@ -64,6 +158,28 @@ func ParseDurationToSeconds(durationStr string) (int, error) {
 }
 
 // stringPtr возвращает указатель на строку
+// splitList разбирает список через запятую. Пустая строка даёт пустой, но не nil список
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitPairs разбирает список пар ключ=значение через запятую. Элемент без "="
+// даёт ключ с пустым значением, чтобы ошибка была обнаружена при разборе значения
+func splitPairs(s string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range splitList(s) {
+		key, value, _ := strings.Cut(item, "=")
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return pairs
+}
+
 func stringPtr(s string) *string {
 	if s == "" {
 		return nil
@@ -90,6 +206,27 @@ func (jsonCfg *AgentJSONConfig) ApplyToAgentConfig(cfg *AgentJSONConfig) {
 	if cfg.CryptoKey == nil && jsonCfg.CryptoKey != nil {
 		cfg.CryptoKey = jsonCfg.CryptoKey
 	}
+	if cfg.ScrapeTargets == nil && jsonCfg.ScrapeTargets != nil {
+		cfg.ScrapeTargets = jsonCfg.ScrapeTargets
+	}
+	if cfg.StatsDAddress == nil && jsonCfg.StatsDAddress != nil {
+		cfg.StatsDAddress = jsonCfg.StatsDAddress
+	}
+	if cfg.RuntimeMetrics == nil && jsonCfg.RuntimeMetrics != nil {
+		cfg.RuntimeMetrics = jsonCfg.RuntimeMetrics
+	}
+	if cfg.Endpoints == nil && jsonCfg.Endpoints != nil {
+		cfg.Endpoints = jsonCfg.Endpoints
+	}
+	if cfg.SendMode == nil && jsonCfg.SendMode != nil {
+		cfg.SendMode = jsonCfg.SendMode
+	}
+	if cfg.Transport == nil && jsonCfg.Transport != nil {
+		cfg.Transport = jsonCfg.Transport
+	}
+	if cfg.AgentID == nil && jsonCfg.AgentID != nil {
+		cfg.AgentID = jsonCfg.AgentID
+	}
 }
 
 // ApplyToServerConfig применяет значения из JSON конфигурации, если они не заданы во flags/env
@@ -112,4 +249,76 @@ func (jsonCfg *ServerJSONConfig) ApplyToServerConfig(cfg *ServerJSONConfig) {
 	if cfg.CryptoKey == nil && jsonCfg.CryptoKey != nil {
 		cfg.CryptoKey = jsonCfg.CryptoKey
 	}
+	if cfg.IngestRateLimit == nil && jsonCfg.IngestRateLimit != nil {
+		cfg.IngestRateLimit = jsonCfg.IngestRateLimit
+	}
+	if cfg.IngestBurst == nil && jsonCfg.IngestBurst != nil {
+		cfg.IngestBurst = jsonCfg.IngestBurst
+	}
+	if cfg.MaxInFlight == nil && jsonCfg.MaxInFlight != nil {
+		cfg.MaxInFlight = jsonCfg.MaxInFlight
+	}
+	if cfg.RateLimitIdentity == nil && jsonCfg.RateLimitIdentity != nil {
+		cfg.RateLimitIdentity = jsonCfg.RateLimitIdentity
+	}
+	if cfg.StreamBuffer == nil && jsonCfg.StreamBuffer != nil {
+		cfg.StreamBuffer = jsonCfg.StreamBuffer
+	}
+	if cfg.StreamDropPolicy == nil && jsonCfg.StreamDropPolicy != nil {
+		cfg.StreamDropPolicy = jsonCfg.StreamDropPolicy
+	}
+	if cfg.GaugeTTL == nil && jsonCfg.GaugeTTL != nil {
+		cfg.GaugeTTL = jsonCfg.GaugeTTL
+	}
+	if cfg.CounterTTL == nil && jsonCfg.CounterTTL != nil {
+		cfg.CounterTTL = jsonCfg.CounterTTL
+	}
+	if cfg.BatchMode == nil && jsonCfg.BatchMode != nil {
+		cfg.BatchMode = jsonCfg.BatchMode
+	}
+	if cfg.OTLPResourceMode == nil && jsonCfg.OTLPResourceMode != nil {
+		cfg.OTLPResourceMode = jsonCfg.OTLPResourceMode
+	}
+	if cfg.OTLPResourceAttributes == nil && jsonCfg.OTLPResourceAttributes != nil {
+		cfg.OTLPResourceAttributes = jsonCfg.OTLPResourceAttributes
+	}
+	if cfg.InfluxIntegerMode == nil && jsonCfg.InfluxIntegerMode != nil {
+		cfg.InfluxIntegerMode = jsonCfg.InfluxIntegerMode
+	}
+	if cfg.GraphiteAddress == nil && jsonCfg.GraphiteAddress != nil {
+		cfg.GraphiteAddress = jsonCfg.GraphiteAddress
+	}
+	if cfg.StatsDAddress == nil && jsonCfg.StatsDAddress != nil {
+		cfg.StatsDAddress = jsonCfg.StatsDAddress
+	}
+	if cfg.ListenerFlushInterval == nil && jsonCfg.ListenerFlushInterval != nil {
+		cfg.ListenerFlushInterval = jsonCfg.ListenerFlushInterval
+	}
+	if cfg.RulesFile == nil && jsonCfg.RulesFile != nil {
+		cfg.RulesFile = jsonCfg.RulesFile
+	}
+	if cfg.RulesInterval == nil && jsonCfg.RulesInterval != nil {
+		cfg.RulesInterval = jsonCfg.RulesInterval
+	}
+	if cfg.RateSampleInterval == nil && jsonCfg.RateSampleInterval != nil {
+		cfg.RateSampleInterval = jsonCfg.RateSampleInterval
+	}
+	if cfg.RateRetention == nil && jsonCfg.RateRetention != nil {
+		cfg.RateRetention = jsonCfg.RateRetention
+	}
+	if cfg.RollupsEnabled == nil && jsonCfg.RollupsEnabled != nil {
+		cfg.RollupsEnabled = jsonCfg.RollupsEnabled
+	}
+	if cfg.RollupSampleInterval == nil && jsonCfg.RollupSampleInterval != nil {
+		cfg.RollupSampleInterval = jsonCfg.RollupSampleInterval
+	}
+	if cfg.RollupRetention == nil && jsonCfg.RollupRetention != nil {
+		cfg.RollupRetention = jsonCfg.RollupRetention
+	}
+	if cfg.Exporters == nil && jsonCfg.Exporters != nil {
+		cfg.Exporters = jsonCfg.Exporters
+	}
+	if cfg.Webhooks == nil && jsonCfg.Webhooks != nil {
+		cfg.Webhooks = jsonCfg.Webhooks
+	}
 }
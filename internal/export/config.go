@@ -0,0 +1,99 @@
+// Package export периодически выгружает текущие значения метрик из хранилища
+// во внешние системы: Graphite plaintext, InfluxDB line protocol и Prometheus remote_write.
+package export
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// Форматы выгрузки
+const (
+	// TypeGraphite строки Graphite plaintext по TCP, адрес host:port
+	TypeGraphite = "graphite"
+	// TypeInflux InfluxDB line protocol по HTTP, например http://influx:8086/write?db=metrics
+	TypeInflux = "influx"
+	// TypeRemoteWrite Prometheus remote_write 1.0 по HTTP, например http://prometheus:9090/api/v1/write
+	TypeRemoteWrite = "remote_write"
+)
+
+// Значения по умолчанию
+const (
+	DefaultInterval    = time.Minute
+	DefaultQueueSize   = 10
+	DefaultMaxAttempts = 4
+	DefaultTimeout     = 10 * time.Second
+)
+
+// Config настройки одного экспортёра
+type Config struct {
+	// Name имя экспортёра в логах, по умолчанию Type
+	Name string
+	// Type формат выгрузки: TypeGraphite, TypeInflux или TypeRemoteWrite
+	Type string
+	// Address адрес Graphite (host:port) или URL для HTTP форматов
+	Address string
+	// Interval период выгрузки
+	Interval time.Duration
+	// Filter отбор выгружаемых метрик, сортировка и пагинация не используются
+	Filter storage.MetricsQuery
+	// Prefix префикс имён метрик в выгрузке
+	Prefix string
+	// QueueSize количество выгрузок, ожидающих отправки. При переполнении
+	// отбрасывается самая старая: для текущего состояния важнее свежие значения
+	QueueSize int
+	// MaxAttempts количество попыток отправки одной выгрузки
+	MaxAttempts int
+	// Timeout таймаут одной попытки отправки
+	Timeout time.Duration
+}
+
+// withDefaults возвращает конфигурацию с заполненными значениями по умолчанию
+func (c Config) withDefaults() Config {
+	if c.Name == "" {
+		c.Name = c.Type
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.QueueSize == 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = DefaultMaxAttempts
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	return c
+}
+
+// Validate проверяет конфигурацию экспортёра, нулевые значения заменяются значениями по умолчанию
+func (c Config) Validate() error {
+	c = c.withDefaults()
+	switch c.Type {
+	case TypeGraphite:
+		if c.Address == "" {
+			return fmt.Errorf("экспортёр %s: не задан адрес Graphite", c.Name)
+		}
+	case TypeInflux, TypeRemoteWrite:
+		u, err := url.Parse(c.Address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("экспортёр %s: некорректный URL %q", c.Name, c.Address)
+		}
+	default:
+		return fmt.Errorf("экспортёр %s: неизвестный формат %q, ожидается %s, %s или %s",
+			c.Name, c.Type, TypeGraphite, TypeInflux, TypeRemoteWrite)
+	}
+	if c.Interval < 0 || c.Timeout < 0 || c.QueueSize < 0 || c.MaxAttempts < 0 {
+		return fmt.Errorf("экспортёр %s: интервал, таймаут, очередь и попытки не могут быть отрицательными", c.Name)
+	}
+	filter := c.Filter
+	if err := filter.Normalize(); err != nil {
+		return fmt.Errorf("экспортёр %s: некорректный фильтр: %w", c.Name, err)
+	}
+	return nil
+}
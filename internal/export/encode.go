@@ -0,0 +1,154 @@
+package export
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/remotewrite"
+)
+
+// encoder кодирует выгрузку метрик в тело запроса
+type encoder func(metrics []models.Metrics, prefix string, now time.Time) []byte
+
+// sortedKeys возвращает ключи меток по возрастанию
+func sortedKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeGraphite кодирует метрики строками path[;tag=value...] value timestamp.
+// Метки выгружаются тегами Graphite 1.1.
+func encodeGraphite(metrics []models.Metrics, prefix string, now time.Time) []byte {
+	var b bytes.Buffer
+	ts := strconv.FormatInt(now.Unix(), 10)
+	for _, m := range metrics {
+		name, labels := models.ParseSeriesID(m.ID)
+		b.WriteString(graphiteEscape(prefix + name))
+		for _, k := range sortedKeys(labels) {
+			b.WriteByte(';')
+			b.WriteString(graphiteEscape(k))
+			b.WriteByte('=')
+			b.WriteString(graphiteEscape(labels[k]))
+		}
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(m.FloatValue(), 'g', -1, 64))
+		b.WriteByte(' ')
+		b.WriteString(ts)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// graphiteEscape заменяет символы, разделяющие поля и теги строки Graphite
+func graphiteEscape(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', ';', '=':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// encodeInflux кодирует метрики строками line protocol с полем value:
+// дробным для gauge и целым (суффикс i) для counter
+func encodeInflux(metrics []models.Metrics, prefix string, now time.Time) []byte {
+	var b bytes.Buffer
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	for _, m := range metrics {
+		name, labels := models.ParseSeriesID(m.ID)
+		b.WriteString(influxEscape(prefix+name, ", "))
+		for _, k := range sortedKeys(labels) {
+			if labels[k] == "" {
+				continue // line protocol не допускает пустые значения тегов
+			}
+			b.WriteByte(',')
+			b.WriteString(influxEscape(k, ",= "))
+			b.WriteByte('=')
+			b.WriteString(influxEscape(labels[k], ",= "))
+		}
+		b.WriteString(" value=")
+		if m.MType == "counter" && m.Delta != nil {
+			b.WriteString(strconv.FormatInt(*m.Delta, 10))
+			b.WriteByte('i')
+		} else {
+			b.WriteString(strconv.FormatFloat(m.FloatValue(), 'g', -1, 64))
+		}
+		b.WriteByte(' ')
+		b.WriteString(ts)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// influxEscape экранирует обратной косой чертой символы из specials и переводы строк
+func influxEscape(s, specials string) string {
+	if !strings.ContainsAny(s, specials+"\\\n") {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\\' || strings.ContainsRune(specials, r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// encodeRemoteWrite кодирует метрики запросом remote_write с метаданными типов.
+// Имена приводятся к допустимым в Prometheus, counter выгружается накопленным значением.
+func encodeRemoteWrite(metrics []models.Metrics, prefix string, now time.Time) []byte {
+	req := &remotewrite.WriteRequest{}
+	families := make(map[string]remotewrite.MetricType)
+	ts := now.UnixMilli()
+
+	for _, m := range metrics {
+		name, labels := models.ParseSeriesID(m.ID)
+		name = models.SanitizeLabelName(prefix + name)
+
+		series := remotewrite.TimeSeries{
+			Labels:  make([]remotewrite.Label, 0, len(labels)+1),
+			Samples: []remotewrite.Sample{{Value: m.FloatValue(), Timestamp: ts}},
+		}
+		// Prometheus требует метки, отсортированные по имени, __name__ идёт перед строчными буквами
+		sanitized := make(map[string]string, len(labels))
+		for k, v := range labels {
+			sanitized[models.SanitizeLabelName(k)] = v
+		}
+		sanitized["__name__"] = name
+		for _, k := range sortedKeys(sanitized) {
+			series.Labels = append(series.Labels, remotewrite.Label{Name: k, Value: sanitized[k]})
+		}
+		req.Timeseries = append(req.Timeseries, series)
+
+		if m.MType == "counter" {
+			families[name] = remotewrite.MetricTypeCounter
+		} else {
+			families[name] = remotewrite.MetricTypeGauge
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		req.Metadata = append(req.Metadata, remotewrite.MetricMetadata{Type: families[name], MetricFamilyName: name})
+	}
+	return remotewrite.Encode(req)
+}
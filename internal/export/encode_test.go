@@ -0,0 +1,96 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/remotewrite"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+func testMetrics() []models.Metrics {
+	value := 1.5
+	delta := int64(42)
+	return []models.Metrics{
+		{ID: `cpu{host="a b",core="0"}`, MType: "gauge", Value: &value},
+		{ID: "PollCount", MType: "counter", Delta: &delta},
+	}
+}
+
+var testNow = time.Unix(1700000000, 0)
+
+func TestEncodeGraphite(t *testing.T) {
+	got := string(encodeGraphite(testMetrics(), "app.", testNow))
+	want := "app.cpu;core=0;host=a_b 1.5 1700000000\napp.PollCount 42 1700000000\n"
+	if got != want {
+		t.Errorf("Ожидалось\n%q\nполучено\n%q", want, got)
+	}
+}
+
+func TestEncodeInflux(t *testing.T) {
+	got := string(encodeInflux(testMetrics(), "", testNow))
+	want := "cpu,core=0,host=a\\ b value=1.5 1700000000000000000\nPollCount value=42i 1700000000000000000\n"
+	if got != want {
+		t.Errorf("Ожидалось\n%q\nполучено\n%q", want, got)
+	}
+}
+
+func TestEncodeRemoteWrite(t *testing.T) {
+	req, err := remotewrite.Decode(encodeRemoteWrite(testMetrics(), "app_", testNow))
+	if err != nil {
+		t.Fatalf("Ошибка декодирования: %v", err)
+	}
+	if len(req.Timeseries) != 2 {
+		t.Fatalf("Ожидалось 2 серии, получено %d", len(req.Timeseries))
+	}
+
+	cpu := req.Timeseries[0]
+	var names []string
+	for _, l := range cpu.Labels {
+		names = append(names, l.Name+"="+l.Value)
+	}
+	if got := strings.Join(names, ","); got != "__name__=app_cpu,core=0,host=a b" {
+		t.Errorf("Неожиданные метки: %s", got)
+	}
+	if cpu.Samples[0].Value != 1.5 || cpu.Samples[0].Timestamp != testNow.UnixMilli() {
+		t.Errorf("Неожиданный отсчёт: %+v", cpu.Samples[0])
+	}
+	if v := req.Timeseries[1].Samples[0].Value; v != 42 {
+		t.Errorf("Counter должен выгружаться накопленным значением, получено %v", v)
+	}
+
+	types := make(map[string]remotewrite.MetricType)
+	for _, md := range req.Metadata {
+		types[md.MetricFamilyName] = md.Type
+	}
+	if types["app_cpu"] != remotewrite.MetricTypeGauge || types["app_PollCount"] != remotewrite.MetricTypeCounter {
+		t.Errorf("Неожиданные метаданные: %+v", req.Metadata)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"graphite", Config{Type: TypeGraphite, Address: "localhost:2003"}, false},
+		{"graphite без адреса", Config{Type: TypeGraphite}, true},
+		{"influx", Config{Type: TypeInflux, Address: "http://localhost:8086/write?db=m"}, false},
+		{"remote_write без схемы", Config{Type: TypeRemoteWrite, Address: "localhost:9090"}, true},
+		{"неизвестный формат", Config{Type: "opentsdb", Address: "localhost:4242"}, true},
+		{"некорректный фильтр", Config{Type: TypeGraphite, Address: "localhost:2003",
+			Filter: storage.MetricsQuery{NameRegex: "("}}, true},
+		{"отрицательный интервал", Config{Type: TypeGraphite, Address: "localhost:2003", Interval: -time.Second}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.withDefaults().Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// Stats статистика экспортёра
+type Stats struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Sent успешно отправленные выгрузки
+	Sent int64 `json:"sent"`
+	// Failed выгрузки, не отправленные после всех попыток
+	Failed int64 `json:"failed"`
+	// Dropped выгрузки, вытесненные из переполненной очереди
+	Dropped int64 `json:"dropped"`
+}
+
+// exporter периодически снимает выгрузку метрик и отправляет её из очереди
+// отдельной горутиной, чтобы медленный приёмник не сбивал период выгрузки
+type exporter struct {
+	cfg     Config
+	storage storage.Storage
+	encode  encoder
+	sink    sink
+	retry   utils.RetryConfig
+	queue   chan []byte
+	now     func() time.Time
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+}
+
+// newExporter создаёт экспортёр по проверенной конфигурации
+func newExporter(s storage.Storage, cfg Config) *exporter {
+	e := &exporter{
+		cfg:     cfg,
+		storage: s,
+		queue:   make(chan []byte, cfg.QueueSize),
+		now:     time.Now,
+	}
+	switch cfg.Type {
+	case TypeGraphite:
+		e.encode = encodeGraphite
+		e.sink = &tcpSink{address: cfg.Address}
+	case TypeInflux:
+		e.encode = encodeInflux
+		e.sink = newInfluxSink(cfg.Address)
+	case TypeRemoteWrite:
+		e.encode = encodeRemoteWrite
+		e.sink = newRemoteWriteSink(cfg.Address)
+	}
+
+	e.retry = utils.DefaultBackoffRetryConfig()
+	e.retry.MaxAttempts = cfg.MaxAttempts
+	// Выгрузка должна успеть отправиться до следующей, иначе очередь только растёт
+	e.retry.MaxElapsedTime = min(e.retry.MaxElapsedTime, cfg.Interval)
+	return e
+}
+
+// snapshot возвращает текущие значения метрик, отобранных фильтром
+func (e *exporter) snapshot() ([]models.Metrics, error) {
+	query := e.cfg.Filter
+	query.Sort = ""
+	query.Desc = false
+	query.Limit = storage.MaxListLimit
+	query.Cursor = ""
+
+	var metrics []models.Metrics
+	for {
+		page, err := e.storage.ListMetrics(query)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, page.Metrics...)
+		if page.NextCursor == "" {
+			return metrics, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// collect снимает выгрузку и ставит её в очередь отправки
+func (e *exporter) collect() {
+	metrics, err := e.snapshot()
+	if err != nil {
+		log.Printf("Экспортёр %s: ошибка чтения метрик: %v", e.cfg.Name, err)
+		return
+	}
+	if len(metrics) == 0 {
+		return
+	}
+	e.enqueue(e.encode(metrics, e.cfg.Prefix, e.now()))
+}
+
+// enqueue ставит выгрузку в очередь, вытесняя самую старую при переполнении
+func (e *exporter) enqueue(body []byte) {
+	for {
+		select {
+		case e.queue <- body:
+			return
+		default:
+		}
+		select {
+		case <-e.queue:
+			n := e.dropped.Add(1)
+			log.Printf("Экспортёр %s: очередь переполнена, старая выгрузка отброшена (всего %d)", e.cfg.Name, n)
+		default:
+		}
+	}
+}
+
+// collectLoop снимает выгрузки с периодом Interval до отмены ctx
+func (e *exporter) collectLoop(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.collect()
+		}
+	}
+}
+
+// sendLoop отправляет выгрузки из очереди до её закрытия.
+// После отмены ctx оставшиеся выгрузки отбрасываются.
+func (e *exporter) sendLoop(ctx context.Context) {
+	for body := range e.queue {
+		if ctx.Err() != nil {
+			e.failed.Add(1)
+			continue
+		}
+		if err := e.send(ctx, body); err != nil {
+			e.failed.Add(1)
+			log.Printf("Экспортёр %s: выгрузка не отправлена: %v", e.cfg.Name, err)
+			continue
+		}
+		e.sent.Add(1)
+	}
+}
+
+// send отправляет выгрузку с повторами, ограничивая каждую попытку таймаутом
+func (e *exporter) send(ctx context.Context, body []byte) error {
+	return utils.Retry(ctx, e.retry, func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+		defer cancel()
+		if err := e.sink.Send(attemptCtx, body); err != nil {
+			return fmt.Errorf("%s %s: %w", e.cfg.Type, e.cfg.Address, err)
+		}
+		return nil
+	})
+}
+
+// stats возвращает статистику экспортёра
+func (e *exporter) stats() Stats {
+	return Stats{
+		Name:    e.cfg.Name,
+		Type:    e.cfg.Type,
+		Sent:    e.sent.Load(),
+		Failed:  e.failed.Load(),
+		Dropped: e.dropped.Load(),
+	}
+}
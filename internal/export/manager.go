@@ -0,0 +1,121 @@
+package export
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// Manager управляет фоновыми экспортёрами
+type Manager struct {
+	exporters []*exporter
+
+	// Поля для graceful shutdown: collect останавливает снятие выгрузок,
+	// send прерывает отправку, если очередь не успела опустеть
+	collectCtx    context.Context
+	collectCancel context.CancelFunc
+	sendCtx       context.Context
+	sendCancel    context.CancelFunc
+	collectWG     sync.WaitGroup
+	sendWG        sync.WaitGroup
+	mu            sync.Mutex
+	started       bool
+	stopped       bool
+}
+
+// NewManager создаёт менеджер экспортёров, проверяя их конфигурации
+func NewManager(s storage.Storage, configs []Config) (*Manager, error) {
+	m := &Manager{}
+	m.collectCtx, m.collectCancel = context.WithCancel(context.Background())
+	m.sendCtx, m.sendCancel = context.WithCancel(context.Background())
+
+	for _, cfg := range configs {
+		cfg = cfg.withDefaults()
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		m.exporters = append(m.exporters, newExporter(s, cfg))
+	}
+	return m, nil
+}
+
+// Enabled возвращает true, если настроен хотя бы один экспортёр
+func (m *Manager) Enabled() bool {
+	return len(m.exporters) > 0
+}
+
+// Start запускает экспортёры
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started || m.stopped {
+		return
+	}
+	m.started = true
+
+	for _, e := range m.exporters {
+		log.Printf("Экспортёр %s: выгрузка %s в %s каждые %v", e.cfg.Name, e.cfg.Type, e.cfg.Address, e.cfg.Interval)
+		m.collectWG.Add(1)
+		go func(e *exporter) {
+			defer m.collectWG.Done()
+			e.collectLoop(m.collectCtx)
+		}(e)
+		m.sendWG.Add(1)
+		go func(e *exporter) {
+			defer m.sendWG.Done()
+			e.sendLoop(m.sendCtx)
+		}(e)
+	}
+}
+
+// Stop останавливает выгрузку по расписанию, снимает финальную выгрузку
+// и ожидает отправки очередей до истечения ctx
+func (m *Manager) Stop(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.started || m.stopped {
+		m.stopped = true
+		return
+	}
+	m.stopped = true
+
+	log.Printf("Остановка экспортёров...")
+	m.collectCancel()
+	m.collectWG.Wait()
+
+	for _, e := range m.exporters {
+		e.collect()
+		close(e.queue)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.sendWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Экспортёры не успели отправить очереди: %v", ctx.Err())
+		m.sendCancel()
+		<-done
+	}
+	m.sendCancel()
+
+	for _, s := range m.Stats() {
+		log.Printf("Экспортёр %s остановлен: отправлено %d, ошибок %d, отброшено %d", s.Name, s.Sent, s.Failed, s.Dropped)
+	}
+}
+
+// Stats возвращает статистику экспортёров
+func (m *Manager) Stats() []Stats {
+	stats := make([]Stats, 0, len(m.exporters))
+	for _, e := range m.exporters {
+		stats = append(stats, e.stats())
+	}
+	return stats
+}
@@ -0,0 +1,261 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/remotewrite"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// fastRetry сокращает задержки повторов в тестах
+func fastRetry(m *Manager) {
+	for _, e := range m.exporters {
+		e.retry.BaseDelay = time.Millisecond
+		e.retry.MaxDelay = time.Millisecond
+	}
+}
+
+func newTestStorage() storage.Storage {
+	s := storage.NewMemStorage()
+	s.UpdateGauge(`Alloc{host="a"}`, 1.5)
+	s.UpdateCounter("PollCount", 3)
+	return s
+}
+
+// graphiteStub принимает строки Graphite по TCP
+type graphiteStub struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	lines []string
+}
+
+func newGraphiteStub(t *testing.T) *graphiteStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &graphiteStub{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					g.mu.Lock()
+					g.lines = append(g.lines, scanner.Text())
+					g.mu.Unlock()
+				}
+			}()
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return g
+}
+
+func (g *graphiteStub) received() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.lines...)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Условие не выполнено за отведённое время")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManager_Graphite(t *testing.T) {
+	stub := newGraphiteStub(t)
+	m, err := NewManager(newTestStorage(), []Config{{
+		Type:     TypeGraphite,
+		Address:  stub.ln.Addr().String(),
+		Interval: 20 * time.Millisecond,
+		Prefix:   "srv.",
+		Filter:   storage.MetricsQuery{Types: []string{"gauge"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	waitFor(t, func() bool { return len(stub.received()) >= 2 })
+	m.Stop(context.Background())
+
+	for _, line := range stub.received() {
+		if !strings.HasPrefix(line, "srv.Alloc;host=a 1.5 ") {
+			t.Errorf("Неожиданная строка: %q", line)
+		}
+	}
+	if s := m.Stats()[0]; s.Sent < 2 || s.Failed != 0 {
+		t.Errorf("Неожиданная статистика: %+v", s)
+	}
+}
+
+func TestManager_InfluxRetry(t *testing.T) {
+	var requests atomic.Int32
+	var body atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Первая попытка завершается ошибкой сервера, вторая принимается
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		body.Store(string(data))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	m, err := NewManager(newTestStorage(), []Config{{
+		Type:     TypeInflux,
+		Address:  srv.URL + "/write?db=metrics",
+		Interval: time.Hour,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fastRetry(m)
+	m.Start()
+	// Stop снимает финальную выгрузку и дожидается её отправки
+	m.Stop(context.Background())
+
+	if requests.Load() != 2 {
+		t.Fatalf("Ожидалось 2 запроса, получено %d", requests.Load())
+	}
+	got, _ := body.Load().(string)
+	if !strings.Contains(got, "Alloc,host=a value=1.5 ") || !strings.Contains(got, "PollCount value=3i ") {
+		t.Errorf("Неожиданное тело запроса: %q", got)
+	}
+	if s := m.Stats()[0]; s.Sent != 1 || s.Failed != 0 {
+		t.Errorf("Неожиданная статистика: %+v", s)
+	}
+}
+
+func TestManager_RemoteWrite(t *testing.T) {
+	received := make(chan *remotewrite.WriteRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Prometheus-Remote-Write-Version") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		req, err := remotewrite.Decode(data)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- req
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	m, err := NewManager(newTestStorage(), []Config{{
+		Type:     TypeRemoteWrite,
+		Address:  srv.URL + "/api/v1/write",
+		Interval: time.Hour,
+		Filter:   storage.MetricsQuery{Types: []string{"counter"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	m.Stop(context.Background())
+
+	select {
+	case req := <-received:
+		if len(req.Timeseries) != 1 || req.Timeseries[0].Samples[0].Value != 3 {
+			t.Errorf("Неожиданный запрос: %+v", req.Timeseries)
+		}
+	default:
+		t.Fatal("Выгрузка remote_write не получена")
+	}
+}
+
+func TestManager_PermanentErrorNotRetried(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	m, err := NewManager(newTestStorage(), []Config{{Type: TypeInflux, Address: srv.URL, Interval: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fastRetry(m)
+	m.Start()
+	m.Stop(context.Background())
+
+	if requests.Load() != 1 {
+		t.Errorf("Ошибка 4xx не должна повторяться, получено %d запросов", requests.Load())
+	}
+	if s := m.Stats()[0]; s.Failed != 1 {
+		t.Errorf("Ожидалась одна неотправленная выгрузка: %+v", s)
+	}
+}
+
+func TestManager_StopDeadline(t *testing.T) {
+	// Приёмник недоступен: Stop не должен ждать дольше переданного контекста
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	m, err := NewManager(newTestStorage(), []Config{{Type: TypeGraphite, Address: addr, Interval: time.Hour, MaxAttempts: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	m.Stop(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Stop занял %v", elapsed)
+	}
+	if s := m.Stats()[0]; s.Sent != 0 || s.Failed != 1 {
+		t.Errorf("Неожиданная статистика: %+v", s)
+	}
+}
+
+func TestExporter_QueueDropsOldest(t *testing.T) {
+	e := newExporter(storage.NewMemStorage(), Config{Type: TypeGraphite, Address: "localhost:2003", QueueSize: 2}.withDefaults())
+	for _, body := range []string{"1", "2", "3"} {
+		e.enqueue([]byte(body))
+	}
+	if got := e.dropped.Load(); got != 1 {
+		t.Fatalf("Ожидалась одна отброшенная выгрузка, получено %d", got)
+	}
+	if first := string(<-e.queue); first != "2" {
+		t.Errorf("Должна отбрасываться самая старая выгрузка, первой в очереди %q", first)
+	}
+}
+
+func TestNewManager_InvalidConfig(t *testing.T) {
+	if _, err := NewManager(storage.NewMemStorage(), []Config{{Type: TypeInflux, Address: "influx:8086"}}); err == nil {
+		t.Error("Ожидалась ошибка для URL без схемы")
+	}
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/remotewrite"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// sink отправляет закодированную выгрузку во внешнюю систему
+type sink interface {
+	Send(ctx context.Context, body []byte) error
+}
+
+// tcpSink отправляет выгрузку Graphite по TCP, открывая соединение на каждую отправку.
+// Выгрузки редкие, поэтому постоянное соединение не держится.
+type tcpSink struct {
+	address string
+	dialer  net.Dialer
+}
+
+// Send отправляет строки Graphite
+func (s *tcpSink) Send(ctx context.Context, body []byte) error {
+	conn, err := s.dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	if _, err := conn.Write(body); err != nil {
+		return utils.NewRetriableError(err)
+	}
+	return nil
+}
+
+// httpSink отправляет выгрузку POST запросом
+type httpSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// newInfluxSink создаёт отправку line protocol
+func newInfluxSink(url string) *httpSink {
+	return &httpSink{
+		url:     url,
+		headers: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		client:  &http.Client{},
+	}
+}
+
+// newRemoteWriteSink создаёт отправку remote_write с обязательными заголовками протокола
+func newRemoteWriteSink(url string) *httpSink {
+	return &httpSink{
+		url: url,
+		headers: map[string]string{
+			"Content-Type":                      remotewrite.ContentType,
+			"Content-Encoding":                  "snappy",
+			"X-Prometheus-Remote-Write-Version": remotewrite.Version,
+		},
+		client: &http.Client{},
+	}
+}
+
+// Send отправляет тело запроса и классифицирует ответ для retry
+func (s *httpSink) Send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return utils.NewPermanentError(fmt.Errorf("failed to create request: %w", err))
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return utils.CheckHTTPResponse(resp)
+}
@@ -0,0 +1,162 @@
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxLineSize максимальная длина строки, более длинные строки разрывают соединение
+const maxLineSize = 64 * 1024
+
+// idleTimeout время, после которого закрывается соединение без данных
+const idleTimeout = 5 * time.Minute
+
+// malformedLogEvery как часто логируются некорректные строки: первая и каждая N-я
+const malformedLogEvery = 100
+
+// Listener принимает строки Graphite plaintext по TCP и передаёт разобранные значения обработчику
+type Listener struct {
+	addr    string
+	handler func(Sample)
+
+	ln        net.Listener
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	conns     map[net.Conn]struct{}
+	closed    bool
+	received  atomic.Int64
+	malformed atomic.Int64
+}
+
+// NewListener создаёт listener для адреса addr. Обработчик вызывается конкурентно
+// из горутин соединений.
+func NewListener(addr string, handler func(Sample)) *Listener {
+	return &Listener{
+		addr:    addr,
+		handler: handler,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Start открывает TCP сокет и запускает приём соединений
+func (l *Listener) Start() error {
+	ln, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen graphite on %s: %w", l.addr, err)
+	}
+	l.ln = ln
+
+	l.wg.Add(1)
+	go l.serve()
+
+	log.Printf("Graphite listener запущен на %s", ln.Addr())
+	return nil
+}
+
+// Addr возвращает фактический адрес сокета
+func (l *Listener) Addr() net.Addr {
+	if l.ln == nil {
+		return nil
+	}
+	return l.ln.Addr()
+}
+
+// Received возвращает количество принятых корректных значений
+func (l *Listener) Received() int64 {
+	return l.received.Load()
+}
+
+// Malformed возвращает количество некорректных строк
+func (l *Listener) Malformed() int64 {
+	return l.malformed.Load()
+}
+
+// Close закрывает сокет и открытые соединения и ожидает завершения их обработки
+func (l *Listener) Close() error {
+	if l.ln == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	l.closed = true
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+
+	err := l.ln.Close()
+	l.wg.Wait()
+	return err
+}
+
+func (l *Listener) serve() {
+	defer l.wg.Done()
+
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Ошибка приёма Graphite соединения: %v", err)
+			continue
+		}
+
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			conn.Close()
+			return
+		}
+		l.conns[conn] = struct{}{}
+		l.wg.Add(1)
+		l.mu.Unlock()
+
+		go l.handle(conn)
+	}
+}
+
+func (l *Listener) handle(conn net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxLineSize)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		sample, err := ParseLine(line)
+		if err != nil {
+			if count := l.malformed.Add(1); count == 1 || count%malformedLogEvery == 0 {
+				log.Printf("Некорректная Graphite строка от %s (всего %d): %v", conn.RemoteAddr(), count, err)
+			}
+			continue
+		}
+		l.received.Add(1)
+		l.handler(sample)
+	}
+
+	// Закрытие при остановке и по таймауту простоя не считается ошибкой
+	var netErr net.Error
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		log.Printf("Ошибка чтения Graphite соединения %s: %v", conn.RemoteAddr(), err)
+	}
+}
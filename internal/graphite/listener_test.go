@@ -0,0 +1,69 @@
+package graphite
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	var mu sync.Mutex
+	var received []Sample
+
+	l := NewListener("127.0.0.1:0", func(s Sample) {
+		mu.Lock()
+		received = append(received, s)
+		mu.Unlock()
+	})
+	if err := l.Start(); err != nil {
+		t.Fatalf("Не удалось запустить listener: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Не удалось подключиться: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("cpu 1 1700000000\nbad line here too\nmem;host=a 5\n")); err != nil {
+		t.Fatalf("Не удалось отправить строки: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if l.Received() == 2 && l.Malformed() == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	if len(received) != 2 {
+		t.Errorf("Ожидалось 2 значения, получено %d", len(received))
+	}
+	mu.Unlock()
+	if l.Malformed() != 1 {
+		t.Errorf("Ожидалась 1 некорректная строка, получено %d", l.Malformed())
+	}
+
+	// Close должен закрыть открытое соединение клиента, а не ждать его завершения
+	done := make(chan struct{})
+	go func() {
+		l.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close не завершился при открытом соединении")
+	}
+}
+
+func TestListenerInvalidAddress(t *testing.T) {
+	l := NewListener("invalid-address", func(Sample) {})
+	if err := l.Start(); err == nil {
+		l.Close()
+		t.Error("Ожидалась ошибка для некорректного адреса")
+	}
+}
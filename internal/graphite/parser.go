@@ -0,0 +1,64 @@
+// Package graphite предоставляет разбор Graphite plaintext протокола
+// и TCP listener для приёма строк.
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample значение из строки Graphite
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	// Timestamp время значения, нулевое, если оно не указано или равно -1
+	Timestamp time.Time
+}
+
+// ParseLine разбирает строку вида path value [timestamp]. Путь может содержать теги
+// Graphite 1.1: path;tag1=value1;tag2=value2.
+func ParseLine(line string) (Sample, error) {
+	var sample Sample
+
+	parts := strings.Fields(line)
+	if len(parts) < 2 || len(parts) > 3 {
+		return sample, fmt.Errorf("invalid graphite line %q: expected path value [timestamp]", line)
+	}
+
+	path, tags, hasTags := strings.Cut(parts[0], ";")
+	if path == "" {
+		return sample, fmt.Errorf("invalid graphite line %q: empty path", line)
+	}
+	sample.Name = path
+	if hasTags {
+		sample.Labels = make(map[string]string)
+		for _, tag := range strings.Split(tags, ";") {
+			key, value, ok := strings.Cut(tag, "=")
+			if !ok || key == "" || value == "" {
+				return sample, fmt.Errorf("invalid graphite line %q: bad tag %q", line, tag)
+			}
+			sample.Labels[key] = value
+		}
+	}
+
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return sample, fmt.Errorf("invalid graphite line %q: bad value", line)
+	}
+	sample.Value = value
+
+	if len(parts) == 3 && parts[2] != "-1" {
+		ts, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || ts < 0 {
+			return sample, fmt.Errorf("invalid graphite line %q: bad timestamp", line)
+		}
+		sec, frac := math.Modf(ts)
+		sample.Timestamp = time.Unix(int64(sec), int64(frac*1e9))
+	}
+
+	return sample, nil
+}
@@ -0,0 +1,53 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	s, err := ParseLine("servers.web1.cpu 42.5 1700000000")
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if s.Name != "servers.web1.cpu" || s.Value != 42.5 || len(s.Labels) != 0 {
+		t.Errorf("Неверный результат разбора: %+v", s)
+	}
+	if !s.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Неверное время: %v", s.Timestamp)
+	}
+
+	s, err = ParseLine("disk.used;host=web1;mount=/var 10 -1")
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if s.Name != "disk.used" || s.Labels["host"] != "web1" || s.Labels["mount"] != "/var" {
+		t.Errorf("Неверный разбор тегов: %+v", s)
+	}
+	if !s.Timestamp.IsZero() {
+		t.Errorf("Время -1 означает отсутствие времени, получено %v", s.Timestamp)
+	}
+
+	if s, err = ParseLine("uptime 5"); err != nil || s.Value != 5 {
+		t.Errorf("Строка без времени разобрана неверно: %+v, %v", s, err)
+	}
+}
+
+func TestParseLine_Errors(t *testing.T) {
+	lines := map[string]string{
+		"только путь":          "cpu",
+		"лишние поля":          "cpu 1 2 3",
+		"некорректное число":   "cpu abc",
+		"NaN":                  "cpu NaN",
+		"некорректное время":   "cpu 1 yesterday",
+		"тег без значения":     "cpu;host 1",
+		"пустой путь с тегами": ";host=a 1",
+	}
+	for name, line := range lines {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseLine(line); err == nil {
+				t.Errorf("Ожидалась ошибка для %q", line)
+			}
+		})
+	}
+}
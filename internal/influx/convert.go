@@ -0,0 +1,70 @@
+package influx
+
+import (
+	"fmt"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// Способы записи целочисленных полей (с суффиксом i или u)
+const (
+	// IntegerGauge целочисленное поле записывается как gauge, как и остальные поля
+	IntegerGauge = "gauge"
+	// IntegerCounter значение целочисленного поля прибавляется к counter
+	IntegerCounter = "counter"
+)
+
+// ValidateIntegerMode проверяет способ записи целочисленных полей
+func ValidateIntegerMode(mode string) error {
+	switch mode {
+	case "", IntegerGauge, IntegerCounter:
+		return nil
+	}
+	return fmt.Errorf("неизвестный способ записи целочисленных полей %q, ожидается %s или %s",
+		mode, IntegerGauge, IntegerCounter)
+}
+
+// Config настройки преобразования точек в метрики
+type Config struct {
+	// IntegerMode способ записи целочисленных полей, по умолчанию IntegerGauge
+	IntegerMode string
+}
+
+// ToMetrics преобразует точки в метрики. Каждое поле становится серией measurement_field
+// с тегами точки в качестве меток. Поле с именем value записывается под именем измерения.
+// Строковые поля не имеют числового значения и пропускаются, их количество возвращается.
+func ToMetrics(points []Point, cfg Config) ([]models.Metrics, int) {
+	var metrics []models.Metrics
+	skipped := 0
+
+	for _, p := range points {
+		labels := make(map[string]string, len(p.Tags))
+		for k, v := range p.Tags {
+			labels[models.SanitizeLabelName(k)] = v
+		}
+
+		for _, f := range p.Fields {
+			if f.Type == FieldString {
+				skipped++
+				continue
+			}
+
+			name := p.Measurement + "_" + f.Key
+			if f.Key == "value" {
+				name = p.Measurement
+			}
+			id := models.FormatSeriesID(name, labels)
+
+			integer := f.Type == FieldInteger || f.Type == FieldUnsigned
+			if integer && cfg.IntegerMode == IntegerCounter {
+				delta := f.Int
+				metrics = append(metrics, models.Metrics{ID: id, MType: "counter", Delta: &delta})
+				continue
+			}
+			value := f.Value
+			metrics = append(metrics, models.Metrics{ID: id, MType: "gauge", Value: &value})
+		}
+	}
+
+	return metrics, skipped
+}
@@ -0,0 +1,75 @@
+package influx
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+func convertLines(t *testing.T, data string, cfg Config) (map[string]models.Metrics, int) {
+	t.Helper()
+	points, errs := Parse([]byte(data), time.Nanosecond)
+	if len(errs) > 0 {
+		t.Fatalf("Ошибки разбора: %v", errs)
+	}
+	metrics, skipped := ToMetrics(points, cfg)
+	result := make(map[string]models.Metrics, len(metrics))
+	for _, m := range metrics {
+		result[m.ID] = m
+	}
+	return result, skipped
+}
+
+func TestToMetrics(t *testing.T) {
+	metrics, skipped := convertLines(t, `cpu,host=a,dc.name=eu usage=0.5,cores=4i,state="ok"
+temperature value=21.5`, Config{})
+
+	if skipped != 1 {
+		t.Errorf("Ожидалось 1 пропущенное строковое поле, получено %d", skipped)
+	}
+	if m := metrics[`cpu_usage{dc_name="eu",host="a"}`]; m.MType != "gauge" || *m.Value != 0.5 {
+		t.Errorf("Ожидался gauge cpu_usage 0.5, получено %v", metrics)
+	}
+	if m := metrics[`cpu_cores{dc_name="eu",host="a"}`]; m.MType != "gauge" || *m.Value != 4 {
+		t.Errorf("По умолчанию целое поле должно быть gauge, получено %v", metrics)
+	}
+	if m := metrics["temperature"]; m.Value == nil || *m.Value != 21.5 {
+		t.Errorf("Поле value должно записываться под именем измерения, получено %v", metrics)
+	}
+}
+
+func TestToMetrics_IntegerCounter(t *testing.T) {
+	metrics, _ := convertLines(t, "requests count=3i,bytes=10u,latency=0.2", Config{IntegerMode: IntegerCounter})
+
+	for id, delta := range map[string]int64{"requests_count": 3, "requests_bytes": 10} {
+		if m := metrics[id]; m.MType != "counter" || *m.Delta != delta {
+			t.Errorf("%s: ожидался counter %d, получено %+v", id, delta, m)
+		}
+	}
+	if m := metrics["requests_latency"]; m.MType != "gauge" {
+		t.Errorf("Дробное поле должно оставаться gauge, получено %+v", m)
+	}
+}
+
+func TestToMetrics_IntegerCounterExact(t *testing.T) {
+	metrics, _ := convertLines(t, "bytes total=9223372036854775807u,offset=9007199254740993i", Config{IntegerMode: IntegerCounter})
+
+	for id, delta := range map[string]int64{"bytes_total": math.MaxInt64, "bytes_offset": 1<<53 + 1} {
+		if m := metrics[id]; m.Delta == nil || *m.Delta != delta {
+			t.Errorf("%s: ожидался counter %d без потери точности, получено %+v", id, delta, m)
+		}
+	}
+}
+
+func TestValidateIntegerMode(t *testing.T) {
+	for _, mode := range []string{"", IntegerGauge, IntegerCounter} {
+		if err := ValidateIntegerMode(mode); err != nil {
+			t.Errorf("Режим %q должен быть допустим: %v", mode, err)
+		}
+	}
+	if ValidateIntegerMode("histogram") == nil {
+		t.Error("Ожидалась ошибка для неизвестного режима")
+	}
+}
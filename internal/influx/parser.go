@@ -0,0 +1,265 @@
+// Package influx предоставляет разбор InfluxDB line protocol и преобразование
+// точек в метрики хранилища.
+package influx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Типы значений полей
+const (
+	FieldFloat    = "float"
+	FieldInteger  = "integer"
+	FieldUnsigned = "unsigned"
+	FieldBoolean  = "boolean"
+	FieldString   = "string"
+)
+
+// Field поле точки
+type Field struct {
+	Key  string
+	Type string
+	// Value числовое значение, для boolean 1 или 0, для string не заполняется
+	Value float64
+	// Int точное значение целочисленного поля (integer или unsigned)
+	Int int64
+	// Str значение строкового поля
+	Str string
+}
+
+// Point строка line protocol
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      []Field
+	// Timestamp время точки, нулевое, если оно не указано
+	Timestamp time.Time
+}
+
+// LineError ошибка разбора строки с её номером
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ParsePrecision возвращает единицу времени меток для параметра precision запроса.
+// Поддерживаются значения InfluxDB 1.x (n, u, ms, s, m, h) и 2.x (ns, us, ms, s),
+// пустое значение означает наносекунды.
+func ParsePrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µ":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf("unknown precision %q", precision)
+}
+
+// Parse разбирает тело запроса из строк line protocol. Пустые строки и комментарии,
+// начинающиеся с #, пропускаются. Возвращает ошибки *LineError для некорректных строк.
+func Parse(data []byte, precision time.Duration) ([]Point, []error) {
+	var points []Point
+	var errs []error
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		point, err := ParseLine(line, precision)
+		if err != nil {
+			errs = append(errs, &LineError{Line: i + 1, Err: err})
+			continue
+		}
+		points = append(points, point)
+	}
+
+	return points, errs
+}
+
+// ParseLine разбирает строку вида measurement[,tag=value...] field=value[,field=value...] [timestamp]
+func ParseLine(line string, precision time.Duration) (Point, error) {
+	point := Point{Tags: map[string]string{}}
+
+	// Имя измерения заканчивается неэкранированной запятой или пробелом
+	end := scan(line, 0, ", ", false)
+	point.Measurement = unescape(line[:end], ", ")
+	if point.Measurement == "" {
+		return point, errors.New("missing measurement")
+	}
+
+	pos := end
+	if pos < len(line) && line[pos] == ',' {
+		end = scan(line, pos+1, " ", false)
+		for _, pair := range split(line[pos+1:end], ',', false) {
+			key, value, err := splitPair(pair, false)
+			if err != nil {
+				return point, fmt.Errorf("invalid tag %q: %w", pair, err)
+			}
+			point.Tags[unescape(key, ",= ")] = unescape(value, ",= ")
+		}
+		pos = end
+	}
+
+	pos = skipSpaces(line, pos)
+	end = scan(line, pos, " ", true)
+	if pos == end {
+		return point, errors.New("missing fields")
+	}
+	for _, pair := range split(line[pos:end], ',', true) {
+		key, raw, err := splitPair(pair, true)
+		if err != nil {
+			return point, fmt.Errorf("invalid field %q: %w", pair, err)
+		}
+		field, err := parseFieldValue(unescape(key, ",= "), raw)
+		if err != nil {
+			return point, err
+		}
+		point.Fields = append(point.Fields, field)
+	}
+
+	pos = skipSpaces(line, end)
+	if pos < len(line) {
+		ts, err := strconv.ParseInt(line[pos:], 10, 64)
+		if err != nil {
+			return point, fmt.Errorf("invalid timestamp %q", line[pos:])
+		}
+		point.Timestamp = time.Unix(0, ts*int64(precision))
+	}
+
+	return point, nil
+}
+
+// parseFieldValue разбирает значение поля: 1.5, 1i, 1u, true или "text"
+func parseFieldValue(key, raw string) (Field, error) {
+	field := Field{Key: key}
+
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return field, fmt.Errorf("field %q: unterminated string", key)
+		}
+		field.Type = FieldString
+		field.Str = unescape(raw[1:len(raw)-1], `"\`)
+		return field, nil
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return field, fmt.Errorf("field %q: invalid integer %q", key, raw)
+		}
+		field.Type, field.Value, field.Int = FieldInteger, float64(v), v
+		return field, nil
+	case strings.HasSuffix(raw, "u"):
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return field, fmt.Errorf("field %q: invalid unsigned integer %q", key, raw)
+		}
+		if v > math.MaxInt64 {
+			return field, fmt.Errorf("field %q: unsigned integer %q out of range", key, raw)
+		}
+		field.Type, field.Value, field.Int = FieldUnsigned, float64(v), int64(v)
+		return field, nil
+	}
+
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		field.Type, field.Value = FieldBoolean, 1
+		return field, nil
+	case "f", "F", "false", "False", "FALSE":
+		field.Type, field.Value = FieldBoolean, 0
+		return field, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return field, fmt.Errorf("field %q: invalid value %q", key, raw)
+	}
+	field.Type, field.Value = FieldFloat, v
+	return field, nil
+}
+
+// scan возвращает позицию первого неэкранированного символа из stops, начиная с from.
+// Если quoted, символы внутри двойных кавычек не учитываются.
+func scan(s string, from int, stops string, quoted bool) int {
+	inQuotes := false
+	for i := from; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+		case quoted && c == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && strings.IndexByte(stops, c) >= 0:
+			return i
+		}
+	}
+	return len(s)
+}
+
+// split делит s по неэкранированному разделителю sep
+func split(s string, sep byte, quoted bool) []string {
+	var parts []string
+	for {
+		end := scan(s, 0, string(sep), quoted)
+		parts = append(parts, s[:end])
+		if end == len(s) {
+			return parts
+		}
+		s = s[end+1:]
+	}
+}
+
+// splitPair делит пару key=value по первому неэкранированному знаку равенства
+func splitPair(pair string, quoted bool) (string, string, error) {
+	eq := scan(pair, 0, "=", quoted)
+	if eq == len(pair) {
+		return "", "", errors.New("missing '='")
+	}
+	if eq == 0 || eq == len(pair)-1 {
+		return "", "", errors.New("empty key or value")
+	}
+	return pair[:eq], pair[eq+1:], nil
+}
+
+// unescape удаляет обратную косую черту перед символами из specials
+func unescape(s, specials string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(specials, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func skipSpaces(s string, pos int) int {
+	for pos < len(s) && s[pos] == ' ' {
+		pos++
+	}
+	return pos
+}
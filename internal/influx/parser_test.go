@@ -0,0 +1,106 @@
+package influx
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	p, err := ParseLine(`cpu\ load,host=server\ 1,region=eu\,west usage=0.64,cores=8i,free=3u,up=t,state="ok \"fine\"" 1700000000000000000`, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+
+	if p.Measurement != "cpu load" {
+		t.Errorf("Ожидалось измерение %q, получено %q", "cpu load", p.Measurement)
+	}
+	if p.Tags["host"] != "server 1" || p.Tags["region"] != "eu,west" {
+		t.Errorf("Неверные теги: %v", p.Tags)
+	}
+	if !p.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Неверное время: %v", p.Timestamp)
+	}
+
+	expected := []Field{
+		{Key: "usage", Type: FieldFloat, Value: 0.64},
+		{Key: "cores", Type: FieldInteger, Value: 8, Int: 8},
+		{Key: "free", Type: FieldUnsigned, Value: 3, Int: 3},
+		{Key: "up", Type: FieldBoolean, Value: 1},
+		{Key: "state", Type: FieldString, Str: `ok "fine"`},
+	}
+	if len(p.Fields) != len(expected) {
+		t.Fatalf("Ожидалось %d полей, получено %d: %+v", len(expected), len(p.Fields), p.Fields)
+	}
+	for i, f := range expected {
+		if p.Fields[i] != f {
+			t.Errorf("Поле %d: ожидалось %+v, получено %+v", i, f, p.Fields[i])
+		}
+	}
+}
+
+func TestParseLine_Precision(t *testing.T) {
+	p, err := ParseLine("mem used=1 1700000000", time.Second)
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if !p.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Неверное время: %v", p.Timestamp)
+	}
+
+	p, err = ParseLine("mem used=1", time.Second)
+	if err != nil || !p.Timestamp.IsZero() || len(p.Tags) != 0 {
+		t.Errorf("Строка без тегов и времени разобрана неверно: %+v, %v", p, err)
+	}
+}
+
+func TestParseLine_Errors(t *testing.T) {
+	lines := map[string]string{
+		"без полей":          "cpu,host=a",
+		"пустое измерение":   ",host=a usage=1",
+		"тег без значения":   "cpu,host usage=1",
+		"поле без значения":  "cpu usage=",
+		"некорректное число": "cpu usage=abc",
+		"некорректное целое": "cpu usage=1.5i",
+		"NaN":                "cpu usage=NaN",
+		"бесконечность":      "cpu usage=-Inf",
+		"переполнение int64": "cpu bytes=9223372036854775808u",
+		"незакрытая строка":  `cpu state="ok`,
+		"некорректное время": "cpu usage=1 yesterday",
+	}
+	for name, line := range lines {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseLine(line, time.Nanosecond); err == nil {
+				t.Errorf("Ожидалась ошибка для %q", line)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	data := []byte("# комментарий\ncpu usage=1\n\nbroken\nmem used=2i\n")
+	points, errs := Parse(data, time.Nanosecond)
+
+	if len(points) != 2 {
+		t.Errorf("Ожидалось 2 точки, получено %d", len(points))
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Ожидалась 1 ошибка, получено %v", errs)
+	}
+	var lineErr *LineError
+	if !errors.As(errs[0], &lineErr) || lineErr.Line != 4 {
+		t.Errorf("Ожидалась ошибка в строке 4, получено %v", errs[0])
+	}
+}
+
+func TestParsePrecision(t *testing.T) {
+	tests := map[string]time.Duration{"": time.Nanosecond, "ns": time.Nanosecond, "u": time.Microsecond, "ms": time.Millisecond, "s": time.Second, "h": time.Hour}
+	for precision, expected := range tests {
+		if got, err := ParsePrecision(precision); err != nil || got != expected {
+			t.Errorf("ParsePrecision(%q): ожидалось %v, получено %v (%v)", precision, expected, got, err)
+		}
+	}
+	if _, err := ParsePrecision("d"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной точности")
+	}
+}
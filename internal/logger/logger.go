@@ -2,6 +2,8 @@
 package logger
 
 import (
+	"bufio"
+	"net"
 	"net/http"
 	"time"
 
@@ -29,6 +31,17 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode
 }
 
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack передаёт соединение обработчику, например для WebSocket
+func (r *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.responseData.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(r.ResponseWriter).Hijack()
+}
+
 // WithLogging создает middleware для логирования HTTP-запросов.
 // Логирует URI, метод, статус ответа, время выполнения и размер ответа.
 // Использует zap логгер для структурированного логирования.
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AdminAuthMiddleware создает middleware, пропускающее только запросы с заголовком
+// "Authorization: Bearer <token>". Токен администратора не связан с ключом подписи
+// метрик, поэтому агенты с ключом KEY не могут удалять и сбрасывать метрики.
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return AdminAuthMiddlewareWithErrors(token, PlainErrorWriter)
+}
+
+// AdminAuthMiddlewareWithErrors аналогичен AdminAuthMiddleware, но формирует ответы
+// с ошибками через writeError
+func AdminAuthMiddlewareWithErrors(token string, writeError ErrorWriter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, credentials, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeError(w, r, http.StatusUnauthorized, ErrCodeUnauthorized, "Требуется авторизация администратора")
+				return
+			}
+
+			if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(credentials)), []byte(token)) != 1 {
+				writeError(w, r, http.StatusForbidden, ErrCodeForbidden, "Неверный токен администратора")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	handler := AdminAuthMiddleware("admin-secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "верный токен", authorization: "Bearer admin-secret", want: http.StatusNoContent},
+		{name: "схема в нижнем регистре", authorization: "bearer admin-secret", want: http.StatusNoContent},
+		{name: "без заголовка", want: http.StatusUnauthorized},
+		{name: "другая схема", authorization: "Basic YWRtaW46c2VjcmV0", want: http.StatusUnauthorized},
+		{name: "неверный токен", authorization: "Bearer wrong", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/metrics/gauge/test", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Ожидался статус %d, получен %d", tt.want, w.Code)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Ожидался заголовок WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestAdminAuthMiddlewareWithErrors(t *testing.T) {
+	var gotCode string
+	writeError := func(w http.ResponseWriter, _ *http.Request, status int, code, _ string) {
+		gotCode = code
+		w.WriteHeader(status)
+	}
+	handler := AdminAuthMiddlewareWithErrors("admin-secret", writeError)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/metrics/gauge/test", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden || gotCode != ErrCodeForbidden {
+		t.Errorf("Ожидался статус 403 с кодом %s, получено %d %q", ErrCodeForbidden, w.Code, gotCode)
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import "net/http"
+
+// Коды ошибок, которые middleware передают в ErrorWriter
+const (
+	// ErrCodeUnauthorized не передан токен авторизации
+	ErrCodeUnauthorized = "unauthorized"
+	// ErrCodeForbidden токен авторизации неверный
+	ErrCodeForbidden = "forbidden"
+	// ErrCodeRateLimited превышено ограничение частоты запросов
+	ErrCodeRateLimited = "rate_limited"
+)
+
+// ErrorWriter формирует ответ с ошибкой. code - машиночитаемый код ошибки,
+// message - описание для человека. Заголовки вроде Retry-After уже установлены.
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, status int, code, message string)
+
+// PlainErrorWriter отвечает текстом ошибки через http.Error
+func PlainErrorWriter(w http.ResponseWriter, _ *http.Request, status int, _, message string) {
+	http.Error(w, message, status)
+}
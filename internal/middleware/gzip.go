@@ -17,12 +17,26 @@ func (w *gzipResponseWriter) Write(b []byte) (int, error) {
 	return w.Writer.Write(b)
 }
 
+// Flush отправляет клиенту накопленные сжатые данные, что нужно для потоковых ответов
+func (w *gzipResponseWriter) Flush() {
+	if err := w.Writer.Flush(); err != nil {
+		return
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
 // GzipMiddleware создает middleware для сжатия HTTP-ответов и распаковки запросов.
 // Автоматически сжимает ответы, если клиент поддерживает gzip.
 // Распаковывает входящие запросы, если они сжаты gzip.
 // Устанавливает заголовок Content-Encoding для сжатых ответов.
 func GzipMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Запросы на смену протокола (WebSocket) передаются без изменений
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Декомпрессия запроса
 		if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
 			gz, err := gzip.NewReader(r.Body)
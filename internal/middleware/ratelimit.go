@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// AgentIDHeader заголовок, в котором агент передаёт свой идентификатор, подписанный ключом
+// (utils.AgentToken)
+const AgentIDHeader = "X-Agent-ID"
+
+// Способы определения клиента для rate limiting
+const (
+	// IdentityIP клиент определяется по IP адресу соединения
+	IdentityIP = "ip"
+	// IdentityKey клиент определяется по идентификатору агента из заголовка X-Agent-ID
+	// с подписью ключом сервера, без заголовка или с неверной подписью - по IP адресу
+	IdentityKey = "key"
+	// IdentityHeaderPrefix клиент определяется по доверенному заголовку, например "header:X-Real-IP"
+	IdentityHeaderPrefix = "header:"
+)
+
+const (
+	// bucketIdleTTL время, после которого неиспользуемый bucket клиента удаляется
+	bucketIdleTTL = 10 * time.Minute
+	// bucketSweepInterval минимальный интервал между очистками неиспользуемых bucket
+	bucketSweepInterval = time.Minute
+)
+
+// RateLimitConfig содержит настройки ограничения частоты запросов
+type RateLimitConfig struct {
+	Rate        float64 // Запросов в секунду на клиента, 0 - без ограничения
+	Burst       int     // Размер bucket, по умолчанию равен ceil(Rate)
+	MaxInFlight int     // Максимум одновременно обрабатываемых запросов, 0 - без ограничения
+	Identity    string  // Способ определения клиента: ip, key или header:<Имя>
+	// Key ключ подписи сервера, которым в режиме key проверяется X-Agent-ID
+	Key string
+}
+
+// Enabled возвращает true, если задано хотя бы одно ограничение
+func (c RateLimitConfig) Enabled() bool {
+	return c.Rate > 0 || c.MaxInFlight > 0
+}
+
+// ValidateIdentity проверяет способ определения клиента
+func ValidateIdentity(identity string) error {
+	switch {
+	case identity == "", identity == IdentityIP, identity == IdentityKey:
+		return nil
+	case strings.HasPrefix(identity, IdentityHeaderPrefix) && len(identity) > len(IdentityHeaderPrefix):
+		return nil
+	}
+	return fmt.Errorf("неизвестный способ определения клиента %q, ожидается ip, key или header:<Имя>", identity)
+}
+
+// tokenBucket bucket одного клиента
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter ограничивает частоту запросов каждого клиента по алгоритму token bucket
+// и общее число одновременно обрабатываемых запросов
+type RateLimiter struct {
+	config   RateLimitConfig
+	now      func() time.Time
+	inFlight chan struct{}
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter создаёт RateLimiter
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.Burst <= 0 {
+		config.Burst = int(math.Max(1, math.Ceil(config.Rate)))
+	}
+	if config.Identity == "" {
+		config.Identity = IdentityIP
+	}
+
+	rl := &RateLimiter{
+		config:  config,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+	if config.MaxInFlight > 0 {
+		rl.inFlight = make(chan struct{}, config.MaxInFlight)
+	}
+	return rl
+}
+
+// Middleware возвращает middleware, которое отвечает 429 Too Many Requests
+// с заголовком Retry-After при превышении ограничений
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return rl.MiddlewareWithErrors(PlainErrorWriter)(next)
+}
+
+// MiddlewareWithErrors аналогичен Middleware, но формирует ответ 429 через writeError
+func (rl *RateLimiter) MiddlewareWithErrors(writeError ErrorWriter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if wait := rl.Reserve(r); wait > 0 {
+				tooManyRequests(w, r, wait, writeError)
+				return
+			}
+
+			release, ok := rl.Acquire()
+			if !ok {
+				tooManyRequests(w, r, time.Second, writeError)
+				return
+			}
+			defer release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Reserve забирает токен из bucket клиента, отправившего запрос. Если токенов нет,
+// возвращает время до появления следующего токена. Используется и для отдельных
+// сообщений долгоживущих соединений.
+func (rl *RateLimiter) Reserve(r *http.Request) time.Duration {
+	if rl.config.Rate <= 0 {
+		return 0
+	}
+	return rl.reserve(rl.clientID(r))
+}
+
+// Acquire занимает место в ограничении одновременно обрабатываемых запросов.
+// Возвращает функцию освобождения места и false, если мест нет.
+func (rl *RateLimiter) Acquire() (func(), bool) {
+	if rl.inFlight == nil {
+		return func() {}, true
+	}
+	select {
+	case rl.inFlight <- struct{}{}:
+		return func() { <-rl.inFlight }, true
+	default:
+		return nil, false
+	}
+}
+
+// reserve забирает токен из bucket клиента. Если токенов нет,
+// возвращает время до появления следующего токена.
+func (rl *RateLimiter) reserve(client string) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	b, ok := rl.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: float64(rl.config.Burst), last: now}
+		rl.buckets[client] = b
+	}
+
+	b.tokens = math.Min(float64(rl.config.Burst), b.tokens+now.Sub(b.last).Seconds()*rl.config.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rl.config.Rate * float64(time.Second))
+}
+
+// sweep удаляет bucket клиентов, не отправлявших запросы дольше bucketIdleTTL.
+// Вызывается под блокировкой.
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < bucketSweepInterval {
+		return
+	}
+	rl.lastSweep = now
+	for client, b := range rl.buckets {
+		if now.Sub(b.last) > bucketIdleTTL {
+			delete(rl.buckets, client)
+		}
+	}
+}
+
+// clientID определяет клиента по настроенному способу.
+// Limiter работает до расшифровки и проверки подписи тела, поэтому идентификатор агента
+// принимается только с подписью ключом сервера: без ключа нельзя получить новый bucket
+// на каждый придуманный идентификатор или расходовать bucket другого агента.
+func (rl *RateLimiter) clientID(r *http.Request) string {
+	switch {
+	case rl.config.Identity == IdentityKey:
+		if agentID, ok := utils.VerifyAgentToken(r.Header.Get(AgentIDHeader), rl.config.Key); ok {
+			return "agent:" + agentID
+		}
+	case strings.HasPrefix(rl.config.Identity, IdentityHeaderPrefix):
+		header := strings.TrimPrefix(rl.config.Identity, IdentityHeaderPrefix)
+		if value := r.Header.Get(header); value != "" {
+			// X-Forwarded-For может содержать цепочку прокси, клиент указан первым
+			first, _, _ := strings.Cut(value, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tooManyRequests отвечает 429 с задержкой Retry-After в целых секундах, не меньше одной
+func tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration, writeError ErrorWriter) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+	writeError(w, r, http.StatusTooManyRequests, ErrCodeRateLimited, "Слишком много запросов")
+}
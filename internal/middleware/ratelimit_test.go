@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// newTestLimiter создаёт RateLimiter с управляемым временем
+func newTestLimiter(cfg RateLimitConfig) (*RateLimiter, *time.Time) {
+	now := time.Now()
+	rl := NewRateLimiter(cfg)
+	rl.now = func() time.Time { return now }
+	return rl, &now
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func doRequest(h http.Handler, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// TestRateLimiterTokenBucket тестирует ограничение частоты запросов одного клиента.
+func TestRateLimiterTokenBucket(t *testing.T) {
+	rl, now := newTestLimiter(RateLimitConfig{Rate: 0.5, Burst: 2})
+	h := rl.Middleware(okHandler())
+
+	for i := 0; i < 2; i++ {
+		if w := doRequest(h, "10.0.0.1:1000", nil); w.Code != http.StatusOK {
+			t.Fatalf("Запрос %d в пределах burst должен пройти, получен %d", i, w.Code)
+		}
+	}
+
+	w := doRequest(h, "10.0.0.1:1001", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Ожидался статус 429, получен %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Ожидался Retry-After 2, получено %q", got)
+	}
+
+	// Другой клиент ограничивается независимо
+	if w := doRequest(h, "10.0.0.2:1000", nil); w.Code != http.StatusOK {
+		t.Errorf("Запрос другого клиента должен пройти, получен %d", w.Code)
+	}
+
+	// Через 2 секунды появляется один токен
+	*now = now.Add(2 * time.Second)
+	if w := doRequest(h, "10.0.0.1:1000", nil); w.Code != http.StatusOK {
+		t.Errorf("После пополнения bucket запрос должен пройти, получен %d", w.Code)
+	}
+	if w := doRequest(h, "10.0.0.1:1000", nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Ожидался статус 429, получен %d", w.Code)
+	}
+}
+
+// TestRateLimiterIdentity тестирует определение клиента по заголовкам.
+func TestRateLimiterIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity string
+		headers  map[string]string
+		expected string
+	}{
+		{name: "ip", identity: IdentityIP, headers: map[string]string{AgentIDHeader: utils.AgentToken("agent-1", "server-key")}, expected: "10.0.0.1"},
+		{name: "key", identity: IdentityKey, headers: map[string]string{AgentIDHeader: utils.AgentToken("agent-1", "server-key")}, expected: "agent:agent-1"},
+		{name: "key other agent", identity: IdentityKey, headers: map[string]string{AgentIDHeader: utils.AgentToken("agent-2", "server-key")}, expected: "agent:agent-2"},
+		{name: "key fallback to ip", identity: IdentityKey, expected: "10.0.0.1"},
+		{name: "forged agent fallback to ip", identity: IdentityKey, headers: map[string]string{AgentIDHeader: "agent-1.forged"}, expected: "10.0.0.1"},
+		{name: "other key fallback to ip", identity: IdentityKey, headers: map[string]string{AgentIDHeader: utils.AgentToken("agent-1", "other-key")}, expected: "10.0.0.1"},
+		{name: "trusted header", identity: "header:X-Forwarded-For", headers: map[string]string{"X-Forwarded-For": "192.168.1.5, 10.0.0.1"}, expected: "192.168.1.5"},
+		{name: "trusted header missing", identity: "header:X-Real-IP", expected: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiter(RateLimitConfig{Rate: 1, Identity: tt.identity, Key: "server-key"})
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			req.RemoteAddr = "10.0.0.1:5555"
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := rl.clientID(req); got != tt.expected {
+				t.Errorf("Ожидался клиент %q, получено %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestRateLimiterMaxInFlight тестирует ограничение одновременно обрабатываемых запросов.
+func TestRateLimiterMaxInFlight(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{MaxInFlight: 1})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		doRequest(h, "10.0.0.1:1000", nil)
+	}()
+	<-started
+
+	w := doRequest(h, "10.0.0.2:1000", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Ожидался статус 429 при превышении лимита одновременных запросов, получен %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Ожидался заголовок Retry-After")
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+// TestRateLimiterSweep тестирует удаление неиспользуемых bucket.
+func TestRateLimiterSweep(t *testing.T) {
+	rl, now := newTestLimiter(RateLimitConfig{Rate: 1})
+	rl.reserve("a")
+	*now = now.Add(bucketIdleTTL + bucketSweepInterval)
+	rl.reserve("b")
+
+	if _, ok := rl.buckets["a"]; ok {
+		t.Error("Неиспользуемый bucket должен быть удалён")
+	}
+	if _, ok := rl.buckets["b"]; !ok {
+		t.Error("Активный bucket должен сохраниться")
+	}
+}
+
+func TestValidateIdentity(t *testing.T) {
+	for _, identity := range []string{"", "ip", "key", "header:X-Real-IP"} {
+		if err := ValidateIdentity(identity); err != nil {
+			t.Errorf("ValidateIdentity(%q) неожиданная ошибка: %v", identity, err)
+		}
+	}
+	for _, identity := range []string{"cookie", "header:"} {
+		if err := ValidateIdentity(identity); err == nil {
+			t.Errorf("ValidateIdentity(%q) ожидалась ошибка", identity)
+		}
+	}
+}
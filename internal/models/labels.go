@@ -0,0 +1,120 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// FormatSeriesID формирует идентификатор метрики с метками в стиле Prometheus:
+// name{key1="value1",key2="value2"}. Метки сортируются по ключу, чтобы одна и та же
+// серия всегда получала одинаковый идентификатор. Без меток возвращается имя как есть.
+func FormatSeriesID(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(labels[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseSeriesID разбирает идентификатор, сформированный FormatSeriesID,
+// на имя и метки. Для идентификатора без меток возвращается пустая карта.
+func ParseSeriesID(id string) (string, map[string]string) {
+	labels := make(map[string]string)
+
+	open := strings.IndexByte(id, '{')
+	if open < 0 || !strings.HasSuffix(id, "}") {
+		return id, labels
+	}
+
+	name := id[:open]
+	body := id[open+1 : len(id)-1]
+
+	for len(body) > 0 {
+		eq := strings.Index(body, `="`)
+		if eq < 0 {
+			break
+		}
+		key := body[:eq]
+		body = body[eq+2:]
+
+		var value strings.Builder
+		i := 0
+		for ; i < len(body); i++ {
+			c := body[i]
+			if c == '\\' && i+1 < len(body) {
+				i++
+				switch body[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(body[i])
+				}
+				continue
+			}
+			if c == '"' {
+				break
+			}
+			value.WriteByte(c)
+		}
+		labels[key] = value.String()
+
+		if i >= len(body) {
+			break
+		}
+		body = strings.TrimPrefix(body[i+1:], ",")
+	}
+
+	return name, labels
+}
+
+// SanitizeLabelName приводит ключ внешнего формата к имени метки: символы кроме букв,
+// цифр и подчёркивания заменяются подчёркиванием, например service.name -> service_name
+func SanitizeLabelName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// escapeLabelValue экранирует значение метки по правилам текстового формата Prometheus
+func escapeLabelValue(v string) string {
+	if !strings.ContainsAny(v, "\\\"\n") {
+		return v
+	}
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestFormatSeriesID(t *testing.T) {
+	tests := []struct {
+		name     string
+		metric   string
+		labels   map[string]string
+		expected string
+	}{
+		{"без меток", "up", nil, "up"},
+		{"одна метка", "http_requests_total", map[string]string{"code": "200"}, `http_requests_total{code="200"}`},
+		{"сортировка меток", "req", map[string]string{"b": "2", "a": "1"}, `req{a="1",b="2"}`},
+		{"экранирование", "req", map[string]string{"path": `a"b\c`}, `req{path="a\"b\\c"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatSeriesID(tt.metric, tt.labels); got != tt.expected {
+				t.Errorf("Ожидался %q, получен %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseSeriesID(t *testing.T) {
+	labels := map[string]string{"code": "200", "path": `a"b\c`, "host": "h1"}
+	id := FormatSeriesID("http_requests_total", labels)
+
+	name, parsed := ParseSeriesID(id)
+	if name != "http_requests_total" {
+		t.Errorf("Ожидалось имя http_requests_total, получено %q", name)
+	}
+	if len(parsed) != len(labels) {
+		t.Fatalf("Ожидалось %d меток, получено %d", len(labels), len(parsed))
+	}
+	for k, v := range labels {
+		if parsed[k] != v {
+			t.Errorf("Метка %s: ожидалось %q, получено %q", k, v, parsed[k])
+		}
+	}
+
+	name, parsed = ParseSeriesID("Alloc")
+	if name != "Alloc" || len(parsed) != 0 {
+		t.Errorf("Идентификатор без меток разобран некорректно: %q %v", name, parsed)
+	}
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	tests := map[string]string{
+		"service.name": "service_name",
+		"http-route":   "http_route",
+		"code_2xx":     "code_2xx",
+		"путь":         "____",
+	}
+	for key, expected := range tests {
+		if got := SanitizeLabelName(key); got != expected {
+			t.Errorf("SanitizeLabelName(%q): ожидалось %q, получено %q", key, expected, got)
+		}
+	}
+}
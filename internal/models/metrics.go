@@ -7,3 +7,17 @@ type Metrics struct {
 	Value *float64 `json:"value,omitempty"` // для gauge
 	Hash  string   `json:"hash,omitempty"`  // хеш для проверки целостности
 }
+
+// FloatValue возвращает значение gauge или накопленное значение counter.
+// Метрика без значения возвращает 0.
+func (m Metrics) FloatValue() float64 {
+	switch {
+	case m.MType == "counter" && m.Delta != nil:
+		return float64(*m.Delta)
+	case m.Value != nil:
+		return *m.Value
+	case m.Delta != nil:
+		return float64(*m.Delta)
+	}
+	return 0
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestMetricsFloatValue(t *testing.T) {
+	value, delta := 1.5, int64(7)
+
+	tests := []struct {
+		name     string
+		metric   Metrics
+		expected float64
+	}{
+		{name: "gauge", metric: Metrics{MType: "gauge", Value: &value}, expected: 1.5},
+		{name: "counter", metric: Metrics{MType: "counter", Delta: &delta}, expected: 7},
+		{name: "counter с value", metric: Metrics{MType: "counter", Delta: &delta, Value: &value}, expected: 7},
+		{name: "без типа", metric: Metrics{Delta: &delta}, expected: 7},
+		{name: "без значения", metric: Metrics{MType: "gauge"}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.metric.FloatValue(); got != tt.expected {
+				t.Errorf("FloatValue() = %v, ожидалось %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,568 @@
+// Package otlp преобразует метрики OpenTelemetry (OTLP) в модель хранилища.
+//
+// Gauge и немонотонные Sum становятся gauge метриками. Монотонные Sum становятся counter:
+// для cumulative сумм приращение вычисляется по предыдущему значению того же потока
+// с учётом сброса, delta суммы записываются как есть. Histogram и Summary раскладываются
+// на серии _count, _sum, _bucket и квантили в стиле Prometheus. Атрибуты точки становятся
+// метками идентификатора серии, выбранные атрибуты ресурса - метками или префиксом имени.
+package otlp
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// Способы переноса атрибутов ресурса в идентификатор метрики
+const (
+	// ResourceLabels атрибуты ресурса добавляются метками серии
+	ResourceLabels = "labels"
+	// ResourcePrefix значения атрибутов ресурса через точку добавляются префиксом имени метрики
+	ResourcePrefix = "prefix"
+)
+
+// DefaultResourceAttributes атрибуты ресурса, переносимые в метрику по умолчанию
+var DefaultResourceAttributes = []string{"service.namespace", "service.name", "service.instance.id", "host.name"}
+
+// DefaultStaleAfter время, после которого забывается состояние потока, не присылавшего точки
+const DefaultStaleAfter = time.Hour
+
+// streamLocks количество блокировок состояния потоков, потоки распределяются по ним по хешу
+const streamLocks = 64
+
+// maxErrorMessages ограничивает количество сообщений об отклонённых точках в ответе
+const maxErrorMessages = 5
+
+// flagNoRecordedValue флаг точки без значения (DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK)
+const flagNoRecordedValue = uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK)
+
+// ValidateResourceMode проверяет способ переноса атрибутов ресурса
+func ValidateResourceMode(mode string) error {
+	switch mode {
+	case "", ResourceLabels, ResourcePrefix:
+		return nil
+	}
+	return fmt.Errorf("неизвестный способ переноса атрибутов ресурса %q, ожидается %s или %s",
+		mode, ResourceLabels, ResourcePrefix)
+}
+
+// Config настройки преобразования
+type Config struct {
+	// ResourceMode способ переноса атрибутов ресурса, по умолчанию ResourceLabels
+	ResourceMode string
+	// ResourceAttributes ключи переносимых атрибутов ресурса, по умолчанию DefaultResourceAttributes
+	ResourceAttributes []string
+	// StaleAfter время хранения состояния потока, по умолчанию DefaultStaleAfter
+	StaleAfter time.Duration
+}
+
+// streamState состояние потока монотонной суммы или немонотонной delta суммы
+type streamState struct {
+	// start время начала cumulative потока, смена означает перезапуск источника
+	start uint64
+	// time время последней принятой точки cumulative потока, точки не новее пропускаются
+	time uint64
+	// last последнее значение cumulative суммы или накопленная delta сумма
+	last float64
+	// carry дробная часть приращения, ещё не записанная в целочисленный counter
+	carry float64
+	seen  time.Time
+}
+
+// Converter преобразует запросы OTLP в метрики и хранит состояние потоков между запросами.
+// Безопасен для конкурентного использования: запросы с общими потоками выполняются
+// последовательно от Convert до Result.Commit или Result.Release, поэтому параллельные
+// экспорты одного потока не учитывают приращения дважды.
+type Converter struct {
+	cfg       Config
+	startedAt uint64
+	now       func() time.Time
+
+	// locks удерживаются от Convert до Commit или Release запроса
+	locks [streamLocks]sync.Mutex
+
+	mu        sync.Mutex
+	streams   map[string]streamState
+	lastSweep time.Time
+}
+
+// NewConverter создаёт Converter
+func NewConverter(cfg Config) *Converter {
+	if cfg.ResourceMode == "" {
+		cfg.ResourceMode = ResourceLabels
+	}
+	if cfg.ResourceAttributes == nil {
+		cfg.ResourceAttributes = DefaultResourceAttributes
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = DefaultStaleAfter
+	}
+	now := time.Now()
+	return &Converter{
+		cfg:       cfg,
+		startedAt: uint64(now.UnixNano()),
+		now:       time.Now,
+		streams:   make(map[string]streamState),
+		lastSweep: now,
+	}
+}
+
+// Result результат преобразования запроса
+type Result struct {
+	// Metrics метрики для Storage.UpdateBatch
+	Metrics []models.Metrics
+	// Rejected количество точек, которые не удалось преобразовать
+	Rejected int64
+	// Errors описания причин отклонения, не больше maxErrorMessages
+	Errors []string
+
+	converter *Converter
+	pending   map[string]streamState
+	// locked номера удерживаемых блокировок потоков по возрастанию
+	locked []int
+}
+
+// Commit сохраняет состояние потоков после успешной записи метрик в хранилище
+// и освобождает потоки запроса. Без Commit повторная отправка того же запроса
+// даст те же приращения.
+func (r *Result) Commit() {
+	if r.converter == nil {
+		return
+	}
+	r.converter.commit(r.pending)
+	r.Release()
+}
+
+// Release освобождает потоки запроса без сохранения состояния, например после ошибки
+// хранилища. Вызывается для каждого результата Convert; после Commit ничего не делает.
+func (r *Result) Release() {
+	if r.converter == nil {
+		return
+	}
+	for i := len(r.locked) - 1; i >= 0; i-- {
+		r.converter.locks[r.locked[i]].Unlock()
+	}
+	r.converter, r.locked = nil, nil
+}
+
+// ErrorMessage возвращает причины отклонения точек одной строкой
+func (r *Result) ErrorMessage() string {
+	return strings.Join(r.Errors, "; ")
+}
+
+// reject учитывает отклонённые точки
+func (r *Result) reject(points int, format string, args ...any) {
+	r.Rejected += int64(points)
+	if len(r.Errors) < maxErrorMessages {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// addGauge добавляет значение gauge серии, значения NaN и ±Inf отклоняются
+func (r *Result) addGauge(id string, v float64) {
+	if !finite(v) {
+		r.reject(1, "%s: non-finite value %v", id, v)
+		return
+	}
+	r.Metrics = append(r.Metrics, models.Metrics{ID: id, MType: "gauge", Value: &v})
+}
+
+func (r *Result) addCounter(id string, delta int64) {
+	r.Metrics = append(r.Metrics, models.Metrics{ID: id, MType: "counter", Delta: &delta})
+}
+
+// Convert преобразует запрос OTLP. Состояние потоков обновляется только после Result.Commit,
+// до Commit или Release потоки запроса заблокированы для других вызовов Convert.
+func (c *Converter) Convert(req *colmetricspb.ExportMetricsServiceRequest) *Result {
+	res := &Result{converter: c, pending: make(map[string]streamState)}
+	c.lockStreams(res, req)
+
+	for _, rm := range req.GetResourceMetrics() {
+		prefix, resourceLabels := c.resourceIdentity(rm.GetResource().GetAttributes())
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				if m.GetName() == "" {
+					res.reject(dataPointCount(m), "metric without name")
+					continue
+				}
+				s := series{name: prefix + m.GetName(), resourceLabels: resourceLabels}
+				c.convertMetric(res, s, m)
+			}
+		}
+	}
+	return res
+}
+
+// lockStreams захватывает блокировки потоков запроса в порядке возрастания номеров.
+// Все серии точки (_count, _sum, _bucket) защищены одной блокировкой по идентификатору точки.
+func (c *Converter) lockStreams(res *Result, req *colmetricspb.ExportMetricsServiceRequest) {
+	var need [streamLocks]bool
+	for _, rm := range req.GetResourceMetrics() {
+		prefix, resourceLabels := c.resourceIdentity(rm.GetResource().GetAttributes())
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				s := series{name: prefix + m.GetName(), resourceLabels: resourceLabels}
+				for _, attrs := range streamAttributes(m) {
+					h := fnv.New32a()
+					h.Write([]byte(s.id("", attrs)))
+					need[h.Sum32()%streamLocks] = true
+				}
+			}
+		}
+	}
+	for i := range need {
+		if need[i] {
+			c.locks[i].Lock()
+			res.locked = append(res.locked, i)
+		}
+	}
+}
+
+// streamAttributes возвращает атрибуты точек метрики, у которых есть состояние потока
+func streamAttributes(m *metricspb.Metric) [][]*commonpb.KeyValue {
+	var attrs [][]*commonpb.KeyValue
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Sum:
+		for _, dp := range data.Sum.GetDataPoints() {
+			attrs = append(attrs, dp.GetAttributes())
+		}
+	case *metricspb.Metric_Histogram:
+		for _, dp := range data.Histogram.GetDataPoints() {
+			attrs = append(attrs, dp.GetAttributes())
+		}
+	case *metricspb.Metric_Summary:
+		for _, dp := range data.Summary.GetDataPoints() {
+			attrs = append(attrs, dp.GetAttributes())
+		}
+	}
+	return attrs
+}
+
+// series имя метрики с учётом префикса ресурса и метки ресурса
+type series struct {
+	name           string
+	resourceLabels map[string]string
+}
+
+// id формирует идентификатор серии: имя с суффиксом и метки точки, дополненные метками ресурса.
+// Метки точки имеют приоритет над метками ресурса.
+func (s series) id(suffix string, attrs []*commonpb.KeyValue, extra ...string) string {
+	labels := make(map[string]string, len(attrs)+len(s.resourceLabels)+len(extra)/2)
+	for k, v := range s.resourceLabels {
+		labels[k] = v
+	}
+	for _, kv := range attrs {
+		labels[models.SanitizeLabelName(kv.GetKey())] = anyValueString(kv.GetValue())
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels[extra[i]] = extra[i+1]
+	}
+	return models.FormatSeriesID(s.name+suffix, labels)
+}
+
+// resourceIdentity возвращает префикс имени и метки из атрибутов ресурса
+func (c *Converter) resourceIdentity(attrs []*commonpb.KeyValue) (string, map[string]string) {
+	values := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		values[kv.GetKey()] = anyValueString(kv.GetValue())
+	}
+
+	if c.cfg.ResourceMode == ResourcePrefix {
+		var parts []string
+		for _, key := range c.cfg.ResourceAttributes {
+			if v := values[key]; v != "" {
+				parts = append(parts, v)
+			}
+		}
+		if len(parts) == 0 {
+			return "", nil
+		}
+		return strings.Join(parts, ".") + ".", nil
+	}
+
+	labels := make(map[string]string)
+	for _, key := range c.cfg.ResourceAttributes {
+		if v := values[key]; v != "" {
+			labels[models.SanitizeLabelName(key)] = v
+		}
+	}
+	return "", labels
+}
+
+// convertMetric преобразует точки одной метрики
+func (c *Converter) convertMetric(res *Result, s series, m *metricspb.Metric) {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, dp := range data.Gauge.GetDataPoints() {
+			if dp.GetFlags()&flagNoRecordedValue != 0 {
+				continue
+			}
+			res.addGauge(s.id("", dp.GetAttributes()), numberValue(dp))
+		}
+	case *metricspb.Metric_Sum:
+		c.convertSum(res, s, data.Sum)
+	case *metricspb.Metric_Histogram:
+		c.convertHistogram(res, s, data.Histogram)
+	case *metricspb.Metric_Summary:
+		c.convertSummary(res, s, data.Summary)
+	case *metricspb.Metric_ExponentialHistogram:
+		res.reject(len(data.ExponentialHistogram.GetDataPoints()), "%s: exponential histograms are not supported", m.GetName())
+	default:
+		res.reject(0, "%s: metric has no data", m.GetName())
+	}
+}
+
+// convertSum преобразует Sum: монотонные суммы в counter, немонотонные в gauge
+func (c *Converter) convertSum(res *Result, s series, sum *metricspb.Sum) {
+	temporality := sum.GetAggregationTemporality()
+	if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED {
+		res.reject(len(sum.GetDataPoints()), "%s: aggregation temporality is not specified", s.name)
+		return
+	}
+	cumulative := temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+
+	for _, dp := range sum.GetDataPoints() {
+		if dp.GetFlags()&flagNoRecordedValue != 0 {
+			continue
+		}
+		id := s.id("", dp.GetAttributes())
+		value := numberValue(dp)
+		if !finite(value) {
+			res.reject(1, "%s: non-finite value %v", id, value)
+			continue
+		}
+
+		switch {
+		case sum.GetIsMonotonic():
+			c.addMonotonic(res, id, cumulative, dp.GetStartTimeUnixNano(), dp.GetTimeUnixNano(), value)
+		case cumulative:
+			res.addGauge(id, value)
+		default:
+			// Немонотонная delta сумма (UpDownCounter): храним накопленное значение как gauge
+			key := "gauge:" + id
+			st := c.state(res, key)
+			st.last += value
+			st.seen = c.now()
+			res.pending[key] = st
+			res.addGauge(id, st.last)
+		}
+	}
+}
+
+// convertHistogram раскладывает Histogram на counter серии _count, _sum и _bucket{le=...}
+// и gauge серии _min и _max
+func (c *Converter) convertHistogram(res *Result, s series, h *metricspb.Histogram) {
+	temporality := h.GetAggregationTemporality()
+	if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED {
+		res.reject(len(h.GetDataPoints()), "%s: aggregation temporality is not specified", s.name)
+		return
+	}
+	cumulative := temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+
+	for _, dp := range h.GetDataPoints() {
+		if dp.GetFlags()&flagNoRecordedValue != 0 {
+			continue
+		}
+		bounds, counts := dp.GetExplicitBounds(), dp.GetBucketCounts()
+		if len(counts) > 0 && len(counts) != len(bounds)+1 {
+			res.reject(1, "%s: %d bucket counts for %d explicit bounds", s.name, len(counts), len(bounds))
+			continue
+		}
+
+		attrs, start, ts := dp.GetAttributes(), dp.GetStartTimeUnixNano(), dp.GetTimeUnixNano()
+		c.addMonotonic(res, s.id("_count", attrs), cumulative, start, ts, float64(dp.GetCount()))
+		if dp.Sum != nil {
+			c.addMonotonic(res, s.id("_sum", attrs), cumulative, start, ts, dp.GetSum())
+		}
+
+		// Счётчики корзин OTLP не накопительные, а le серии считают все значения не больше границы
+		var cumulativeCount uint64
+		for i, n := range counts {
+			cumulativeCount += n
+			le := "+Inf"
+			if i < len(bounds) {
+				le = strconv.FormatFloat(bounds[i], 'g', -1, 64)
+			}
+			c.addMonotonic(res, s.id("_bucket", attrs, "le", le), cumulative, start, ts, float64(cumulativeCount))
+		}
+
+		if dp.Min != nil {
+			res.addGauge(s.id("_min", attrs), dp.GetMin())
+		}
+		if dp.Max != nil {
+			res.addGauge(s.id("_max", attrs), dp.GetMax())
+		}
+	}
+}
+
+// convertSummary раскладывает Summary на gauge квантили и cumulative counter серии _count и _sum
+func (c *Converter) convertSummary(res *Result, s series, summary *metricspb.Summary) {
+	for _, dp := range summary.GetDataPoints() {
+		if dp.GetFlags()&flagNoRecordedValue != 0 {
+			continue
+		}
+		attrs, start, ts := dp.GetAttributes(), dp.GetStartTimeUnixNano(), dp.GetTimeUnixNano()
+		for _, q := range dp.GetQuantileValues() {
+			res.addGauge(s.id("", attrs, "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)), q.GetValue())
+		}
+		c.addMonotonic(res, s.id("_count", attrs), true, start, ts, float64(dp.GetCount()))
+		c.addMonotonic(res, s.id("_sum", attrs), true, start, ts, dp.GetSum())
+	}
+}
+
+// addMonotonic добавляет приращение монотонной суммы в counter серии id.
+//
+// Для cumulative суммы приращение - разница с предыдущим значением потока. Если значение
+// уменьшилось или сменилось время начала потока, источник перезапущен и приращением
+// считается всё значение. Первая точка потока, начавшегося до запуска сервера, только
+// запоминается: её значение могло быть уже записано до перезапуска сервера. Точки
+// cumulative потока со временем ts не новее принятой - повтор или запрос, пришедший
+// позже следующего, - пропускаются: их приращение уже учтено.
+//
+// Counter хранит целые значения, поэтому дробная часть приращения переносится
+// в следующие точки потока и не теряется.
+func (c *Converter) addMonotonic(res *Result, id string, cumulative bool, start, ts uint64, value float64) {
+	if !finite(value) {
+		res.reject(1, "%s: non-finite value %v", id, value)
+		return
+	}
+	key := "counter:" + id
+	st, known := c.lookup(res, key)
+	if cumulative && known && ts != 0 && ts <= st.time {
+		return
+	}
+
+	var delta float64
+	switch {
+	case !cumulative:
+		delta = value
+	case !known:
+		if start == 0 || start < c.startedAt {
+			res.pending[key] = streamState{start: start, time: ts, last: value, seen: c.now()}
+			return
+		}
+		delta = value
+	case value < st.last || (start != 0 && start != st.start):
+		delta = value
+	default:
+		delta = value - st.last
+	}
+
+	total := delta + st.carry
+	whole := math.Floor(total)
+	res.pending[key] = streamState{start: start, time: ts, last: value, carry: total - whole, seen: c.now()}
+	res.addCounter(id, int64(whole))
+}
+
+// lookup возвращает состояние потока с учётом изменений текущего запроса
+func (c *Converter) lookup(res *Result, key string) (streamState, bool) {
+	if st, ok := res.pending[key]; ok {
+		return st, true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.streams[key]
+	return st, ok
+}
+
+// state возвращает состояние потока или пустое состояние для нового потока
+func (c *Converter) state(res *Result, key string) streamState {
+	st, _ := c.lookup(res, key)
+	return st
+}
+
+// commit сохраняет состояние потоков и периодически удаляет устаревшие потоки
+func (c *Converter) commit(pending map[string]streamState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, st := range pending {
+		c.streams[key] = st
+	}
+
+	now := c.now()
+	if now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+	for key, st := range c.streams {
+		if now.Sub(st.seen) > c.cfg.StaleAfter {
+			delete(c.streams, key)
+		}
+	}
+}
+
+// Streams возвращает количество отслеживаемых потоков
+func (c *Converter) Streams() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.streams)
+}
+
+// numberValue возвращает значение точки как float64
+func numberValue(dp *metricspb.NumberDataPoint) float64 {
+	if v, ok := dp.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+	return dp.GetAsDouble()
+}
+
+// finite сообщает, что значение не NaN и не ±Inf
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// dataPointCount возвращает количество точек метрики
+func dataPointCount(m *metricspb.Metric) int {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		return len(data.Gauge.GetDataPoints())
+	case *metricspb.Metric_Sum:
+		return len(data.Sum.GetDataPoints())
+	case *metricspb.Metric_Histogram:
+		return len(data.Histogram.GetDataPoints())
+	case *metricspb.Metric_ExponentialHistogram:
+		return len(data.ExponentialHistogram.GetDataPoints())
+	case *metricspb.Metric_Summary:
+		return len(data.Summary.GetDataPoints())
+	}
+	return 0
+}
+
+// anyValueString возвращает строковое представление значения атрибута
+func anyValueString(v *commonpb.AnyValue) string {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(value.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(value.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(value.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return fmt.Sprintf("%x", value.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		parts := make([]string, 0, len(value.ArrayValue.GetValues()))
+		for _, item := range value.ArrayValue.GetValues() {
+			parts = append(parts, anyValueString(item))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case *commonpb.AnyValue_KvlistValue:
+		parts := make([]string, 0, len(value.KvlistValue.GetValues()))
+		for _, kv := range value.KvlistValue.GetValues() {
+			parts = append(parts, kv.GetKey()+"="+anyValueString(kv.GetValue()))
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+	return ""
+}
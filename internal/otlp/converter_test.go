@@ -0,0 +1,380 @@
+package otlp
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func attr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+// request оборачивает метрики в запрос с ресурсом service.name=api
+func request(metrics ...*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:     &resourcepb.Resource{Attributes: []*commonpb.KeyValue{attr("service.name", "api"), attr("process.pid", "42")}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: metrics}},
+		}},
+	}
+}
+
+func cumulativeSum(name string, start uint64, value float64) *metricspb.Metric {
+	return cumulativeSumAt(name, start, 0, value)
+}
+
+// cumulativeSumAt cumulative сумма с временем точки ts
+func cumulativeSumAt(name string, start, ts uint64, value float64) *metricspb.Metric {
+	return &metricspb.Metric{Name: name, Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+		AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		IsMonotonic:            true,
+		DataPoints: []*metricspb.NumberDataPoint{{
+			StartTimeUnixNano: start,
+			TimeUnixNano:      ts,
+			Attributes:        []*commonpb.KeyValue{attr("code", "200")},
+			Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+		}},
+	}}}
+}
+
+func toMap(metrics []models.Metrics) map[string]models.Metrics {
+	result := make(map[string]models.Metrics, len(metrics))
+	for _, m := range metrics {
+		result[m.ID] = m
+	}
+	return result
+}
+
+// convert преобразует запрос и сразу фиксирует состояние потоков
+func convert(c *Converter, req *colmetricspb.ExportMetricsServiceRequest) map[string]models.Metrics {
+	res := c.Convert(req)
+	res.Commit()
+	return toMap(res.Metrics)
+}
+
+func TestConvertGauge(t *testing.T) {
+	c := NewConverter(Config{})
+	metrics := convert(c, request(&metricspb.Metric{Name: "temperature", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+		DataPoints: []*metricspb.NumberDataPoint{
+			{Attributes: []*commonpb.KeyValue{attr("room.id", "1")}, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 21.5}},
+			{Attributes: []*commonpb.KeyValue{attr("room.id", "2")}, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 19}},
+			{Flags: flagNoRecordedValue},
+		},
+	}}}))
+
+	if len(metrics) != 2 {
+		t.Fatalf("Ожидалось 2 метрики, получено %d: %v", len(metrics), metrics)
+	}
+	m, ok := metrics[`temperature{room_id="1",service_name="api"}`]
+	if !ok || m.MType != "gauge" || *m.Value != 21.5 {
+		t.Errorf("Неверная gauge метрика: %v", metrics)
+	}
+	if m := metrics[`temperature{room_id="2",service_name="api"}`]; m.Value == nil || *m.Value != 19 {
+		t.Errorf("Целое значение должно сохраняться как gauge: %v", metrics)
+	}
+}
+
+func TestConvertResourcePrefix(t *testing.T) {
+	c := NewConverter(Config{ResourceMode: ResourcePrefix})
+	metrics := convert(c, request(&metricspb.Metric{Name: "up", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+		DataPoints: []*metricspb.NumberDataPoint{{Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 1}}},
+	}}}))
+
+	if _, ok := metrics["api.up"]; !ok {
+		t.Errorf("Ожидалась метрика с префиксом ресурса api.up, получено %v", metrics)
+	}
+}
+
+func TestConvertCumulativeSum(t *testing.T) {
+	c := NewConverter(Config{})
+	id := `requests{code="200",service_name="api"}`
+	start := c.startedAt + 1
+
+	steps := []struct {
+		name  string
+		start uint64
+		value float64
+		delta int64
+	}{
+		{"первая точка после запуска сервера", start, 10, 10},
+		{"приращение", start, 15, 5},
+		{"без изменений", start, 15, 0},
+		{"сброс по уменьшению значения", start, 3, 3},
+		{"сброс по смене времени начала", start + 100, 4, 4},
+	}
+	for _, step := range steps {
+		metrics := convert(c, request(cumulativeSum("requests", step.start, step.value)))
+		m, ok := metrics[id]
+		if !ok || m.MType != "counter" || *m.Delta != step.delta {
+			t.Errorf("%s: ожидалось приращение %d, получено %v", step.name, step.delta, metrics)
+		}
+	}
+}
+
+func TestConvertCumulativeSum_StartedBeforeServer(t *testing.T) {
+	c := NewConverter(Config{})
+	start := c.startedAt - uint64(time.Hour)
+
+	// Значение, накопленное до запуска сервера, могло быть уже записано
+	if metrics := convert(c, request(cumulativeSum("requests", start, 100))); len(metrics) != 0 {
+		t.Errorf("Первая точка старого потока должна только запоминаться, получено %v", metrics)
+	}
+	metrics := convert(c, request(cumulativeSum("requests", start, 104)))
+	if m := metrics[`requests{code="200",service_name="api"}`]; m.Delta == nil || *m.Delta != 4 {
+		t.Errorf("Ожидалось приращение 4, получено %v", metrics)
+	}
+}
+
+func TestConvertCumulativeSum_Carry(t *testing.T) {
+	c := NewConverter(Config{})
+	start := c.startedAt + 1
+	id := `bytes{code="200",service_name="api"}`
+
+	var total int64
+	for _, v := range []float64{0.4, 0.8, 1.2, 2.6} {
+		metrics := convert(c, request(cumulativeSum("bytes", start, v)))
+		total += *metrics[id].Delta
+	}
+	if total != 2 {
+		t.Errorf("Дробные приращения должны накапливаться: ожидалось 2, получено %d", total)
+	}
+}
+
+func TestConvertWithoutCommit(t *testing.T) {
+	c := NewConverter(Config{})
+	start := c.startedAt + 1
+	convert(c, request(cumulativeSum("requests", start, 10)))
+
+	// Запрос не записан в хранилище: повтор должен дать то же приращение
+	req := request(cumulativeSum("requests", start, 15))
+	res := c.Convert(req)
+	res.Release()
+	first := toMap(res.Metrics)
+	res = c.Convert(req)
+	res.Release()
+	second := toMap(res.Metrics)
+	id := `requests{code="200",service_name="api"}`
+	if *first[id].Delta != 5 || *second[id].Delta != 5 {
+		t.Errorf("Без Commit состояние не должно меняться: %v, %v", first, second)
+	}
+}
+
+func TestConvertCumulativeSum_OutOfOrder(t *testing.T) {
+	c := NewConverter(Config{})
+	start := c.startedAt + 1
+	id := `requests{code="200",service_name="api"}`
+
+	steps := []struct {
+		name  string
+		ts    uint64
+		value float64
+		delta int64
+	}{
+		{"первая точка", start + 10, 10, 10},
+		{"новая точка", start + 30, 15, 5},
+		{"запоздавшая точка с меньшим значением", start + 20, 12, 0},
+		{"повтор принятой точки", start + 30, 15, 0},
+		{"следующая точка", start + 40, 17, 2},
+	}
+	for _, step := range steps {
+		metrics := convert(c, request(cumulativeSumAt("requests", start, step.ts, step.value)))
+		var delta int64
+		if m, ok := metrics[id]; ok {
+			delta = *m.Delta
+		}
+		if delta != step.delta {
+			t.Errorf("%s: ожидалось приращение %d, получено %v", step.name, step.delta, metrics)
+		}
+	}
+}
+
+func TestConvertConcurrentExports(t *testing.T) {
+	c := NewConverter(Config{})
+	start := c.startedAt + 1
+	id := `requests{code="200",service_name="api"}`
+	convert(c, request(cumulativeSumAt("requests", start, start+10, 10)))
+
+	// Повторы экспорта приходят, пока первый ещё записывается в хранилище
+	req := request(cumulativeSumAt("requests", start, start+20, 15))
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total int64
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := c.Convert(req)
+			defer res.Release()
+			if m, ok := toMap(res.Metrics)[id]; ok {
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				total += *m.Delta
+				mu.Unlock()
+			}
+			res.Commit()
+		}()
+	}
+	wg.Wait()
+
+	if total != 5 {
+		t.Errorf("Приращение параллельных экспортов должно учитываться один раз: ожидалось 5, получено %d", total)
+	}
+}
+
+func TestConvertReleaseWithoutCommit(t *testing.T) {
+	c := NewConverter(Config{})
+	req := request(cumulativeSum("requests", c.startedAt+1, 10))
+
+	res := c.Convert(req)
+	res.Release()
+	res.Release()
+	res.Commit()
+
+	// Потоки освобождены: следующий Convert не блокируется, состояние не сохранено
+	if metrics := convert(c, req); *metrics[`requests{code="200",service_name="api"}`].Delta != 10 {
+		t.Errorf("Release не должен сохранять состояние: %v", metrics)
+	}
+}
+
+func TestConvertDeltaSums(t *testing.T) {
+	c := NewConverter(Config{ResourceAttributes: []string{}})
+	sum := func(monotonic bool, value int64) *metricspb.Metric {
+		return &metricspb.Metric{Name: "items", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			IsMonotonic:            monotonic,
+			DataPoints:             []*metricspb.NumberDataPoint{{Value: &metricspb.NumberDataPoint_AsInt{AsInt: value}}},
+		}}}
+	}
+
+	if m := convert(c, request(sum(true, 7)))["items"]; m.MType != "counter" || *m.Delta != 7 {
+		t.Errorf("Монотонная delta сумма должна стать counter 7, получено %+v", m)
+	}
+
+	c = NewConverter(Config{ResourceAttributes: []string{}})
+	convert(c, request(sum(false, 5)))
+	if m := convert(c, request(sum(false, -2)))["items"]; m.MType != "gauge" || *m.Value != 3 {
+		t.Errorf("Немонотонная delta сумма должна накапливаться в gauge 3, получено %+v", m)
+	}
+}
+
+func TestConvertHistogram(t *testing.T) {
+	c := NewConverter(Config{ResourceAttributes: []string{}})
+	sum, minValue, maxValue := 12.5, 0.1, 9.0
+	metrics := convert(c, request(&metricspb.Metric{Name: "latency", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+		AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+		DataPoints: []*metricspb.HistogramDataPoint{{
+			Count:          6,
+			Sum:            &sum,
+			Min:            &minValue,
+			Max:            &maxValue,
+			ExplicitBounds: []float64{0.5, 1},
+			BucketCounts:   []uint64{1, 2, 3},
+		}},
+	}}}))
+
+	counters := map[string]int64{
+		"latency_count":             6,
+		"latency_sum":               12,
+		`latency_bucket{le="0.5"}`:  1,
+		`latency_bucket{le="1"}`:    3,
+		`latency_bucket{le="+Inf"}`: 6,
+	}
+	for id, want := range counters {
+		m, ok := metrics[id]
+		if !ok || m.MType != "counter" || *m.Delta != want {
+			t.Errorf("%s: ожидался counter %d, получено %+v", id, want, m)
+		}
+	}
+	if m := metrics["latency_max"]; m.Value == nil || *m.Value != 9 {
+		t.Errorf("Ожидался gauge latency_max 9, получено %+v", m)
+	}
+	if m := metrics["latency_min"]; m.Value == nil || *m.Value != 0.1 {
+		t.Errorf("Ожидался gauge latency_min 0.1, получено %+v", m)
+	}
+}
+
+func TestConvertRejected(t *testing.T) {
+	c := NewConverter(Config{})
+	res := c.Convert(request(
+		&metricspb.Metric{Name: "exp", Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+			DataPoints: []*metricspb.ExponentialHistogramDataPoint{{}, {}},
+		}}},
+		&metricspb.Metric{Name: "bad", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			DataPoints:             []*metricspb.HistogramDataPoint{{ExplicitBounds: []float64{1}, BucketCounts: []uint64{1}}},
+		}}},
+	))
+
+	if res.Rejected != 3 {
+		t.Errorf("Ожидалось 3 отклонённые точки, получено %d", res.Rejected)
+	}
+	if len(res.Metrics) != 0 || res.ErrorMessage() == "" {
+		t.Errorf("Ожидались только ошибки, получено %v, %q", res.Metrics, res.ErrorMessage())
+	}
+}
+
+func TestConvertNonFinite(t *testing.T) {
+	c := NewConverter(Config{ResourceAttributes: []string{}})
+	upDown := func(value float64) *metricspb.Metric {
+		return &metricspb.Metric{Name: "queue", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			DataPoints:             []*metricspb.NumberDataPoint{{Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: value}}},
+		}}}
+	}
+	res := c.Convert(request(
+		&metricspb.Metric{Name: "temperature", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+			DataPoints: []*metricspb.NumberDataPoint{{Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: math.NaN()}}},
+		}}},
+		cumulativeSum("requests", 0, math.Inf(1)),
+		upDown(math.Inf(-1)),
+	))
+	res.Commit()
+
+	if res.Rejected != 3 {
+		t.Errorf("Ожидалось 3 отклонённые точки, получено %d", res.Rejected)
+	}
+	if len(res.Metrics) != 0 || res.ErrorMessage() == "" {
+		t.Errorf("Значения NaN и ±Inf не должны записываться, получено %v, %q", res.Metrics, res.ErrorMessage())
+	}
+
+	if m := convert(c, request(upDown(2)))["queue"]; m.Value == nil || *m.Value != 2 {
+		t.Errorf("Отклонённое значение не должно попадать в накопленную сумму, получено %+v", m)
+	}
+}
+
+func TestConverterSweepsStaleStreams(t *testing.T) {
+	c := NewConverter(Config{StaleAfter: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	convert(c, request(cumulativeSum("requests", c.startedAt+1, 1)))
+	if c.Streams() != 1 {
+		t.Fatalf("Ожидался 1 поток, получено %d", c.Streams())
+	}
+
+	now = now.Add(time.Hour)
+	convert(c, request())
+	if c.Streams() != 0 {
+		t.Errorf("Устаревший поток должен быть удалён, осталось %d", c.Streams())
+	}
+}
+
+func TestValidateResourceMode(t *testing.T) {
+	for _, mode := range []string{"", ResourceLabels, ResourcePrefix} {
+		if err := ValidateResourceMode(mode); err != nil {
+			t.Errorf("Режим %q должен быть допустим: %v", mode, err)
+		}
+	}
+	if ValidateResourceMode("suffix") == nil {
+		t.Error("Ожидалась ошибка для неизвестного режима")
+	}
+}
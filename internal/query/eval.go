@@ -0,0 +1,408 @@
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// Типы результата
+const (
+	// TypeScalar результат - одно число без имени и меток
+	TypeScalar = "scalar"
+	// TypeVector результат - набор серий
+	TypeVector = "vector"
+)
+
+// Series серия снимка хранилища
+type Series struct {
+	Type   string
+	ID     string
+	Name   string
+	Labels map[string]string
+	// Value значение gauge или накопленное значение counter
+	Value float64
+}
+
+// Snapshot значения всех серий хранилища на момент снятия, упорядоченные по ID и типу
+type Snapshot []Series
+
+// TakeSnapshot постранично читает все метрики хранилища. Все селекторы запроса
+// вычисляются по одному снимку, поэтому видят согласованные значения.
+func TakeSnapshot(s storage.Storage) (Snapshot, error) {
+	query := storage.MetricsQuery{Limit: storage.MaxListLimit}
+
+	var snapshot Snapshot
+	for {
+		page, err := s.ListMetrics(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, metric := range page.Metrics {
+			if metric.Value == nil && metric.Delta == nil {
+				continue
+			}
+			series := Series{Type: metric.MType, ID: metric.ID, Value: metric.FloatValue()}
+			series.Name, series.Labels = models.ParseSeriesID(metric.ID)
+			if len(series.Labels) == 0 {
+				series.Labels = nil
+			}
+			snapshot = append(snapshot, series)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	slices.SortFunc(snapshot, func(a, b Series) int {
+		return cmp.Or(strings.Compare(a.ID, b.ID), strings.Compare(a.Type, b.Type))
+	})
+	return snapshot, nil
+}
+
+// Float число результата. NaN и бесконечности, например от деления на ноль,
+// кодируются в JSON строками "NaN", "+Inf" и "-Inf".
+type Float float64
+
+// MarshalJSON кодирует конечные значения числом, остальные - строкой
+func (f Float) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	switch {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Inf"`), nil
+	}
+	return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+}
+
+// Sample значение серии результата. Агрегации и операции между сериями
+// отбрасывают имя метрики, topk, bottomk и операции с числом его сохраняют.
+type Sample struct {
+	Metric string            `json:"metric,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  Float             `json:"value"`
+}
+
+// Result результат запроса. Скалярный результат содержит одну серию без имени и меток.
+type Result struct {
+	// Query каноническая запись запроса
+	Query  string   `json:"query"`
+	Type   string   `json:"type"`
+	Series []Sample `json:"series"`
+}
+
+// value промежуточный результат: число или набор серий
+type value struct {
+	scalar   float64
+	vector   []Sample
+	isVector bool
+}
+
+// Eval вычисляет выражение по снимку. Результат детерминирован: серии упорядочены
+// по имени и меткам, а результаты topk и bottomk - по группам и рангу.
+func Eval(e Expr, snapshot Snapshot) (Result, error) {
+	v, err := eval(e, snapshot)
+	if err != nil {
+		return Result{}, err
+	}
+	if !v.isVector {
+		return Result{Query: e.String(), Type: TypeScalar, Series: []Sample{{Value: Float(v.scalar)}}}, nil
+	}
+	if v.vector == nil {
+		v.vector = make([]Sample, 0)
+	}
+	return Result{Query: e.String(), Type: TypeVector, Series: v.vector}, nil
+}
+
+// evalError возвращает ошибку вычисления выражения
+func evalError(e Expr, format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidQuery, e, fmt.Sprintf(format, args...))
+}
+
+func eval(e Expr, snapshot Snapshot) (value, error) {
+	switch e := e.(type) {
+	case *NumberLiteral:
+		return value{scalar: e.Value}, nil
+	case *Selector:
+		return value{vector: selectSeries(e, snapshot), isVector: true}, nil
+	case *UnaryExpr:
+		v, err := eval(e.Expr, snapshot)
+		if err != nil {
+			return value{}, err
+		}
+		if !v.isVector {
+			return value{scalar: -v.scalar}, nil
+		}
+		for i := range v.vector {
+			v.vector[i].Value = -v.vector[i].Value
+		}
+		return v, nil
+	case *BinaryExpr:
+		return evalBinary(e, snapshot)
+	case *Aggregation:
+		return evalAggregation(e, snapshot)
+	}
+	return value{}, evalError(e, "unsupported expression")
+}
+
+// selectSeries возвращает серии снимка, подходящие под селектор
+func selectSeries(sel *Selector, snapshot Snapshot) []Sample {
+	var result []Sample
+	for _, s := range snapshot {
+		if !matchPattern(sel.Pattern, s.Name) || !matchLabels(sel.Matchers, s.Labels) {
+			continue
+		}
+		result = append(result, Sample{Metric: s.Name, Labels: s.Labels, Value: Float(s.Value)})
+	}
+	sortSamples(result)
+	return result
+}
+
+// matchPattern сопоставляет имя с шаблоном, где * - любая последовательность символов
+func matchPattern(pattern, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return len(name) >= len(last) && strings.HasSuffix(name, last)
+}
+
+func matchLabels(matchers []LabelMatcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if (labels[m.Name] == m.Value) == m.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// arith применяет арифметический оператор. Деление на ноль даёт ±Inf или NaN по IEEE 754.
+func arith(op string, a, b float64) float64 {
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	}
+	return a / b
+}
+
+// evalBinary вычисляет операцию между числами и сериями. Операция между серией и числом
+// применяется к каждой серии. Серии двух наборов сопоставляются по меткам без учёта
+// имени, каждой серии должна соответствовать не более одной серии другого набора.
+func evalBinary(e *BinaryExpr, snapshot Snapshot) (value, error) {
+	lhs, err := eval(e.LHS, snapshot)
+	if err != nil {
+		return value{}, err
+	}
+	rhs, err := eval(e.RHS, snapshot)
+	if err != nil {
+		return value{}, err
+	}
+
+	switch {
+	case !lhs.isVector && !rhs.isVector:
+		return value{scalar: arith(e.Op, lhs.scalar, rhs.scalar)}, nil
+	case !rhs.isVector:
+		for i := range lhs.vector {
+			lhs.vector[i].Value = Float(arith(e.Op, float64(lhs.vector[i].Value), rhs.scalar))
+		}
+		return lhs, nil
+	case !lhs.isVector:
+		for i := range rhs.vector {
+			rhs.vector[i].Value = Float(arith(e.Op, lhs.scalar, float64(rhs.vector[i].Value)))
+		}
+		return rhs, nil
+	}
+
+	right := make(map[string]Sample, len(rhs.vector))
+	for _, s := range rhs.vector {
+		key := models.FormatSeriesID("", s.Labels)
+		if _, ok := right[key]; ok {
+			return value{}, evalError(e, "several right-hand series match labels %s", labelsString(s.Labels))
+		}
+		right[key] = s
+	}
+	seen := make(map[string]bool, len(lhs.vector))
+	result := value{isVector: true}
+	for _, s := range lhs.vector {
+		key := models.FormatSeriesID("", s.Labels)
+		if seen[key] {
+			return value{}, evalError(e, "several left-hand series match labels %s", labelsString(s.Labels))
+		}
+		seen[key] = true
+		if r, ok := right[key]; ok {
+			result.vector = append(result.vector, Sample{
+				Labels: s.Labels,
+				Value:  Float(arith(e.Op, float64(s.Value), float64(r.Value))),
+			})
+		}
+	}
+	sortSamples(result.vector)
+	return result, nil
+}
+
+// labelsString форматирует метки для сообщений об ошибках
+func labelsString(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "{}"
+	}
+	return models.FormatSeriesID("", labels)
+}
+
+// group серии одной группы агрегации
+type group struct {
+	labels  map[string]string
+	samples []Sample
+}
+
+// evalAggregation вычисляет агрегацию по группам значений меток By. Без By все серии
+// составляют одну группу. Пустой набор серий даёт пустой результат.
+func evalAggregation(e *Aggregation, snapshot Snapshot) (value, error) {
+	k := 0
+	if e.Param != nil {
+		param, err := eval(e.Param, snapshot)
+		if err != nil {
+			return value{}, err
+		}
+		if param.isVector {
+			return value{}, evalError(e, "%s parameter must be a number", e.Op)
+		}
+		if math.IsNaN(param.scalar) || param.scalar < 0 || param.scalar > math.MaxInt32 {
+			return value{}, evalError(e, "%s parameter must be a non-negative integer", e.Op)
+		}
+		k = int(param.scalar)
+	}
+
+	v, err := eval(e.Expr, snapshot)
+	if err != nil {
+		return value{}, err
+	}
+	if !v.isVector {
+		return value{}, evalError(e, "%s expects series, got a number", e.Op)
+	}
+
+	groups := make(map[string]*group)
+	keys := make([]string, 0)
+	for _, s := range v.vector {
+		labels := make(map[string]string, len(e.By))
+		for _, name := range e.By {
+			if v := s.Labels[name]; v != "" {
+				labels[name] = v
+			}
+		}
+		key := models.FormatSeriesID("", labels)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.samples = append(g.samples, s)
+	}
+	slices.Sort(keys)
+
+	result := value{isVector: true}
+	for _, key := range keys {
+		g := groups[key]
+		if e.Op == OpTopK || e.Op == OpBottomK {
+			result.vector = append(result.vector, rank(g.samples, k, e.Op == OpTopK)...)
+			continue
+		}
+		sample := Sample{Value: Float(reduce(e.Op, g.samples))}
+		if len(g.labels) > 0 {
+			sample.Labels = g.labels
+		}
+		result.vector = append(result.vector, sample)
+	}
+	return result, nil
+}
+
+// reduce вычисляет sum, avg, min, max или count значений группы
+func reduce(op string, samples []Sample) float64 {
+	if op == OpCount {
+		return float64(len(samples))
+	}
+	acc := float64(samples[0].Value)
+	for _, s := range samples[1:] {
+		v := float64(s.Value)
+		switch op {
+		case OpSum, OpAvg:
+			acc += v
+		case OpMin:
+			if v < acc || math.IsNaN(acc) {
+				acc = v
+			}
+		case OpMax:
+			if v > acc || math.IsNaN(acc) {
+				acc = v
+			}
+		}
+	}
+	if op == OpAvg {
+		acc /= float64(len(samples))
+	}
+	return acc
+}
+
+// rank возвращает k серий с наибольшими (top) или наименьшими значениями.
+// NaN считается меньше любого значения, равные значения упорядочиваются по имени и меткам.
+func rank(samples []Sample, k int, top bool) []Sample {
+	sorted := slices.Clone(samples)
+	slices.SortStableFunc(sorted, func(a, b Sample) int {
+		c := compareValues(float64(a.Value), float64(b.Value))
+		if top {
+			c = -c
+		}
+		return cmp.Or(c, compareSamples(a, b))
+	})
+	return sorted[:min(k, len(sorted))]
+}
+
+// compareValues сравнивает числа, считая NaN наименьшим
+func compareValues(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	}
+	return cmp.Compare(a, b)
+}
+
+// compareSamples упорядочивает серии по имени и меткам
+func compareSamples(a, b Sample) int {
+	return cmp.Or(
+		strings.Compare(a.Metric, b.Metric),
+		strings.Compare(models.FormatSeriesID("", a.Labels), models.FormatSeriesID("", b.Labels)),
+	)
+}
+
+func sortSamples(samples []Sample) {
+	slices.SortStableFunc(samples, compareSamples)
+}
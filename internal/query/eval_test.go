@@ -0,0 +1,237 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// testStorage хранилище с CPU, памятью и counter метриками с метками
+func testStorage() storage.Storage {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("CPUutilization1", 10)
+	s.UpdateGauge("CPUutilization2", 30)
+	s.UpdateGauge("CPUutilization3", 20)
+	s.UpdateGauge("HeapInuse", 50)
+	s.UpdateGauge("HeapSys", 200)
+	s.UpdateGauge("Zero", 0)
+	s.UpdateCounter("PollCount", 3)
+	s.UpdateCounter(`requests{code="200",host="a"}`, 5)
+	s.UpdateCounter(`requests{code="500",host="a"}`, 1)
+	s.UpdateCounter(`requests{code="200",host="b"}`, 7)
+	return s
+}
+
+func testSnapshot() Snapshot {
+	snapshot, err := TakeSnapshot(testStorage())
+	if err != nil {
+		panic(err)
+	}
+	return snapshot
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	snapshot := testSnapshot()
+	if len(snapshot) != 10 {
+		t.Fatalf("ожидалось 10 серий, получено %d", len(snapshot))
+	}
+	for i := 1; i < len(snapshot); i++ {
+		if snapshot[i-1].ID > snapshot[i].ID {
+			t.Errorf("серии не упорядочены: %s перед %s", snapshot[i-1].ID, snapshot[i].ID)
+		}
+	}
+	s := snapshot[len(snapshot)-1]
+	if s.Type != string(storage.Counter) || s.Name != "requests" || s.Value != 1 ||
+		!reflect.DeepEqual(s.Labels, map[string]string{"code": "500", "host": "a"}) {
+		t.Errorf("неожиданная серия %+v", s)
+	}
+}
+
+func TestEval(t *testing.T) {
+	a200 := map[string]string{"code": "200", "host": "a"}
+	a500 := map[string]string{"code": "500", "host": "a"}
+	b200 := map[string]string{"code": "200", "host": "b"}
+
+	tests := []struct {
+		query    string
+		wantType string
+		want     []Sample
+	}{
+		{query: "sum(CPUutilization*)", wantType: TypeVector, want: []Sample{{Value: 60}}},
+		{query: "avg(CPUutilization*)", wantType: TypeVector, want: []Sample{{Value: 20}}},
+		{query: "min(CPUutilization*)", wantType: TypeVector, want: []Sample{{Value: 10}}},
+		{query: "max(CPUutilization*)", wantType: TypeVector, want: []Sample{{Value: 30}}},
+		{query: "count(*)", wantType: TypeVector, want: []Sample{{Value: 10}}},
+		{query: "sum(Missing*)", wantType: TypeVector, want: []Sample{}},
+		{query: "HeapInuse / HeapSys", wantType: TypeVector, want: []Sample{{Value: 0.25}}},
+		{query: "HeapInuse / HeapSys * 100", wantType: TypeVector, want: []Sample{{Value: 25}}},
+		{query: "(1 + 2) * 3", wantType: TypeScalar, want: []Sample{{Value: 9}}},
+		{query: "CPUutilization* / 10", wantType: TypeVector, want: []Sample{
+			{Metric: "CPUutilization1", Value: 1},
+			{Metric: "CPUutilization2", Value: 3},
+			{Metric: "CPUutilization3", Value: 2},
+		}},
+		{query: "100 - CPUutilization1", wantType: TypeVector, want: []Sample{{Metric: "CPUutilization1", Value: 90}}},
+		{query: "-PollCount", wantType: TypeVector, want: []Sample{{Metric: "PollCount", Value: -3}}},
+		{query: "topk(2, CPUutilization*)", wantType: TypeVector, want: []Sample{
+			{Metric: "CPUutilization2", Value: 30},
+			{Metric: "CPUutilization3", Value: 20},
+		}},
+		{query: "bottomk(1, CPUutilization*)", wantType: TypeVector, want: []Sample{{Metric: "CPUutilization1", Value: 10}}},
+		{query: "topk(0, CPUutilization*)", wantType: TypeVector, want: []Sample{}},
+		{query: `requests{host="a"}`, wantType: TypeVector, want: []Sample{
+			{Metric: "requests", Labels: a200, Value: 5},
+			{Metric: "requests", Labels: a500, Value: 1},
+		}},
+		{query: `requests{code!="200"}`, wantType: TypeVector, want: []Sample{{Metric: "requests", Labels: a500, Value: 1}}},
+		{query: `requests{host=""}`, wantType: TypeVector, want: []Sample{}},
+		{query: "sum by (host) (requests)", wantType: TypeVector, want: []Sample{
+			{Labels: map[string]string{"host": "a"}, Value: 6},
+			{Labels: map[string]string{"host": "b"}, Value: 7},
+		}},
+		{query: "sum by (code) (requests) / sum(requests)", wantType: TypeVector, want: []Sample{}},
+		{query: "topk by (host) (1, requests)", wantType: TypeVector, want: []Sample{
+			{Metric: "requests", Labels: a200, Value: 5},
+			{Metric: "requests", Labels: b200, Value: 7},
+		}},
+		{query: "count by (missing) (requests)", wantType: TypeVector, want: []Sample{{Value: 3}}},
+		{query: "requests / requests", wantType: TypeVector, want: []Sample{
+			{Labels: a200, Value: 1},
+			{Labels: b200, Value: 1},
+			{Labels: a500, Value: 1},
+		}},
+	}
+
+	snapshot := testSnapshot()
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			e, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := Eval(e, snapshot)
+			if err != nil {
+				t.Fatalf("Eval(%q) вернул ошибку: %v", tt.query, err)
+			}
+			if result.Type != tt.wantType {
+				t.Errorf("тип %s, ожидался %s", result.Type, tt.wantType)
+			}
+			if !reflect.DeepEqual(result.Series, tt.want) {
+				t.Errorf("Eval(%q) = %+v, ожидалось %+v", tt.query, result.Series, tt.want)
+			}
+		})
+	}
+}
+
+func TestEval_DivisionByZero(t *testing.T) {
+	e, err := Parse("HeapInuse / Zero")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Eval(e, testSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Series) != 1 || !math.IsInf(float64(result.Series[0].Value), 1) {
+		t.Fatalf("ожидалось +Inf, получено %+v", result.Series)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"query":"(HeapInuse / Zero)","type":"vector","series":[{"value":"+Inf"}]}`
+	if string(data) != want {
+		t.Errorf("JSON %s, ожидалось %s", data, want)
+	}
+}
+
+func TestEval_Deterministic(t *testing.T) {
+	const query = "topk(3, *) + 0"
+	e, err := Parse(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first []byte
+	for i := 0; i < 20; i++ {
+		// Новое хранилище каждый раз: порядок обхода map внутри не должен влиять на результат
+		snapshot, err := TakeSnapshot(testStorage())
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := Eval(e, snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = data
+		} else if string(data) != string(first) {
+			t.Fatalf("результат изменился: %s, ранее %s", data, first)
+		}
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "sum(1)", want: "sum expects series, got a number"},
+		{query: "topk(CPU*, CPU*)", want: "topk parameter must be a number"},
+		{query: "topk(-1, CPU*)", want: "topk parameter must be a non-negative integer"},
+		{query: "topk(0 / 0, CPU*)", want: "topk parameter must be a non-negative integer"},
+		{query: "CPUutilization* / HeapSys", want: "several left-hand series match labels {}"},
+		{query: "HeapSys / CPUutilization*", want: "several right-hand series match labels {}"},
+	}
+
+	snapshot := testSnapshot()
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			e, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Eval(e, snapshot)
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("Eval(%q) = %v, ожидалась ErrInvalidQuery", tt.query, err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %q должна содержать %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"CPU*", "CPUutilization1", true},
+		{"CPU*", "CPU", true},
+		{"CPU*", "xCPU", false},
+		{"*Sys", "HeapSys", true},
+		{"Heap*Sys", "HeapSys", true},
+		{"Heap*Sys", "HeapIdleSys", true},
+		{"Heap*Sys", "HeapSysX", false},
+		{"a*a", "a", false},
+		{"*a*a*", "aa", true},
+		{"*", "", true},
+		{"HeapSys", "HeapSys", true},
+		{"HeapSys", "HeapSys2", false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, ожидалось %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// tokenKind вид лексемы
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokName
+	tokString
+	tokLParen
+	tokRParen
+	tokLBrace
+	tokRBrace
+	tokComma
+	tokEq
+	tokNeq
+	tokAdd
+	tokSub
+	tokMul
+	tokDiv
+)
+
+// token лексема с позицией первого байта в запросе
+type token struct {
+	kind tokenKind
+	pos  int
+	// text имя, шаблон или значение строки
+	text string
+	num  float64
+}
+
+// String описывает лексему в сообщениях об ошибках
+func (t token) String() string {
+	switch t.kind {
+	case tokNumber:
+		return strconv.FormatFloat(t.num, 'g', -1, 64)
+	case tokName:
+		return t.text
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return kindNames[t.kind]
+}
+
+// kindNames описание вида лексемы в сообщениях об ошибках
+var kindNames = map[tokenKind]string{
+	tokEOF:    "end of query",
+	tokNumber: "number",
+	tokName:   "name",
+	tokString: "string",
+	tokLParen: "(",
+	tokRParen: ")",
+	tokLBrace: "{",
+	tokRBrace: "}",
+	tokComma:  ",",
+	tokEq:     "=",
+	tokNeq:    "!=",
+	tokAdd:    "+",
+	tokSub:    "-",
+	tokMul:    "*",
+	tokDiv:    "/",
+}
+
+// isNameStart допустимый первый символ имени
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// isNameChar допустимый символ имени
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c) || c == '.' || c == ':'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// endsOperand сообщает, может ли лексема завершать операнд. После операнда * - умножение,
+// в остальных случаях * начинает шаблон имени.
+func endsOperand(kind tokenKind) bool {
+	switch kind {
+	case tokNumber, tokName, tokString, tokRParen, tokRBrace:
+		return true
+	}
+	return false
+}
+
+// lex разбивает запрос на лексемы. Символ * без пробела после символа имени
+// входит в шаблон имени: CPU* и Heap*Sys - шаблоны, Heap * 2 - умножение.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	prev := tokEOF
+	for i := 0; i < len(input); {
+		c := input[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case isDigit(c) || c == '.' && i+1 < len(input) && isDigit(input[i+1]):
+			i = scanNumber(input, i)
+			if i < len(input) && isNameChar(input[i]) {
+				return nil, errorAt(start, "invalid number %q", input[start:i+1])
+			}
+			v, err := strconv.ParseFloat(input[start:i], 64)
+			if err != nil {
+				return nil, errorAt(start, "invalid number %q", input[start:i])
+			}
+			tokens = append(tokens, token{kind: tokNumber, pos: start, num: v})
+		case isNameStart(c) || c == '*' && !endsOperand(prev):
+			i++
+			for i < len(input) && (isNameChar(input[i]) || input[i] == '*') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokName, pos: start, text: input[start:i]})
+		case c == '"':
+			i++
+			for i < len(input) && input[i] != '"' {
+				if input[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(input) {
+				return nil, errorAt(start, "unterminated string")
+			}
+			i++
+			s, err := strconv.Unquote(input[start:i])
+			if err != nil {
+				return nil, errorAt(start, "invalid string %s", input[start:i])
+			}
+			tokens = append(tokens, token{kind: tokString, pos: start, text: s})
+		case c == '!' && i+1 < len(input) && input[i+1] == '=':
+			i += 2
+			tokens = append(tokens, token{kind: tokNeq, pos: start})
+		default:
+			kind, ok := singleChar[c]
+			if !ok {
+				return nil, errorAt(start, "unexpected character %q", c)
+			}
+			i++
+			tokens = append(tokens, token{kind: kind, pos: start})
+		}
+		prev = tokens[len(tokens)-1].kind
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(input)}), nil
+}
+
+// singleChar односимвольные лексемы
+var singleChar = map[byte]tokenKind{
+	'(': tokLParen,
+	')': tokRParen,
+	'{': tokLBrace,
+	'}': tokRBrace,
+	',': tokComma,
+	'=': tokEq,
+	'+': tokAdd,
+	'-': tokSub,
+	'*': tokMul,
+	'/': tokDiv,
+}
+
+// scanNumber возвращает конец числа, начинающегося в позиции i: 12, 1.5, .5, 1e-3
+func scanNumber(input string, i int) int {
+	for i < len(input) && isDigit(input[i]) {
+		i++
+	}
+	if i < len(input) && input[i] == '.' {
+		i++
+		for i < len(input) && isDigit(input[i]) {
+			i++
+		}
+	}
+	if i < len(input) && (input[i] == 'e' || input[i] == 'E') {
+		j := i + 1
+		if j < len(input) && (input[j] == '+' || input[j] == '-') {
+			j++
+		}
+		if j < len(input) && isDigit(input[j]) {
+			for i = j; i < len(input) && isDigit(input[i]); i++ {
+			}
+		}
+	}
+	return i
+}
+
+// errorAt возвращает ошибку разбора с позицией в запросе
+func errorAt(pos int, format string, args ...any) error {
+	return fmt.Errorf("%w: position %d: %s", ErrInvalidQuery, pos, fmt.Sprintf(format, args...))
+}
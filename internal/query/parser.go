@@ -0,0 +1,405 @@
+// Package query разбирает и вычисляет выражения над сериями метрик хранилища:
+// агрегации sum, avg, min, max, count, topk и bottomk по шаблонам имён и арифметику
+// между сериями и числами, например sum(CPUutilization*) или HeapInuse / HeapSys.
+package query
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Ограничения запроса
+const (
+	// MaxLength наибольшая длина запроса в байтах
+	MaxLength = 4096
+	// MaxDepth наибольшая вложенность выражений
+	MaxDepth = 64
+)
+
+// ErrInvalidQuery возвращается для запросов с синтаксической ошибкой
+// и для выражений, которые нельзя вычислить
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Операторы агрегации
+const (
+	OpSum     = "sum"
+	OpAvg     = "avg"
+	OpMin     = "min"
+	OpMax     = "max"
+	OpCount   = "count"
+	OpTopK    = "topk"
+	OpBottomK = "bottomk"
+)
+
+// aggregations операторы агрегации; true у операторов с числовым параметром
+var aggregations = map[string]bool{
+	OpSum:     false,
+	OpAvg:     false,
+	OpMin:     false,
+	OpMax:     false,
+	OpCount:   false,
+	OpTopK:    true,
+	OpBottomK: true,
+}
+
+// Expr узел выражения. String возвращает каноническую запись, которая разбирается
+// в то же выражение.
+type Expr interface {
+	String() string
+	expr()
+}
+
+// NumberLiteral число
+type NumberLiteral struct {
+	Value float64
+}
+
+// LabelMatcher условие на значение метки. Отсутствующая метка имеет пустое значение.
+type LabelMatcher struct {
+	Name  string
+	Value string
+	// Negate условие != вместо =
+	Negate bool
+}
+
+// Selector выбирает серии, имя которых подходит под шаблон, а метки - под условия.
+// В шаблоне * обозначает любую последовательность символов.
+type Selector struct {
+	Pattern  string
+	Matchers []LabelMatcher
+}
+
+// Aggregation агрегирует серии выражения, отдельно по каждой группе значений меток By
+type Aggregation struct {
+	Op string
+	// Param количество серий для topk и bottomk
+	Param Expr
+	Expr  Expr
+	By    []string
+}
+
+// BinaryExpr арифметическая операция +, -, * или /
+type BinaryExpr struct {
+	Op  string
+	LHS Expr
+	RHS Expr
+}
+
+// UnaryExpr смена знака
+type UnaryExpr struct {
+	Expr Expr
+}
+
+func (*NumberLiteral) expr() {}
+func (*Selector) expr()      {}
+func (*Aggregation) expr()   {}
+func (*BinaryExpr) expr()    {}
+func (*UnaryExpr) expr()     {}
+
+func (e *NumberLiteral) String() string {
+	return strconv.FormatFloat(e.Value, 'g', -1, 64)
+}
+
+func (e *Selector) String() string {
+	if len(e.Matchers) == 0 {
+		return e.Pattern
+	}
+	parts := make([]string, 0, len(e.Matchers))
+	for _, m := range e.Matchers {
+		op := "="
+		if m.Negate {
+			op = "!="
+		}
+		parts = append(parts, m.Name+op+strconv.Quote(m.Value))
+	}
+	return e.Pattern + "{" + strings.Join(parts, ", ") + "}"
+}
+
+func (e *Aggregation) String() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	if len(e.By) > 0 {
+		b.WriteString(" by (" + strings.Join(e.By, ", ") + ") ")
+	}
+	b.WriteByte('(')
+	if e.Param != nil {
+		b.WriteString(e.Param.String() + ", ")
+	}
+	b.WriteString(e.Expr.String() + ")")
+	return b.String()
+}
+
+func (e *BinaryExpr) String() string {
+	return "(" + e.LHS.String() + " " + e.Op + " " + e.RHS.String() + ")"
+}
+
+func (e *UnaryExpr) String() string {
+	return "-" + e.Expr.String()
+}
+
+// parser разбирает выражение методом рекурсивного спуска:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | aggregation | selector | "(" expr ")"
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// Parse разбирает запрос. Ошибки оборачивают ErrInvalidQuery и содержат позицию в запросе.
+func Parse(input string) (Expr, error) {
+	if len(input) > MaxLength {
+		return nil, errorAt(MaxLength, "query is longer than %d bytes", MaxLength)
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorAt(t.pos, "unexpected %s", t)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// expect читает лексему вида kind или возвращает ошибку
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, errorAt(t.pos, "expected %s, got %s", kindNames[kind], t)
+	}
+	return t, nil
+}
+
+// enter учитывает вложенность выражения; leave вызывается по выходу из узла
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > MaxDepth {
+		return errorAt(p.peek().pos, "expression is nested deeper than %d", MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokAdd && t.kind != tokSub {
+			return lhs, nil
+		}
+		p.next()
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: kindNames[t.kind], LHS: lhs, RHS: rhs}
+	}
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokMul && t.kind != tokDiv {
+			return lhs, nil
+		}
+		p.next()
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: kindNames[t.kind], LHS: lhs, RHS: rhs}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().kind != tokSub {
+		return p.parsePrimary()
+	}
+	p.next()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	e, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	// Отрицательные числа хранятся как числа, чтобы запись -2 разбиралась одинаково
+	if n, ok := e.(*NumberLiteral); ok {
+		return &NumberLiteral{Value: -n.Value}, nil
+	}
+	return &UnaryExpr{Expr: e}, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &NumberLiteral{Value: t.num}, nil
+	case tokLParen:
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case tokName:
+		if _, ok := aggregations[t.text]; ok {
+			if next := p.peek(); next.kind == tokLParen || next.kind == tokName && next.text == "by" {
+				return p.parseAggregation(t.text)
+			}
+		}
+		return p.parseSelector(t)
+	}
+	return nil, errorAt(t.pos, "unexpected %s", t)
+}
+
+// parseAggregation разбирает op [by (labels)] ([param,] expr) [by (labels)]
+func (p *parser) parseAggregation(op string) (Expr, error) {
+	agg := &Aggregation{Op: op}
+	if p.peek().kind == tokName {
+		by, err := p.parseBy()
+		if err != nil {
+			return nil, err
+		}
+		agg.By = by
+	}
+	if _, err := p.expect(tokLParen); err != nil {
+		return nil, err
+	}
+	if aggregations[op] {
+		param, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokComma); err != nil {
+			return nil, err
+		}
+		agg.Param = param
+	}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	agg.Expr = e
+	if _, err := p.expect(tokRParen); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokName && t.text == "by" {
+		if agg.By != nil {
+			return nil, errorAt(t.pos, "duplicate by clause")
+		}
+		by, err := p.parseBy()
+		if err != nil {
+			return nil, err
+		}
+		agg.By = by
+	}
+	return agg, nil
+}
+
+// parseBy разбирает by (label, ...). Метки сортируются, повторы отбрасываются.
+func (p *parser) parseBy() ([]string, error) {
+	if t := p.next(); t.kind != tokName || t.text != "by" {
+		return nil, errorAt(t.pos, "expected by, got %s", t)
+	}
+	if _, err := p.expect(tokLParen); err != nil {
+		return nil, err
+	}
+	by := make([]string, 0)
+	for p.peek().kind != tokRParen {
+		if len(by) > 0 {
+			if _, err := p.expect(tokComma); err != nil {
+				return nil, err
+			}
+		}
+		t, err := p.expectLabelName()
+		if err != nil {
+			return nil, err
+		}
+		by = append(by, t.text)
+	}
+	p.next()
+	slices.Sort(by)
+	return slices.Compact(by), nil
+}
+
+// expectLabelName читает имя метки: имя без * и без символов . и :
+func (p *parser) expectLabelName() (token, error) {
+	t := p.next()
+	if t.kind != tokName || strings.ContainsAny(t.text, "*.:") {
+		return t, errorAt(t.pos, "expected label name, got %s", t)
+	}
+	return t, nil
+}
+
+// parseSelector разбирает шаблон имени с необязательными условиями на метки {label="value", ...}
+func (p *parser) parseSelector(name token) (Expr, error) {
+	sel := &Selector{Pattern: name.text}
+	if p.peek().kind != tokLBrace {
+		return sel, nil
+	}
+	p.next()
+	for p.peek().kind != tokRBrace {
+		if len(sel.Matchers) > 0 {
+			if _, err := p.expect(tokComma); err != nil {
+				return nil, err
+			}
+		}
+		label, err := p.expectLabelName()
+		if err != nil {
+			return nil, err
+		}
+		op := p.next()
+		if op.kind != tokEq && op.kind != tokNeq {
+			return nil, errorAt(op.pos, "expected = or !=, got %s", op)
+		}
+		value, err := p.expect(tokString)
+		if err != nil {
+			return nil, err
+		}
+		sel.Matchers = append(sel.Matchers, LabelMatcher{Name: label.text, Value: value.text, Negate: op.kind == tokNeq})
+	}
+	p.next()
+	return sel, nil
+}
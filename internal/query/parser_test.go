@@ -0,0 +1,134 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "HeapInuse", want: "HeapInuse"},
+		{input: "sum(CPUutilization*)", want: "sum(CPUutilization*)"},
+		{input: "sum(*)", want: "sum(*)"},
+		{input: "avg( *Alloc* )", want: "avg(*Alloc*)"},
+		{input: "HeapInuse / HeapSys", want: "(HeapInuse / HeapSys)"},
+		{input: "HeapInuse*2", want: "HeapInuse*2"},
+		{input: "HeapInuse * 2", want: "(HeapInuse * 2)"},
+		{input: "2*HeapInuse", want: "(2 * HeapInuse)"},
+		{input: "Heap* * 2", want: "(Heap* * 2)"},
+		{input: "1 + 2 * 3", want: "(1 + (2 * 3))"},
+		{input: "(1 + 2) * 3", want: "((1 + 2) * 3)"},
+		{input: "1 - 2 - 3", want: "((1 - 2) - 3)"},
+		{input: "-2", want: "-2"},
+		{input: "- -2", want: "2"},
+		{input: "-HeapInuse", want: "-HeapInuse"},
+		{input: ".5e-3", want: "0.0005"},
+		{input: "topk(3, CPU*)", want: "topk(3, CPU*)"},
+		{input: "bottomk(1 + 1, CPU*)", want: "bottomk((1 + 1), CPU*)"},
+		{input: `requests{code="500", method!="GET"}`, want: `requests{code="500", method!="GET"}`},
+		{input: `requests{}`, want: "requests"},
+		{input: "sum by (host, code, host) (requests)", want: "sum by (code, host) (requests)"},
+		{input: "sum(requests) by (host)", want: "sum by (host) (requests)"},
+		{input: "sum + 1", want: "(sum + 1)"},
+		{input: "sum(sum)", want: "sum(sum)"},
+		{input: `a{k="\"\n"}`, want: `a{k="\"\n"}`},
+		{input: "service.requests:rate1m", want: "service.requests:rate1m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) вернул ошибку: %v", tt.input, err)
+			}
+			if got := e.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %q, ожидалось %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "", want: "position 0: unexpected end of query"},
+		{input: "sum(", want: "position 4: unexpected end of query"},
+		{input: "sum(CPU*", want: "position 8: expected ), got end of query"},
+		{input: "topk(CPU*)", want: "position 9: expected ,, got )"},
+		{input: "1 +", want: "position 3: unexpected end of query"},
+		{input: "a b", want: "position 2: unexpected b"},
+		{input: "12abc", want: `position 0: invalid number "12a"`},
+		{input: "1e999", want: `position 0: invalid number "1e999"`},
+		{input: `a{k="v}`, want: "position 4: unterminated string"},
+		{input: `a{k=v}`, want: "position 4: expected string, got v"},
+		{input: `a{k~"v"}`, want: "position 3: unexpected character '~'"},
+		{input: `a{k.x="v"}`, want: "position 2: expected label name, got k.x"},
+		{input: "sum by (host) (a) by (host)", want: "position 18: duplicate by clause"},
+		{input: "a !b", want: "position 2: unexpected character '!'"},
+		{input: strings.Repeat("(", MaxDepth+1) + "1" + strings.Repeat(")", MaxDepth+1), want: "nested deeper"},
+		{input: strings.Repeat("1+", MaxLength), want: "longer than"},
+	}
+
+	for _, tt := range tests {
+		name := tt.input
+		if len(name) > 32 {
+			name = name[:32]
+		}
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil {
+				t.Fatalf("Parse(%q) должен вернуть ошибку", tt.input)
+			}
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("ошибка %v должна оборачивать ErrInvalidQuery", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %q должна содержать %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzParse проверяет, что разбор не паникует, а каноническая запись
+// разобранного выражения разбирается в то же выражение
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"sum(CPUutilization*)",
+		"HeapInuse / HeapSys",
+		"topk(3, CPU* * 100)",
+		`sum by (code) (requests{method!="GET"}) / 2`,
+		"-(1 + .5e3) * -x",
+		`a{k="\x00é"}`,
+		"((((1))))",
+		"*",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		e, err := Parse(input)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("Parse(%q): ошибка %v не оборачивает ErrInvalidQuery", input, err)
+			}
+			return
+		}
+		canonical := e.String()
+		again, err := Parse(canonical)
+		if err != nil {
+			t.Fatalf("каноническая запись %q запроса %q не разбирается: %v", canonical, input, err)
+		}
+		if got := again.String(); got != canonical {
+			t.Fatalf("повторный разбор %q дал %q", canonical, got)
+		}
+		if _, err := Eval(e, testSnapshot()); err != nil && !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("Eval(%q): ошибка %v не оборачивает ErrInvalidQuery", input, err)
+		}
+	})
+}
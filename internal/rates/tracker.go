@@ -0,0 +1,252 @@
+// Package rates хранит недавние значения counter метрик и вычисляет по ним
+// прирост (increase) и скорость роста в секунду (rate) за заданное окно.
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// Значения по умолчанию
+const (
+	// DefaultSampleInterval период снятия значений counter метрик
+	DefaultSampleInterval = 10 * time.Second
+	// DefaultRetention время хранения значений, ограничивает максимальное окно
+	DefaultRetention = time.Hour
+	// DefaultWindow окно по умолчанию для HTML страницы и /metrics
+	DefaultWindow = time.Minute
+)
+
+// ErrInvalidWindow возвращается для окна вне допустимого диапазона
+var ErrInvalidWindow = errors.New("invalid rate window")
+
+// Sample значение counter метрики в момент снятия
+type Sample struct {
+	Time  time.Time
+	Value int64
+}
+
+// Result прирост и скорость роста counter метрики за окно
+type Result struct {
+	ID string `json:"id"`
+	// Total последнее снятое значение
+	Total int64 `json:"total"`
+	// Increase прирост за окно с учётом сбросов
+	Increase int64 `json:"increase"`
+	// Rate средняя скорость роста в секунду: Increase, делённый на длительность окна
+	Rate float64 `json:"rate"`
+	// Samples количество значений, использованных в расчёте
+	Samples int `json:"samples"`
+	// Resets количество сбросов счётчика в окне
+	Resets int `json:"resets"`
+}
+
+// Tracker периодически снимает значения всех counter метрик из хранилища.
+// Значения снимаются из хранилища, а не из потока обновлений, поэтому учитываются
+// обновления любых обработчиков приёма, других экземпляров сервера с общей базой,
+// а также сбросы через API и удаление устаревших метрик.
+type Tracker struct {
+	storage   storage.Storage
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+
+	mu     sync.RWMutex
+	series map[string][]Sample
+
+	// Поля для graceful shutdown
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	runMu   sync.Mutex
+	started bool
+	stopped bool
+}
+
+// NewTracker создаёт Tracker. Нулевые interval и retention заменяются значениями по умолчанию.
+func NewTracker(s storage.Storage, interval, retention time.Duration) *Tracker {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tracker{
+		storage:   s,
+		interval:  interval,
+		retention: retention,
+		now:       time.Now,
+		series:    make(map[string][]Sample),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Interval возвращает период снятия значений
+func (t *Tracker) Interval() time.Duration {
+	return t.interval
+}
+
+// Retention возвращает время хранения значений
+func (t *Tracker) Retention() time.Duration {
+	return t.retention
+}
+
+// Start снимает первые значения и запускает периодическое снятие
+func (t *Tracker) Start() {
+	t.runMu.Lock()
+	defer t.runMu.Unlock()
+
+	if t.started || t.stopped {
+		return
+	}
+	t.started = true
+
+	if err := t.Collect(); err != nil {
+		log.Printf("Ошибка снятия значений counter метрик: %v", err)
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.Collect(); err != nil {
+					log.Printf("Ошибка снятия значений counter метрик: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop останавливает периодическое снятие значений
+func (t *Tracker) Stop() {
+	t.runMu.Lock()
+	defer t.runMu.Unlock()
+
+	if t.stopped {
+		return
+	}
+	t.stopped = true
+	t.cancel()
+	t.wg.Wait()
+}
+
+// Collect снимает текущие значения всех counter метрик и удаляет значения старше retention
+func (t *Tracker) Collect() error {
+	now := t.now()
+	query := storage.MetricsQuery{Types: []string{string(storage.Counter)}, Limit: storage.MaxListLimit}
+
+	values := make(map[string]int64)
+	for {
+		page, err := t.storage.ListMetrics(query)
+		if err != nil {
+			return err
+		}
+		for _, m := range page.Metrics {
+			if m.Delta != nil {
+				values[m.ID] = *m.Delta
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, v := range values {
+		t.series[id] = append(t.series[id], Sample{Time: now, Value: v})
+	}
+	// Значения пропавших метрик хранятся до истечения retention: если метрика
+	// появится снова, это будет учтено как сброс счётчика
+	cutoff := now.Add(-t.retention)
+	for id, samples := range t.series {
+		i := sort.Search(len(samples), func(i int) bool { return samples[i].Time.After(cutoff) })
+		// Последнее значение до начала хранения остаётся опорным для окна, равного retention
+		if i > 0 {
+			i--
+		}
+		samples = samples[i:]
+		if _, ok := values[id]; !ok && !samples[len(samples)-1].Time.After(cutoff) {
+			delete(t.series, id)
+			continue
+		}
+		t.series[id] = samples
+	}
+	return nil
+}
+
+// ValidateWindow проверяет, что окно не короче периода снятия и не длиннее времени хранения
+func (t *Tracker) ValidateWindow(window time.Duration) error {
+	if window < t.interval || window > t.retention {
+		return fmt.Errorf("%w: %v, expected from %v to %v", ErrInvalidWindow, window, t.interval, t.retention)
+	}
+	return nil
+}
+
+// Rate вычисляет прирост и скорость роста counter метрики за окно, заканчивающееся сейчас.
+// Возвращает false, если значений метрики нет.
+func (t *Tracker) Rate(id string, window time.Duration) (Result, bool) {
+	t.mu.RLock()
+	samples := t.series[id]
+	t.mu.RUnlock()
+
+	if len(samples) == 0 {
+		return Result{}, false
+	}
+	return compute(id, samples, t.now().Add(-window), window), true
+}
+
+// Rates вычисляет прирост и скорость роста всех отслеживаемых counter метрик
+func (t *Tracker) Rates(window time.Duration) map[string]Result {
+	start := t.now().Add(-window)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	results := make(map[string]Result, len(t.series))
+	for id, samples := range t.series {
+		results[id] = compute(id, samples, start, window)
+	}
+	return results
+}
+
+// compute вычисляет прирост по значениям после start. Опорным считается последнее значение
+// не позже start, а если метрика появилась позже - первое значение в окне.
+// Уменьшение значения считается сбросом счётчика: счёт начался заново с нуля,
+// поэтому в прирост входит новое значение целиком.
+func compute(id string, samples []Sample, start time.Time, window time.Duration) Result {
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].Time.After(start) })
+	if i > 0 {
+		i--
+	}
+	samples = samples[i:]
+
+	result := Result{ID: id, Total: samples[len(samples)-1].Value, Samples: len(samples)}
+	for j := 1; j < len(samples); j++ {
+		delta := samples[j].Value - samples[j-1].Value
+		if delta < 0 {
+			result.Resets++
+			delta = max(samples[j].Value, 0)
+		}
+		result.Increase += delta
+	}
+	result.Rate = float64(result.Increase) / window.Seconds()
+	return result
+}
@@ -0,0 +1,166 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// testTracker создаёт Tracker с управляемым временем
+func testTracker(s storage.Storage) (*Tracker, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tr := NewTracker(s, 10*time.Second, time.Hour)
+	tr.now = func() time.Time { return now }
+	return tr, &now
+}
+
+// step обновляет счётчик на delta, сдвигает время и снимает значения
+func step(t *testing.T, tr *Tracker, now *time.Time, s storage.Storage, name string, delta int64) {
+	t.Helper()
+	*now = now.Add(10 * time.Second)
+	if delta != 0 {
+		s.UpdateCounter(name, delta)
+	}
+	if err := tr.Collect(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTracker_Rate(t *testing.T) {
+	s := storage.NewMemStorage()
+	tr, now := testTracker(s)
+
+	s.UpdateCounter("requests", 100)
+	if err := tr.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		step(t, tr, now, s, "requests", 10)
+	}
+
+	got, ok := tr.Rate("requests", time.Minute)
+	if !ok {
+		t.Fatal("Ожидались значения для requests")
+	}
+	if got.Total != 160 || got.Increase != 60 || got.Rate != 1 || got.Samples != 7 || got.Resets != 0 {
+		t.Errorf("Неожиданный результат за минуту: %+v", got)
+	}
+
+	// Окно 30s: опорное значение снято ровно 30 секунд назад
+	got, _ = tr.Rate("requests", 30*time.Second)
+	if got.Increase != 30 || got.Rate != 1 {
+		t.Errorf("Неожиданный результат за 30s: %+v", got)
+	}
+
+	if _, ok := tr.Rate("missing", time.Minute); ok {
+		t.Error("Для отсутствующей метрики результата быть не должно")
+	}
+}
+
+func TestTracker_Resets(t *testing.T) {
+	s := storage.NewMemStorage()
+	tr, now := testTracker(s)
+
+	s.UpdateCounter("requests", 50)
+	step(t, tr, now, s, "requests", 10) // 60
+	if err := s.ResetCounter("requests"); err != nil {
+		t.Fatal(err)
+	}
+	step(t, tr, now, s, "requests", 5) // сброс, затем 5
+	step(t, tr, now, s, "requests", 5) // 10
+
+	got, _ := tr.Rate("requests", time.Minute)
+	// Прирост до сброса не виден: опорное значение 60 - первое снятое.
+	// После сброса учитывается новое значение 5 целиком, затем ещё 5.
+	if got.Increase != 10 || got.Resets != 1 || got.Total != 10 {
+		t.Errorf("Неожиданный результат со сбросом: %+v", got)
+	}
+}
+
+func TestTracker_MetricRecreated(t *testing.T) {
+	// Метрика удалена (например, по TTL после перезапуска агента) и создана заново
+	s := storage.NewMemStorage()
+	tr, now := testTracker(s)
+
+	s.UpdateCounter("polls", 30)
+	step(t, tr, now, s, "polls", 0)
+	if err := s.DeleteMetric("counter", "polls"); err != nil {
+		t.Fatal(err)
+	}
+	step(t, tr, now, s, "polls", 0)
+	step(t, tr, now, s, "polls", 4)
+
+	got, ok := tr.Rate("polls", time.Minute)
+	if !ok || got.Increase != 4 || got.Resets != 1 {
+		t.Errorf("Пересозданная метрика должна учитываться как сброс: %+v", got)
+	}
+}
+
+func TestTracker_Retention(t *testing.T) {
+	s := storage.NewMemStorage()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tr := NewTracker(s, 10*time.Second, time.Minute)
+	tr.now = func() time.Time { return now }
+
+	s.UpdateCounter("a", 1)
+	s.UpdateCounter("b", 1)
+	for i := 0; i < 20; i++ {
+		step(t, tr, &now, s, "a", 1)
+		if i == 0 {
+			if err := s.DeleteMetric("counter", "b"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tr.mu.RLock()
+	samples := len(tr.series["a"])
+	_, hasB := tr.series["b"]
+	tr.mu.RUnlock()
+	// 6 значений за минуту и одно опорное до её начала
+	if samples != 7 {
+		t.Errorf("Ожидалось 7 хранимых значений, получено %d", samples)
+	}
+	if hasB {
+		t.Error("Значения удалённой метрики должны удаляться после retention")
+	}
+}
+
+func TestTracker_ValidateWindow(t *testing.T) {
+	tr := NewTracker(storage.NewMemStorage(), 10*time.Second, time.Hour)
+	for _, w := range []time.Duration{10 * time.Second, time.Minute, time.Hour} {
+		if err := tr.ValidateWindow(w); err != nil {
+			t.Errorf("Окно %v должно быть допустимым: %v", w, err)
+		}
+	}
+	for _, w := range []time.Duration{time.Second, 2 * time.Hour, 0} {
+		if err := tr.ValidateWindow(w); !errors.Is(err, ErrInvalidWindow) {
+			t.Errorf("Окно %v должно быть недопустимым, получено %v", w, err)
+		}
+	}
+}
+
+func TestTracker_StartStop(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateCounter("requests", 1)
+	tr := NewTracker(s, 10*time.Millisecond, time.Minute)
+	tr.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		if got, _ := tr.Rate("requests", time.Minute); got.Samples >= 3 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("Значения не снимаются периодически")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	tr.Stop()
+	tr.Stop()
+}
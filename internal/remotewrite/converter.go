@@ -0,0 +1,331 @@
+package remotewrite
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// DefaultStaleAfter время, после которого забывается состояние ряда, не присылавшего значения
+const DefaultStaleAfter = time.Hour
+
+// seriesLocks количество блокировок состояния рядов, ряды распределяются по ним по хешу
+const seriesLocks = 64
+
+// counterSuffixes суффиксы имён, по которым ряд без метаданных считается counter
+var counterSuffixes = []string{"_total", "_count", "_sum", "_bucket"}
+
+// seriesState последнее принятое значение ряда
+type seriesState struct {
+	// timestamp время последнего значения в миллисекундах, более старые значения
+	// считаются повтором уже принятого запроса
+	timestamp int64
+	last      float64
+	// carry дробная часть приращения counter, ещё не записанная в хранилище
+	carry float64
+	seen  time.Time
+}
+
+// Converter преобразует запросы remote_write в метрики и хранит состояние рядов
+// и метаданные семейств между запросами. Безопасен для конкурентного использования:
+// запросы с общими рядами выполняются последовательно от Convert до Result.Commit
+// или Result.Release, поэтому повтор запроса, ещё не завершившегося у сервера,
+// и одинаковые запросы HA пары Prometheus не удваивают counter.
+type Converter struct {
+	staleAfter time.Duration
+	now        func() time.Time
+
+	// locks удерживаются от Convert до Commit или Release запроса
+	locks [seriesLocks]sync.Mutex
+
+	mu        sync.Mutex
+	series    map[string]seriesState
+	metadata  map[string]MetricType
+	lastSweep time.Time
+}
+
+// NewConverter создаёт Converter. staleAfter <= 0 означает DefaultStaleAfter.
+func NewConverter(staleAfter time.Duration) *Converter {
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	return &Converter{
+		staleAfter: staleAfter,
+		now:        time.Now,
+		series:     make(map[string]seriesState),
+		metadata:   make(map[string]MetricType),
+		lastSweep:  time.Now(),
+	}
+}
+
+// Result результат преобразования запроса
+type Result struct {
+	// Metrics метрики для Storage.UpdateBatch
+	Metrics []models.Metrics
+	// Skipped количество рядов без имени или без значений
+	Skipped int
+
+	converter *Converter
+	pending   map[string]seriesState
+	// locked номера удерживаемых блокировок рядов по возрастанию
+	locked []int
+}
+
+// Commit сохраняет состояние рядов после успешной записи метрик в хранилище
+// и освобождает ряды запроса. Без Commit повторная отправка запроса даст те же приращения.
+func (r *Result) Commit() {
+	if r.converter == nil {
+		return
+	}
+	r.converter.commit(r.pending)
+	r.Release()
+}
+
+// Release освобождает ряды запроса без сохранения состояния, например после ошибки
+// хранилища. Вызывается для каждого результата Convert; после Commit ничего не делает.
+func (r *Result) Release() {
+	if r.converter == nil {
+		return
+	}
+	for i := len(r.locked) - 1; i >= 0; i-- {
+		r.converter.locks[r.locked[i]].Unlock()
+	}
+	r.converter, r.locked = nil, nil
+}
+
+// convertSeries ряд запроса, подготовленный к преобразованию
+type convertSeries struct {
+	id      string
+	counter bool
+	samples []Sample
+}
+
+// key ключ состояния ряда
+func (s convertSeries) key() string {
+	if s.counter {
+		return "counter:" + s.id
+	}
+	return "gauge:" + s.id
+}
+
+// lockIndex номер блокировки ряда
+func lockIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % seriesLocks)
+}
+
+// lockSeries захватывает блокировки рядов в порядке возрастания номеров,
+// чтобы запросы с пересекающимися рядами не блокировали друг друга навсегда
+func (c *Converter) lockSeries(res *Result, series []convertSeries) {
+	var need [seriesLocks]bool
+	for _, s := range series {
+		need[lockIndex(s.key())] = true
+	}
+	for i := range need {
+		if need[i] {
+			c.locks[i].Lock()
+			res.locked = append(res.locked, i)
+		}
+	}
+}
+
+// Convert преобразует запрос. Метаданные запоминаются сразу, состояние рядов -
+// только после Result.Commit. До Commit или Release ряды запроса заблокированы
+// для других вызовов Convert.
+//
+// Counter записывается приращением с прошлого значения ряда. Уменьшение значения
+// означает сброс counter: приращением считается всё новое значение. Первое значение
+// ряда только запоминается, так как неизвестно, какая его часть уже была учтена.
+// Для gauge записывается последнее по времени значение. Значения не новее уже принятых
+// (повтор запроса после ошибки сети) и маркеры устаревания (NaN) пропускаются.
+func (c *Converter) Convert(req *WriteRequest) *Result {
+	c.rememberMetadata(req.Metadata)
+
+	res := &Result{converter: c, pending: make(map[string]seriesState)}
+	series := make([]convertSeries, 0, len(req.Timeseries))
+	for i := range req.Timeseries {
+		ts := &req.Timeseries[i]
+
+		name, labels := splitLabels(ts.Labels)
+		if name == "" || len(ts.Samples) == 0 {
+			res.Skipped++
+			continue
+		}
+		id := models.FormatSeriesID(name, labels)
+
+		samples := ts.Samples
+		if !sort.SliceIsSorted(samples, func(a, b int) bool { return samples[a].Timestamp < samples[b].Timestamp }) {
+			samples = append([]Sample(nil), samples...)
+			sort.Slice(samples, func(a, b int) bool { return samples[a].Timestamp < samples[b].Timestamp })
+		}
+
+		series = append(series, convertSeries{id: id, counter: c.isCounter(name), samples: samples})
+	}
+
+	c.lockSeries(res, series)
+	for _, s := range series {
+		if s.counter {
+			c.addCounter(res, s.id, s.samples)
+		} else {
+			c.addGauge(res, s.id, s.samples)
+		}
+	}
+	return res
+}
+
+// addCounter суммирует приращения значений counter ряда
+func (c *Converter) addCounter(res *Result, id string, samples []Sample) {
+	key := "counter:" + id
+	st, known := c.lookup(res, key)
+
+	var increase float64
+	updated := false
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) || (known && s.Timestamp <= st.timestamp) {
+			continue
+		}
+		if known {
+			if s.Value < st.last {
+				increase += s.Value
+			} else {
+				increase += s.Value - st.last
+			}
+			updated = true
+		}
+		known = true
+		st.timestamp, st.last, st.seen = s.Timestamp, s.Value, c.now()
+	}
+	if !known {
+		return
+	}
+	if !updated {
+		res.pending[key] = st
+		return
+	}
+
+	total := increase + st.carry
+	whole := math.Floor(total)
+	st.carry = total - whole
+	res.pending[key] = st
+
+	delta := int64(whole)
+	res.Metrics = append(res.Metrics, models.Metrics{ID: id, MType: "counter", Delta: &delta})
+}
+
+// addGauge записывает последнее значение gauge ряда
+func (c *Converter) addGauge(res *Result, id string, samples []Sample) {
+	key := "gauge:" + id
+	st, known := c.lookup(res, key)
+
+	updated := false
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) || (known && s.Timestamp <= st.timestamp) {
+			continue
+		}
+		known, updated = true, true
+		st.timestamp, st.last, st.seen = s.Timestamp, s.Value, c.now()
+	}
+	if !updated {
+		return
+	}
+	res.pending[key] = st
+
+	value := st.last
+	res.Metrics = append(res.Metrics, models.Metrics{ID: id, MType: "gauge", Value: &value})
+}
+
+// isCounter определяет тип ряда по метаданным семейства или, если их нет, по суффиксу имени
+func (c *Converter) isCounter(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t, ok := c.metadata[name]; ok {
+		return t == MetricTypeCounter
+	}
+	for _, suffix := range counterSuffixes {
+		family, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		// Ряды _count, _sum и _bucket гистограмм и summary накапливаются как counter
+		if t, ok := c.metadata[family]; ok && suffix != "_total" {
+			return t == MetricTypeHistogram || t == MetricTypeSummary
+		}
+		return true
+	}
+	return false
+}
+
+// rememberMetadata запоминает типы семейств. Prometheus отправляет метаданные
+// отдельными запросами, поэтому они хранятся между запросами.
+func (c *Converter) rememberMetadata(metadata []MetricMetadata) {
+	if len(metadata) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, md := range metadata {
+		if md.MetricFamilyName != "" && md.Type != MetricTypeUnknown {
+			c.metadata[md.MetricFamilyName] = md.Type
+		}
+	}
+}
+
+// lookup возвращает состояние ряда с учётом изменений текущего запроса
+func (c *Converter) lookup(res *Result, key string) (seriesState, bool) {
+	if st, ok := res.pending[key]; ok {
+		return st, true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.series[key]
+	return st, ok
+}
+
+// commit сохраняет состояние рядов и периодически удаляет устаревшие ряды
+func (c *Converter) commit(pending map[string]seriesState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, st := range pending {
+		c.series[key] = st
+	}
+
+	now := c.now()
+	if now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+	for key, st := range c.series {
+		if now.Sub(st.seen) > c.staleAfter {
+			delete(c.series, key)
+		}
+	}
+}
+
+// Series возвращает количество отслеживаемых рядов
+func (c *Converter) Series() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.series)
+}
+
+// splitLabels возвращает имя ряда из __name__ и остальные метки
+func splitLabels(labels []Label) (string, map[string]string) {
+	var name string
+	result := make(map[string]string, len(labels))
+	for _, l := range labels {
+		if l.Name == "__name__" {
+			name = l.Value
+			continue
+		}
+		result[l.Name] = l.Value
+	}
+	return name, result
+}
@@ -0,0 +1,219 @@
+package remotewrite
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// staleNaN маркер устаревания ряда Prometheus
+var staleNaN = math.Float64frombits(0x7ff0000000000002)
+
+func series(name string, samples ...Sample) TimeSeries {
+	return TimeSeries{
+		Labels:  []Label{{Name: "__name__", Value: name}, {Name: "job", Value: "api"}},
+		Samples: samples,
+	}
+}
+
+func convert(c *Converter, req *WriteRequest) map[string]models.Metrics {
+	res := c.Convert(req)
+	res.Commit()
+	result := make(map[string]models.Metrics, len(res.Metrics))
+	for _, m := range res.Metrics {
+		result[m.ID] = m
+	}
+	return result
+}
+
+func TestConvertCounter(t *testing.T) {
+	c := NewConverter(0)
+	id := `http_requests_total{job="api"}`
+
+	steps := []struct {
+		name    string
+		samples []Sample
+		delta   int64
+		written bool
+	}{
+		{"первое значение только запоминается", []Sample{{Value: 100, Timestamp: 1000}}, 0, false},
+		{"приращение", []Sample{{Value: 105, Timestamp: 2000}, {Value: 110, Timestamp: 3000}}, 10, true},
+		{"повтор запроса пропускается", []Sample{{Value: 105, Timestamp: 2000}, {Value: 110, Timestamp: 3000}}, 0, false},
+		{"сброс counter", []Sample{{Value: 4, Timestamp: 4000}}, 4, true},
+		{"сброс внутри запроса", []Sample{{Value: 9, Timestamp: 5000}, {Value: 2, Timestamp: 6000}}, 7, true},
+		{"маркер устаревания", []Sample{{Value: staleNaN, Timestamp: 7000}}, 0, false},
+	}
+	for _, step := range steps {
+		metrics := convert(c, &WriteRequest{Timeseries: []TimeSeries{series("http_requests_total", step.samples...)}})
+		m, ok := metrics[id]
+		if ok != step.written {
+			t.Fatalf("%s: ожидалась запись %v, получено %v", step.name, step.written, metrics)
+		}
+		if ok && (m.MType != "counter" || *m.Delta != step.delta) {
+			t.Errorf("%s: ожидалось приращение %d, получено %+v", step.name, step.delta, m)
+		}
+	}
+}
+
+func TestConvertCounter_UnsortedAndCarry(t *testing.T) {
+	c := NewConverter(0)
+	id := `bytes_total{job="api"}`
+	convert(c, &WriteRequest{Timeseries: []TimeSeries{series("bytes_total", Sample{Value: 0, Timestamp: 1})}})
+
+	// Значения не по порядку и дробные приращения
+	metrics := convert(c, &WriteRequest{Timeseries: []TimeSeries{series("bytes_total",
+		Sample{Value: 1.2, Timestamp: 3}, Sample{Value: 0.6, Timestamp: 2})}})
+	if m := metrics[id]; *m.Delta != 1 {
+		t.Errorf("Ожидалось приращение 1, получено %+v", m)
+	}
+	metrics = convert(c, &WriteRequest{Timeseries: []TimeSeries{series("bytes_total", Sample{Value: 2, Timestamp: 4})}})
+	if m := metrics[id]; *m.Delta != 1 {
+		t.Errorf("Дробная часть должна переноситься: ожидалось 1, получено %+v", m)
+	}
+}
+
+func TestConvertGauge(t *testing.T) {
+	c := NewConverter(0)
+	id := `temperature{job="api"}`
+
+	metrics := convert(c, &WriteRequest{Timeseries: []TimeSeries{series("temperature",
+		Sample{Value: 21, Timestamp: 2}, Sample{Value: 20, Timestamp: 1}, Sample{Value: staleNaN, Timestamp: 3})}})
+	if m := metrics[id]; m.MType != "gauge" || *m.Value != 21 {
+		t.Errorf("Ожидался gauge с последним значением 21, получено %+v", m)
+	}
+
+	// Более старое значение не перезаписывает новое
+	metrics = convert(c, &WriteRequest{Timeseries: []TimeSeries{series("temperature", Sample{Value: 5, Timestamp: 1})}})
+	if len(metrics) != 0 {
+		t.Errorf("Устаревшее значение должно пропускаться, получено %v", metrics)
+	}
+}
+
+func TestConvertMetadata(t *testing.T) {
+	c := NewConverter(0)
+
+	// Метаданные приходят отдельным запросом
+	convert(c, &WriteRequest{Metadata: []MetricMetadata{
+		{Type: MetricTypeGauge, MetricFamilyName: "queue_total"},
+		{Type: MetricTypeCounter, MetricFamilyName: "restarts"},
+		{Type: MetricTypeSummary, MetricFamilyName: "latency"},
+	}})
+
+	req := &WriteRequest{Timeseries: []TimeSeries{
+		series("queue_total", Sample{Value: 3, Timestamp: 1}),
+		series("restarts", Sample{Value: 1, Timestamp: 1}),
+		series("latency", Sample{Value: 0.2, Timestamp: 1}),
+		series("latency_count", Sample{Value: 1, Timestamp: 1}),
+	}}
+	convert(c, req)
+	for i := range req.Timeseries {
+		req.Timeseries[i].Samples[0] = Sample{Value: 5, Timestamp: 2}
+	}
+	metrics := convert(c, req)
+
+	expected := map[string]string{
+		`queue_total{job="api"}`:   "gauge",
+		`restarts{job="api"}`:      "counter",
+		`latency{job="api"}`:       "gauge",
+		`latency_count{job="api"}`: "counter",
+	}
+	for id, mType := range expected {
+		if m, ok := metrics[id]; !ok || m.MType != mType {
+			t.Errorf("%s: ожидался тип %s, получено %+v", id, mType, m)
+		}
+	}
+}
+
+func TestConvertSkipped(t *testing.T) {
+	c := NewConverter(0)
+	res := c.Convert(&WriteRequest{Timeseries: []TimeSeries{
+		{Labels: []Label{{Name: "job", Value: "api"}}, Samples: []Sample{{Value: 1}}},
+		series("up"),
+	}})
+	if res.Skipped != 2 || len(res.Metrics) != 0 {
+		t.Errorf("Ожидалось 2 пропущенных ряда, получено %d и %v", res.Skipped, res.Metrics)
+	}
+}
+
+func TestConvertWithoutCommit(t *testing.T) {
+	c := NewConverter(0)
+	convert(c, &WriteRequest{Timeseries: []TimeSeries{series("jobs_total", Sample{Value: 1, Timestamp: 1})}})
+
+	req := &WriteRequest{Timeseries: []TimeSeries{series("jobs_total", Sample{Value: 4, Timestamp: 2})}}
+	first := c.Convert(req)
+	first.Release()
+	second := c.Convert(req)
+	second.Release()
+	if len(first.Metrics) != 1 || len(second.Metrics) != 1 || *second.Metrics[0].Delta != 3 {
+		t.Errorf("Без Commit повтор должен дать то же приращение: %v, %v", first.Metrics, second.Metrics)
+	}
+}
+
+func TestConvertConcurrentRetries(t *testing.T) {
+	c := NewConverter(0)
+	convert(c, &WriteRequest{Timeseries: []TimeSeries{series("jobs_total", Sample{Value: 100, Timestamp: 1})}})
+
+	// Повторы одного запроса и запросы HA пары приходят, пока первый ещё записывается в хранилище
+	req := &WriteRequest{Timeseries: []TimeSeries{
+		series("jobs_total", Sample{Value: 110, Timestamp: 2}),
+		series("queue_size", Sample{Value: 3, Timestamp: 2}),
+	}}
+	var total atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := c.Convert(req)
+			defer res.Release()
+			for _, m := range res.Metrics {
+				if m.MType == "counter" {
+					time.Sleep(time.Millisecond)
+					total.Add(*m.Delta)
+				}
+			}
+			res.Commit()
+		}()
+	}
+	wg.Wait()
+
+	if got := total.Load(); got != 10 {
+		t.Errorf("Ожидалось суммарное приращение 10, получено %d", got)
+	}
+}
+
+func TestConvertReleaseWithoutCommit(t *testing.T) {
+	c := NewConverter(0)
+	convert(c, &WriteRequest{Timeseries: []TimeSeries{series("jobs_total", Sample{Value: 1, Timestamp: 1})}})
+
+	req := &WriteRequest{Timeseries: []TimeSeries{series("jobs_total", Sample{Value: 4, Timestamp: 2})}}
+	failed := c.Convert(req)
+	failed.Release()
+	failed.Commit()
+
+	// Ошибка хранилища: состояние не сохранено, повтор запроса даёт то же приращение
+	metrics := convert(c, req)
+	if m, ok := metrics[`jobs_total{job="api"}`]; !ok || *m.Delta != 3 {
+		t.Errorf("Ожидалось приращение 3 после Release, получено %v", metrics)
+	}
+}
+
+func TestConverterSweepsStaleSeries(t *testing.T) {
+	c := NewConverter(time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	convert(c, &WriteRequest{Timeseries: []TimeSeries{series("up", Sample{Value: 1, Timestamp: 1})}})
+	if c.Series() != 1 {
+		t.Fatalf("Ожидался 1 ряд, получено %d", c.Series())
+	}
+
+	now = now.Add(time.Hour)
+	convert(c, &WriteRequest{})
+	if c.Series() != 0 {
+		t.Errorf("Устаревший ряд должен быть удалён, осталось %d", c.Series())
+	}
+}
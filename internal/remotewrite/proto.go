@@ -0,0 +1,345 @@
+// Package remotewrite предоставляет кодирование запросов Prometheus remote_write 1.0
+// (prometheus.WriteRequest, protobuf со сжатием snappy) и преобразование рядов
+// в метрики хранилища с определением сброса counter.
+//
+// Сообщения кодируются вручную через protowire, чтобы не подключать модуль Prometheus
+// ради трёх сообщений. Нативные гистограммы и exemplars пропускаются.
+package remotewrite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ContentType тип содержимого запроса remote_write 1.0
+const ContentType = "application/x-protobuf"
+
+// ProtoMessage значение параметра proto типа содержимого для remote_write 1.0
+const ProtoMessage = "prometheus.WriteRequest"
+
+// Version значение заголовка X-Prometheus-Remote-Write-Version
+const Version = "0.1.0"
+
+// MetricType тип семейства метрик из метаданных
+type MetricType int32
+
+// Типы семейств метрик, значения совпадают с prometheus.MetricMetadata.MetricType
+const (
+	MetricTypeUnknown        MetricType = 0
+	MetricTypeCounter        MetricType = 1
+	MetricTypeGauge          MetricType = 2
+	MetricTypeHistogram      MetricType = 3
+	MetricTypeGaugeHistogram MetricType = 4
+	MetricTypeSummary        MetricType = 5
+	MetricTypeInfo           MetricType = 6
+	MetricTypeStateset       MetricType = 7
+)
+
+// WriteRequest запрос remote_write
+type WriteRequest struct {
+	Timeseries []TimeSeries
+	Metadata   []MetricMetadata
+}
+
+// TimeSeries ряд: метки, включая __name__, и значения
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// Label метка ряда
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample значение ряда с временем в миллисекундах
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// MetricMetadata метаданные семейства метрик
+type MetricMetadata struct {
+	Type             MetricType
+	MetricFamilyName string
+	Help             string
+	Unit             string
+}
+
+// Номера полей сообщений prometheus.WriteRequest
+const (
+	fieldRequestTimeseries = 1
+	fieldRequestMetadata   = 3
+
+	fieldSeriesLabels  = 1
+	fieldSeriesSamples = 2
+
+	fieldLabelName  = 1
+	fieldLabelValue = 2
+
+	fieldSampleValue     = 1
+	fieldSampleTimestamp = 2
+
+	fieldMetadataType   = 1
+	fieldMetadataFamily = 2
+	fieldMetadataHelp   = 4
+	fieldMetadataUnit   = 5
+)
+
+// MaxDecodedSize наибольший размер запроса после распаковки snappy. Prometheus
+// по умолчанию отправляет до 2000 рядов в запросе, что намного меньше предела.
+const MaxDecodedSize = 32 << 20
+
+// MaxCompressedSize наибольший размер сжатого snappy запроса с MaxDecodedSize байтами
+var MaxCompressedSize = snappy.MaxEncodedLen(MaxDecodedSize)
+
+// errTruncated сообщение protobuf обрывается посередине поля
+var errTruncated = errors.New("truncated protobuf message")
+
+// ErrTooLarge возвращается, если распакованный запрос больше MaxDecodedSize
+var ErrTooLarge = errors.New("decoded request is too large")
+
+// Decode распаковывает snappy и разбирает WriteRequest. Размер после распаковки
+// проверяется по заголовку snappy до выделения памяти.
+func Decode(compressed []byte) (*WriteRequest, error) {
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("snappy decode: %w", err)
+	}
+	if size > MaxDecodedSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrTooLarge, size, MaxDecodedSize)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("snappy decode: %w", err)
+	}
+	req := &WriteRequest{}
+	if err := req.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Encode кодирует WriteRequest и сжимает его snappy
+func Encode(req *WriteRequest) []byte {
+	return snappy.Encode(nil, req.Marshal())
+}
+
+// Marshal кодирует WriteRequest в protobuf
+func (r *WriteRequest) Marshal() []byte {
+	var b []byte
+	for i := range r.Timeseries {
+		b = appendMessage(b, fieldRequestTimeseries, r.Timeseries[i].marshal())
+	}
+	for i := range r.Metadata {
+		b = appendMessage(b, fieldRequestMetadata, r.Metadata[i].marshal())
+	}
+	return b
+}
+
+// Unmarshal разбирает WriteRequest из protobuf. Неизвестные поля пропускаются.
+func (r *WriteRequest) Unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == fieldRequestTimeseries && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return 0, errTruncated
+			}
+			var ts TimeSeries
+			if err := ts.unmarshal(msg); err != nil {
+				return 0, fmt.Errorf("timeseries %d: %w", len(r.Timeseries), err)
+			}
+			r.Timeseries = append(r.Timeseries, ts)
+			return n, nil
+		case num == fieldRequestMetadata && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return 0, errTruncated
+			}
+			var md MetricMetadata
+			if err := md.unmarshal(msg); err != nil {
+				return 0, fmt.Errorf("metadata %d: %w", len(r.Metadata), err)
+			}
+			r.Metadata = append(r.Metadata, md)
+			return n, nil
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (ts *TimeSeries) marshal() []byte {
+	var b []byte
+	for _, l := range ts.Labels {
+		var lb []byte
+		lb = appendString(lb, fieldLabelName, l.Name)
+		lb = appendString(lb, fieldLabelValue, l.Value)
+		b = appendMessage(b, fieldSeriesLabels, lb)
+	}
+	for _, s := range ts.Samples {
+		var sb []byte
+		sb = protowire.AppendTag(sb, fieldSampleValue, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+		sb = protowire.AppendTag(sb, fieldSampleTimestamp, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+		b = appendMessage(b, fieldSeriesSamples, sb)
+	}
+	return b
+}
+
+func (ts *TimeSeries) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType || (num != fieldSeriesLabels && num != fieldSeriesSamples) {
+			return skip(num, typ, b)
+		}
+		msg, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return 0, errTruncated
+		}
+		if num == fieldSeriesLabels {
+			var l Label
+			if err := l.unmarshal(msg); err != nil {
+				return 0, err
+			}
+			ts.Labels = append(ts.Labels, l)
+		} else {
+			var s Sample
+			if err := s.unmarshal(msg); err != nil {
+				return 0, err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return n, nil
+	})
+}
+
+func (l *Label) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType || (num != fieldLabelName && num != fieldLabelValue) {
+			return skip(num, typ, b)
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return 0, errTruncated
+		}
+		if num == fieldLabelName {
+			l.Name = string(v)
+		} else {
+			l.Value = string(v)
+		}
+		return n, nil
+	})
+}
+
+func (s *Sample) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == fieldSampleValue && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return 0, errTruncated
+			}
+			s.Value = math.Float64frombits(v)
+			return n, nil
+		case num == fieldSampleTimestamp && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return 0, errTruncated
+			}
+			s.Timestamp = int64(v)
+			return n, nil
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *MetricMetadata) marshal() []byte {
+	var b []byte
+	if m.Type != MetricTypeUnknown {
+		b = protowire.AppendTag(b, fieldMetadataType, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.Type))
+	}
+	b = appendString(b, fieldMetadataFamily, m.MetricFamilyName)
+	if m.Help != "" {
+		b = appendString(b, fieldMetadataHelp, m.Help)
+	}
+	if m.Unit != "" {
+		b = appendString(b, fieldMetadataUnit, m.Unit)
+	}
+	return b
+}
+
+func (m *MetricMetadata) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num == fieldMetadataType && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return 0, errTruncated
+			}
+			m.Type = MetricType(v)
+			return n, nil
+		}
+		if typ != protowire.BytesType {
+			return skip(num, typ, b)
+		}
+		var target *string
+		switch num {
+		case fieldMetadataFamily:
+			target = &m.MetricFamilyName
+		case fieldMetadataHelp:
+			target = &m.Help
+		case fieldMetadataUnit:
+			target = &m.Unit
+		default:
+			return skip(num, typ, b)
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return 0, errTruncated
+		}
+		*target = string(v)
+		return n, nil
+	})
+}
+
+// walk перебирает поля сообщения. Функция field получает данные после тега
+// и возвращает количество прочитанных байт.
+func walk(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// skip пропускает значение неизвестного поля
+func skip(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+	n := protowire.ConsumeFieldValue(num, typ, b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	return n, nil
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
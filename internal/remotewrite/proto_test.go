@@ -0,0 +1,84 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	req := &WriteRequest{
+		Timeseries: []TimeSeries{{
+			Labels:  []Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "code", Value: "200"}},
+			Samples: []Sample{{Value: 10, Timestamp: 1700000000000}, {Value: 12.5, Timestamp: 1700000015000}},
+		}},
+		Metadata: []MetricMetadata{{Type: MetricTypeCounter, MetricFamilyName: "http_requests_total", Help: "Requests", Unit: "requests"}},
+	}
+
+	decoded, err := Decode(Encode(req))
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if !reflect.DeepEqual(req, decoded) {
+		t.Errorf("Запрос изменился после кодирования:\nожидалось %+v\nполучено  %+v", req, decoded)
+	}
+}
+
+func TestDecode_SkipsUnknownFields(t *testing.T) {
+	// Ряд с exemplar (поле 3) и нативной гистограммой (поле 4) и запрос с зарезервированным полем 2
+	var series []byte
+	series = appendMessage(series, fieldSeriesLabels, appendString(appendString(nil, fieldLabelName, "__name__"), fieldLabelValue, "up"))
+	series = appendMessage(series, 3, []byte{0x08, 0x01})
+	series = appendMessage(series, 4, []byte{0x08, 0x01})
+	var sample []byte
+	sample = protowire.AppendTag(sample, fieldSampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(1))
+	series = appendMessage(series, fieldSeriesSamples, sample)
+
+	var data []byte
+	data = appendMessage(data, 2, []byte("legacy"))
+	data = appendMessage(data, fieldRequestTimeseries, series)
+
+	req, err := Decode(snappy.Encode(nil, data))
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if len(req.Timeseries) != 1 || len(req.Timeseries[0].Samples) != 1 || req.Timeseries[0].Labels[0].Value != "up" {
+		t.Errorf("Неверный результат разбора: %+v", req)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	valid := (&WriteRequest{Timeseries: []TimeSeries{{
+		Labels:  []Label{{Name: "__name__", Value: "up"}},
+		Samples: []Sample{{Value: 1, Timestamp: 1}},
+	}}}).Marshal()
+
+	tests := map[string][]byte{
+		"не snappy":        []byte("plain protobuf"),
+		"обрезанное тело":  snappy.Encode(nil, valid[:len(valid)-3]),
+		"некорректный тег": snappy.Encode(nil, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(data); err == nil {
+				t.Error("Ожидалась ошибка разбора")
+			}
+		})
+	}
+}
+
+func TestDecode_TooLarge(t *testing.T) {
+	// Заголовок snappy заявляет 4 ГиБ после распаковки, само тело - несколько байт
+	data := binary.AppendUvarint(nil, 1<<32-1)
+	data = append(data, 0x00, 'x')
+
+	if _, err := Decode(data); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Ожидалась ErrTooLarge, получено %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Package rollup периодически снимает значения метрик из хранилища и агрегирует их
+// по tier 1m, 1h и 1d: min, max, avg и last для gauge и сумму приростов для counter.
+// У каждого tier своё время хранения, запросы выбирают самый крупный tier,
+// подходящий под шаг.
+package rollup
+
+import (
+	"fmt"
+	"time"
+)
+
+// Имена tier
+const (
+	// TierRaw значения, снятые с периодом SampleInterval
+	TierRaw = "raw"
+	// Tier1m агрегаты за минуту
+	Tier1m = "1m"
+	// Tier1h агрегаты за час
+	Tier1h = "1h"
+	// Tier1d агрегаты за сутки UTC
+	Tier1d = "1d"
+)
+
+// Tier уровень хранения агрегатов
+type Tier struct {
+	Name string
+	// Width длительность интервала агрегации, 0 для TierRaw
+	Width time.Duration
+}
+
+// Tiers уровни хранения от мелкого к крупному. Каждый tier агрегируется из предыдущего.
+var Tiers = []Tier{
+	{Name: TierRaw},
+	{Name: Tier1m, Width: time.Minute},
+	{Name: Tier1h, Width: time.Hour},
+	{Name: Tier1d, Width: 24 * time.Hour},
+}
+
+// Значения по умолчанию
+const (
+	// DefaultSampleInterval период снятия значений метрик
+	DefaultSampleInterval = 10 * time.Second
+	// MaxSampleInterval наибольший период снятия: в каждой минуте должно быть хотя бы одно значение
+	MaxSampleInterval = time.Minute
+)
+
+// DefaultRetention возвращает время хранения tier по умолчанию:
+// raw - 1 час, 1m - сутки, 1h - 30 дней, 1d - год
+func DefaultRetention() map[string]time.Duration {
+	return map[string]time.Duration{
+		TierRaw: time.Hour,
+		Tier1m:  24 * time.Hour,
+		Tier1h:  30 * 24 * time.Hour,
+		Tier1d:  365 * 24 * time.Hour,
+	}
+}
+
+// Config настройки агрегации
+type Config struct {
+	// Enabled включает снятие значений и агрегацию. По умолчанию выключено: снятие
+	// записывает каждую серию в хранилище с периодом SampleInterval
+	Enabled bool
+	// SampleInterval период снятия значений метрик, 0 - DefaultSampleInterval
+	SampleInterval time.Duration
+	// Retention время хранения по имени tier, отсутствующие tier получают значения по умолчанию
+	Retention map[string]time.Duration
+}
+
+// withDefaults заменяет незаданные значения значениями по умолчанию
+func (c Config) withDefaults() Config {
+	if c.SampleInterval == 0 {
+		c.SampleInterval = DefaultSampleInterval
+	}
+	retention := DefaultRetention()
+	for tier, d := range c.Retention {
+		retention[tier] = d
+	}
+	c.Retention = retention
+	return c
+}
+
+// Validate проверяет настройки. Время хранения tier должно покрывать хотя бы два
+// интервала следующего tier, иначе агрегату не хватит исходных значений.
+func (c Config) Validate() error {
+	c = c.withDefaults()
+	if c.SampleInterval <= 0 || c.SampleInterval > MaxSampleInterval {
+		return fmt.Errorf("rollup sample interval must be in (0, %v], got %v", MaxSampleInterval, c.SampleInterval)
+	}
+	for name := range c.Retention {
+		if _, ok := tierIndex(name); !ok {
+			return fmt.Errorf("unknown rollup tier %q", name)
+		}
+	}
+	for i, tier := range Tiers {
+		retention := c.Retention[tier.Name]
+		if retention <= 0 {
+			return fmt.Errorf("rollup retention of tier %s must be positive, got %v", tier.Name, retention)
+		}
+		if i+1 < len(Tiers) && retention < 2*Tiers[i+1].Width {
+			return fmt.Errorf("rollup retention of tier %s must be at least %v, got %v",
+				tier.Name, 2*Tiers[i+1].Width, retention)
+		}
+	}
+	return nil
+}
+
+// tierIndex возвращает номер tier в Tiers
+func tierIndex(name string) (int, bool) {
+	for i, tier := range Tiers {
+		if tier.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
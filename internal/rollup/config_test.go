@@ -0,0 +1,47 @@
+package rollup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"по умолчанию", Config{}, false},
+		{"часть tier", Config{Retention: map[string]time.Duration{Tier1m: 6 * time.Hour}}, false},
+		{"период больше минуты", Config{SampleInterval: 2 * time.Minute}, true},
+		{"отрицательный период", Config{SampleInterval: -time.Second}, true},
+		{"неизвестный tier", Config{Retention: map[string]time.Duration{"5m": time.Hour}}, true},
+		{"raw короче двух минут", Config{Retention: map[string]time.Duration{TierRaw: time.Minute}}, true},
+		{"1h короче двух суток", Config{Retention: map[string]time.Duration{Tier1h: 24 * time.Hour}}, true},
+		{"нулевое хранение 1d", Config{Retention: map[string]time.Duration{Tier1d: -time.Hour}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSelectTier(t *testing.T) {
+	tests := map[time.Duration]string{
+		10 * time.Second:   TierRaw,
+		90 * time.Second:   TierRaw,
+		time.Minute:        Tier1m,
+		5 * time.Minute:    Tier1m,
+		90 * time.Minute:   Tier1m,
+		time.Hour:          Tier1h,
+		6 * time.Hour:      Tier1h,
+		24 * time.Hour:     Tier1d,
+		7 * 24 * time.Hour: Tier1d,
+	}
+	for step, want := range tests {
+		if got := SelectTier(step); got != want {
+			t.Errorf("SelectTier(%v): ожидался %s, получен %s", step, want, got)
+		}
+	}
+}
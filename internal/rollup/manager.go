@@ -0,0 +1,279 @@
+package rollup
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// cleanupInterval период удаления точек старше времени хранения tier
+const cleanupInterval = time.Minute
+
+// Manager снимает значения метрик в tier raw, агрегирует завершённые интервалы
+// в следующие tier и удаляет точки старше времени хранения.
+//
+// Значения снимаются из хранилища, поэтому учитываются обновления любых обработчиков
+// приёма. Агрегаты tier пересчитываются целиком из предыдущего tier и записываются
+// с заменой, поэтому повторная обработка интервала после перезапуска безопасна.
+type Manager struct {
+	storage storage.Storage
+	store   storage.RollupStore
+	cfg     Config
+	now     func() time.Time
+
+	// sampleMu защищает состояние снятия и агрегации: вызовы Sample, Rollup и Cleanup
+	// последовательны
+	sampleMu    sync.Mutex
+	counters    map[string]int64
+	sampled     bool
+	lastCleanup time.Time
+
+	// mu защищает watermarks, которые читаются запросами
+	mu sync.RWMutex
+	// watermarks конец последнего агрегированного интервала по tier
+	watermarks map[string]time.Time
+
+	// Поля для graceful shutdown
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	runMu   sync.Mutex
+	started bool
+	stopped bool
+}
+
+// NewManager создаёт Manager. Настройки должны пройти Config.Validate.
+func NewManager(s storage.Storage, store storage.RollupStore, cfg Config) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		storage:    s,
+		store:      store,
+		cfg:        cfg.withDefaults(),
+		now:        time.Now,
+		counters:   make(map[string]int64),
+		watermarks: make(map[string]time.Time),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Config возвращает настройки с применёнными значениями по умолчанию
+func (m *Manager) Config() Config {
+	return m.cfg
+}
+
+// Start запускает периодическое снятие значений, агрегацию и удаление старых точек
+func (m *Manager) Start() {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	if m.started || m.stopped {
+		return
+	}
+	m.started = true
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.run()
+		ticker := time.NewTicker(m.cfg.SampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-ticker.C:
+				m.run()
+			}
+		}
+	}()
+}
+
+// Stop останавливает фоновую обработку и дожидается завершения текущей итерации
+func (m *Manager) Stop() {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	m.cancel()
+	m.wg.Wait()
+}
+
+// run выполняет одну итерацию фоновой обработки
+func (m *Manager) run() {
+	if err := m.Sample(); err != nil {
+		log.Printf("Ошибка снятия значений метрик для агрегации: %v", err)
+	}
+	if err := m.Rollup(); err != nil {
+		log.Printf("Ошибка агрегации метрик: %v", err)
+	}
+	if err := m.Cleanup(); err != nil {
+		log.Printf("Ошибка удаления устаревших агрегатов: %v", err)
+	}
+}
+
+// Sample снимает текущие значения всех метрик в tier raw. Для counter записывается
+// прирост с предыдущего снятия: уменьшение значения считается сбросом, и приростом
+// становится новое значение. При первом снятии прирост counter неизвестен и равен нулю.
+func (m *Manager) Sample() error {
+	m.sampleMu.Lock()
+	defer m.sampleMu.Unlock()
+
+	now := m.now().UTC()
+	query := storage.MetricsQuery{Limit: storage.MaxListLimit}
+
+	var points []storage.RollupPoint
+	counters := make(map[string]int64, len(m.counters))
+	for {
+		page, err := m.storage.ListMetrics(query)
+		if err != nil {
+			return err
+		}
+		for _, metric := range page.Metrics {
+			if metric.Value == nil && metric.Delta == nil {
+				continue
+			}
+			value := metric.FloatValue()
+			p := storage.RollupPoint{Type: metric.MType, ID: metric.ID, Start: now, Count: 1}
+			p.Min, p.Max, p.Sum, p.Last = value, value, value, value
+			if metric.MType == string(storage.Counter) && metric.Delta != nil {
+				total := *metric.Delta
+				counters[metric.ID] = total
+				// Counter, появившийся после предыдущего снятия, начал счёт с нуля
+				var increase int64
+				if prev, ok := m.counters[metric.ID]; ok {
+					increase = total - prev
+					if increase < 0 {
+						increase = total
+					}
+				} else if m.sampled {
+					increase = total
+				}
+				p.Sum = float64(increase)
+			}
+			points = append(points, p)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if err := m.store.WriteRollups(TierRaw, points); err != nil {
+		return err
+	}
+	m.counters = counters
+	m.sampled = true
+	return nil
+}
+
+// Rollup агрегирует завершённые интервалы каждого tier из предыдущего tier.
+// Интервал tier обрабатывается, только когда предыдущий tier агрегирован до его конца.
+// После запуска обрабатываются интервалы, полностью покрытые временем хранения
+// предыдущего tier.
+func (m *Manager) Rollup() error {
+	m.sampleMu.Lock()
+	defer m.sampleMu.Unlock()
+
+	now := m.now().UTC()
+	for i := 1; i < len(Tiers); i++ {
+		src, dst := Tiers[i-1], Tiers[i]
+
+		end := now.Truncate(dst.Width)
+		if i > 1 {
+			if srcEnd := m.watermark(src.Name).Truncate(dst.Width); srcEnd.Before(end) {
+				end = srcEnd
+			}
+		}
+		start := m.watermark(dst.Name)
+		if start.IsZero() {
+			start = ceilTime(now.Add(-m.cfg.Retention[src.Name]), dst.Width)
+		}
+		if !start.Before(end) {
+			continue
+		}
+
+		points, err := m.store.ReadRollupRange(src.Name, start, end)
+		if err != nil {
+			return err
+		}
+		if err := m.store.WriteRollups(dst.Name, aggregate(points, dst.Width)); err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		m.watermarks[dst.Name] = end
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Cleanup удаляет точки старше времени хранения tier, не чаще раза в минуту
+func (m *Manager) Cleanup() error {
+	m.sampleMu.Lock()
+	defer m.sampleMu.Unlock()
+
+	now := m.now().UTC()
+	if now.Sub(m.lastCleanup) < cleanupInterval {
+		return nil
+	}
+	for _, tier := range Tiers {
+		if _, err := m.store.DeleteRollups(tier.Name, now.Add(-m.cfg.Retention[tier.Name])); err != nil {
+			return err
+		}
+	}
+	m.lastCleanup = now
+	return nil
+}
+
+// watermark возвращает конец последнего агрегированного интервала tier
+func (m *Manager) watermark(tier string) time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.watermarks[tier]
+}
+
+// ceilTime округляет t вверх до кратного d
+func ceilTime(t time.Time, d time.Duration) time.Time {
+	truncated := t.Truncate(d)
+	if truncated.Before(t) {
+		return truncated.Add(d)
+	}
+	return truncated
+}
+
+// merge добавляет к агрегату p следующую по времени точку next
+func merge(p *storage.RollupPoint, next storage.RollupPoint) {
+	if p.Count == 0 {
+		start := p.Start
+		*p = next
+		p.Start = start
+		return
+	}
+	p.Count += next.Count
+	p.Min = min(p.Min, next.Min)
+	p.Max = max(p.Max, next.Max)
+	p.Sum += next.Sum
+	p.Last = next.Last
+}
+
+// aggregate объединяет точки, упорядоченные по серии и времени, в интервалы длительности width
+func aggregate(points []storage.RollupPoint, width time.Duration) []storage.RollupPoint {
+	var result []storage.RollupPoint
+	for _, p := range points {
+		start := p.Start.Truncate(width)
+		n := len(result)
+		if n == 0 || result[n-1].Type != p.Type || result[n-1].ID != p.ID || !result[n-1].Start.Equal(start) {
+			result = append(result, storage.RollupPoint{Type: p.Type, ID: p.ID, Start: start})
+			n++
+		}
+		merge(&result[n-1], p)
+	}
+	return result
+}
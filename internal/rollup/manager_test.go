@@ -0,0 +1,242 @@
+package rollup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// clock управляемое время для Manager
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestManager(t *testing.T, s *storage.MemStorage, c *clock) *Manager {
+	t.Helper()
+	m := NewManager(s, s, Config{})
+	m.now = c.now
+	return m
+}
+
+// simulate каждые 10 секунд в течение d увеличивает PollCount на 1, записывает
+// в Load номер минуты и выполняет итерацию фоновой обработки
+func simulate(t *testing.T, m *Manager, s *storage.MemStorage, c *clock, d time.Duration) {
+	t.Helper()
+	for end := c.t.Add(d); c.t.Before(end); c.t = c.t.Add(10 * time.Second) {
+		s.UpdateCounter("PollCount", 1)
+		s.UpdateGauge("Load", float64(c.t.Sub(epoch)/time.Minute))
+		if err := m.Sample(); err != nil {
+			t.Fatalf("Sample() error: %v", err)
+		}
+		if err := m.Rollup(); err != nil {
+			t.Fatalf("Rollup() error: %v", err)
+		}
+		if err := m.Cleanup(); err != nil {
+			t.Fatalf("Cleanup() error: %v", err)
+		}
+	}
+}
+
+func TestManager_Rollup(t *testing.T) {
+	s := storage.NewMemStorage()
+	c := &clock{t: epoch}
+	m := newTestManager(t, s, c)
+	simulate(t, m, s, c, 2*time.Hour+5*time.Minute)
+
+	minutes, _ := s.ReadRollups(Tier1m, "gauge", "Load", epoch, epoch.Add(time.Hour))
+	if len(minutes) != 60 {
+		t.Fatalf("Ожидалось 60 минутных агрегатов Load, получено %d", len(minutes))
+	}
+	if p := minutes[7]; p.Count != 6 || p.Min != 7 || p.Max != 7 || p.Sum != 42 || p.Last != 7 {
+		t.Errorf("Неверный агрегат Load за 7-ю минуту: %+v", p)
+	}
+
+	counters, _ := s.ReadRollups(Tier1m, "counter", "PollCount", epoch, epoch.Add(2*time.Hour))
+	// При первом снятии прирост неизвестен, поэтому в первой минуте 5 вместо 6
+	if counters[0].Sum != 5 || counters[1].Sum != 6 || counters[1].Last != 12 {
+		t.Errorf("Неверные приросты PollCount: %+v %+v", counters[0], counters[1])
+	}
+
+	hours, _ := s.ReadRollups(Tier1h, "counter", "PollCount", epoch, epoch.Add(24*time.Hour))
+	if len(hours) != 2 || hours[0].Sum != 359 || hours[1].Sum != 360 || hours[1].Count != 360 {
+		t.Fatalf("Ожидалось два часовых агрегата с приростом 359 и 360, получено %+v", hours)
+	}
+	gaugeHours, _ := s.ReadRollups(Tier1h, "gauge", "Load", epoch, epoch.Add(24*time.Hour))
+	if p := gaugeHours[1]; p.Min != 60 || p.Max != 119 || p.Sum/float64(p.Count) != 89.5 {
+		t.Errorf("Неверный часовой агрегат Load: %+v", p)
+	}
+
+	// Суточный интервал ещё не завершён
+	if days, _ := s.ReadRollupRange(Tier1d, epoch, epoch.Add(48*time.Hour)); len(days) != 0 {
+		t.Errorf("Суточных агрегатов быть не должно, получено %+v", days)
+	}
+
+	// Снятые значения старше часа удалены
+	if raw, _ := s.ReadRollups(TierRaw, "gauge", "Load", epoch, c.t.Add(-time.Hour-time.Minute)); len(raw) != 0 {
+		t.Errorf("Снятые значения старше времени хранения не удалены: %d", len(raw))
+	}
+}
+
+func TestManager_DailyRollup(t *testing.T) {
+	s := storage.NewMemStorage()
+	c := &clock{t: epoch}
+	m := newTestManager(t, s, c)
+	simulate(t, m, s, c, 24*time.Hour+time.Hour)
+
+	days, _ := s.ReadRollups(Tier1d, "counter", "PollCount", epoch, epoch.Add(48*time.Hour))
+	if len(days) != 1 || days[0].Sum != 8639 || days[0].Count != 8640 {
+		t.Errorf("Ожидался суточный агрегат с приростом 8639, получено %+v", days)
+	}
+}
+
+func TestManager_CounterReset(t *testing.T) {
+	s := storage.NewMemStorage()
+	c := &clock{t: epoch}
+	m := newTestManager(t, s, c)
+
+	s.UpdateCounter("Requests", 100)
+	steps := []func(){
+		func() {},
+		func() { s.UpdateCounter("Requests", 20) },
+		// Сброс и 7 новых запросов между снятиями
+		func() {
+			if err := s.ResetCounter("Requests"); err != nil {
+				t.Fatalf("ResetCounter() error: %v", err)
+			}
+			s.UpdateCounter("Requests", 7)
+		},
+		// Counter, созданный после первого снятия, учитывается целиком
+		func() { s.UpdateCounter("Created", 3) },
+	}
+	for _, step := range steps {
+		step()
+		if err := m.Sample(); err != nil {
+			t.Fatalf("Sample() error: %v", err)
+		}
+		c.t = c.t.Add(10 * time.Second)
+	}
+
+	raw, _ := s.ReadRollups(TierRaw, "counter", "Requests", epoch, c.t)
+	var increases []float64
+	for _, p := range raw {
+		increases = append(increases, p.Sum)
+	}
+	if len(increases) != 4 || increases[0] != 0 || increases[1] != 20 || increases[2] != 7 || increases[3] != 0 {
+		t.Errorf("Ожидались приросты [0 20 7 0], получено %v", increases)
+	}
+	if created, _ := s.ReadRollups(TierRaw, "counter", "Created", epoch, c.t); len(created) != 1 || created[0].Sum != 3 {
+		t.Errorf("Ожидался прирост 3 нового counter, получено %+v", created)
+	}
+}
+
+func TestManager_Restart(t *testing.T) {
+	s := storage.NewMemStorage()
+	c := &clock{t: epoch}
+	simulate(t, newTestManager(t, s, c), s, c, 90*time.Minute)
+	// Последняя минута завершится только при следующем запуске агрегации
+	before, _ := s.ReadRollupRange(Tier1m, epoch, c.t)
+
+	// Новый Manager пересчитывает минуты из сохранённых значений с заменой
+	m := newTestManager(t, s, c)
+	if err := m.Rollup(); err != nil {
+		t.Fatalf("Rollup() error: %v", err)
+	}
+	after, _ := s.ReadRollupRange(Tier1m, epoch, c.t.Add(-time.Minute))
+	if len(after) != len(before) {
+		t.Fatalf("Количество агрегатов изменилось: %d -> %d", len(before), len(after))
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatalf("Агрегат изменился после перезапуска: %+v -> %+v", before[i], after[i])
+		}
+	}
+}
+
+func TestManager_Query(t *testing.T) {
+	s := storage.NewMemStorage()
+	c := &clock{t: epoch}
+	m := newTestManager(t, s, c)
+	simulate(t, m, s, c, 2*time.Hour+5*time.Minute)
+
+	tests := []struct {
+		name       string
+		mType      string
+		step       time.Duration
+		from       time.Time
+		wantTier   string
+		wantPoints int
+	}{
+		{"по часам с дополнением из минут и снятых значений", "counter", time.Hour, epoch, Tier1h, 3},
+		{"по 5 минут", "gauge", 5 * time.Minute, epoch.Add(time.Hour), Tier1m, 13},
+		{"по 30 секунд за последние 10 минут", "gauge", 30 * time.Second, c.t.Add(-10 * time.Minute), TierRaw, 20},
+	}
+	for _, tt := range tests {
+		result, err := m.Query(tt.mType, map[string]string{"counter": "PollCount", "gauge": "Load"}[tt.mType], tt.from, c.t, tt.step)
+		if err != nil {
+			t.Fatalf("%s: Query() error: %v", tt.name, err)
+		}
+		if result.Tier != tt.wantTier || len(result.Points) != tt.wantPoints {
+			t.Errorf("%s: ожидалось %d точек из %s, получено %d из %s",
+				tt.name, tt.wantPoints, tt.wantTier, len(result.Points), result.Tier)
+		}
+	}
+
+	result, _ := m.Query("counter", "PollCount", epoch, c.t, time.Hour)
+	var sums []int64
+	for _, p := range result.Points {
+		sums = append(sums, *p.Sum)
+	}
+	// Последний неполный час собран из минутных агрегатов и снятых значений
+	if len(sums) != 3 || sums[0] != 359 || sums[1] != 360 || sums[2] != 30 {
+		t.Errorf("Ожидались приросты [359 360 30], получено %v", sums)
+	}
+	if last := result.Points[2]; last.Last != 750 || last.Min != nil {
+		t.Errorf("Неверная последняя точка counter: %+v", last)
+	}
+
+	gauge, _ := m.Query("gauge", "Load", epoch.Add(time.Hour), epoch.Add(2*time.Hour), 30*time.Minute)
+	if p := gauge.Points[0]; *p.Min != 60 || *p.Max != 89 || *p.Avg != 74.5 || p.Sum != nil {
+		t.Errorf("Неверная точка gauge: %+v", p)
+	}
+}
+
+func TestManager_QueryInvalid(t *testing.T) {
+	s := storage.NewMemStorage()
+	m := NewManager(s, s, Config{})
+
+	tests := []struct {
+		mType    string
+		from, to time.Time
+		step     time.Duration
+	}{
+		{"histogram", epoch, epoch.Add(time.Hour), time.Minute},
+		{"gauge", epoch, epoch.Add(time.Hour), 0},
+		{"gauge", epoch, epoch, time.Minute},
+		{"gauge", epoch, epoch.Add(365 * 24 * time.Hour), time.Second},
+	}
+	for _, tt := range tests {
+		if _, err := m.Query(tt.mType, "Load", tt.from, tt.to, tt.step); err == nil {
+			t.Errorf("%+v: ожидалась ошибка", tt)
+		}
+	}
+}
+
+func TestManager_StartStop(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Load", 1)
+	m := NewManager(s, s, Config{SampleInterval: 10 * time.Millisecond})
+	m.Start()
+	time.Sleep(50 * time.Millisecond)
+	m.Stop()
+	m.Stop()
+
+	raw, _ := s.ReadRollups(TierRaw, "gauge", "Load", time.Now().Add(-time.Minute), time.Now())
+	if len(raw) == 0 {
+		t.Error("После запуска должны быть снятые значения")
+	}
+}
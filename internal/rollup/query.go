@@ -0,0 +1,104 @@
+package rollup
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// MaxPoints наибольшее количество точек в ответе на запрос
+const MaxPoints = 11000
+
+// ErrInvalidQuery возвращается для некорректных параметров запроса
+var ErrInvalidQuery = errors.New("invalid rollup query")
+
+// Point агрегат серии за шаг запроса. Для gauge заполняются min, max и avg,
+// для counter - sum, прирост за шаг.
+type Point struct {
+	Start time.Time `json:"start"`
+	// Count количество снятых значений в шаге
+	Count int64    `json:"count"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	Avg   *float64 `json:"avg,omitempty"`
+	Sum   *int64   `json:"sum,omitempty"`
+	// Last последнее значение gauge или накопленное значение counter
+	Last float64 `json:"last"`
+}
+
+// Result ответ на запрос агрегатов серии
+type Result struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Tier самый крупный tier, из которого читались точки
+	Tier   string  `json:"tier"`
+	Step   string  `json:"step"`
+	Points []Point `json:"points"`
+}
+
+// SelectTier возвращает самый крупный tier, интервал которого укладывается в шаг
+// целое число раз. Для шага меньше минуты используется TierRaw.
+func SelectTier(step time.Duration) string {
+	for i := len(Tiers) - 1; i > 0; i-- {
+		if w := Tiers[i].Width; step >= w && step%w == 0 {
+			return Tiers[i].Name
+		}
+	}
+	return TierRaw
+}
+
+// Query возвращает агрегаты серии за [from, to) с шагом step. Точки читаются из tier,
+// выбранного SelectTier; интервалы, которые этот tier ещё не агрегировал, дополняются
+// из более мелких tier, вплоть до снятых значений.
+func (m *Manager) Query(mType, id string, from, to time.Time, step time.Duration) (Result, error) {
+	switch {
+	case mType != string(storage.Gauge) && mType != string(storage.Counter):
+		return Result{}, fmt.Errorf("%w: unknown metric type %q", ErrInvalidQuery, mType)
+	case step <= 0:
+		return Result{}, fmt.Errorf("%w: step must be positive", ErrInvalidQuery)
+	case !from.Before(to):
+		return Result{}, fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	case to.Sub(from)/step > MaxPoints:
+		return Result{}, fmt.Errorf("%w: more than %d points, increase step", ErrInvalidQuery, MaxPoints)
+	}
+
+	tier := SelectTier(step)
+	index, _ := tierIndex(tier)
+	cursor := from.UTC().Truncate(step)
+	to = to.UTC()
+
+	var points []storage.RollupPoint
+	for i := index; i >= 0 && cursor.Before(to); i-- {
+		end := to
+		if i > 0 {
+			if wm := m.watermark(Tiers[i].Name); wm.Before(end) {
+				end = wm
+			}
+		}
+		if !cursor.Before(end) {
+			continue
+		}
+		read, err := m.store.ReadRollups(Tiers[i].Name, mType, id, cursor, end)
+		if err != nil {
+			return Result{}, err
+		}
+		points = append(points, read...)
+		cursor = end
+	}
+
+	result := Result{ID: id, Type: mType, Tier: tier, Step: step.String(), Points: make([]Point, 0)}
+	for _, p := range aggregate(points, step) {
+		point := Point{Start: p.Start, Count: p.Count, Last: p.Last}
+		if mType == string(storage.Gauge) {
+			avg := p.Sum / float64(p.Count)
+			point.Min, point.Max, point.Avg = &p.Min, &p.Max, &avg
+		} else {
+			sum := int64(p.Sum)
+			point.Sum = &sum
+		}
+		result.Points = append(result.Points, point)
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/alerting"
+)
+
+// AlertsPath путь списка активных алертов
+const AlertsPath = "/api/v1/alerts"
+
+// AlertsResponse ответ GET /api/v1/alerts
+type AlertsResponse struct {
+	Alerts []alerting.Alert `json:"alerts"`
+}
+
+// WithAlerts публикует активные алерты движка правил по GET /api/v1/alerts и на HTML странице
+func WithAlerts(engine *alerting.Engine) RouterOption {
+	return func(o *routerOptions) {
+		o.alerts = engine
+	}
+}
+
+// AlertsHandler обрабатывает GET /api/v1/alerts: возвращает алерты в состояниях pending и firing.
+// Параметр state оставляет алерты только в указанном состоянии.
+func (h *Handlers) AlertsHandler(w http.ResponseWriter, r *http.Request) {
+	if responseFormat(w, r, contentTypeJSON) == "" {
+		return
+	}
+
+	state := alerting.State(r.URL.Query().Get("state"))
+	switch state {
+	case "", alerting.StatePending, alerting.StateFiring:
+	default:
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "",
+			"invalid state %q, expected %s or %s", state, alerting.StatePending, alerting.StateFiring))
+		return
+	}
+
+	alerts := make([]alerting.Alert, 0)
+	for _, a := range h.alerts.Alerts() {
+		if state == "" || a.State == state {
+			alerts = append(alerts, a)
+		}
+	}
+	writeJSON(w, http.StatusOK, AlertsResponse{Alerts: alerts}, "AlertsHandler")
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/alerting"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+func alertsRouter(t *testing.T) http.Handler {
+	t.Helper()
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 150)
+	s.UpdateGauge("Load", 5)
+
+	engine := alerting.NewEngine(s, []alerting.Rule{
+		{Name: "HighHeap", Selector: alerting.Selector{Name: "HeapAlloc"}, Op: alerting.OpGreater, Threshold: 100,
+			Severity: alerting.SeverityCritical, Summary: "Много памяти"},
+		{Name: "HighLoad", Selector: alerting.Selector{Name: "Load"}, Op: alerting.OpGreater, Threshold: 1,
+			For: time.Hour, Severity: alerting.SeverityWarning},
+	}, nil, 0)
+	engine.Evaluate()
+
+	return NewRouter(s, "", "", WithAlerts(engine)).GetRouter()
+}
+
+func TestAlertsHandler(t *testing.T) {
+	router := alertsRouter(t)
+
+	tests := []struct {
+		query     string
+		wantRules []string
+	}{
+		{"", []string{"HighHeap", "HighLoad"}},
+		{"?state=firing", []string{"HighHeap"}},
+		{"?state=pending", []string{"HighLoad"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, AlertsPath+tt.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: ожидался статус 200, получен %d", tt.query, w.Code)
+		}
+
+		var resp AlertsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Ошибка разбора ответа: %v", err)
+		}
+		var rules []string
+		for _, a := range resp.Alerts {
+			rules = append(rules, a.Rule)
+		}
+		if strings.Join(rules, ",") != strings.Join(tt.wantRules, ",") {
+			t.Errorf("%q: ожидались алерты %v, получено %v", tt.query, tt.wantRules, rules)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, AlertsPath+"?state=resolved", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ErrCodeInvalidQuery) {
+		t.Errorf("Ожидалась ошибка invalid_query, получено %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestIndexHandler_Alerts(t *testing.T) {
+	w := httptest.NewRecorder()
+	alertsRouter(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "Активные алерты") || !strings.Contains(body, "[critical] HighHeap</strong> firing") {
+		t.Errorf("Алерты не показаны на странице:\n%s", body)
+	}
+
+	// Без движка правил раздел не показывается, маршрут не регистрируется
+	router := NewRouter(storage.NewMemStorage(), "", "").GetRouter()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(w.Body.String(), "Активные алерты") {
+		t.Error("Раздел алертов не должен показываться без движка правил")
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, AlertsPath, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Ожидался статус 404 без движка правил, получен %d", w.Code)
+	}
+}
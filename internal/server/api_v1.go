@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// validateMetric проверяет имя, тип и значение метрики. Если requireHash и задан ключ,
+// метрика должна быть подписана, как в батчах /updates/.
+func (h *Handlers) validateMetric(m models.Metrics, requireHash bool) *APIError {
+	if m.ID == "" {
+		return newAPIError(ErrCodeInvalidName, "", "metric id is required")
+	}
+	switch m.MType {
+	case string(storage.Gauge):
+		if m.Value == nil {
+			return newAPIError(ErrCodeMissingValue, m.ID, "gauge requires value")
+		}
+	case string(storage.Counter):
+		if m.Delta == nil {
+			return newAPIError(ErrCodeMissingValue, m.ID, "counter requires delta")
+		}
+	default:
+		return newAPIError(ErrCodeInvalidType, m.ID, "unknown metric type %q", m.MType)
+	}
+	if requireHash && !h.verifyMetricHash(m) {
+		return newAPIError(ErrCodeInvalidHash, m.ID, "hash is missing or does not match")
+	}
+	return nil
+}
+
+// currentMetric возвращает текущее значение метрики из хранилища
+func (h *Handlers) currentMetric(metricType, name string) (models.Metrics, *APIError) {
+	m := models.Metrics{ID: name, MType: metricType}
+	switch metricType {
+	case string(storage.Gauge):
+		v, err := h.storage.GetGauge(name)
+		if err != nil {
+			return m, newAPIError(ErrCodeNotFound, name, "metric not found")
+		}
+		m.Value = &v
+	case string(storage.Counter):
+		v, err := h.storage.GetCounter(name)
+		if err != nil {
+			return m, newAPIError(ErrCodeNotFound, name, "metric not found")
+		}
+		m.Delta = &v
+	default:
+		return m, newAPIError(ErrCodeInvalidType, name, "unknown metric type %q", metricType)
+	}
+	return m, nil
+}
+
+// writeMetric отвечает метрикой в выбранном формате: JSON с подписью
+// или текстом только со значением, как GET /value/{type}/{name}
+func (h *Handlers) writeMetric(w http.ResponseWriter, format string, m models.Metrics, handler string) {
+	if format == contentTypeText {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		var err error
+		if m.Value != nil {
+			_, err = fmt.Fprint(w, *m.Value)
+		} else if m.Delta != nil {
+			_, err = fmt.Fprint(w, *m.Delta)
+		}
+		if err != nil {
+			log.Printf("Ошибка при записи ответа в %s: %v", handler, err)
+		}
+		return
+	}
+
+	h.addHashToMetrics(&m)
+	writeJSON(w, http.StatusOK, m, handler)
+}
+
+// applyMetric применяет проверенную метрику и возвращает её значение после обновления
+func (h *Handlers) applyMetric(m models.Metrics) (models.Metrics, *APIError) {
+	switch m.MType {
+	case string(storage.Gauge):
+		h.storage.UpdateGauge(m.ID, *m.Value)
+	case string(storage.Counter):
+		h.storage.UpdateCounter(m.ID, *m.Delta)
+	}
+	current, apiErr := h.currentMetric(m.MType, m.ID)
+	if apiErr != nil {
+		log.Printf("Метрика %s не найдена после обновления", m.ID)
+		return current, newAPIError(ErrCodeStorage, m.ID, "metric was not stored")
+	}
+	return current, nil
+}
+
+// UpdateV1Handler обрабатывает POST /api/v1/update/{type}/{name}/{value}.
+// В ответ возвращается значение метрики после обновления.
+func (h *Handlers) UpdateV1Handler(w http.ResponseWriter, r *http.Request) {
+	format := responseFormat(w, r, contentTypeJSON, contentTypeText)
+	if format == "" {
+		return
+	}
+
+	m := models.Metrics{ID: chi.URLParam(r, "name"), MType: chi.URLParam(r, "type")}
+	value := chi.URLParam(r, "value")
+
+	if !h.checkHash(r) {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidHash, m.ID, "request hash does not match"))
+		return
+	}
+
+	switch m.MType {
+	case string(storage.Gauge):
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidValue, m.ID, "gauge value must be a number, got %q", value))
+			return
+		}
+		m.Value = &v
+	case string(storage.Counter):
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidValue, m.ID, "counter value must be an integer, got %q", value))
+			return
+		}
+		m.Delta = &v
+	}
+
+	if apiErr := h.validateMetric(m, false); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	current, apiErr := h.applyMetric(m)
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusInternalServerError, apiErr)
+		return
+	}
+	h.writeMetric(w, format, current, "UpdateV1Handler")
+}
+
+// decodeMetricJSON разбирает метрику из тела JSON запроса
+func (h *Handlers) decodeMetricJSON(w http.ResponseWriter, r *http.Request) (models.Metrics, bool) {
+	var m models.Metrics
+	if !isJSONRequest(r) {
+		writeAPIError(w, r, http.StatusUnsupportedMediaType,
+			newAPIError(ErrCodeUnsupportedMediaType, "", "request body must be %s", contentTypeJSON))
+		return m, false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &m)
+	}
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidJSON, "", "%v", err))
+		return m, false
+	}
+
+	// checkHash читает тело запроса повторно
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if !h.checkHash(r) {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidHash, m.ID, "request hash does not match"))
+		return m, false
+	}
+	return m, true
+}
+
+// UpdateJSONV1Handler обрабатывает POST /api/v1/update с метрикой в JSON
+func (h *Handlers) UpdateJSONV1Handler(w http.ResponseWriter, r *http.Request) {
+	format := responseFormat(w, r, contentTypeJSON, contentTypeText)
+	if format == "" {
+		return
+	}
+
+	m, ok := h.decodeMetricJSON(w, r)
+	if !ok {
+		return
+	}
+	if apiErr := h.validateMetric(m, false); apiErr != nil {
+		writeAPIError(w, r, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	current, apiErr := h.applyMetric(m)
+	if apiErr != nil {
+		writeAPIError(w, r, http.StatusInternalServerError, apiErr)
+		return
+	}
+	h.writeMetric(w, format, current, "UpdateJSONV1Handler")
+}
+
+// ValueV1Handler обрабатывает GET /api/v1/value/{type}/{name}
+func (h *Handlers) ValueV1Handler(w http.ResponseWriter, r *http.Request) {
+	format := responseFormat(w, r, contentTypeJSON, contentTypeText)
+	if format == "" {
+		return
+	}
+	h.writeValue(w, r, format, chi.URLParam(r, "type"), chi.URLParam(r, "name"), "ValueV1Handler")
+}
+
+// ValueJSONV1Handler обрабатывает POST /api/v1/value с идентификатором метрики в JSON
+func (h *Handlers) ValueJSONV1Handler(w http.ResponseWriter, r *http.Request) {
+	format := responseFormat(w, r, contentTypeJSON, contentTypeText)
+	if format == "" {
+		return
+	}
+
+	m, ok := h.decodeMetricJSON(w, r)
+	if !ok {
+		return
+	}
+	if m.ID == "" {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidName, "", "metric id is required"))
+		return
+	}
+	h.writeValue(w, r, format, m.MType, m.ID, "ValueJSONV1Handler")
+}
+
+// writeValue отвечает текущим значением метрики: 400 для неизвестного типа,
+// 404 для отсутствующей метрики
+func (h *Handlers) writeValue(w http.ResponseWriter, r *http.Request, format, metricType, name, handler string) {
+	m, apiErr := h.currentMetric(metricType, name)
+	if apiErr != nil {
+		status := http.StatusNotFound
+		if apiErr.Code == ErrCodeInvalidType {
+			status = http.StatusBadRequest
+		}
+		writeAPIError(w, r, status, apiErr)
+		return
+	}
+	h.writeMetric(w, format, m, handler)
+}
+
+// UpdatesV1Handler обрабатывает POST /api/v1/updates. Батч всегда применяется в режиме
+// BatchModeBestEffort: об отклонённых метриках сообщается в BatchResult, чтобы клиент
+// не повторял весь батч из-за одной ошибки.
+func (h *Handlers) UpdatesV1Handler(w http.ResponseWriter, r *http.Request) {
+	if responseFormat(w, r, contentTypeJSON) == "" {
+		return
+	}
+	if !isJSONRequest(r) {
+		writeAPIError(w, r, http.StatusUnsupportedMediaType,
+			newAPIError(ErrCodeUnsupportedMediaType, "", "request body must be %s", contentTypeJSON))
+		return
+	}
+
+	var metrics []models.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidJSON, "", "%v", err))
+		return
+	}
+	if len(metrics) == 0 {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeEmptyBatch, "", "batch contains no metrics"))
+		return
+	}
+
+	result, err := h.applyBatchBestEffort(metrics)
+	if err != nil {
+		log.Printf("Failed to update batch: %v", err)
+		writeAPIError(w, r, http.StatusInternalServerError, newAPIError(ErrCodeStorage, "", "batch was not applied, retry later"))
+		return
+	}
+	writeJSON(w, result.Status(), result, "UpdatesV1Handler")
+}
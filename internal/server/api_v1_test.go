@@ -0,0 +1,190 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		offers []string
+		want   string
+	}{
+		{name: "без заголовка", offers: []string{contentTypeJSON, contentTypeText}, want: contentTypeJSON},
+		{name: "точное совпадение", accept: "text/plain", offers: []string{contentTypeJSON, contentTypeText}, want: contentTypeText},
+		{name: "любой тип", accept: "*/*", offers: []string{contentTypeJSON, contentTypeText}, want: contentTypeJSON},
+		{name: "приоритет q", accept: "application/json;q=0.5, text/plain", offers: []string{contentTypeJSON, contentTypeText}, want: contentTypeText},
+		{name: "точный диапазон важнее маски", accept: "text/*;q=0.2, text/plain;q=0.9, application/json;q=0.5", offers: []string{contentTypeJSON, contentTypeText}, want: contentTypeText},
+		{name: "маска типа", accept: "text/*", offers: []string{contentTypeJSON, contentTypeText}, want: contentTypeText},
+		{name: "запрет через q=0", accept: "application/json;q=0, */*;q=0.1", offers: []string{contentTypeJSON, contentTypeText}, want: contentTypeText},
+		{name: "нет подходящего", accept: "text/html", offers: []string{contentTypeJSON}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if got := negotiate(req, tt.offers...); got != tt.want {
+				t.Errorf("negotiate(%q) = %q, ожидалось %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+// v1Request выполняет запрос к роутеру и разбирает ошибку APIError, если статус не 2xx
+func v1Request(t *testing.T, router http.Handler, method, path, body string, headers map[string]string) (*httptest.ResponseRecorder, APIError) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var apiErr APIError
+	if w.Code >= http.StatusBadRequest && strings.HasPrefix(w.Header().Get("Content-Type"), contentTypeJSON) {
+		if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil {
+			t.Fatalf("Ошибка разбора APIError: %v, тело %s", err, w.Body.String())
+		}
+	}
+	return w, apiErr
+}
+
+func TestV1Errors(t *testing.T) {
+	const key = "secret"
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1.5)
+	router := NewRouter(s, key, "").GetRouter()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		headers    map[string]string
+		wantStatus int
+		wantCode   string
+		wantMetric string
+	}{
+		{name: "неверная подпись", method: http.MethodPost, path: "/api/v1/update/gauge/Load/1", headers: map[string]string{"HashSHA256": "bad"}, wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidHash, wantMetric: "Load"},
+		{name: "некорректное значение", method: http.MethodPost, path: "/api/v1/update/counter/Load/1.5", wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidValue, wantMetric: "Load"},
+		{name: "неизвестный тип", method: http.MethodPost, path: "/api/v1/update/histogram/Load/1", wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidType, wantMetric: "Load"},
+		{name: "некорректный JSON", method: http.MethodPost, path: "/api/v1/update", body: `{"id":`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidJSON},
+		{name: "нет значения", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"Load","type":"gauge"}`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeMissingValue, wantMetric: "Load"},
+		{name: "не JSON тело", method: http.MethodPost, path: "/api/v1/update", headers: map[string]string{"Content-Type": "text/plain"}, wantStatus: http.StatusUnsupportedMediaType, wantCode: ErrCodeUnsupportedMediaType},
+		{name: "отсутствующая метрика", method: http.MethodGet, path: "/api/v1/value/gauge/Missing", wantStatus: http.StatusNotFound, wantCode: ErrCodeNotFound, wantMetric: "Missing"},
+		{name: "неподдерживаемый Accept", method: http.MethodGet, path: "/api/v1/value/gauge/Alloc", headers: map[string]string{"Accept": "text/html"}, wantStatus: http.StatusNotAcceptable, wantCode: ErrCodeNotAcceptable},
+		{name: "пустой батч", method: http.MethodPost, path: "/api/v1/updates", body: `[]`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeEmptyBatch},
+		{name: "некорректный запрос списка", method: http.MethodGet, path: "/api/v1/metrics?sort=size", wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidQuery},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, apiErr := v1Request(t, router, tt.method, tt.path, tt.body, tt.headers)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Ожидался статус %d, получен %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if apiErr.Code != tt.wantCode || apiErr.Metric != tt.wantMetric || apiErr.Message == "" {
+				t.Errorf("Ожидалась ошибка %s для %q, получено %+v", tt.wantCode, tt.wantMetric, apiErr)
+			}
+		})
+	}
+}
+
+func TestV1ContentNegotiation(t *testing.T) {
+	s := storage.NewMemStorage()
+	router := NewRouter(s, "", "").GetRouter()
+
+	w, _ := v1Request(t, router, http.MethodPost, "/api/v1/update/counter/PollCount/2", "", nil)
+	w, _ = v1Request(t, router, http.MethodPost, "/api/v1/update/counter/PollCount/3", "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentTypeJSON {
+		t.Fatalf("Ожидался JSON ответ, получено %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"id":"PollCount","type":"counter","delta":5}` {
+		t.Errorf("Ожидалось накопленное значение 5, получено %s", body)
+	}
+
+	w, _ = v1Request(t, router, http.MethodGet, "/api/v1/value/counter/PollCount", "", map[string]string{"Accept": "text/plain"})
+	if w.Code != http.StatusOK || w.Body.String() != "5" || !strings.HasPrefix(w.Header().Get("Content-Type"), contentTypeText) {
+		t.Errorf("Ожидался текстовый ответ 5, получено %d %q", w.Code, w.Body.String())
+	}
+
+	w, _ = v1Request(t, router, http.MethodGet, "/api/v1/value/counter/Missing", "", map[string]string{"Accept": "text/plain"})
+	if w.Code != http.StatusNotFound || !strings.HasPrefix(w.Body.String(), ErrCodeNotFound+":") {
+		t.Errorf("Ожидалась текстовая ошибка not_found, получено %d %q", w.Code, w.Body.String())
+	}
+
+	w, _ = v1Request(t, router, http.MethodPost, "/api/v1/value", `{"id":"PollCount","type":"counter"}`, nil)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"id":"PollCount","type":"counter","delta":5}` {
+		t.Errorf("Ожидалась метрика PollCount, получено %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdatesV1Handler(t *testing.T) {
+	const key = "secret"
+	sign := func(id, mType, value string) string {
+		return utils.CalculateHash([]byte(fmt.Sprintf("%s:%s:%s", id, mType, value)), key)
+	}
+
+	s := storage.NewMemStorage()
+	router := NewRouter(s, key, "").GetRouter()
+
+	body := fmt.Sprintf(`[
+		{"id":"Alloc","type":"gauge","value":1.5,"hash":%q},
+		{"id":"PollCount","type":"counter","delta":2,"hash":"bad"},
+		{"id":"Heap","type":"histogram","value":1},
+		{"id":"Load","type":"gauge"},
+		{"id":"PollCount","type":"counter","delta":3,"hash":%q}
+	]`, sign("Alloc", "gauge", "1.500000"), sign("PollCount", "counter", "3"))
+
+	w, _ := v1Request(t, router, http.MethodPost, "/api/v1/updates", body, nil)
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("Ожидался статус 207, получен %d: %s", w.Code, w.Body.String())
+	}
+
+	var result BatchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Ошибка разбора ответа: %v", err)
+	}
+	if result.Accepted != 2 || result.Rejected != 3 || len(result.Results) != 5 {
+		t.Fatalf("Ожидалось 2 принятых и 3 отклонённых метрики, получено %+v", result)
+	}
+	wantCodes := []string{"", ErrCodeInvalidHash, ErrCodeInvalidType, ErrCodeMissingValue, ""}
+	for i, item := range result.Results {
+		if item.Index != i {
+			t.Errorf("Неверный индекс результата %d: %d", i, item.Index)
+		}
+		switch {
+		case wantCodes[i] == "" && (item.Status != BatchItemOK || item.Error != nil):
+			t.Errorf("Метрика %d должна быть принята: %+v", i, item)
+		case wantCodes[i] != "" && (item.Status != BatchItemRejected || item.Error == nil || item.Error.Code != wantCodes[i]):
+			t.Errorf("Метрика %d должна быть отклонена с кодом %s: %+v", i, wantCodes[i], item)
+		}
+	}
+
+	if v, err := s.GetGauge("Alloc"); err != nil || v != 1.5 {
+		t.Errorf("Ожидалось Alloc=1.5, получено %v %v", v, err)
+	}
+	if v, err := s.GetCounter("PollCount"); err != nil || v != 3 {
+		t.Errorf("Отклонённое приращение не должно применяться: PollCount=%v %v", v, err)
+	}
+
+	w, _ = v1Request(t, router, http.MethodPost, "/api/v1/updates", `[{"id":"Load","type":"gauge","value":1}]`, nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Без подписи все метрики отклоняются, ожидался статус 422, получен %d", w.Code)
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Типы содержимого, которые поддерживает /api/v1
+const (
+	contentTypeJSON = "application/json"
+	contentTypeText = "text/plain"
+)
+
+// Коды ошибок /api/v1
+const (
+	// ErrCodeInvalidJSON тело запроса не является корректным JSON
+	ErrCodeInvalidJSON = "invalid_json"
+	// ErrCodeInvalidName не задано имя метрики
+	ErrCodeInvalidName = "invalid_name"
+	// ErrCodeInvalidType неизвестный тип метрики
+	ErrCodeInvalidType = "invalid_type"
+	// ErrCodeInvalidValue значение метрики не разбирается как число нужного типа
+	ErrCodeInvalidValue = "invalid_value"
+	// ErrCodeMissingValue не передано value для gauge или delta для counter
+	ErrCodeMissingValue = "missing_value"
+	// ErrCodeInvalidHash подпись HMAC-SHA256 не совпадает или отсутствует
+	ErrCodeInvalidHash = "invalid_hash"
+	// ErrCodeEmptyBatch батч не содержит метрик
+	ErrCodeEmptyBatch = "empty_batch"
+	// ErrCodeInvalidQuery некорректные параметры запроса
+	ErrCodeInvalidQuery = "invalid_query"
+	// ErrCodeNotFound метрика не найдена
+	ErrCodeNotFound = "not_found"
+	// ErrCodeUnsupportedMediaType тело запроса передано в неподдерживаемом формате
+	ErrCodeUnsupportedMediaType = "unsupported_media_type"
+	// ErrCodeNotAcceptable клиент не принимает ни один из форматов ответа
+	ErrCodeNotAcceptable = "not_acceptable"
+	// ErrCodeStorage ошибка хранилища, запрос можно повторить
+	ErrCodeStorage = "storage_error"
+	// ErrCodeInvalidPayload тело запроса не разбирается в заявленном формате (protobuf, snappy)
+	ErrCodeInvalidPayload = "invalid_payload"
+	// ErrCodePayloadTooLarge тело запроса больше допустимого размера
+	ErrCodePayloadTooLarge = "payload_too_large"
+)
+
+// APIError тело ответа с ошибкой в /api/v1
+type APIError struct {
+	// Code машиночитаемый код ошибки
+	Code string `json:"code"`
+	// Message описание ошибки
+	Message string `json:"message"`
+	// Metric идентификатор метрики, к которой относится ошибка
+	Metric string `json:"metric,omitempty"`
+}
+
+// Error реализует интерфейс error
+func (e *APIError) Error() string {
+	if e.Metric != "" {
+		return fmt.Sprintf("%s: %s (%s)", e.Code, e.Message, e.Metric)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// newAPIError создаёт ошибку для метрики metric, metric может быть пустым
+func newAPIError(code, metric, format string, args ...any) *APIError {
+	return &APIError{Code: code, Message: fmt.Sprintf(format, args...), Metric: metric}
+}
+
+// negotiate выбирает тип ответа из offers по заголовку Accept. Вес варианта берётся
+// из самого точного подходящего диапазона, при равном весе побеждает вариант,
+// указанный раньше. Без заголовка Accept выбирается первый вариант. Пустая строка
+// означает, что клиент не принимает ни один из вариантов.
+func negotiate(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, mr := range ranges {
+			if s := mediaRangeSpecificity(mr.mediaType, offer); s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// mediaRangeSpecificity возвращает точность совпадения диапазона из Accept с типом:
+// 2 - точное совпадение, 1 - type/*, 0 - */*, -1 - не совпадает
+func mediaRangeSpecificity(mediaRange, offer string) int {
+	switch {
+	case mediaRange == offer:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	}
+	return -1
+}
+
+// isJSONRequest проверяет, что тело запроса передано в формате JSON.
+// Параметры вроде charset допускаются.
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == contentTypeJSON
+}
+
+// writeAPIError отвечает ошибкой в формате, выбранном по заголовку Accept:
+// JSON объект APIError или текст "code: message"
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, apiErr *APIError) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if negotiate(r, contentTypeJSON, contentTypeText) == contentTypeText {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		if _, err := fmt.Fprintln(w, apiErr.Error()); err != nil {
+			log.Printf("Ошибка при записи ответа с ошибкой: %v", err)
+		}
+		return
+	}
+
+	// Клиент, не принимающий ни JSON, ни текст, всё равно получает JSON
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(apiErr); err != nil {
+		log.Printf("Ошибка при записи ответа с ошибкой: %v", err)
+	}
+}
+
+// writeMiddlewareError формирует ошибки middleware в формате /api/v1
+func writeMiddlewareError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeAPIError(w, r, status, &APIError{Code: code, Message: message})
+}
+
+// responseFormat выбирает формат ответа из offers. Если клиент не принимает
+// ни один из них, отвечает 406 и возвращает пустую строку.
+func responseFormat(w http.ResponseWriter, r *http.Request, offers ...string) string {
+	format := negotiate(r, offers...)
+	if format == "" {
+		writeAPIError(w, r, http.StatusNotAcceptable,
+			newAPIError(ErrCodeNotAcceptable, "", "supported response types: %s", strings.Join(offers, ", ")))
+	}
+	return format
+}
+
+// writeJSON отвечает объектом v в JSON с указанным статусом
+func writeJSON(w http.ResponseWriter, status int, v any, handler string) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Ошибка при записи ответа в %s: %v", handler, err)
+	}
+}
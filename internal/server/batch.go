@@ -0,0 +1,149 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// Режимы применения батча /updates/
+const (
+	// BatchModeAtomic батч применяется целиком или отклоняется целиком, если хотя бы
+	// одна метрика некорректна или не прошла проверку подписи
+	BatchModeAtomic = "atomic"
+	// BatchModeBestEffort применяются корректные метрики батча, об отклонённых
+	// сообщается в BatchResult
+	BatchModeBestEffort = "best-effort"
+)
+
+// ValidateBatchMode проверяет название режима применения батча
+func ValidateBatchMode(mode string) error {
+	switch mode {
+	case "", BatchModeAtomic, BatchModeBestEffort:
+		return nil
+	}
+	return fmt.Errorf("неизвестный режим применения батча %q, ожидается %s или %s",
+		mode, BatchModeAtomic, BatchModeBestEffort)
+}
+
+// Статусы метрики в ответе на батч
+const (
+	// BatchItemOK метрика применена
+	BatchItemOK = "ok"
+	// BatchItemRejected метрика отклонена, причина в поле error
+	BatchItemRejected = "rejected"
+)
+
+// BatchItemResult результат обработки одной метрики батча
+type BatchItemResult struct {
+	// Index позиция метрики в батче
+	Index  int       `json:"index"`
+	ID     string    `json:"id"`
+	MType  string    `json:"type"`
+	Status string    `json:"status"`
+	Error  *APIError `json:"error,omitempty"`
+}
+
+// BatchResult ответ на батч: количество принятых и отклонённых метрик
+// и результат по каждой метрике в порядке батча
+type BatchResult struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Results  []BatchItemResult `json:"results"`
+}
+
+// Status возвращает HTTP статус ответа на батч: 200 - применены все метрики,
+// 207 - часть метрик отклонена, 422 - отклонены все метрики
+func (res BatchResult) Status() int {
+	switch {
+	case res.Accepted == 0:
+		return http.StatusUnprocessableEntity
+	case res.Rejected > 0:
+		return http.StatusMultiStatus
+	}
+	return http.StatusOK
+}
+
+// errInvalidBatch ошибка в данных батча, повтор запроса не поможет
+var errInvalidBatch = errors.New("invalid batch")
+
+// applyBatch применяет батч в режиме BatchModeAtomic: проверяет все метрики и их подписи
+// и применяет батч к хранилищу одной операцией. Ошибки в данных оборачивают
+// errInvalidBatch, остальные - ошибки хранилища.
+func (h *Handlers) applyBatch(metrics []models.Metrics) error {
+	if len(metrics) == 0 {
+		return fmt.Errorf("%w: empty batch", errInvalidBatch)
+	}
+
+	for i, metric := range metrics {
+		if apiErr := h.validateMetric(metric, true); apiErr != nil {
+			log.Printf("Batch metric %d rejected: %v", i, apiErr)
+			return fmt.Errorf("%w: %v", errInvalidBatch, apiErr)
+		}
+	}
+
+	// Обновляем все метрики в батче одной операцией
+	if err := h.storage.UpdateBatch(mergeBatch(metrics)); err != nil {
+		if errors.Is(err, storage.ErrInvalidMetric) {
+			return fmt.Errorf("%w: %v", errInvalidBatch, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// applyBatchBestEffort применяет батч в режиме BatchModeBestEffort: каждая метрика
+// проверяется отдельно, корректные применяются одной операцией. Ошибка возвращается,
+// только если хранилище не применило корректные метрики, тогда не применена ни одна.
+func (h *Handlers) applyBatchBestEffort(metrics []models.Metrics) (BatchResult, error) {
+	result := BatchResult{Results: make([]BatchItemResult, len(metrics))}
+	valid := make([]models.Metrics, 0, len(metrics))
+	for i, m := range metrics {
+		item := BatchItemResult{Index: i, ID: m.ID, MType: m.MType, Status: BatchItemOK}
+		if apiErr := h.validateMetric(m, true); apiErr != nil {
+			item.Status = BatchItemRejected
+			item.Error = apiErr
+			result.Rejected++
+		} else {
+			valid = append(valid, m)
+			result.Accepted++
+		}
+		result.Results[i] = item
+	}
+
+	if len(valid) > 0 {
+		if err := h.storage.UpdateBatch(mergeBatch(valid)); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// mergeBatch объединяет метрики батча с одинаковыми именем и типом:
+// для counter приращения суммируются, для gauge остаётся последнее значение
+func mergeBatch(metrics []models.Metrics) []models.Metrics {
+	// Группируем метрики по ключу (name, type) для избежания дубликатов в одном батче
+	metricsMap := make(map[string]models.Metrics)
+	for _, metric := range metrics {
+		key := metric.ID + "_" + metric.MType
+		if existing, exists := metricsMap[key]; exists {
+			// Если метрика уже есть, объединяем значения
+			if metric.MType == "counter" && metric.Delta != nil && existing.Delta != nil {
+				combinedDelta := *existing.Delta + *metric.Delta
+				metric.Delta = &combinedDelta
+			}
+		}
+		metricsMap[key] = metric
+	}
+
+	uniqueMetrics := make([]models.Metrics, 0, len(metricsMap))
+	for _, metric := range metricsMap {
+		uniqueMetrics = append(uniqueMetrics, metric)
+	}
+
+	return uniqueMetrics
+}
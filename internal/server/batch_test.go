@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+func TestValidateBatchMode(t *testing.T) {
+	for _, mode := range []string{"", BatchModeAtomic, BatchModeBestEffort} {
+		if err := ValidateBatchMode(mode); err != nil {
+			t.Errorf("Режим %q должен быть допустимым: %v", mode, err)
+		}
+	}
+	if err := ValidateBatchMode("partial"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного режима")
+	}
+}
+
+// mixedBatch возвращает батч из двух подписанных корректных метрик и трёх некорректных:
+// с неверной подписью, неизвестным типом и без значения
+func mixedBatch(key string) string {
+	sign := func(data string) string {
+		return utils.CalculateHash([]byte(data), key)
+	}
+	return fmt.Sprintf(`[
+		{"id":"Alloc","type":"gauge","value":1.5,"hash":%q},
+		{"id":"PollCount","type":"counter","delta":2,"hash":"bad"},
+		{"id":"Heap","type":"histogram","value":1},
+		{"id":"Load","type":"gauge","hash":%q},
+		{"id":"PollCount","type":"counter","delta":3,"hash":%q}
+	]`, sign("Alloc:gauge:1.500000"), sign("Load:gauge:0.000000"), sign("PollCount:counter:3"))
+}
+
+func postUpdates(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdatesHandler_BatchModes(t *testing.T) {
+	const key = "test-key"
+
+	t.Run("atomic отклоняет весь батч", func(t *testing.T) {
+		for _, mode := range []string{"", BatchModeAtomic} {
+			s := storage.NewMemStorage()
+			router := NewRouter(s, key, "", WithBatchMode(mode)).GetRouter()
+
+			w := postUpdates(router, mixedBatch(key))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Режим %q: ожидался статус 400, получен %d", mode, w.Code)
+			}
+			if _, err := s.GetGauge("Alloc"); err == nil {
+				t.Errorf("Режим %q: корректные метрики не должны применяться", mode)
+			}
+			if _, err := s.GetCounter("PollCount"); err == nil {
+				t.Errorf("Режим %q: корректные метрики не должны применяться", mode)
+			}
+		}
+	})
+
+	t.Run("atomic без подписей и с неизвестным типом", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		router := NewRouter(s, "", "").GetRouter()
+
+		// Раньше неизвестный тип приводил к 500 после частичного применения батча
+		w := postUpdates(router, `[{"id":"Alloc","type":"gauge","value":1},{"id":"Heap","type":"histogram","value":1}]`)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Ожидался статус 400, получен %d", w.Code)
+		}
+		if _, err := s.GetGauge("Alloc"); err == nil {
+			t.Error("Метрика Alloc не должна применяться")
+		}
+	})
+
+	t.Run("best-effort применяет корректные метрики", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		router := NewRouter(s, key, "", WithBatchMode(BatchModeBestEffort)).GetRouter()
+
+		w := postUpdates(router, mixedBatch(key))
+		if w.Code != http.StatusMultiStatus {
+			t.Fatalf("Ожидался статус 207, получен %d: %s", w.Code, w.Body.String())
+		}
+
+		var result BatchResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Ошибка разбора ответа: %v", err)
+		}
+		if result.Accepted != 2 || result.Rejected != 3 {
+			t.Fatalf("Ожидалось 2 принятых и 3 отклонённых метрики, получено %+v", result)
+		}
+		wantCodes := []string{"", ErrCodeInvalidHash, ErrCodeInvalidType, ErrCodeMissingValue, ""}
+		for i, item := range result.Results {
+			got := ""
+			if item.Error != nil {
+				got = item.Error.Code
+			}
+			if got != wantCodes[i] {
+				t.Errorf("Метрика %d: ожидался код %q, получен %q", i, wantCodes[i], got)
+			}
+		}
+
+		if v, err := s.GetGauge("Alloc"); err != nil || v != 1.5 {
+			t.Errorf("Ожидалось Alloc=1.5, получено %v %v", v, err)
+		}
+		if v, err := s.GetCounter("PollCount"); err != nil || v != 3 {
+			t.Errorf("Ожидалось PollCount=3 без отклонённого приращения, получено %v %v", v, err)
+		}
+	})
+
+	t.Run("best-effort все метрики корректны", func(t *testing.T) {
+		router := NewRouter(storage.NewMemStorage(), "", "", WithBatchMode(BatchModeBestEffort)).GetRouter()
+		w := postUpdates(router, `[{"id":"Alloc","type":"gauge","value":1}]`)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"accepted":1`) {
+			t.Errorf("Ожидался статус 200 с отчётом, получено %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("best-effort все метрики отклонены", func(t *testing.T) {
+		router := NewRouter(storage.NewMemStorage(), "", "", WithBatchMode(BatchModeBestEffort)).GetRouter()
+		w := postUpdates(router, `[{"id":"Alloc","type":"gauge"}]`)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Ожидался статус 422, получен %d", w.Code)
+		}
+	})
+
+	t.Run("best-effort пустой батч", func(t *testing.T) {
+		router := NewRouter(storage.NewMemStorage(), "", "", WithBatchMode(BatchModeBestEffort)).GetRouter()
+		if w := postUpdates(router, `[]`); w.Code != http.StatusBadRequest {
+			t.Errorf("Ожидался статус 400, получен %d", w.Code)
+		}
+	})
+}
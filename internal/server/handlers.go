@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -10,7 +11,13 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/ViktorBystrov72/go-metrics/internal/alerting"
+	"github.com/ViktorBystrov72/go-metrics/internal/influx"
 	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/otlp"
+	"github.com/ViktorBystrov72/go-metrics/internal/rates"
+	"github.com/ViktorBystrov72/go-metrics/internal/remotewrite"
+	"github.com/ViktorBystrov72/go-metrics/internal/rollup"
 	"github.com/ViktorBystrov72/go-metrics/internal/storage"
 	"github.com/ViktorBystrov72/go-metrics/internal/utils"
 	"github.com/go-chi/chi/v5"
@@ -20,13 +27,29 @@ import (
 type Handlers struct {
 	storage storage.Storage
 	key     string
+	// batchMode режим применения батча /updates/, по умолчанию BatchModeAtomic
+	batchMode string
+	// otlpConverter преобразует метрики OTLP и хранит состояние cumulative сумм
+	otlpConverter *otlp.Converter
+	// influx настройки преобразования точек line protocol
+	influx influx.Config
+	// remoteWrite преобразует ряды remote_write и хранит последние значения counter
+	remoteWrite *remotewrite.Converter
+	// alerts движок правил, активные алерты которого показываются на HTML странице
+	alerts *alerting.Engine
+	// rates прирост и скорость роста counter метрик для /api/v1/rates, HTML страницы и /metrics
+	rates *rates.Tracker
+	// rollups агрегаты метрик по tier для /api/v1/rollups
+	rollups *rollup.Manager
 }
 
 // NewHandlers создает новые обработчики
 func NewHandlers(storage storage.Storage, key string) *Handlers {
 	return &Handlers{
-		storage: storage,
-		key:     key,
+		storage:       storage,
+		key:           key,
+		otlpConverter: otlp.NewConverter(otlp.Config{}),
+		remoteWrite:   remotewrite.NewConverter(0),
 	}
 }
 
@@ -81,6 +104,16 @@ func (h *Handlers) checkHash(r *http.Request) bool {
 	// Восстанавливаем тело запроса для дальнейшего использования
 	r.Body = io.NopCloser(bytes.NewReader(body))
 
+	return h.checkBodyHash(r, body)
+}
+
+// checkBodyHash проверяет хеш тела запроса из заголовка HashSHA256 (или Hash).
+// Запрос без заголовка проходит проверку.
+func (h *Handlers) checkBodyHash(r *http.Request, body []byte) bool {
+	if h.key == "" {
+		return true
+	}
+
 	receivedHash := r.Header.Get("HashSHA256")
 	if receivedHash == "" {
 		// Поддерживаем также заголовок Hash для обратной совместимости
@@ -298,10 +331,25 @@ func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
         .metric-item { margin: 5px 0; padding: 5px; background-color: #f5f5f5; border-radius: 3px; }
         h1 { color: #333; }
         h2 { color: #666; }
+        .alert-firing { background-color: #f8d7da; }
+        .alert-pending { background-color: #fff3cd; }
+        .metric-rate { color: #666; }
     </style>
 </head>
 <body>
     <h1>Метрики системы</h1>
+    {{if .AlertsEnabled}}
+    <div class="metric-section">
+        <h2>Активные алерты</h2>
+        {{range .Alerts}}
+        <div class="metric-item alert-{{.State}}">
+            <strong>[{{.Severity}}] {{.Rule}}</strong> {{.State}}: {{.Metric}} = {{.Value}} ({{.Op}} {{.Threshold}}){{if .Summary}} - {{.Summary}}{{end}}
+        </div>
+        {{else}}
+        <div class="metric-item">Нет активных алертов</div>
+        {{end}}
+    </div>
+    {{end}}
 
     <div class="metric-section">
         <h2>Gauge метрики</h2>
@@ -318,7 +366,8 @@ func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
         <h2>Counter метрики</h2>
         {{range $name, $value := .Counters}}
         <div class="metric-item">
-            <strong>{{$name}}:</strong> {{$value}}
+            <strong>{{$name}}:</strong> {{$value}}{{with index $.Rates $name}}{{if .Samples}}
+            <span class="metric-rate">(increase за {{$.RateWindow}}: {{.Increase}}, rate: {{printf "%.3f" .Rate}}/s{{if .Resets}}, сбросов: {{.Resets}}{{end}})</span>{{end}}{{end}}
         </div>
         {{else}}
         <div class="metric-item">Нет counter метрик</div>
@@ -334,11 +383,23 @@ func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := struct {
-		Gauges   map[string]float64
-		Counters map[string]int64
+		Gauges        map[string]float64
+		Counters      map[string]int64
+		AlertsEnabled bool
+		Alerts        []alerting.Alert
+		Rates         map[string]rates.Result
+		RateWindow    string
 	}{
-		Gauges:   gauges,
-		Counters: counters,
+		Gauges:        gauges,
+		Counters:      counters,
+		AlertsEnabled: h.alerts != nil,
+	}
+	if h.alerts != nil {
+		data.Alerts = h.alerts.Alerts()
+	}
+	if h.rates != nil {
+		data.Rates = h.rates.Rates(rates.DefaultWindow)
+		data.RateWindow = promWindow(rates.DefaultWindow)
 	}
 
 	w.Header().Set("Content-Type", "text/html")
@@ -486,7 +547,9 @@ func (h *Handlers) PingHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// UpdatesHandler обрабатывает POST запросы для обновления множества метрик в JSON формате
+// UpdatesHandler обрабатывает POST запросы для обновления множества метрик в JSON формате.
+// В режиме BatchModeAtomic при ошибке в любой метрике отвечает 400 без применения батча,
+// в режиме BatchModeBestEffort применяет корректные метрики и возвращает BatchResult.
 func (h *Handlers) UpdatesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -504,43 +567,22 @@ func (h *Handlers) UpdatesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(metrics) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	// Проверяем хеш каждой метрики если ключ задан
-	if h.key != "" {
-		for i, metric := range metrics {
-			if !h.verifyMetricHash(metric) {
-				log.Printf("Hash verification failed for metric %d: %s, type: %s", i, metric.ID, metric.MType)
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
+	if h.batchMode == BatchModeBestEffort && len(metrics) > 0 {
+		result, err := h.applyBatchBestEffort(metrics)
+		if err != nil {
+			log.Printf("Failed to update batch: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
+		writeJSON(w, result.Status(), result, "UpdatesHandler")
+		return
 	}
 
-	// Группируем метрики по ключу (name, type) для избежания дубликатов в одном батче
-	metricsMap := make(map[string]models.Metrics)
-	for _, metric := range metrics {
-		key := metric.ID + "_" + metric.MType
-		if existing, exists := metricsMap[key]; exists {
-			// Если метрика уже есть, объединяем значения
-			if metric.MType == "counter" && metric.Delta != nil && existing.Delta != nil {
-				combinedDelta := *existing.Delta + *metric.Delta
-				metric.Delta = &combinedDelta
-			}
+	if err := h.applyBatch(metrics); err != nil {
+		if errors.Is(err, errInvalidBatch) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
 		}
-		metricsMap[key] = metric
-	}
-
-	uniqueMetrics := make([]models.Metrics, 0, len(metricsMap))
-	for _, metric := range metricsMap {
-		uniqueMetrics = append(uniqueMetrics, metric)
-	}
-
-	// Обновляем все метрики в батче одной операцией
-	if err := h.storage.UpdateBatch(uniqueMetrics); err != nil {
 		log.Printf("Failed to update batch: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
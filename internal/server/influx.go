@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/influx"
+)
+
+// InfluxWritePath путь приёма метрик в формате InfluxDB line protocol
+const InfluxWritePath = "/write"
+
+// maxInfluxErrors ограничивает количество ошибок разбора строк в ответе
+const maxInfluxErrors = 5
+
+// WithInflux задаёт настройки преобразования точек, принимаемых по InfluxWritePath.
+// Без опции целочисленные поля записываются как gauge.
+func WithInflux(cfg influx.Config) RouterOption {
+	return func(o *routerOptions) {
+		o.influx = cfg
+	}
+}
+
+// InfluxWriteHandler обрабатывает POST /write с телом в InfluxDB line protocol.
+//
+// Параметр precision задаёт единицу времени меток, параметры db и rp принимаются
+// для совместимости с клиентами InfluxDB и не используются. Если хотя бы одна строка
+// некорректна, батч не записывается. Ответы повторяют InfluxDB 1.x: 204 при успехе
+// и {"error": "..."} при ошибке.
+func (h *Handlers) InfluxWriteHandler(w http.ResponseWriter, r *http.Request) {
+	precision, err := influx.ParsePrecision(r.URL.Query().Get("precision"))
+	if err != nil {
+		writeInfluxError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeInfluxError(w, http.StatusBadRequest, fmt.Sprintf("read body: %v", err))
+		return
+	}
+	if !h.checkBodyHash(r, body) {
+		writeInfluxError(w, http.StatusBadRequest, "request hash does not match")
+		return
+	}
+
+	points, errs := influx.Parse(body, precision)
+	if len(errs) > 0 {
+		if len(errs) > maxInfluxErrors {
+			errs = append(errs[:maxInfluxErrors], fmt.Errorf("and %d more", len(errs)-maxInfluxErrors))
+		}
+		writeInfluxError(w, http.StatusBadRequest, "unable to parse: "+errors.Join(errs...).Error())
+		return
+	}
+
+	metrics, skipped := influx.ToMetrics(points, h.influx)
+	if skipped > 0 {
+		log.Printf("Пропущено строковых полей line protocol: %d", skipped)
+	}
+	if len(metrics) > 0 {
+		if err := h.storage.UpdateBatch(mergeBatch(metrics)); err != nil {
+			log.Printf("Ошибка записи метрик line protocol: %v", err)
+			writeInfluxError(w, http.StatusInternalServerError, "failed to store metrics")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeInfluxError записывает ошибку в формате InfluxDB
+func writeInfluxError(w http.ResponseWriter, status int, message string) {
+	data, _ := json.Marshal(map[string]string{"error": message})
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Ошибка записи ответа line protocol: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/influx"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+func postInflux(router http.Handler, query string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, InfluxWritePath+query, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestInfluxWriteHandler(t *testing.T) {
+	const lines = "cpu,host=a usage=0.5,cores=4i 1700000000\nrequests,host=a count=3i\n"
+
+	t.Run("запись точек", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		router := NewRouter(s, "", "", WithInflux(influx.Config{IntegerMode: influx.IntegerCounter})).GetRouter()
+
+		for i := 0; i < 2; i++ {
+			w := postInflux(router, "?db=telegraf&precision=s", []byte(lines), nil)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("Ожидался статус 204, получен %d: %s", w.Code, w.Body.String())
+			}
+		}
+
+		if v, err := s.GetGauge(`cpu_usage{host="a"}`); err != nil || v != 0.5 {
+			t.Errorf("Ожидался gauge 0.5, получено %v (%v)", v, err)
+		}
+		if v, err := s.GetCounter(`requests_count{host="a"}`); err != nil || v != 6 {
+			t.Errorf("Ожидался counter 6, получено %d (%v)", v, err)
+		}
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		router := NewRouter(s, "", "").GetRouter()
+
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write([]byte(lines))
+		_ = gz.Close()
+
+		w := postInflux(router, "", buf.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Ожидался статус 204, получен %d: %s", w.Code, w.Body.String())
+		}
+		if v, err := s.GetGauge(`cpu_cores{host="a"}`); err != nil || v != 4 {
+			t.Errorf("Ожидался gauge 4, получено %v (%v)", v, err)
+		}
+	})
+
+	t.Run("некорректная строка отклоняет батч", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		router := NewRouter(s, "", "").GetRouter()
+
+		w := postInflux(router, "", []byte("cpu usage=1\ncpu usage=oops\n"), nil)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Ожидался статус 400, получен %d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"error":"unable to parse: line 2`) {
+			t.Errorf("Ожидалась ошибка с номером строки, получено %s", w.Body.String())
+		}
+		if len(s.GetAllGauges()) != 0 {
+			t.Errorf("Батч с ошибкой не должен записываться: %v", s.GetAllGauges())
+		}
+	})
+
+	t.Run("подпись и точность", func(t *testing.T) {
+		const key = "test-key"
+		router := NewRouter(storage.NewMemStorage(), key, "").GetRouter()
+		body := []byte(lines)
+
+		tests := []struct {
+			name   string
+			query  string
+			hash   string
+			status int
+		}{
+			{"неверная подпись", "", "bad", http.StatusBadRequest},
+			{"верная подпись", "", utils.CalculateHash(body, key), http.StatusNoContent},
+			{"неизвестная точность", "?precision=d", "", http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				headers := map[string]string{}
+				if tt.hash != "" {
+					headers["HashSHA256"] = tt.hash
+				}
+				if w := postInflux(router, tt.query, body, headers); w.Code != tt.status {
+					t.Errorf("Ожидался статус %d, получен %d: %s", tt.status, w.Code, w.Body.String())
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/graphite"
+	"github.com/ViktorBystrov72/go-metrics/internal/statsd"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// DefaultListenerFlushInterval интервал записи метрик, принятых по Graphite и StatsD
+const DefaultListenerFlushInterval = 10 * time.Second
+
+// ListenersConfig настройки приёма метрик вне HTTP
+type ListenersConfig struct {
+	// GraphiteAddress TCP адрес приёма Graphite plaintext, пустой - выключен
+	GraphiteAddress string
+	// StatsDAddress UDP адрес приёма StatsD, пустой - выключен
+	StatsDAddress string
+	// FlushInterval интервал записи накопленных значений в хранилище
+	FlushInterval time.Duration
+}
+
+// Listeners принимает метрики по Graphite plaintext (TCP) и StatsD (UDP).
+// Значения накапливаются в statsd.Aggregator и раз в FlushInterval записываются
+// в хранилище одним батчем: StatsD значения агрегируются по правилам StatsD,
+// значения Graphite записываются как gauge с последним значением за интервал.
+type Listeners struct {
+	storage storage.Storage
+	config  ListenersConfig
+
+	aggregator *statsd.Aggregator
+	graphite   *graphite.Listener
+	statsd     *statsd.Listener
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewListeners создаёт Listeners для включённых в config протоколов
+func NewListeners(storage storage.Storage, config ListenersConfig) *Listeners {
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultListenerFlushInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Listeners{
+		storage:    storage,
+		config:     config,
+		aggregator: statsd.NewAggregator(),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	if config.GraphiteAddress != "" {
+		l.graphite = graphite.NewListener(config.GraphiteAddress, l.addGraphite)
+	}
+	if config.StatsDAddress != "" {
+		l.statsd = statsd.NewListener(config.StatsDAddress, l.aggregator.Add)
+	}
+	return l
+}
+
+// Enabled сообщает, включён ли хотя бы один протокол
+func (l *Listeners) Enabled() bool {
+	return l.graphite != nil || l.statsd != nil
+}
+
+// Start открывает сокеты и запускает периодическую запись в хранилище.
+// При ошибке уже открытые сокеты закрываются.
+func (l *Listeners) Start() error {
+	if !l.Enabled() {
+		return nil
+	}
+	if l.graphite != nil {
+		if err := l.graphite.Start(); err != nil {
+			return err
+		}
+	}
+	if l.statsd != nil {
+		if err := l.statsd.Start(); err != nil {
+			if l.graphite != nil {
+				l.graphite.Close()
+			}
+			return err
+		}
+	}
+
+	l.wg.Add(1)
+	go l.flushLoop()
+	return nil
+}
+
+// Close закрывает сокеты, дожидается обработки принятых строк
+// и записывает последние накопленные значения
+func (l *Listeners) Close() {
+	if !l.Enabled() {
+		return
+	}
+
+	if l.graphite != nil {
+		if err := l.graphite.Close(); err != nil {
+			log.Printf("Ошибка закрытия Graphite listener: %v", err)
+		}
+		log.Printf("Graphite listener остановлен: принято %d, некорректных строк %d",
+			l.graphite.Received(), l.graphite.Malformed())
+	}
+	if l.statsd != nil {
+		if err := l.statsd.Close(); err != nil {
+			log.Printf("Ошибка закрытия StatsD listener: %v", err)
+		}
+		log.Printf("StatsD listener остановлен: принято %d, некорректных строк %d",
+			l.statsd.Received(), l.statsd.Malformed())
+	}
+
+	l.cancel()
+	l.wg.Wait()
+	l.flush()
+}
+
+// addGraphite передаёт значение Graphite в агрегатор как gauge
+func (l *Listeners) addGraphite(s graphite.Sample) {
+	l.aggregator.Add(statsd.Sample{
+		Name:       s.Name,
+		Labels:     s.Labels,
+		Type:       statsd.TypeGauge,
+		Value:      s.Value,
+		SampleRate: 1,
+	})
+}
+
+func (l *Listeners) flushLoop() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+			l.flush()
+		}
+	}
+}
+
+// flush записывает накопленные за интервал значения в хранилище
+func (l *Listeners) flush() {
+	metrics := l.aggregator.Flush()
+	if len(metrics) == 0 {
+		return
+	}
+	if err := l.storage.UpdateBatch(metrics); err != nil {
+		log.Printf("Ошибка записи метрик Graphite/StatsD (%d шт.): %v", len(metrics), err)
+	}
+}
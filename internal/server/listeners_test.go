@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+func TestListeners(t *testing.T) {
+	s := storage.NewMemStorage()
+	l := NewListeners(s, ListenersConfig{
+		GraphiteAddress: "127.0.0.1:0",
+		StatsDAddress:   "127.0.0.1:0",
+		FlushInterval:   time.Hour,
+	})
+	if err := l.Start(); err != nil {
+		t.Fatalf("Не удалось запустить listeners: %v", err)
+	}
+
+	tcp, err := net.Dial("tcp", l.graphite.Addr().String())
+	if err != nil {
+		t.Fatalf("Не удалось подключиться к Graphite: %v", err)
+	}
+	defer tcp.Close()
+	if _, err := tcp.Write([]byte("servers.web1.load 1.5\nservers.web1.load 2.5 1700000000\nbroken\n")); err != nil {
+		t.Fatalf("Не удалось отправить строки Graphite: %v", err)
+	}
+
+	udp, err := net.Dial("udp", l.statsd.Addr().String())
+	if err != nil {
+		t.Fatalf("Не удалось подключиться к StatsD: %v", err)
+	}
+	defer udp.Close()
+	if _, err := udp.Write([]byte("hits:2|c\nhits:3|c|#host:a\n")); err != nil {
+		t.Fatalf("Не удалось отправить пакет StatsD: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if l.graphite.Received() == 2 && l.graphite.Malformed() == 1 && l.statsd.Received() == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Интервал записи не истёк: значения записываются при закрытии
+	l.Close()
+
+	if v, err := s.GetGauge("servers.web1.load"); err != nil || v != 2.5 {
+		t.Errorf("Ожидался gauge 2.5, получено %v (%v)", v, err)
+	}
+	if v, err := s.GetCounter("hits"); err != nil || v != 2 {
+		t.Errorf("Ожидался counter hits 2, получено %d (%v)", v, err)
+	}
+	if v, err := s.GetCounter(`hits{host="a"}`); err != nil || v != 3 {
+		t.Errorf("Ожидался counter hits{host=\"a\"} 3, получено %d (%v)", v, err)
+	}
+}
+
+func TestListeners_Disabled(t *testing.T) {
+	l := NewListeners(storage.NewMemStorage(), ListenersConfig{})
+	if l.Enabled() {
+		t.Error("Без адресов listeners должны быть выключены")
+	}
+	if err := l.Start(); err != nil {
+		t.Errorf("Запуск выключенных listeners не должен возвращать ошибку: %v", err)
+	}
+	l.Close()
+}
+
+func TestListeners_StartError(t *testing.T) {
+	l := NewListeners(storage.NewMemStorage(), ListenersConfig{
+		GraphiteAddress: "127.0.0.1:0",
+		StatsDAddress:   "invalid-address",
+	})
+	if err := l.Start(); err == nil {
+		l.Close()
+		t.Fatal("Ожидалась ошибка для некорректного адреса StatsD")
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// splitQueryValues объединяет повторяющиеся параметры запроса
+// и значения, перечисленные через запятую
+func splitQueryValues(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+// parseMetricsQuery разбирает параметры запроса списка метрик
+func parseMetricsQuery(r *http.Request) (storage.MetricsQuery, error) {
+	values := r.URL.Query()
+	query := storage.MetricsQuery{
+		Types:      splitQueryValues(values["type"]),
+		NamePrefix: values.Get("prefix"),
+		NameRegex:  values.Get("regex"),
+		Sort:       values.Get("sort"),
+		Cursor:     values.Get("cursor"),
+	}
+
+	// Метки передаются как label=key=value, параметр можно повторять
+	for _, label := range values["label"] {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return query, fmt.Errorf("%w: label must be key=value, got %q", storage.ErrInvalidQuery, label)
+		}
+		if query.Labels == nil {
+			query.Labels = make(map[string]string)
+		}
+		query.Labels[key] = value
+	}
+
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, fmt.Errorf("%w: order must be asc or desc, got %q", storage.ErrInvalidQuery, order)
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return query, fmt.Errorf("%w: invalid limit %q", storage.ErrInvalidQuery, limit)
+		}
+		query.Limit = n
+	}
+
+	return query, query.Normalize()
+}
+
+// ListMetricsHandler обрабатывает GET /api/v1/metrics: возвращает страницу метрик
+// с фильтрами по типу, префиксу или регулярному выражению имени и меткам
+func (h *Handlers) ListMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if responseFormat(w, r, contentTypeJSON) == "" {
+		return
+	}
+
+	query, err := parseMetricsQuery(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "%v", err))
+		return
+	}
+
+	page, err := h.storage.ListMetrics(query)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidQuery) {
+			writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "%v", err))
+			return
+		}
+		log.Printf("Failed to list metrics: %v", err)
+		writeAPIError(w, r, http.StatusInternalServerError, newAPIError(ErrCodeStorage, "", "Ошибка получения метрик"))
+		return
+	}
+
+	for i := range page.Metrics {
+		h.addHashToMetrics(&page.Metrics[i])
+	}
+
+	writeJSON(w, http.StatusOK, page, "ListMetricsHandler")
+}
+
+// writeStorageError отвечает 404 для отсутствующей метрики и 500 для прочих ошибок хранилища
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error, action, metric string) {
+	if errors.Is(err, storage.ErrMetricNotFound) {
+		writeAPIError(w, r, http.StatusNotFound, newAPIError(ErrCodeNotFound, metric, "Метрика не найдена"))
+		return
+	}
+	log.Printf("Failed to %s: %v", action, err)
+	writeAPIError(w, r, http.StatusInternalServerError, newAPIError(ErrCodeStorage, metric, "Ошибка хранилища"))
+}
+
+// DeleteMetricHandler обрабатывает DELETE /api/v1/metrics/{type}/{name}
+func (h *Handlers) DeleteMetricHandler(w http.ResponseWriter, r *http.Request) {
+	metricType := chi.URLParam(r, "type")
+	name := chi.URLParam(r, "name")
+
+	if metricType != string(storage.Gauge) && metricType != string(storage.Counter) {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidType, name, "Неизвестный тип метрики"))
+		return
+	}
+
+	if err := h.storage.DeleteMetric(metricType, name); err != nil {
+		writeStorageError(w, r, err, "delete metric", name)
+		return
+	}
+	log.Printf("Метрика удалена: %s %s", metricType, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteMetricsHandler обрабатывает DELETE /api/v1/metrics: удаляет метрики по фильтрам
+// type, prefix, regex и label. Хотя бы один фильтр обязателен, чтобы запрос
+// без параметров не удалил все метрики.
+func (h *Handlers) DeleteMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseMetricsQuery(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "%v", err))
+		return
+	}
+	if !query.HasFilter() {
+		writeAPIError(w, r, http.StatusBadRequest,
+			newAPIError(ErrCodeInvalidQuery, "", "Требуется хотя бы один фильтр: type, prefix, regex или label"))
+		return
+	}
+
+	deleted, err := h.storage.DeleteMetrics(query)
+	if err != nil {
+		writeStorageError(w, r, err, "delete metrics", "")
+		return
+	}
+	log.Printf("Удалено метрик по фильтру %s: %d", r.URL.RawQuery, deleted)
+
+	writeJSON(w, http.StatusOK, map[string]int{"deleted": deleted}, "DeleteMetricsHandler")
+}
+
+// ResetCounterHandler обрабатывает POST /api/v1/metrics/{type}/{name}/reset.
+// Сбросить можно только counter метрику.
+func (h *Handlers) ResetCounterHandler(w http.ResponseWriter, r *http.Request) {
+	metricType := chi.URLParam(r, "type")
+	name := chi.URLParam(r, "name")
+
+	if metricType != string(storage.Counter) {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidType, name, "Сбросить можно только counter метрику"))
+		return
+	}
+
+	if err := h.storage.ResetCounter(name); err != nil {
+		writeStorageError(w, r, err, "reset counter", name)
+		return
+	}
+	log.Printf("Counter метрика сброшена: %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+func TestListMetricsHandler(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 10)
+	s.UpdateGauge("HeapInuse", 20)
+	s.UpdateGauge("Alloc", 30)
+	s.UpdateCounter(`requests{code="200"}`, 3)
+	router := NewRouter(s, "", "").GetRouter()
+
+	get := func(query string) (int, storage.MetricsPage) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var page storage.MetricsPage
+		if w.Code == http.StatusOK {
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Ожидался Content-Type application/json, получен %s", ct)
+			}
+			if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+				t.Fatalf("Ошибка разбора ответа: %v", err)
+			}
+		}
+		return w.Code, page
+	}
+
+	code, page := get("type=gauge&prefix=Heap&sort=value&order=desc&limit=1")
+	if code != http.StatusOK {
+		t.Fatalf("Ожидался статус 200, получен %d", code)
+	}
+	if len(page.Metrics) != 1 || page.Metrics[0].ID != "HeapInuse" || *page.Metrics[0].Value != 20 {
+		t.Fatalf("Ожидалась метрика HeapInuse, получено %+v", page.Metrics)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("Ожидался курсор следующей страницы")
+	}
+
+	code, page = get("type=gauge&prefix=Heap&sort=value&order=desc&limit=1&cursor=" + url.QueryEscape(page.NextCursor))
+	if code != http.StatusOK || len(page.Metrics) != 1 || page.Metrics[0].ID != "HeapAlloc" || page.NextCursor != "" {
+		t.Errorf("Ожидалась последняя страница с HeapAlloc, получено %d %+v", code, page)
+	}
+
+	code, page = get("label=code=200")
+	if code != http.StatusOK || len(page.Metrics) != 1 || *page.Metrics[0].Delta != 3 {
+		t.Errorf("Ожидалась метрика с меткой code=200, получено %d %+v", code, page)
+	}
+
+	for _, query := range []string{"type=histogram", "regex=(", "sort=created", "order=up", "limit=x", "label=code", "cursor=bad!"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался статус 400, получен %d", query, code)
+		}
+	}
+}
+
+func TestAdminMetricsHandlers(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1)
+	s.UpdateGauge("HeapAlloc", 2)
+	s.UpdateGauge("HeapInuse", 3)
+	s.UpdateCounter("PollCount", 5)
+	router := NewRouter(s, "ingest-key", "", WithAdminToken("admin-secret")).GetRouter()
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodDelete, "/api/v1/metrics/gauge/Alloc", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Без токена ожидался статус 401, получен %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/v1/metrics/gauge/Alloc", "ingest-key"); w.Code != http.StatusForbidden {
+		t.Errorf("Ключ подписи не должен давать доступ к административному API, получен %d", w.Code)
+	}
+
+	if w := do(http.MethodDelete, "/api/v1/metrics/gauge/Alloc", "admin-secret"); w.Code != http.StatusNoContent {
+		t.Fatalf("Ожидался статус 204, получен %d", w.Code)
+	}
+	if _, err := s.GetGauge("Alloc"); err == nil {
+		t.Error("Метрика должна быть удалена")
+	}
+	if w := do(http.MethodDelete, "/api/v1/metrics/gauge/Alloc", "admin-secret"); w.Code != http.StatusNotFound {
+		t.Errorf("Повторное удаление: ожидался статус 404, получен %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/v1/metrics/histogram/Alloc", "admin-secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Неизвестный тип: ожидался статус 400, получен %d", w.Code)
+	}
+
+	if w := do(http.MethodDelete, "/api/v1/metrics", "admin-secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Удаление без фильтров: ожидался статус 400, получен %d", w.Code)
+	}
+	w := do(http.MethodDelete, "/api/v1/metrics?prefix=Heap", "admin-secret")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"deleted":2}` {
+		t.Errorf("Ожидалось удаление 2 метрик, получено %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodPost, "/api/v1/metrics/counter/PollCount/reset", "admin-secret"); w.Code != http.StatusNoContent {
+		t.Fatalf("Ожидался статус 204, получен %d", w.Code)
+	}
+	if v, _ := s.GetCounter("PollCount"); v != 0 {
+		t.Errorf("Counter должен быть сброшен, получено %d", v)
+	}
+	if w := do(http.MethodPost, "/api/v1/metrics/gauge/PollCount/reset", "admin-secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Сброс gauge: ожидался статус 400, получен %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/metrics/counter/Missing/reset", "admin-secret"); w.Code != http.StatusNotFound {
+		t.Errorf("Сброс отсутствующего counter: ожидался статус 404, получен %d", w.Code)
+	}
+}
+
+func TestAdminMetricsHandlers_Disabled(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1)
+	router := NewRouter(s, "", "").GetRouter()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/metrics/gauge/Alloc", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code == http.StatusNoContent {
+		t.Error("Без токена администратора удаление должно быть недоступно")
+	}
+	if _, err := s.GetGauge("Alloc"); err != nil {
+		t.Error("Метрика не должна быть удалена")
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	_ "embed"
+	"log"
+	"net/http"
+)
+
+// OpenAPIPath путь, по которому сервер отдаёт описание API
+const OpenAPIPath = "/api/openapi.json"
+
+// openAPISpec описание всех маршрутов NewRouter в формате OpenAPI 3.
+// Соответствие документа ответам роутера проверяется контрактными тестами.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec возвращает описание API в формате OpenAPI 3
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// OpenAPIHandler отдаёт описание API
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(openAPISpec); err != nil {
+		log.Printf("Ошибка при записи ответа в OpenAPIHandler: %v", err)
+	}
+}
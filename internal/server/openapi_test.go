@@ -0,0 +1,283 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/alerting"
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/rates"
+	"github.com/ViktorBystrov72/go-metrics/internal/rollup"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/go-chi/chi/v5"
+)
+
+// contractBaseURL совпадает с servers в openapi.json, чтобы роутер kin-openapi находил операции
+const contractBaseURL = "http://localhost:8080"
+
+func init() {
+	// kin-openapi не декодирует text/html, главная страница проверяется как строка
+	openapi3filter.RegisterBodyDecoder("text/html", func(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (any, error) {
+		data, err := io.ReadAll(body)
+		return string(data), err
+	})
+}
+
+func loadOpenAPI(t *testing.T) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromData(OpenAPISpec())
+	if err != nil {
+		t.Fatalf("Ошибка загрузки OpenAPI документа: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("OpenAPI документ некорректен: %v", err)
+	}
+	return doc
+}
+
+// contractRouter роутер со всеми включёнными маршрутами
+func contractRouter(s storage.Storage) *chi.Mux {
+	limiter := middleware.NewRateLimiter(middleware.RateLimitConfig{Rate: 1000, Burst: 1000})
+	manager := NewStorageManager(s, &Config{GaugeTTL: time.Hour})
+	engine := alerting.NewEngine(s, []alerting.Rule{
+		{Name: "HighAlloc", Selector: alerting.Selector{Name: "Alloc"}, Op: alerting.OpGreater, Threshold: 1, Severity: alerting.SeverityWarning},
+	}, nil, 0)
+	engine.Evaluate()
+	tracker := rates.NewTracker(s, 0, 0)
+	if err := tracker.Collect(); err != nil {
+		panic(err)
+	}
+	rollups := rollup.NewManager(s, storage.NewMemStorage(), rollup.Config{})
+	if err := rollups.Sample(); err != nil {
+		panic(err)
+	}
+	return NewRouter(s, "", "",
+		WithRateLimiter(limiter),
+		WithStream(stream.NewBroker(16, "")),
+		WithWebSocket(NewWebSocketHub()),
+		WithAdminToken("secret"),
+		WithExpiryStats(manager),
+		WithAlerts(engine),
+		WithRates(tracker),
+		WithRollups(rollups),
+	).GetRouter()
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	router := NewRouter(storage.NewMemStorage(), "", "").GetRouter()
+
+	req := httptest.NewRequest(http.MethodGet, OpenAPIPath, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Ожидался статус 200, получен %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Ожидался Content-Type application/json, получен %q", ct)
+	}
+	if _, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes()); err != nil {
+		t.Errorf("Ответ не является OpenAPI документом: %v", err)
+	}
+}
+
+// TestOpenAPI_AllRoutesDocumented проверяет, что каждый маршрут роутера описан в документе
+func TestOpenAPI_AllRoutesDocumented(t *testing.T) {
+	doc := loadOpenAPI(t)
+	router := contractRouter(storage.NewMemStorage())
+
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		item := doc.Paths.Value(route)
+		if item == nil {
+			t.Errorf("Маршрут %s не описан в OpenAPI документе", route)
+			return nil
+		}
+		if item.GetOperation(method) == nil {
+			t.Errorf("Метод %s %s не описан в OpenAPI документе", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Ошибка обхода маршрутов: %v", err)
+	}
+}
+
+// TestOpenAPI_Contract проверяет запросы и ответы роутера по OpenAPI документу
+func TestOpenAPI_Contract(t *testing.T) {
+	doc := loadOpenAPI(t)
+	routes, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("Ошибка создания роутера OpenAPI: %v", err)
+	}
+
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1.5)
+	s.UpdateCounter("PollCount", 3)
+	s.UpdateCounter("Requests", 7)
+	router := contractRouter(s)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{name: "обновление gauge", method: http.MethodPost, path: "/update/gauge/Load/2.5", wantStatus: http.StatusOK},
+		{name: "обновление неизвестного типа", method: http.MethodPost, path: "/update/unknown/Load/1", wantStatus: http.StatusBadRequest},
+		{name: "обновление в JSON", method: http.MethodPost, path: "/update/", body: `{"id":"PollCount","type":"counter","delta":2}`, wantStatus: http.StatusOK},
+		{name: "обновление в JSON с ошибкой", method: http.MethodPost, path: "/update/", body: `{"id":"Load","type":"gauge"}`, wantStatus: http.StatusBadRequest},
+		{name: "батч", method: http.MethodPost, path: "/updates/", body: `[{"id":"Load","type":"gauge","value":3}]`, wantStatus: http.StatusOK},
+		{name: "значение метрики", method: http.MethodGet, path: "/value/gauge/Alloc", wantStatus: http.StatusOK},
+		{name: "значение отсутствующей метрики", method: http.MethodGet, path: "/value/gauge/Missing", wantStatus: http.StatusNotFound},
+		{name: "значение в JSON", method: http.MethodPost, path: "/value/", body: `{"id":"Alloc","type":"gauge"}`, wantStatus: http.StatusOK},
+		{name: "значение в JSON отсутствующей метрики", method: http.MethodPost, path: "/value/", body: `{"id":"Missing","type":"counter"}`, wantStatus: http.StatusNotFound},
+		{name: "главная страница", method: http.MethodGet, path: "/", wantStatus: http.StatusOK},
+		{name: "ping", method: http.MethodGet, path: "/ping", wantStatus: http.StatusOK},
+		{name: "v1 обновление", method: http.MethodPost, path: "/api/v1/update/counter/PollCount/1", wantStatus: http.StatusOK},
+		{name: "v1 обновление с текстовым ответом", method: http.MethodPost, path: "/api/v1/update/gauge/Load/1", headers: map[string]string{"Accept": "text/plain"}, wantStatus: http.StatusOK},
+		{name: "v1 обновление с ошибкой", method: http.MethodPost, path: "/api/v1/update/gauge/Load/abc", wantStatus: http.StatusBadRequest},
+		{name: "v1 обновление в JSON", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"Load","type":"gauge","value":4}`, wantStatus: http.StatusOK},
+		{name: "v1 обновление не в JSON", method: http.MethodPost, path: "/api/v1/update", headers: map[string]string{"Content-Type": "text/plain"}, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "v1 батч", method: http.MethodPost, path: "/api/v1/updates", body: `[{"id":"Load","type":"gauge","value":5}]`, wantStatus: http.StatusOK},
+		{name: "v1 батч с частичной ошибкой", method: http.MethodPost, path: "/api/v1/updates", body: `[{"id":"Load","type":"gauge","value":5},{"id":"Load","type":"gauge"}]`, wantStatus: http.StatusMultiStatus},
+		{name: "v1 значение", method: http.MethodGet, path: "/api/v1/value/gauge/Alloc", wantStatus: http.StatusOK},
+		{name: "v1 значение с текстовой ошибкой", method: http.MethodGet, path: "/api/v1/value/gauge/Missing", headers: map[string]string{"Accept": "text/plain"}, wantStatus: http.StatusNotFound},
+		{name: "v1 неподдерживаемый Accept", method: http.MethodGet, path: "/api/v1/value/gauge/Alloc", headers: map[string]string{"Accept": "text/html"}, wantStatus: http.StatusNotAcceptable},
+		{name: "v1 значение в JSON", method: http.MethodPost, path: "/api/v1/value", body: `{"id":"Missing","type":"gauge"}`, wantStatus: http.StatusNotFound},
+		{name: "список метрик", method: http.MethodGet, path: "/api/v1/metrics?type=counter&sort=value&order=desc&limit=1", wantStatus: http.StatusOK},
+		{name: "список метрик с неверной сортировкой", method: http.MethodGet, path: "/api/v1/metrics?sort=size", wantStatus: http.StatusBadRequest},
+		{name: "статистика TTL", method: http.MethodGet, path: "/api/v1/expiry", wantStatus: http.StatusOK},
+		{name: "активные алерты", method: http.MethodGet, path: "/api/v1/alerts?state=firing", wantStatus: http.StatusOK},
+		{name: "алерты с неверным состоянием", method: http.MethodGet, path: "/api/v1/alerts?state=resolved", wantStatus: http.StatusBadRequest},
+		{name: "скорости counter метрик", method: http.MethodGet, path: "/api/v1/rates?window=5m&prefix=Poll", wantStatus: http.StatusOK},
+		{name: "скорости с неверным окном", method: http.MethodGet, path: "/api/v1/rates?window=1s", wantStatus: http.StatusBadRequest},
+		{name: "скорость counter метрики", method: http.MethodGet, path: "/api/v1/rates/PollCount", wantStatus: http.StatusOK},
+		{name: "скорость отсутствующей метрики", method: http.MethodGet, path: "/api/v1/rates/Missing", wantStatus: http.StatusNotFound},
+		{name: "метрики Prometheus", method: http.MethodGet, path: "/metrics", wantStatus: http.StatusOK},
+		{name: "метрики Prometheus с неверным окном", method: http.MethodGet, path: "/metrics?window=1d", wantStatus: http.StatusBadRequest},
+		{name: "агрегаты серии", method: http.MethodGet, path: "/api/v1/rollups/gauge/Alloc?step=1h", wantStatus: http.StatusOK},
+		{name: "агрегаты с неверным шагом", method: http.MethodGet, path: "/api/v1/rollups/counter/PollCount?step=0s", wantStatus: http.StatusBadRequest},
+		{name: "выражение над сериями", method: http.MethodGet, path: "/api/v1/query?query=sum(*)%20/%20PollCount", wantStatus: http.StatusOK},
+		{name: "деление на ноль", method: http.MethodGet, path: "/api/v1/query?query=Alloc%20/%200", wantStatus: http.StatusOK},
+		{name: "выражение с синтаксической ошибкой", method: http.MethodGet, path: "/api/v1/query?query=sum(", wantStatus: http.StatusBadRequest},
+		{name: "поток с неверным типом", method: http.MethodGet, path: "/api/v1/stream?type=histogram", wantStatus: http.StatusBadRequest},
+		{name: "OTLP в JSON", method: http.MethodPost, path: "/v1/metrics", body: `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"up","gauge":{"dataPoints":[{"asDouble":1}]}}]}]}]}`, wantStatus: http.StatusOK},
+		{name: "OTLP с повреждённым JSON", method: http.MethodPost, path: "/v1/metrics", body: `{"resourceMetrics":1}`, wantStatus: http.StatusBadRequest},
+		{name: "line protocol", method: http.MethodPost, path: "/write?precision=s", body: "cpu,host=a usage=1 1700000000", headers: map[string]string{"Content-Type": "text/plain"}, wantStatus: http.StatusNoContent},
+		{name: "line protocol с ошибкой", method: http.MethodPost, path: "/write", body: "cpu usage=", headers: map[string]string{"Content-Type": "text/plain"}, wantStatus: http.StatusBadRequest},
+		{name: "WebSocket без handshake", method: http.MethodGet, path: "/ws/updates", headers: map[string]string{"Upgrade": "websocket"}, wantStatus: http.StatusBadRequest},
+		{name: "описание API", method: http.MethodGet, path: OpenAPIPath, wantStatus: http.StatusOK},
+		{name: "сброс без токена", method: http.MethodPost, path: "/api/v1/metrics/counter/Requests/reset", wantStatus: http.StatusUnauthorized},
+		{name: "сброс с неверным токеном", method: http.MethodPost, path: "/api/v1/metrics/counter/Requests/reset", headers: map[string]string{"Authorization": "Bearer wrong"}, wantStatus: http.StatusForbidden},
+		{name: "сброс counter", method: http.MethodPost, path: "/api/v1/metrics/counter/Requests/reset", headers: map[string]string{"Authorization": "Bearer secret"}, wantStatus: http.StatusNoContent},
+		{name: "сброс gauge", method: http.MethodPost, path: "/api/v1/metrics/gauge/Alloc/reset", headers: map[string]string{"Authorization": "Bearer secret"}, wantStatus: http.StatusBadRequest},
+		{name: "удаление по фильтру", method: http.MethodDelete, path: "/api/v1/metrics?prefix=Lo", headers: map[string]string{"Authorization": "Bearer secret"}, wantStatus: http.StatusOK},
+		{name: "удаление без фильтра", method: http.MethodDelete, path: "/api/v1/metrics", headers: map[string]string{"Authorization": "Bearer secret"}, wantStatus: http.StatusBadRequest},
+		{name: "удаление метрики", method: http.MethodDelete, path: "/api/v1/metrics/gauge/Alloc", headers: map[string]string{"Authorization": "Bearer secret"}, wantStatus: http.StatusNoContent},
+		{name: "удаление отсутствующей метрики", method: http.MethodDelete, path: "/api/v1/metrics/gauge/Alloc", headers: map[string]string{"Authorization": "Bearer secret"}, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, contractBaseURL+tt.path, body)
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			route, pathParams, err := routes.FindRoute(req)
+			if err != nil {
+				t.Fatalf("Операция не найдена в OpenAPI документе: %v", err)
+			}
+			requestInput := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+			}
+			// Запросы с ошибками намеренно нарушают схему, для них проверяется только ответ
+			if tt.wantStatus < http.StatusBadRequest {
+				if err := openapi3filter.ValidateRequest(context.Background(), requestInput); err != nil {
+					t.Fatalf("Запрос не соответствует OpenAPI документу: %v", err)
+				}
+			}
+
+			// Тело запроса могло быть прочитано валидатором
+			if tt.body != "" {
+				req.Body = io.NopCloser(strings.NewReader(tt.body))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Ожидался статус %d, получен %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			responseInput := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: requestInput,
+				Status:                 w.Code,
+				Header:                 w.Header(),
+				Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+			}
+			if err := openapi3filter.ValidateResponse(context.Background(), responseInput); err != nil {
+				t.Errorf("Ответ не соответствует OpenAPI документу: %v", err)
+			}
+		})
+	}
+}
+
+// TestOpenAPI_RateLimitResponse проверяет ответ 429 с заголовком Retry-After
+func TestOpenAPI_RateLimitResponse(t *testing.T) {
+	doc := loadOpenAPI(t)
+	routes, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("Ошибка создания роутера OpenAPI: %v", err)
+	}
+
+	limiter := middleware.NewRateLimiter(middleware.RateLimitConfig{Rate: 1, Burst: 1})
+	router := NewRouter(storage.NewMemStorage(), "", "", WithRateLimiter(limiter)).GetRouter()
+
+	var w *httptest.ResponseRecorder
+	var req *http.Request
+	for i := 0; i < 2; i++ {
+		req = httptest.NewRequest(http.MethodPost, contractBaseURL+"/update/gauge/Load/1", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Ожидался статус 429, получен %d", w.Code)
+	}
+
+	route, pathParams, err := routes.FindRoute(req)
+	if err != nil {
+		t.Fatalf("Операция не найдена в OpenAPI документе: %v", err)
+	}
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{Request: req, PathParams: pathParams, Route: route},
+		Status:                 w.Code,
+		Header:                 w.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+	}
+	if err := openapi3filter.ValidateResponse(context.Background(), responseInput); err != nil {
+		t.Errorf("Ответ 429 не соответствует OpenAPI документу: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/otlp"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLPPath путь приёма метрик по протоколу OTLP/HTTP
+const OTLPPath = "/v1/metrics"
+
+// contentTypeProtobuf тип содержимого OTLP/HTTP в бинарном protobuf
+const contentTypeProtobuf = "application/x-protobuf"
+
+// WithOTLP задаёт настройки преобразования метрик OTLP, принимаемых по OTLPPath.
+// Без опции используются настройки по умолчанию.
+func WithOTLP(cfg otlp.Config) RouterOption {
+	return func(o *routerOptions) {
+		o.otlp = cfg
+	}
+}
+
+// OTLPHandler обрабатывает POST /v1/metrics: запрос ExportMetricsServiceRequest
+// в protobuf или JSON кодировке.
+//
+// Ответ ExportMetricsServiceResponse возвращается в кодировке запроса. Точки, которые
+// не удалось преобразовать, отражаются в partial_success. Ошибка хранилища возвращается
+// как 503, чтобы клиент повторил отправку.
+func (h *Handlers) OTLPHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != contentTypeProtobuf && mediaType != contentTypeJSON {
+		writeAPIError(w, r, http.StatusUnsupportedMediaType, newAPIError(ErrCodeUnsupportedMediaType, "",
+			"request body must be %s or %s", contentTypeProtobuf, contentTypeJSON))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeOTLPStatus(w, mediaType, http.StatusBadRequest, codes.InvalidArgument, fmt.Sprintf("read body: %v", err))
+		return
+	}
+	if !h.checkBodyHash(r, body) {
+		writeOTLPStatus(w, mediaType, http.StatusBadRequest, codes.InvalidArgument, "request hash does not match")
+		return
+	}
+
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+	if mediaType == contentTypeProtobuf {
+		err = proto.Unmarshal(body, req)
+	} else {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, req)
+	}
+	if err != nil {
+		writeOTLPStatus(w, mediaType, http.StatusBadRequest, codes.InvalidArgument, fmt.Sprintf("decode request: %v", err))
+		return
+	}
+
+	// Потоки запроса заблокированы до Commit, чтобы параллельный экспорт не учёл приращения дважды
+	result := h.otlpConverter.Convert(req)
+	defer result.Release()
+	if len(result.Metrics) > 0 {
+		if err := h.storage.UpdateBatch(mergeBatch(result.Metrics)); err != nil {
+			log.Printf("Ошибка записи метрик OTLP: %v", err)
+			writeOTLPStatus(w, mediaType, http.StatusServiceUnavailable, codes.Unavailable, "failed to store metrics")
+			return
+		}
+	}
+	result.Commit()
+
+	resp := &colmetricspb.ExportMetricsServiceResponse{}
+	if result.Rejected > 0 {
+		resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: result.Rejected,
+			ErrorMessage:       result.ErrorMessage(),
+		}
+	}
+	writeOTLPMessage(w, mediaType, http.StatusOK, resp)
+}
+
+// writeOTLPStatus записывает ошибку OTLP/HTTP в виде google.rpc.Status
+func writeOTLPStatus(w http.ResponseWriter, mediaType string, httpStatus int, code codes.Code, message string) {
+	writeOTLPMessage(w, mediaType, httpStatus, &status.Status{Code: int32(code), Message: message})
+}
+
+// writeOTLPMessage записывает сообщение protobuf в кодировке mediaType
+func writeOTLPMessage(w http.ResponseWriter, mediaType string, httpStatus int, msg proto.Message) {
+	var data []byte
+	var err error
+	if mediaType == contentTypeProtobuf {
+		data, err = proto.Marshal(msg)
+	} else {
+		data, err = protojson.Marshal(msg)
+	}
+	if err != nil {
+		log.Printf("Ошибка кодирования ответа OTLP: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(httpStatus)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Ошибка записи ответа OTLP: %v", err)
+	}
+}
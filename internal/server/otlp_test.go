@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/otlp"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpStart время начала cumulative потока в тестовых запросах, позже запуска сервера
+var otlpStart = uint64(time.Now().Add(time.Hour).UnixNano())
+
+// otlpRequest возвращает запрос с cumulative суммой requests и gauge temperature
+// от ресурса service.name=api
+func otlpRequest(requests int64) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{
+				Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "api"}},
+			}}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{
+				{Name: "requests", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+					AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+					IsMonotonic:            true,
+					DataPoints: []*metricspb.NumberDataPoint{{
+						StartTimeUnixNano: otlpStart,
+						Value:             &metricspb.NumberDataPoint_AsInt{AsInt: requests},
+					}},
+				}}},
+				{Name: "temperature", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+					DataPoints: []*metricspb.NumberDataPoint{{Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 21.5}}},
+				}}},
+			}}},
+		}},
+	}
+}
+
+func postOTLP(router http.Handler, contentType string, body []byte, hash string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, OTLPPath, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if hash != "" {
+		req.Header.Set("HashSHA256", hash)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOTLPHandler(t *testing.T) {
+	const counterID = `requests{service_name="api"}`
+
+	t.Run("protobuf", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		router := NewRouter(s, "", "").GetRouter()
+
+		for _, value := range []int64{10, 25} {
+			body, _ := proto.Marshal(otlpRequest(value))
+			w := postOTLP(router, contentTypeProtobuf, body, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("Ожидался статус 200, получен %d: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != contentTypeProtobuf {
+				t.Errorf("Ответ должен быть в protobuf, получен %q", ct)
+			}
+		}
+
+		// Counter хранит сумму приращений cumulative суммы, а не её последнее значение
+		if v, err := s.GetCounter(counterID); err != nil || v != 25 {
+			t.Errorf("Ожидался counter 25, получено %d (%v)", v, err)
+		}
+		if v, err := s.GetGauge(`temperature{service_name="api"}`); err != nil || v != 21.5 {
+			t.Errorf("Ожидался gauge 21.5, получено %v (%v)", v, err)
+		}
+	})
+
+	t.Run("json с префиксом ресурса", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		router := NewRouter(s, "", "", WithOTLP(otlp.Config{ResourceMode: otlp.ResourcePrefix})).GetRouter()
+
+		body, _ := protojson.Marshal(otlpRequest(3))
+		w := postOTLP(router, "application/json; charset=utf-8", body, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Ожидался статус 200, получен %d: %s", w.Code, w.Body.String())
+		}
+		if v, err := s.GetCounter("api.requests"); err != nil || v != 3 {
+			t.Errorf("Ожидался counter api.requests 3, получено %d (%v)", v, err)
+		}
+	})
+
+	t.Run("partial success", func(t *testing.T) {
+		router := NewRouter(storage.NewMemStorage(), "", "").GetRouter()
+		req := otlpRequest(1)
+		req.ResourceMetrics[0].ScopeMetrics[0].Metrics = append(req.ResourceMetrics[0].ScopeMetrics[0].Metrics,
+			&metricspb.Metric{Name: "exp", Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+				DataPoints: []*metricspb.ExponentialHistogramDataPoint{{}},
+			}}})
+		body, _ := protojson.Marshal(req)
+
+		w := postOTLP(router, contentTypeJSON, body, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Ожидался статус 200, получен %d", w.Code)
+		}
+		var resp colmetricspb.ExportMetricsServiceResponse
+		if err := protojson.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Ошибка разбора ответа: %v", err)
+		}
+		if resp.GetPartialSuccess().GetRejectedDataPoints() != 1 {
+			t.Errorf("Ожидалась 1 отклонённая точка, получено %v", resp.GetPartialSuccess())
+		}
+	})
+
+	t.Run("ошибки запроса", func(t *testing.T) {
+		const key = "test-key"
+		router := NewRouter(storage.NewMemStorage(), key, "").GetRouter()
+		body, _ := proto.Marshal(otlpRequest(1))
+
+		tests := []struct {
+			name        string
+			contentType string
+			body        []byte
+			hash        string
+			status      int
+		}{
+			{"неподдерживаемый тип", "text/plain", body, "", http.StatusUnsupportedMediaType},
+			{"повреждённое тело", contentTypeProtobuf, []byte("not a protobuf"), "", http.StatusBadRequest},
+			{"неверная подпись", contentTypeProtobuf, body, "bad", http.StatusBadRequest},
+			{"верная подпись", contentTypeProtobuf, body, utils.CalculateHash(body, key), http.StatusOK},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := postOTLP(router, tt.contentType, tt.body, tt.hash)
+				if w.Code != tt.status {
+					t.Errorf("Ожидался статус %d, получен %d: %s", tt.status, w.Code, strings.TrimSpace(w.Body.String()))
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/rates"
+)
+
+// PrometheusPath путь выдачи метрик в текстовом формате экспозиции Prometheus
+const PrometheusPath = "/metrics"
+
+// promContentType тип содержимого текстового формата экспозиции Prometheus 0.0.4
+const promContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// promSample одно значение семейства метрик
+type promSample struct {
+	labels map[string]string
+	value  string
+}
+
+// promFamily семейство метрик с одним именем и типом
+type promFamily struct {
+	name    string
+	typ     string
+	samples []promSample
+}
+
+// sanitizeMetricName приводит имя метрики к формату Prometheus [a-zA-Z_:][a-zA-Z0-9_:]*
+func sanitizeMetricName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// promWindow форматирует окно в стиле Prometheus: 1m, 5m, 1h30m
+func promWindow(window time.Duration) string {
+	s := window.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// PrometheusHandler обрабатывает GET /metrics: все метрики в текстовом формате
+// экспозиции Prometheus. Если включён расчёт скоростей, для каждого counter
+// добавляются gauge семейства <name>:rate<window> и <name>:increase<window>
+// за окно из параметра window (по умолчанию 1m).
+func (h *Handlers) PrometheusHandler(w http.ResponseWriter, r *http.Request) {
+	var window time.Duration
+	if h.rates != nil {
+		var err error
+		if window, err = h.parseRateWindow(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	families := make(map[string]*promFamily)
+	add := func(name, typ string, labels map[string]string, value string) {
+		f, ok := families[name]
+		if !ok {
+			f = &promFamily{name: name, typ: typ}
+			families[name] = f
+		}
+		f.samples = append(f.samples, promSample{labels: labels, value: value})
+	}
+	sanitizeLabels := func(labels map[string]string) map[string]string {
+		result := make(map[string]string, len(labels))
+		for k, v := range labels {
+			result[models.SanitizeLabelName(k)] = v
+		}
+		return result
+	}
+
+	for id, value := range h.storage.GetAllGauges() {
+		name, labels := models.ParseSeriesID(id)
+		add(sanitizeMetricName(name), "gauge", sanitizeLabels(labels), strconv.FormatFloat(value, 'g', -1, 64))
+	}
+
+	counters := h.storage.GetAllCounters()
+	var results map[string]rates.Result
+	if h.rates != nil {
+		results = h.rates.Rates(window)
+	}
+	suffix := promWindow(window)
+	for id, value := range counters {
+		name, labels := models.ParseSeriesID(id)
+		name, labels = sanitizeMetricName(name), sanitizeLabels(labels)
+		// Семейство с тем же именем уже занято gauge метрикой
+		if f, ok := families[name]; ok && f.typ != "counter" {
+			name += "_total"
+		}
+		add(name, "counter", labels, strconv.FormatInt(value, 10))
+
+		if result, ok := results[id]; ok {
+			add(name+":rate"+suffix, "gauge", labels, strconv.FormatFloat(result.Rate, 'g', -1, 64))
+			add(name+":increase"+suffix, "gauge", labels, strconv.FormatInt(result.Increase, 10))
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	for _, name := range names {
+		f := families[name]
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.typ)
+		lines := make([]string, 0, len(f.samples))
+		for _, s := range f.samples {
+			lines = append(lines, models.FormatSeriesID(f.name, s.labels)+" "+s.value)
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+
+	w.Header().Set("Content-Type", promContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b.Bytes()); err != nil {
+		log.Printf("Ошибка при записи ответа в PrometheusHandler: %v", err)
+	}
+}
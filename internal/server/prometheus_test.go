@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1.5)
+	s.UpdateGauge(`cpu.usage{core="1",host.name="a\"b"}`, 0.25)
+	s.UpdateGauge("Requests", 2)
+	s.UpdateCounter("Requests", 7)
+	s.UpdateCounter("1st", 1)
+	router := NewRouter(s, "", "").GetRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, PrometheusPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Ожидался статус 200, получен %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != promContentType {
+		t.Errorf("Ожидался Content-Type %q, получен %q", promContentType, ct)
+	}
+
+	want := `# TYPE Alloc gauge
+Alloc 1.5
+# TYPE Requests gauge
+Requests 2
+# TYPE Requests_total counter
+Requests_total 7
+# TYPE _1st counter
+_1st 1
+# TYPE cpu_usage gauge
+cpu_usage{core="1",host_name="a\"b"} 0.25
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("Ожидалось:\n%s\nполучено:\n%s", want, got)
+	}
+}
+
+func TestPrometheusHandler_Rates(t *testing.T) {
+	router, _ := ratesRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, PrometheusPath+"?window=5m", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Ожидался статус 200, получен %d", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE PollCount counter\nPollCount 40\n",
+		"# TYPE PollCount:increase5m gauge\nPollCount:increase5m 30\n",
+		"# TYPE PollCount:rate5m gauge\nPollCount:rate5m 0.1\n",
+		"requests:increase5m{code=\"500\"} 4\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Ответ не содержит %q:\n%s", line, body)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, PrometheusPath+"?window=1s0ms", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Ожидался статус 200 для окна, равного периоду снятия, получен %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, PrometheusPath+"?window=2h", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Для окна больше времени хранения ожидался статус 400, получен %d", w.Code)
+	}
+}
+
+func TestPromWindow(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Minute:                 "1m",
+		90 * time.Second:            "1m30s",
+		time.Hour:                   "1h",
+		time.Hour + 30*time.Minute:  "1h30m",
+		30 * time.Second:            "30s",
+		2*time.Hour + 5*time.Second: "2h0m5s",
+	}
+	for window, want := range tests {
+		if got := promWindow(window); got != want {
+			t.Errorf("promWindow(%v): ожидалось %q, получено %q", window, want, got)
+		}
+	}
+}
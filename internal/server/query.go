@@ -0,0 +1,44 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/query"
+)
+
+// QueryPath путь вычисления выражений над сериями метрик
+const QueryPath = "/api/v1/query"
+
+// QueryHandler обрабатывает GET /api/v1/query?query=<выражение>: вычисляет выражение
+// по снимку текущих значений всех метрик, например sum(CPUutilization*) или HeapInuse / HeapSys.
+func (h *Handlers) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	if responseFormat(w, r, contentTypeJSON) == "" {
+		return
+	}
+
+	q := r.URL.Query().Get("query")
+	if q == "" {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "query parameter is required"))
+		return
+	}
+	expr, err := query.Parse(q)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "%v", err))
+		return
+	}
+
+	snapshot, err := query.TakeSnapshot(h.storage)
+	if err != nil {
+		log.Printf("Failed to list metrics: %v", err)
+		writeAPIError(w, r, http.StatusInternalServerError, newAPIError(ErrCodeStorage, "", "Ошибка получения метрик"))
+		return
+	}
+
+	result, err := query.Eval(expr, snapshot)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "%v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, result, "QueryHandler")
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/query"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+func queryRouter() http.Handler {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("CPUutilization1", 10)
+	s.UpdateGauge("CPUutilization2", 30)
+	s.UpdateGauge("HeapInuse", 50)
+	s.UpdateGauge("HeapSys", 200)
+	s.UpdateCounter(`requests{code="200"}`, 5)
+	s.UpdateCounter(`requests{code="500"}`, 1)
+	return NewRouter(s, "", "").GetRouter()
+}
+
+func TestQueryHandler(t *testing.T) {
+	router := queryRouter()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"sum(CPUutilization*)", `{"query":"sum(CPUutilization*)","type":"vector","series":[{"value":40}]}`},
+		{"HeapInuse / HeapSys", `{"query":"(HeapInuse / HeapSys)","type":"vector","series":[{"value":0.25}]}`},
+		{"topk(1, CPU*)", `{"query":"topk(1, CPU*)","type":"vector","series":[{"metric":"CPUutilization2","value":30}]}`},
+		{"sum by (code) (requests) * 2", `{"query":"(sum by (code) (requests) * 2)","type":"vector","series":[` +
+			`{"labels":{"code":"200"},"value":10},{"labels":{"code":"500"},"value":2}]}`},
+		{"2 / 0", `{"query":"(2 / 0)","type":"scalar","series":[{"value":"+Inf"}]}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, QueryPath+"?query="+url.QueryEscape(tt.query), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: ожидался статус 200, получен %d: %s", tt.query, w.Code, w.Body.String())
+		}
+		var got, want any
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Ошибка разбора ответа: %v", err)
+		}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ответ %s, ожидался %s", tt.query, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestQueryHandler_Invalid(t *testing.T) {
+	router := queryRouter()
+
+	for _, q := range []string{"", "sum(", "CPU* / HeapSys", "topk(CPU*, HeapSys)"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, QueryPath+"?query="+url.QueryEscape(q), nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%q: ожидался статус 400, получен %d", q, w.Code)
+		}
+		var apiErr APIError
+		if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil {
+			t.Fatalf("Ошибка разбора ответа: %v", err)
+		}
+		if apiErr.Code != ErrCodeInvalidQuery {
+			t.Errorf("%q: ожидался код %s, получен %s", q, ErrCodeInvalidQuery, apiErr.Code)
+		}
+	}
+}
+
+func TestQueryHandler_TooLong(t *testing.T) {
+	router := queryRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, QueryPath+"?query="+strings.Repeat("1", query.MaxLength+1), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("ожидался статус 400, получен %d", w.Code)
+	}
+}
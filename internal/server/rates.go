@@ -0,0 +1,125 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/rates"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// RatesPath путь списка скоростей роста counter метрик
+const RatesPath = "/api/v1/rates"
+
+// RatesPage ответ GET /api/v1/rates
+type RatesPage struct {
+	// Window окно расчёта, например 5m0s
+	Window string         `json:"window"`
+	Rates  []rates.Result `json:"rates"`
+	// NextCursor курсор следующей страницы, пустой на последней странице
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// RateResponse ответ GET /api/v1/rates/{name}
+type RateResponse struct {
+	Window string `json:"window"`
+	rates.Result
+}
+
+// WithRates публикует прирост и скорость роста counter метрик по GET /api/v1/rates,
+// на HTML странице и в /metrics
+func WithRates(tracker *rates.Tracker) RouterOption {
+	return func(o *routerOptions) {
+		o.rates = tracker
+	}
+}
+
+// parseRateWindow разбирает параметр window, без параметра возвращает rates.DefaultWindow
+func (h *Handlers) parseRateWindow(r *http.Request) (time.Duration, error) {
+	window := rates.DefaultWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		var err error
+		if window, err = time.ParseDuration(v); err != nil {
+			return 0, fmt.Errorf("%w: %q", rates.ErrInvalidWindow, v)
+		}
+	}
+	return window, h.rates.ValidateWindow(window)
+}
+
+// RatesHandler обрабатывает GET /api/v1/rates: возвращает страницу counter метрик
+// с приростом и скоростью роста за окно window. Фильтры и пагинация как у /api/v1/metrics.
+func (h *Handlers) RatesHandler(w http.ResponseWriter, r *http.Request) {
+	if responseFormat(w, r, contentTypeJSON) == "" {
+		return
+	}
+
+	window, err := h.parseRateWindow(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "%v", err))
+		return
+	}
+
+	query, err := parseMetricsQuery(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "%v", err))
+		return
+	}
+	if slices.Contains(query.Types, string(storage.Gauge)) {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "",
+			"rates are computed for counter metrics only"))
+		return
+	}
+	query.Types = []string{string(storage.Counter)}
+
+	page, err := h.storage.ListMetrics(query)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidQuery) {
+			writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, "", "%v", err))
+			return
+		}
+		log.Printf("Failed to list metrics: %v", err)
+		writeAPIError(w, r, http.StatusInternalServerError, newAPIError(ErrCodeStorage, "", "Ошибка получения метрик"))
+		return
+	}
+
+	resp := RatesPage{Window: window.String(), Rates: make([]rates.Result, 0, len(page.Metrics)), NextCursor: page.NextCursor}
+	for _, m := range page.Metrics {
+		result, ok := h.rates.Rate(m.ID, window)
+		if !ok && m.Delta != nil {
+			// Метрика появилась после последнего снятия значений
+			result = rates.Result{ID: m.ID, Total: *m.Delta}
+		}
+		resp.Rates = append(resp.Rates, result)
+	}
+	writeJSON(w, http.StatusOK, resp, "RatesHandler")
+}
+
+// RateHandler обрабатывает GET /api/v1/rates/{name}: прирост и скорость роста одной counter метрики
+func (h *Handlers) RateHandler(w http.ResponseWriter, r *http.Request) {
+	if responseFormat(w, r, contentTypeJSON) == "" {
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	window, err := h.parseRateWindow(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, name, "%v", err))
+		return
+	}
+
+	result, ok := h.rates.Rate(name, window)
+	if !ok {
+		total, err := h.storage.GetCounter(name)
+		if err != nil {
+			writeAPIError(w, r, http.StatusNotFound, newAPIError(ErrCodeNotFound, name, "metric not found"))
+			return
+		}
+		result = rates.Result{ID: name, Total: total}
+	}
+	writeJSON(w, http.StatusOK, RateResponse{Window: window.String(), Result: result}, "RateHandler")
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/rates"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// ratesRouter роутер с двумя снятыми значениями counter метрик: PollCount вырос на 30,
+// requests{code="500"} сбросился с 10 до 4
+func ratesRouter(t *testing.T) (http.Handler, *storage.MemStorage) {
+	t.Helper()
+	s := storage.NewMemStorage()
+	s.UpdateCounter("PollCount", 10)
+	s.UpdateCounter(`requests{code="500"}`, 10)
+	s.UpdateGauge("Alloc", 1)
+
+	tracker := rates.NewTracker(s, time.Second, time.Hour)
+	if err := tracker.Collect(); err != nil {
+		t.Fatalf("Ошибка снятия значений: %v", err)
+	}
+	s.UpdateCounter("PollCount", 30)
+	if err := s.ResetCounter(`requests{code="500"}`); err != nil {
+		t.Fatalf("Ошибка сброса счётчика: %v", err)
+	}
+	s.UpdateCounter(`requests{code="500"}`, 4)
+	if err := tracker.Collect(); err != nil {
+		t.Fatalf("Ошибка снятия значений: %v", err)
+	}
+
+	return NewRouter(s, "", "", WithRates(tracker)).GetRouter(), s
+}
+
+func TestRatesHandler(t *testing.T) {
+	router, s := ratesRouter(t)
+	// Метрика без снятых значений возвращается с нулевым приростом
+	s.UpdateCounter("NewCounter", 5)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RatesPath+"?window=5m", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Ожидался статус 200, получен %d: %s", w.Code, w.Body.String())
+	}
+
+	var page RatesPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Ошибка разбора ответа: %v", err)
+	}
+	if page.Window != "5m0s" {
+		t.Errorf("Ожидалось окно 5m0s, получено %q", page.Window)
+	}
+
+	want := map[string]rates.Result{
+		"NewCounter":           {ID: "NewCounter", Total: 5},
+		"PollCount":            {ID: "PollCount", Total: 40, Increase: 30, Rate: 0.1, Samples: 2},
+		`requests{code="500"}`: {ID: `requests{code="500"}`, Total: 4, Increase: 4, Rate: 4.0 / 300, Samples: 2, Resets: 1},
+	}
+	if len(page.Rates) != len(want) {
+		t.Fatalf("Ожидалось %d метрик, получено %v", len(want), page.Rates)
+	}
+	for _, got := range page.Rates {
+		if got != want[got.ID] {
+			t.Errorf("Ожидалось %+v, получено %+v", want[got.ID], got)
+		}
+	}
+}
+
+func TestRatesHandler_Filters(t *testing.T) {
+	router, _ := ratesRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RatesPath+"?label=code=500", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Ожидался статус 200, получен %d", w.Code)
+	}
+	var page RatesPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Ошибка разбора ответа: %v", err)
+	}
+	if len(page.Rates) != 1 || page.Rates[0].ID != `requests{code="500"}` {
+		t.Errorf("Ожидалась одна метрика requests, получено %v", page.Rates)
+	}
+	if page.Window != rates.DefaultWindow.String() {
+		t.Errorf("Ожидалось окно по умолчанию, получено %q", page.Window)
+	}
+}
+
+func TestRatesHandler_InvalidQuery(t *testing.T) {
+	router, _ := ratesRouter(t)
+
+	for _, query := range []string{"?window=abc", "?window=100ms", "?window=2h", "?type=gauge", "?regex=("} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RatesPath+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: ожидался статус 400, получен %d", query, w.Code)
+			continue
+		}
+		var apiErr APIError
+		if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil || apiErr.Code != ErrCodeInvalidQuery {
+			t.Errorf("%q: ожидалась ошибка %s, получено %s", query, ErrCodeInvalidQuery, w.Body.String())
+		}
+	}
+}
+
+func TestRateHandler(t *testing.T) {
+	router, s := ratesRouter(t)
+	s.UpdateCounter("NewCounter", 5)
+
+	tests := []struct {
+		name       string
+		wantStatus int
+		want       rates.Result
+	}{
+		{"PollCount", http.StatusOK, rates.Result{ID: "PollCount", Total: 40, Increase: 30, Rate: 0.5, Samples: 2}},
+		{"NewCounter", http.StatusOK, rates.Result{ID: "NewCounter", Total: 5}},
+		{"Unknown", http.StatusNotFound, rates.Result{}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RatesPath+"/"+tt.name, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: ожидался статус %d, получен %d", tt.name, tt.wantStatus, w.Code)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var resp RateResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Ошибка разбора ответа: %v", err)
+		}
+		if resp.Window != "1m0s" || resp.Result != tt.want {
+			t.Errorf("%s: ожидалось %+v за 1m0s, получено %+v за %s", tt.name, tt.want, resp.Result, resp.Window)
+		}
+	}
+}
+
+func TestRatesRoutes_Disabled(t *testing.T) {
+	router := NewRouter(storage.NewMemStorage(), "", "").GetRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RatesPath, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Без WithRates ожидался статус 404, получен %d", w.Code)
+	}
+}
+
+func TestIndexHandler_Rates(t *testing.T) {
+	router, _ := ratesRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"(increase за 1m: 30, rate: 0.500/s)",
+		"(increase за 1m: 4, rate: 0.067/s, сбросов: 1)",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Страница не содержит %q:\n%s", want, body)
+		}
+	}
+}
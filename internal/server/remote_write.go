@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/remotewrite"
+)
+
+// RemoteWritePath путь приёма Prometheus remote_write
+const RemoteWritePath = "/api/v1/write"
+
+// RemoteWriteHandler обрабатывает POST /api/v1/write: prometheus.WriteRequest
+// в protobuf, сжатый snappy (remote_write 1.0).
+//
+// Ряды преобразуются в counter и gauge по метаданным семейства или суффиксу имени.
+// Ошибка хранилища возвращается как 500, и Prometheus повторяет отправку, а ошибки
+// формата - как 4xx, которые Prometheus не повторяет. Тело и распакованный запрос
+// ограничены remotewrite.MaxCompressedSize и remotewrite.MaxDecodedSize.
+func (h *Handlers) RemoteWriteHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != remotewrite.ContentType || (params["proto"] != "" && params["proto"] != remotewrite.ProtoMessage) {
+		writeAPIError(w, r, http.StatusUnsupportedMediaType, newAPIError(ErrCodeUnsupportedMediaType, "",
+			"request body must be %s;proto=%s compressed with snappy", remotewrite.ContentType, remotewrite.ProtoMessage))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(remotewrite.MaxCompressedSize)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeAPIError(w, r, http.StatusRequestEntityTooLarge, newAPIError(ErrCodePayloadTooLarge, "",
+				"request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidPayload, "", "read body: %v", err))
+		return
+	}
+	if !h.checkBodyHash(r, body) {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidHash, "", "request hash does not match"))
+		return
+	}
+
+	req, err := remotewrite.Decode(body)
+	if errors.Is(err, remotewrite.ErrTooLarge) {
+		writeAPIError(w, r, http.StatusRequestEntityTooLarge, newAPIError(ErrCodePayloadTooLarge, "", "%v", err))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidPayload, "", "%v", err))
+		return
+	}
+
+	// Ряды запроса заблокированы до Commit, чтобы конкурентный повтор не учёл приращения дважды
+	result := h.remoteWrite.Convert(req)
+	defer result.Release()
+	if len(result.Metrics) > 0 {
+		if err := h.storage.UpdateBatch(mergeBatch(result.Metrics)); err != nil {
+			writeStorageError(w, r, err, "store remote_write samples", "")
+			return
+		}
+	}
+	result.Commit()
+
+	w.WriteHeader(http.StatusNoContent)
+}
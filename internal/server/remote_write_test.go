@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/remotewrite"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+func postRemoteWrite(router http.Handler, contentType string, body []byte, hash string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, RemoteWritePath, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", remotewrite.Version)
+	if hash != "" {
+		req.Header.Set("HashSHA256", hash)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// remoteWriteBody возвращает запрос с counter http_requests_total и gauge temperature
+func remoteWriteBody(requests float64, timestamp int64) []byte {
+	labels := func(name string) []remotewrite.Label {
+		return []remotewrite.Label{{Name: "__name__", Value: name}, {Name: "instance", Value: "web1"}}
+	}
+	return remotewrite.Encode(&remotewrite.WriteRequest{Timeseries: []remotewrite.TimeSeries{
+		{Labels: labels("http_requests_total"), Samples: []remotewrite.Sample{{Value: requests, Timestamp: timestamp}}},
+		{Labels: labels("temperature"), Samples: []remotewrite.Sample{{Value: 21.5, Timestamp: timestamp}}},
+	}})
+}
+
+func TestRemoteWriteHandler(t *testing.T) {
+	const contentType = "application/x-protobuf"
+
+	t.Run("counter и gauge", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		router := NewRouter(s, "", "").GetRouter()
+
+		// Первое значение counter только запоминается, затем сброс с 110 до 5
+		for i, value := range []float64{100, 110, 110, 5} {
+			w := postRemoteWrite(router, contentType, remoteWriteBody(value, int64(1000*(i+1))), "")
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("Ожидался статус 204, получен %d: %s", w.Code, w.Body.String())
+			}
+		}
+
+		if v, err := s.GetCounter(`http_requests_total{instance="web1"}`); err != nil || v != 15 {
+			t.Errorf("Ожидался counter 15, получено %d (%v)", v, err)
+		}
+		if v, err := s.GetGauge(`temperature{instance="web1"}`); err != nil || v != 21.5 {
+			t.Errorf("Ожидался gauge 21.5, получено %v (%v)", v, err)
+		}
+	})
+
+	t.Run("ошибки запроса", func(t *testing.T) {
+		const key = "test-key"
+		router := NewRouter(storage.NewMemStorage(), key, "").GetRouter()
+		body := remoteWriteBody(1, 1)
+
+		tests := []struct {
+			name        string
+			contentType string
+			body        []byte
+			hash        string
+			status      int
+		}{
+			{"remote_write 2.0", "application/x-protobuf;proto=io.prometheus.write.v2.Request", body, "", http.StatusUnsupportedMediaType},
+			{"не protobuf", "application/json", body, "", http.StatusUnsupportedMediaType},
+			{"без snappy", contentType, []byte("not snappy"), "", http.StatusBadRequest},
+			// Несколько байт, заголовок snappy которых заявляет 4 ГиБ после распаковки
+			{"огромный размер после распаковки", contentType, append(binary.AppendUvarint(nil, 1<<32-1), 0x00, 'x'), "", http.StatusRequestEntityTooLarge},
+			{"тело больше предела", contentType, make([]byte, remotewrite.MaxCompressedSize+1), "", http.StatusRequestEntityTooLarge},
+			{"неверная подпись", contentType, body, "bad", http.StatusBadRequest},
+			{"верная подпись", contentType + ";proto=prometheus.WriteRequest", body, utils.CalculateHash(body, key), http.StatusNoContent},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := postRemoteWrite(router, tt.contentType, tt.body, tt.hash)
+				if w.Code != tt.status {
+					t.Errorf("Ожидался статус %d, получен %d: %s", tt.status, w.Code, w.Body.String())
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/rollup"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// RollupsPath префикс пути агрегатов серии /api/v1/rollups/{type}/{name}
+const RollupsPath = "/api/v1/rollups"
+
+// defaultRollupRange период запроса агрегатов без параметра from
+const defaultRollupRange = time.Hour
+
+// WithRollups публикует агрегаты метрик по tier 1m, 1h и 1d по GET /api/v1/rollups/{type}/{name}
+func WithRollups(manager *rollup.Manager) RouterOption {
+	return func(o *routerOptions) {
+		o.rollups = manager
+	}
+}
+
+// parseQueryTime разбирает время в формате RFC 3339 или Unix секундах
+func parseQueryTime(v string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// RollupsHandler обрабатывает GET /api/v1/rollups/{type}/{name}: агрегаты серии за [from, to)
+// с шагом step. По умолчанию возвращается последний час с шагом в минуту.
+func (h *Handlers) RollupsHandler(w http.ResponseWriter, r *http.Request) {
+	if responseFormat(w, r, contentTypeJSON) == "" {
+		return
+	}
+
+	metricType := chi.URLParam(r, "type")
+	name := chi.URLParam(r, "name")
+	if metricType != string(storage.Gauge) && metricType != string(storage.Counter) {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidType, name, "unknown metric type %q", metricType))
+		return
+	}
+	values := r.URL.Query()
+	invalid := func(format string, args ...any) {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidQuery, name, format, args...))
+	}
+
+	to := time.Now().UTC()
+	if v := values.Get("to"); v != "" {
+		t, err := parseQueryTime(v)
+		if err != nil {
+			invalid("invalid to %q: expected RFC 3339 or Unix seconds", v)
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultRollupRange)
+	if v := values.Get("from"); v != "" {
+		t, err := parseQueryTime(v)
+		if err != nil {
+			invalid("invalid from %q: expected RFC 3339 or Unix seconds", v)
+			return
+		}
+		from = t
+	}
+	step := time.Minute
+	if v := values.Get("step"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			invalid("invalid step %q", v)
+			return
+		}
+		step = d
+	}
+
+	result, err := h.rollups.Query(metricType, name, from, to, step)
+	if err != nil {
+		if errors.Is(err, rollup.ErrInvalidQuery) {
+			invalid("%v", err)
+			return
+		}
+		log.Printf("Failed to query rollups: %v", err)
+		writeAPIError(w, r, http.StatusInternalServerError, newAPIError(ErrCodeStorage, name, "Ошибка получения агрегатов"))
+		return
+	}
+	writeJSON(w, http.StatusOK, result, "RollupsHandler")
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/rollup"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+func rollupsRouter(t *testing.T) http.Handler {
+	t.Helper()
+	s := storage.NewMemStorage()
+	manager := rollup.NewManager(s, s, rollup.Config{})
+	s.UpdateGauge("Load", 1)
+	s.UpdateCounter("PollCount", 5)
+	if err := manager.Sample(); err != nil {
+		t.Fatalf("Sample() error: %v", err)
+	}
+	s.UpdateGauge("Load", 3)
+	s.UpdateCounter("PollCount", 2)
+	if err := manager.Sample(); err != nil {
+		t.Fatalf("Sample() error: %v", err)
+	}
+	return NewRouter(s, "", "", WithRollups(manager)).GetRouter()
+}
+
+func TestRollupsHandler(t *testing.T) {
+	router := rollupsRouter(t)
+
+	tests := []struct {
+		path     string
+		wantTier string
+		check    func(p rollup.Point) bool
+	}{
+		{"/gauge/Load", rollup.Tier1m, func(p rollup.Point) bool {
+			return p.Count >= 1 && p.Avg != nil && p.Min != nil && p.Max != nil && p.Last == 3
+		}},
+		{"/counter/PollCount?step=1h", rollup.Tier1h, func(p rollup.Point) bool {
+			return p.Sum != nil && *p.Sum == 2 && p.Last == 7 && p.Avg == nil
+		}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RollupsPath+tt.path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: ожидался статус 200, получен %d: %s", tt.path, w.Code, w.Body.String())
+		}
+		var result rollup.Result
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Ошибка разбора ответа: %v", err)
+		}
+		if result.Tier != tt.wantTier || len(result.Points) == 0 {
+			t.Fatalf("%s: ожидались точки из %s, получено %+v", tt.path, tt.wantTier, result)
+		}
+		if last := result.Points[len(result.Points)-1]; !tt.check(last) {
+			t.Errorf("%s: неверная последняя точка %+v", tt.path, last)
+		}
+	}
+}
+
+func TestRollupsHandler_Invalid(t *testing.T) {
+	router := rollupsRouter(t)
+
+	tests := []struct {
+		path     string
+		wantCode string
+	}{
+		{"/histogram/Load", ErrCodeInvalidType},
+		{"/gauge/Load?step=abc", ErrCodeInvalidQuery},
+		{"/gauge/Load?step=-1m", ErrCodeInvalidQuery},
+		{"/gauge/Load?from=yesterday", ErrCodeInvalidQuery},
+		{"/gauge/Load?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z", ErrCodeInvalidQuery},
+		{"/gauge/Load?from=0&to=1700000000&step=1s", ErrCodeInvalidQuery},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RollupsPath+tt.path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался статус 400, получен %d", tt.path, w.Code)
+			continue
+		}
+		var apiErr APIError
+		if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil || apiErr.Code != tt.wantCode {
+			t.Errorf("%s: ожидалась ошибка %s, получено %s", tt.path, tt.wantCode, w.Body.String())
+		}
+	}
+}
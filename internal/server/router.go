@@ -5,10 +5,17 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/ViktorBystrov72/go-metrics/internal/alerting"
 	"github.com/ViktorBystrov72/go-metrics/internal/crypto"
+	"github.com/ViktorBystrov72/go-metrics/internal/influx"
 	"github.com/ViktorBystrov72/go-metrics/internal/logger"
 	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/otlp"
+	"github.com/ViktorBystrov72/go-metrics/internal/rates"
+	"github.com/ViktorBystrov72/go-metrics/internal/rollup"
 	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+	"github.com/ViktorBystrov72/go-metrics/internal/wsproto"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
@@ -19,9 +26,84 @@ type Router struct {
 	router   *chi.Mux
 }
 
+// RouterOption задаёт дополнительные настройки роутера
+type RouterOption func(*routerOptions)
+
+// routerOptions дополнительные настройки роутера
+type routerOptions struct {
+	rateLimiter *middleware.RateLimiter
+	broker      *stream.Broker
+	wsHub       *WebSocketHub
+	adminToken  string
+	expiry      *StorageManager
+	batchMode   string
+	otlp        otlp.Config
+	influx      influx.Config
+	alerts      *alerting.Engine
+	rates       *rates.Tracker
+	rollups     *rollup.Manager
+}
+
+// WithRateLimiter ограничивает частоту запросов к маршрутам приёма метрик
+func WithRateLimiter(limiter *middleware.RateLimiter) RouterOption {
+	return func(o *routerOptions) {
+		o.rateLimiter = limiter
+	}
+}
+
+// WithStream включает SSE поток обновлений метрик /api/v1/stream.
+// Обновления публикуются в broker через stream.PublishingStorage.
+func WithStream(broker *stream.Broker) RouterOption {
+	return func(o *routerOptions) {
+		o.broker = broker
+	}
+}
+
+// WithWebSocket включает приём батчей метрик через WebSocket по пути wsproto.Path.
+// Соединения регистрируются в hub для закрытия при остановке сервера.
+func WithWebSocket(hub *WebSocketHub) RouterOption {
+	return func(o *routerOptions) {
+		o.wsHub = hub
+	}
+}
+
+// WithAdminToken включает административные маршруты удаления и сброса метрик,
+// доступные только с заголовком "Authorization: Bearer <token>"
+func WithAdminToken(token string) RouterOption {
+	return func(o *routerOptions) {
+		o.adminToken = token
+	}
+}
+
+// WithExpiryStats публикует статистику удаления устаревших метрик по GET /api/v1/expiry
+func WithExpiryStats(sm *StorageManager) RouterOption {
+	return func(o *routerOptions) {
+		o.expiry = sm
+	}
+}
+
+// WithBatchMode задаёт режим применения батча /updates/: BatchModeAtomic (по умолчанию)
+// или BatchModeBestEffort
+func WithBatchMode(mode string) RouterOption {
+	return func(o *routerOptions) {
+		o.batchMode = mode
+	}
+}
+
 // NewRouter создает новый роутер
-func NewRouter(storage storage.Storage, key string, cryptoKeyPath string) *Router {
+func NewRouter(storage storage.Storage, key string, cryptoKeyPath string, opts ...RouterOption) *Router {
+	options := &routerOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	handlers := NewHandlers(storage, key)
+	handlers.batchMode = options.batchMode
+	handlers.otlpConverter = otlp.NewConverter(options.otlp)
+	handlers.influx = options.influx
+	handlers.alerts = options.alerts
+	handlers.rates = options.rates
+	handlers.rollups = options.rollups
 	router := chi.NewRouter()
 
 	var privateKey *rsa.PrivateKey
@@ -38,30 +120,112 @@ func NewRouter(storage storage.Storage, key string, cryptoKeyPath string) *Route
 		}
 	}
 
-	// Middleware
-	router.Use(middleware.DecryptMiddleware(privateKey))
-	router.Use(middleware.GzipMiddleware)
+	// Middleware расшифровки и распаковки тела запроса
+	decode := []func(http.Handler) http.Handler{
+		middleware.DecryptMiddleware(privateKey),
+		middleware.GzipMiddleware,
+	}
+	api := router.With(decode...)
+
+	// Маршруты приёма метрик проходят через rate limiting, если он настроен. Ограничение
+	// стоит первым в цепочке, чтобы отклонённые запросы не расшифровывались и не распаковывались.
+	// В /api/v1 ошибки ограничения возвращаются в формате APIError
+	ingest := api
+	ingestV1 := api
+	if options.rateLimiter != nil {
+		ingest = router.With(append([]func(http.Handler) http.Handler{options.rateLimiter.Middleware}, decode...)...)
+		ingestV1 = router.With(append([]func(http.Handler) http.Handler{
+			options.rateLimiter.MiddlewareWithErrors(writeMiddlewareError)}, decode...)...)
+	}
 
 	// Маршруты для обновления метрик
-	router.Route("/update", func(r chi.Router) {
-		r.Post("/{type}/{name}/{value}", handlers.UpdateHandler)
-	})
+	ingest.Post("/update/{type}/{name}/{value}", handlers.UpdateHandler)
 
 	// Маршруты для получения значений метрик
-	router.Route("/value", func(r chi.Router) {
+	api.Route("/value", func(r chi.Router) {
 		r.Get("/{type}/{name}", handlers.ValueHandler)
 	})
 
 	// Главная страница со списком всех метрик
-	router.Get("/", handlers.IndexHandler)
+	api.Get("/", handlers.IndexHandler)
+
+	// Метрики в текстовом формате экспозиции Prometheus
+	api.Get(PrometheusPath, handlers.PrometheusHandler)
 
 	// Проверка соединения с базой данных
-	router.Get("/ping", handlers.PingHandler)
+	api.Get("/ping", handlers.PingHandler)
 
 	// JSON API
-	router.Post("/update/", handlers.UpdateJSONHandler)
-	router.Post("/value/", handlers.ValueJSONHandler)
-	router.Post("/updates/", handlers.UpdatesHandler)
+	ingest.Post("/update/", handlers.UpdateJSONHandler)
+	api.Post("/value/", handlers.ValueJSONHandler)
+	ingest.Post("/updates/", handlers.UpdatesHandler)
+
+	// Приём метрик OpenTelemetry по OTLP/HTTP
+	ingest.Post(OTLPPath, handlers.OTLPHandler)
+
+	// Приём метрик в InfluxDB line protocol (Telegraf, встраиваемые устройства)
+	ingest.Post(InfluxWritePath, handlers.InfluxWriteHandler)
+
+	// Долгоживущие соединения агентов. Rate limiting применяется к каждому батчу,
+	// а не к соединению, поэтому маршрут не входит в группу ingest
+	if options.wsHub != nil {
+		api.Method(http.MethodGet, wsproto.Path,
+			NewWebSocketHandler(handlers, privateKey, options.wsHub, options.rateLimiter))
+	}
+
+	// Описание API
+	api.Get(OpenAPIPath, OpenAPIHandler)
+
+	// Версионированное API: ошибки в формате APIError, выбор формата ответа по Accept,
+	// результат по каждой метрике батча
+	ingestV1.Post("/api/v1/update/{type}/{name}/{value}", handlers.UpdateV1Handler)
+	ingestV1.Post("/api/v1/update", handlers.UpdateJSONV1Handler)
+	ingestV1.Post("/api/v1/updates", handlers.UpdatesV1Handler)
+	ingestV1.Post(RemoteWritePath, handlers.RemoteWriteHandler)
+	api.Get("/api/v1/value/{type}/{name}", handlers.ValueV1Handler)
+	api.Post("/api/v1/value", handlers.ValueJSONV1Handler)
+
+	// Список метрик с фильтрами и пагинацией
+	api.Get("/api/v1/metrics", handlers.ListMetricsHandler)
+
+	// Агрегации и арифметика над сериями метрик
+	api.Get(QueryPath, handlers.QueryHandler)
+
+	// Статистика удаления устаревших метрик
+	if options.expiry != nil {
+		api.Get("/api/v1/expiry", NewExpiryStatsHandler(options.expiry))
+	}
+
+	// Активные алерты движка правил
+	if options.alerts != nil {
+		api.Get(AlertsPath, handlers.AlertsHandler)
+	}
+
+	// Прирост и скорость роста counter метрик
+	if options.rates != nil {
+		api.Get(RatesPath, handlers.RatesHandler)
+		api.Get(RatesPath+"/{name}", handlers.RateHandler)
+	}
+
+	// Агрегаты метрик по tier
+	if options.rollups != nil {
+		api.Get(RollupsPath+"/{type}/{name}", handlers.RollupsHandler)
+	}
+
+	// Удаление и сброс метрик требуют токена администратора
+	if options.adminToken != "" {
+		api.Group(func(r chi.Router) {
+			r.Use(middleware.AdminAuthMiddlewareWithErrors(options.adminToken, writeMiddlewareError))
+			r.Delete("/api/v1/metrics", handlers.DeleteMetricsHandler)
+			r.Delete("/api/v1/metrics/{type}/{name}", handlers.DeleteMetricHandler)
+			r.Post("/api/v1/metrics/{type}/{name}/reset", handlers.ResetCounterHandler)
+		})
+	}
+
+	// Поток обновлений метрик
+	if options.broker != nil {
+		api.Method(http.MethodGet, "/api/v1/stream", NewStreamHandler(options.broker))
+	}
 
 	return &Router{
 		handlers: handlers,
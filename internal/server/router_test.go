@@ -1,8 +1,14 @@
 package server
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
 	"github.com/ViktorBystrov72/go-metrics/internal/storage"
 )
 
@@ -30,3 +36,70 @@ func TestRouter_GetRouter(t *testing.T) {
 		t.Error("GetRouter() вернул nil")
 	}
 }
+
+func TestRouter_WithRateLimiter(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.RateLimitConfig{Rate: 1, Burst: 1})
+	router := NewRouter(storage.NewMemStorage(), "", "", WithRateLimiter(limiter)).GetRouter()
+
+	send := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(http.MethodPost, "/update/gauge/test/1"); code != http.StatusOK {
+		t.Fatalf("Первый запрос должен пройти, получен %d", code)
+	}
+	if code := send(http.MethodPost, "/update/gauge/test/2"); code != http.StatusTooManyRequests {
+		t.Errorf("Ожидался статус 429, получен %d", code)
+	}
+	if code := send(http.MethodGet, "/value/gauge/test"); code != http.StatusOK {
+		t.Errorf("Чтение метрик не должно ограничиваться, получен %d", code)
+	}
+}
+
+func TestRouter_RateLimiterBeforeDecoding(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.RateLimitConfig{Rate: 1, Burst: 1})
+	router := NewRouter(storage.NewMemStorage(), "", "", WithRateLimiter(limiter)).GetRouter()
+
+	send := func(path string) int {
+		// Тело не является gzip: без ограничения запрос получил бы 400 от распаковки
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("not gzip"))
+		req.Header.Set("Content-Encoding", "gzip")
+		req.RemoteAddr = "10.0.0.1:1000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send("/updates/"); code != http.StatusBadRequest {
+		t.Fatalf("Первый запрос должен дойти до распаковки, получен %d", code)
+	}
+	for _, path := range []string{"/updates/", "/api/v1/updates", RemoteWritePath} {
+		if code := send(path); code != http.StatusTooManyRequests {
+			t.Errorf("%s: ограничение должно применяться до распаковки, получен %d", path, code)
+		}
+	}
+}
+
+func TestRouter_WithExpiryStats(t *testing.T) {
+	manager := NewStorageManager(storage.NewMemStorage(), &Config{GaugeTTL: time.Hour})
+	router := NewRouter(storage.NewMemStorage(), "", "", WithExpiryStats(manager)).GetRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/expiry", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Ожидался статус 200, получен %d", w.Code)
+	}
+	var stats ExpiryStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Ошибка разбора ответа: %v", err)
+	}
+	if stats.GaugeTTL != "1h0m0s" || stats.Expired["gauge"] != 0 {
+		t.Errorf("Неверная статистика: %+v", stats)
+	}
+}
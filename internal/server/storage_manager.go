@@ -2,7 +2,9 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"log"
+	"net/http"
 	"sync"
 	"time"
 
@@ -20,6 +22,11 @@ type StorageManager struct {
 	wg      sync.WaitGroup
 	mu      sync.RWMutex
 	stopped bool
+
+	// Статистика удаления устаревших метрик
+	expiryMu      sync.Mutex
+	expired       map[string]int64
+	lastExpiryRun time.Time
 }
 
 // Config содержит конфигурацию для StorageManager
@@ -27,6 +34,24 @@ type Config struct {
 	StoreInterval   int
 	FileStoragePath string
 	Restore         bool
+
+	// TTL метрик по типам: метрика удаляется, если не обновлялась дольше TTL. 0 - без ограничения.
+	GaugeTTL   time.Duration
+	CounterTTL time.Duration
+}
+
+const (
+	// minExpiryInterval и maxExpiryInterval ограничивают период проверки устаревших метрик
+	minExpiryInterval = time.Second
+	maxExpiryInterval = time.Minute
+)
+
+// ExpiryStats статистика удаления устаревших метрик
+type ExpiryStats struct {
+	GaugeTTL   string           `json:"gauge_ttl"`
+	CounterTTL string           `json:"counter_ttl"`
+	Expired    map[string]int64 `json:"expired"`
+	LastRun    *time.Time       `json:"last_run,omitempty"`
 }
 
 // NewStorageManager создает новый StorageManager
@@ -50,6 +75,12 @@ func (sm *StorageManager) Start() {
 		return
 	}
 
+	// Удаление устаревших метрик работает для любого хранилища
+	if sm.config.GaugeTTL > 0 || sm.config.CounterTTL > 0 {
+		sm.wg.Add(1)
+		go sm.expireLoop()
+	}
+
 	if sm.storage.IsDatabase() {
 		log.Printf("Database storage detected, skipping file operations")
 		return
@@ -91,6 +122,104 @@ func (sm *StorageManager) periodicSave() {
 	}
 }
 
+// expiryInterval возвращает период проверки: десятая часть минимального TTL,
+// чтобы метрика удалялась не позже чем через 1.1 TTL, в пределах от секунды до минуты
+func (sm *StorageManager) expiryInterval() time.Duration {
+	ttl := sm.config.GaugeTTL
+	if ttl == 0 || (sm.config.CounterTTL > 0 && sm.config.CounterTTL < ttl) {
+		ttl = sm.config.CounterTTL
+	}
+	return min(max(ttl/10, minExpiryInterval), maxExpiryInterval)
+}
+
+// expireLoop периодически удаляет устаревшие метрики
+func (sm *StorageManager) expireLoop() {
+	defer sm.wg.Done()
+
+	interval := sm.expiryInterval()
+	log.Printf("Удаление устаревших метрик: gauge TTL %v, counter TTL %v, проверка каждые %v",
+		sm.config.GaugeTTL, sm.config.CounterTTL, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sm.ctx.Done():
+			return
+		case <-ticker.C:
+			sm.ExpireNow()
+		}
+	}
+}
+
+// ExpireNow удаляет метрики, не обновлявшиеся дольше TTL своего типа
+func (sm *StorageManager) ExpireNow() {
+	ttls := []struct {
+		mType string
+		ttl   time.Duration
+	}{
+		{string(storage.Gauge), sm.config.GaugeTTL},
+		{string(storage.Counter), sm.config.CounterTTL},
+	}
+
+	expired := make(map[string]int)
+	for _, t := range ttls {
+		if t.ttl <= 0 {
+			continue
+		}
+		n, err := sm.storage.ExpireMetrics(t.mType, t.ttl)
+		if err != nil {
+			log.Printf("Ошибка удаления устаревших %s метрик: %v", t.mType, err)
+			continue
+		}
+		expired[t.mType] = n
+		if n > 0 {
+			log.Printf("Удалено устаревших %s метрик: %d", t.mType, n)
+		}
+	}
+
+	sm.expiryMu.Lock()
+	defer sm.expiryMu.Unlock()
+	if sm.expired == nil {
+		sm.expired = make(map[string]int64)
+	}
+	for mType, n := range expired {
+		sm.expired[mType] += int64(n)
+	}
+	sm.lastExpiryRun = time.Now()
+}
+
+// ExpiryStats возвращает настройки TTL и количество удалённых устаревших метрик по типам
+func (sm *StorageManager) ExpiryStats() ExpiryStats {
+	sm.expiryMu.Lock()
+	defer sm.expiryMu.Unlock()
+
+	stats := ExpiryStats{
+		GaugeTTL:   sm.config.GaugeTTL.String(),
+		CounterTTL: sm.config.CounterTTL.String(),
+		Expired:    map[string]int64{string(storage.Gauge): 0, string(storage.Counter): 0},
+	}
+	for mType, n := range sm.expired {
+		stats.Expired[mType] = n
+	}
+	if !sm.lastExpiryRun.IsZero() {
+		lastRun := sm.lastExpiryRun
+		stats.LastRun = &lastRun
+	}
+	return stats
+}
+
+// NewExpiryStatsHandler возвращает обработчик, отдающий ExpiryStats в JSON
+func NewExpiryStatsHandler(sm *StorageManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(sm.ExpiryStats()); err != nil {
+			log.Printf("Ошибка при записи статистики TTL: %v", err)
+		}
+	}
+}
+
 // Stop gracefully останавливает StorageManager
 func (sm *StorageManager) Stop() {
 	sm.mu.Lock()
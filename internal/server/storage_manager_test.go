@@ -81,3 +81,74 @@ func TestStorageManagerStartWithRestoreDisabled(t *testing.T) {
 
 	time.Sleep(10 * time.Millisecond)
 }
+
+// TestStorageManagerExpireNow тестирует удаление устаревших метрик и статистику.
+func TestStorageManagerExpireNow(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("stale", 1)
+	s.UpdateCounter("stale", 1)
+	time.Sleep(20 * time.Millisecond)
+	s.UpdateGauge("fresh", 2)
+
+	manager := NewStorageManager(s, &Config{GaugeTTL: 10 * time.Millisecond})
+	if stats := manager.ExpiryStats(); stats.LastRun != nil || stats.Expired["gauge"] != 0 {
+		t.Errorf("До первой проверки статистика должна быть пустой: %+v", stats)
+	}
+
+	manager.ExpireNow()
+
+	if _, err := s.GetGauge("stale"); err == nil {
+		t.Error("Устаревший gauge должен быть удалён")
+	}
+	if _, err := s.GetGauge("fresh"); err != nil {
+		t.Error("Обновлённый gauge не должен удаляться")
+	}
+	if _, err := s.GetCounter("stale"); err != nil {
+		t.Error("Без COUNTER_TTL counter не должен удаляться")
+	}
+
+	stats := manager.ExpiryStats()
+	if stats.Expired["gauge"] != 1 || stats.Expired["counter"] != 0 || stats.LastRun == nil {
+		t.Errorf("Неверная статистика: %+v", stats)
+	}
+	if stats.GaugeTTL != "10ms" || stats.CounterTTL != "0s" {
+		t.Errorf("Неверные TTL в статистике: %+v", stats)
+	}
+}
+
+// TestStorageManagerExpiryInterval тестирует выбор периода проверки TTL.
+func TestStorageManagerExpiryInterval(t *testing.T) {
+	tests := []struct {
+		gauge, counter time.Duration
+		want           time.Duration
+	}{
+		{gauge: time.Hour, want: time.Minute},
+		{gauge: 5 * time.Minute, counter: 100 * time.Second, want: 10 * time.Second},
+		{counter: 2 * time.Second, want: time.Second},
+	}
+	for _, tt := range tests {
+		manager := NewStorageManager(storage.NewMemStorage(), &Config{GaugeTTL: tt.gauge, CounterTTL: tt.counter})
+		if got := manager.expiryInterval(); got != tt.want {
+			t.Errorf("gauge=%v counter=%v: ожидалось %v, получено %v", tt.gauge, tt.counter, tt.want, got)
+		}
+	}
+}
+
+// TestStorageManagerExpireLoop тестирует фоновое удаление устаревших метрик.
+func TestStorageManagerExpireLoop(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateCounter("stale", 1)
+
+	manager := NewStorageManager(s, &Config{CounterTTL: time.Millisecond})
+	manager.Start()
+	defer manager.Stop()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := s.GetCounter("stale"); err != nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("Устаревший counter должен быть удалён фоновой проверкой")
+}
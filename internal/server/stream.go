@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+)
+
+// streamHeartbeatInterval интервал комментариев-heartbeat, не дающих прокси закрыть соединение
+const streamHeartbeatInterval = 15 * time.Second
+
+// StreamHandler отдаёт обновления метрик в формате Server-Sent Events
+type StreamHandler struct {
+	broker    *stream.Broker
+	heartbeat time.Duration
+}
+
+// NewStreamHandler создаёт обработчик SSE
+func NewStreamHandler(broker *stream.Broker) *StreamHandler {
+	return &StreamHandler{broker: broker, heartbeat: streamHeartbeatInterval}
+}
+
+// parseStreamFilter разбирает параметры name и type. Параметры можно повторять
+// или перечислять значения через запятую.
+func parseStreamFilter(r *http.Request) (stream.Filter, error) {
+	query := r.URL.Query()
+	filter := stream.Filter{
+		Names: splitQueryValues(query["name"]),
+		Types: splitQueryValues(query["type"]),
+	}
+	for _, t := range filter.Types {
+		if t != string(storage.Gauge) && t != string(storage.Counter) {
+			return filter, fmt.Errorf("unknown metric type: %s", t)
+		}
+	}
+	return filter, nil
+}
+
+// ServeHTTP подписывает клиента на обновления и отправляет их до отключения клиента
+// или закрытия клиентских подписок Broker. Каждое обновление отправляется событием "metric" с метрикой в JSON,
+// отброшенные из-за переполнения буфера обновления - событием "dropped" с их количеством.
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseStreamFilter(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, newAPIError(ErrCodeInvalidType, "", "%v", err))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	sub := h.broker.SubscribeClient(filter)
+	defer h.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Сразу отправляем интервал переподключения, чтобы клиент получил заголовки
+	if _, err := fmt.Fprint(w, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		log.Printf("SSE: ответ не поддерживает потоковую передачу: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case m, ok := <-sub.Updates():
+			if !ok {
+				// Подписка закрыта при остановке сервера или из-за медленного клиента
+				return
+			}
+			if err := writeStreamEvents(w, sub, m); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeStreamEvents записывает событие об отброшенных обновлениях, если они были,
+// и событие с обновлением метрики
+func writeStreamEvents(w http.ResponseWriter, sub *stream.Subscription, m models.Metrics) error {
+	if dropped := sub.TakeDropped(); dropped > 0 {
+		if _, err := fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: metric\ndata: %s\n\n", data)
+	return err
+}
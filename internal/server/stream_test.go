@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+)
+
+// readEvent читает следующее SSE событие и возвращает его тип и данные
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Ошибка чтения потока: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && (event != "" || data != ""):
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	broker := stream.NewBroker(10, stream.DropOldest)
+	s := stream.NewPublishingStorage(storage.NewMemStorage(), broker)
+	server := httptest.NewServer(NewRouter(s, "", "", WithStream(broker)).GetRouter())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/stream?type=gauge&name=Heap*")
+	if err != nil {
+		t.Fatalf("Ошибка подключения к потоку: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Ожидался Content-Type text/event-stream, получен %s", ct)
+	}
+
+	// Дожидаемся регистрации подписки
+	deadline := time.Now().Add(time.Second)
+	for broker.Subscribers() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for _, path := range []string{"/update/counter/HeapCount/1", "/update/gauge/Alloc/1", "/update/gauge/HeapAlloc/2.5"} {
+		r, err := http.Post(server.URL+path, "text/plain", nil)
+		if err != nil {
+			t.Fatalf("Ошибка обновления метрики: %v", err)
+		}
+		r.Body.Close()
+	}
+
+	event, data := readEvent(t, bufio.NewReader(resp.Body))
+	if event != "metric" {
+		t.Fatalf("Ожидалось событие metric, получено %q", event)
+	}
+	var m models.Metrics
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		t.Fatalf("Некорректные данные события: %v", err)
+	}
+	if m.ID != "HeapAlloc" || m.Value == nil || *m.Value != 2.5 {
+		t.Errorf("Ожидалось обновление HeapAlloc=2.5, получено %+v", m)
+	}
+
+	// Остановка Broker завершает поток
+	broker.Close()
+	done := make(chan struct{})
+	go func() {
+		_, _ = bufio.NewReader(resp.Body).ReadString(0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Поток должен завершиться после остановки Broker")
+	}
+}
+
+func TestStreamHandlerInvalidType(t *testing.T) {
+	broker := stream.NewBroker(1, stream.DropOldest)
+	router := NewRouter(storage.NewMemStorage(), "", "", WithStream(broker)).GetRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stream?type=histogram", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался статус 400, получен %d", w.Code)
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+	"github.com/ViktorBystrov72/go-metrics/internal/wsproto"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsPongWait время ожидания pong или сообщения от агента до закрытия соединения
+	wsPongWait = 60 * time.Second
+	// wsPingInterval интервал отправки ping, меньше wsPongWait
+	wsPingInterval = 25 * time.Second
+	// wsWriteWait таймаут записи сообщения
+	wsWriteWait = 10 * time.Second
+	// wsMaxMessageSize максимальный размер сообщения с батчем
+	wsMaxMessageSize = 32 << 20
+)
+
+// WebSocketHub хранит открытые WebSocket соединения для закрытия при остановке сервера.
+// http.Server.Shutdown не отслеживает соединения, перехваченные через Hijack.
+type WebSocketHub struct {
+	mu     sync.Mutex
+	conns  map[*websocket.Conn]struct{}
+	closed bool
+}
+
+// NewWebSocketHub создаёт WebSocketHub
+func NewWebSocketHub() *WebSocketHub {
+	return &WebSocketHub{conns: make(map[*websocket.Conn]struct{})}
+}
+
+// add регистрирует соединение. Возвращает false, если hub уже закрыт.
+func (hub *WebSocketHub) add(conn *websocket.Conn) bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		return false
+	}
+	hub.conns[conn] = struct{}{}
+	return true
+}
+
+// remove удаляет соединение
+func (hub *WebSocketHub) remove(conn *websocket.Conn) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	delete(hub.conns, conn)
+}
+
+// Connections возвращает количество открытых соединений
+func (hub *WebSocketHub) Connections() int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.conns)
+}
+
+// Close отправляет агентам сообщение о закрытии, чтобы они переподключились
+// после перезапуска, и закрывает все соединения
+func (hub *WebSocketHub) Close() {
+	hub.mu.Lock()
+	conns := hub.conns
+	hub.conns = make(map[*websocket.Conn]struct{})
+	hub.closed = true
+	hub.mu.Unlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+	for conn := range conns {
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		conn.Close()
+	}
+}
+
+// WebSocketHandler принимает батчи метрик через долгоживущее WebSocket соединение.
+// Проверки подписи, дешифрование и rate limiting выполняются для каждого батча.
+type WebSocketHandler struct {
+	handlers    *Handlers
+	privateKey  *rsa.PrivateKey
+	hub         *WebSocketHub
+	rateLimiter *middleware.RateLimiter
+	upgrader    websocket.Upgrader
+}
+
+// NewWebSocketHandler создаёт обработчик WebSocket соединений
+func NewWebSocketHandler(handlers *Handlers, privateKey *rsa.PrivateKey, hub *WebSocketHub, rateLimiter *middleware.RateLimiter) *WebSocketHandler {
+	return &WebSocketHandler{
+		handlers:    handlers,
+		privateKey:  privateKey,
+		hub:         hub,
+		rateLimiter: rateLimiter,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  64 << 10,
+			WriteBufferSize: 4 << 10,
+		},
+	}
+}
+
+// ServeHTTP устанавливает соединение и обрабатывает батчи до его закрытия
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrader уже отправил ответ с ошибкой
+		return
+	}
+	defer conn.Close()
+
+	if !h.hub.add(conn) {
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		return
+	}
+	defer h.hub.remove(conn)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go h.ping(conn, done)
+
+	for {
+		var batch wsproto.Batch
+		if err := conn.ReadJSON(&batch); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket %s: соединение закрыто: %v", r.RemoteAddr, err)
+			}
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		ack := h.process(r, batch)
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := conn.WriteJSON(ack); err != nil {
+			log.Printf("WebSocket %s: ошибка отправки подтверждения: %v", r.RemoteAddr, err)
+			return
+		}
+	}
+}
+
+// ping периодически отправляет ping, чтобы обнаруживать потерянные соединения
+func (h *WebSocketHandler) ping(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// process применяет батч и формирует подтверждение
+func (h *WebSocketHandler) process(r *http.Request, batch wsproto.Batch) wsproto.Ack {
+	ack := wsproto.Ack{Seq: batch.Seq, Status: wsproto.StatusOK}
+
+	if h.rateLimiter != nil {
+		if wait := h.rateLimiter.Reserve(r); wait > 0 {
+			ack.Status = wsproto.StatusThrottled
+			ack.RetryAfterMs = wait.Milliseconds() + 1
+			return ack
+		}
+		release, ok := h.rateLimiter.Acquire()
+		if !ok {
+			ack.Status = wsproto.StatusThrottled
+			ack.RetryAfterMs = time.Second.Milliseconds()
+			return ack
+		}
+		defer release()
+	}
+
+	reject := func(err error) wsproto.Ack {
+		ack.Status = wsproto.StatusRejected
+		ack.Error = err.Error()
+		return ack
+	}
+
+	body, err := wsproto.DecodePayload(batch, h.privateKey)
+	if err != nil {
+		return reject(err)
+	}
+
+	if batch.Hash != "" && !utils.VerifyHash(body, h.handlers.key, batch.Hash) {
+		return reject(errors.New("batch hash mismatch"))
+	}
+
+	var metrics []models.Metrics
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		return reject(err)
+	}
+
+	if err := h.handlers.applyBatch(metrics); err != nil {
+		if errors.Is(err, errInvalidBatch) {
+			return reject(err)
+		}
+		log.Printf("Failed to update batch: %v", err)
+		ack.Status = wsproto.StatusError
+		ack.Error = "storage error"
+	}
+	return ack
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/middleware"
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+	"github.com/ViktorBystrov72/go-metrics/internal/wsproto"
+	"github.com/gorilla/websocket"
+)
+
+// dialWebSocket подключается к WebSocket маршруту тестового сервера
+func dialWebSocket(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	url := "ws://" + strings.TrimPrefix(server.URL, "http://") + wsproto.Path
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Ошибка подключения: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// sendBatch отправляет батч и возвращает подтверждение
+func sendBatch(t *testing.T, conn *websocket.Conn, batch wsproto.Batch) wsproto.Ack {
+	t.Helper()
+	if err := conn.WriteJSON(batch); err != nil {
+		t.Fatalf("Ошибка отправки батча: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	var ack wsproto.Ack
+	if err := conn.ReadJSON(&ack); err != nil {
+		t.Fatalf("Ошибка чтения подтверждения: %v", err)
+	}
+	return ack
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	const key = "secret"
+	s := storage.NewMemStorage()
+	hub := NewWebSocketHub()
+	server := httptest.NewServer(NewRouter(s, key, "", WithWebSocket(hub)).GetRouter())
+	defer server.Close()
+
+	conn := dialWebSocket(t, server)
+
+	v := 2.5
+	m := models.Metrics{ID: "Alloc", MType: "gauge", Value: &v}
+	m.Hash = utils.CalculateHash([]byte(fmt.Sprintf("%s:%s:%f", m.ID, m.MType, v)), key)
+	body, err := json.Marshal([]models.Metrics{m})
+	if err != nil {
+		t.Fatalf("Ошибка сериализации: %v", err)
+	}
+
+	ack := sendBatch(t, conn, wsproto.Batch{Seq: 1, Payload: body, Hash: utils.CalculateHash(body, key)})
+	if ack.Seq != 1 || ack.Status != wsproto.StatusOK {
+		t.Fatalf("Ожидалось подтверждение ok для батча 1, получено %+v", ack)
+	}
+	if got, err := s.GetGauge("Alloc"); err != nil || got != v {
+		t.Errorf("Метрика должна быть сохранена: %v, %v", got, err)
+	}
+
+	ack = sendBatch(t, conn, wsproto.Batch{Seq: 2, Payload: body, Hash: "bad"})
+	if ack.Seq != 2 || ack.Status != wsproto.StatusRejected {
+		t.Errorf("Батч с неверной подписью должен быть отклонён, получено %+v", ack)
+	}
+
+	ack = sendBatch(t, conn, wsproto.Batch{Seq: 3, Payload: []byte("not json")})
+	if ack.Status != wsproto.StatusRejected {
+		t.Errorf("Батч с некорректным JSON должен быть отклонён, получено %+v", ack)
+	}
+
+	if hub.Connections() != 1 {
+		t.Errorf("Ожидалось 1 соединение, получено %d", hub.Connections())
+	}
+
+	// При остановке сервера агент получает сообщение о закрытии
+	hub.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Ожидалось закрытие с кодом GoingAway, получено %v", err)
+	}
+}
+
+func TestWebSocketHandler_RateLimit(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.RateLimitConfig{Rate: 1, Burst: 1})
+	server := httptest.NewServer(NewRouter(storage.NewMemStorage(), "", "",
+		WithWebSocket(NewWebSocketHub()), WithRateLimiter(limiter)).GetRouter())
+	defer server.Close()
+
+	conn := dialWebSocket(t, server)
+	body := []byte(`[{"id":"PollCount","type":"counter","delta":1}]`)
+
+	if ack := sendBatch(t, conn, wsproto.Batch{Seq: 1, Payload: body}); ack.Status != wsproto.StatusOK {
+		t.Fatalf("Первый батч должен быть принят, получено %+v", ack)
+	}
+	ack := sendBatch(t, conn, wsproto.Batch{Seq: 2, Payload: body})
+	if ack.Status != wsproto.StatusThrottled || ack.RetryAfterMs <= 0 {
+		t.Errorf("Ожидалось ограничение частоты с задержкой, получено %+v", ack)
+	}
+}
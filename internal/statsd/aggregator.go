@@ -0,0 +1,198 @@
+package statsd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// DefaultPercentiles перцентили, которые рассчитываются для таймеров
+var DefaultPercentiles = []float64{50, 90, 95, 99}
+
+// DefaultGaugeTTL время, после которого забывается значение gauge, не обновлявшегося
+// ни абсолютным, ни относительным значением. Следующее относительное изменение такого gauge
+// применяется к нулю.
+const DefaultGaugeTTL = time.Hour
+
+// gaugeState текущее значение gauge и время последнего обновления
+type gaugeState struct {
+	value float64
+	seen  time.Time
+}
+
+// Aggregator накапливает значения StatsD за интервал.
+// Counter суммируются, для gauge сохраняется последнее значение,
+// для таймеров рассчитываются count, min, max, mean и перцентили.
+type Aggregator struct {
+	mu          sync.Mutex
+	counters    map[string]float64
+	gauges      map[string]gaugeState
+	dirtyGauges map[string]struct{}
+	timers      map[string][]float64
+	timerCounts map[string]float64
+	sets        map[string]map[string]struct{}
+	percentiles []float64
+	gaugeTTL    time.Duration
+	now         func() time.Time
+}
+
+// NewAggregator создаёт новый агрегатор
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		counters:    make(map[string]float64),
+		gauges:      make(map[string]gaugeState),
+		dirtyGauges: make(map[string]struct{}),
+		timers:      make(map[string][]float64),
+		timerCounts: make(map[string]float64),
+		sets:        make(map[string]map[string]struct{}),
+		percentiles: DefaultPercentiles,
+		gaugeTTL:    DefaultGaugeTTL,
+		now:         time.Now,
+	}
+}
+
+// Add добавляет значение в текущий интервал
+func (a *Aggregator) Add(s Sample) {
+	id := models.FormatSeriesID(s.Name, s.Labels)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch s.Type {
+	case TypeCounter:
+		a.counters[id] += s.Value / s.SampleRate
+	case TypeGauge:
+		g := a.gauges[id]
+		if s.Relative {
+			g.value += s.Value
+		} else {
+			g.value = s.Value
+		}
+		g.seen = a.now()
+		a.gauges[id] = g
+		a.dirtyGauges[id] = struct{}{}
+	case TypeTimer, TypeHisto:
+		// При семплировании одно значение представляет 1/rate измерений:
+		// это учитывается в количестве, а для перцентилей значение сохраняется один раз
+		a.timers[id] = append(a.timers[id], s.Value)
+		a.timerCounts[id] += 1 / s.SampleRate
+	case TypeSet:
+		set, ok := a.sets[id]
+		if !ok {
+			set = make(map[string]struct{})
+			a.sets[id] = set
+		}
+		set[s.SetMember] = struct{}{}
+	}
+}
+
+// Flush возвращает агрегированные метрики за интервал и начинает новый интервал.
+// Значения gauge сохраняются между интервалами для корректной обработки относительных изменений,
+// но в результат попадают только gauge, обновлённые в текущем интервале. Gauge, не обновлявшиеся
+// дольше DefaultGaugeTTL, забываются, чтобы набор сохранённых значений не рос бесконечно.
+func (a *Aggregator) Flush() []models.Metrics {
+	a.mu.Lock()
+	counters := a.counters
+	timers := a.timers
+	timerCounts := a.timerCounts
+	sets := a.sets
+	dirty := a.dirtyGauges
+	gauges := make(map[string]float64, len(dirty))
+	for id := range dirty {
+		gauges[id] = a.gauges[id].value
+	}
+	now := a.now()
+	for id, g := range a.gauges {
+		if now.Sub(g.seen) > a.gaugeTTL {
+			delete(a.gauges, id)
+		}
+	}
+	a.counters = make(map[string]float64)
+	a.timers = make(map[string][]float64)
+	a.timerCounts = make(map[string]float64)
+	a.sets = make(map[string]map[string]struct{})
+	a.dirtyGauges = make(map[string]struct{})
+	a.mu.Unlock()
+
+	var metrics []models.Metrics
+
+	for id, value := range counters {
+		delta := int64(math.Round(value))
+		metrics = append(metrics, models.Metrics{ID: id, MType: "counter", Delta: &delta})
+	}
+
+	for id, value := range gauges {
+		v := value
+		metrics = append(metrics, models.Metrics{ID: id, MType: "gauge", Value: &v})
+	}
+
+	for id, values := range timers {
+		metrics = append(metrics, a.timerMetrics(id, values, timerCounts[id])...)
+	}
+
+	for id, members := range sets {
+		v := float64(len(members))
+		metrics = append(metrics, models.Metrics{ID: id, MType: "gauge", Value: &v})
+	}
+
+	return metrics
+}
+
+// timerMetrics рассчитывает сводные значения таймера
+func (a *Aggregator) timerMetrics(id string, values []float64, sampledCount float64) []models.Metrics {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sort.Float64s(values)
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+
+	name, labels := models.ParseSeriesID(id)
+	gauge := func(suffix string, value float64) models.Metrics {
+		v := value
+		return models.Metrics{ID: models.FormatSeriesID(name+suffix, labels), MType: "gauge", Value: &v}
+	}
+
+	count := int64(math.Round(sampledCount))
+	metrics := []models.Metrics{
+		{ID: models.FormatSeriesID(name+".count", labels), MType: "counter", Delta: &count},
+		gauge(".min", values[0]),
+		gauge(".max", values[len(values)-1]),
+		gauge(".mean", sum/float64(len(values))),
+	}
+	for _, p := range a.percentiles {
+		metrics = append(metrics, gauge(".p"+formatPercentile(p), Percentile(values, p)))
+	}
+
+	return metrics
+}
+
+// Percentile возвращает перцентиль p (0-100) по методу ближайшего ранга.
+// Значения должны быть отсортированы по возрастанию.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// formatPercentile форматирует перцентиль для суффикса имени: 99 -> "99", 99.9 -> "99_9"
+func formatPercentile(p float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(p, 'f', -1, 64), ".", "_")
+}
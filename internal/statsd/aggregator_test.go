@@ -0,0 +1,132 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+func flushToMap(a *Aggregator) map[string]models.Metrics {
+	result := make(map[string]models.Metrics)
+	for _, m := range a.Flush() {
+		result[m.ID] = m
+	}
+	return result
+}
+
+func TestAggregatorCounters(t *testing.T) {
+	a := NewAggregator()
+	a.Add(Sample{Name: "hits", Type: TypeCounter, Value: 1, SampleRate: 1})
+	a.Add(Sample{Name: "hits", Type: TypeCounter, Value: 2, SampleRate: 1})
+	a.Add(Sample{Name: "hits", Type: TypeCounter, Value: 1, SampleRate: 0.1})
+
+	metrics := flushToMap(a)
+	if m, ok := metrics["hits"]; !ok || *m.Delta != 13 {
+		t.Errorf("Ожидалась сумма 13 с учётом sample rate, получено %+v", m)
+	}
+
+	// После flush начинается новый интервал
+	if len(a.Flush()) != 0 {
+		t.Error("После flush интервал должен быть пустым")
+	}
+}
+
+func TestAggregatorGauges(t *testing.T) {
+	a := NewAggregator()
+	a.Add(Sample{Name: "queue", Type: TypeGauge, Value: 10, SampleRate: 1})
+	a.Add(Sample{Name: "queue", Type: TypeGauge, Value: 20, SampleRate: 1})
+
+	if m := flushToMap(a)["queue"]; *m.Value != 20 {
+		t.Errorf("Ожидалось последнее значение 20, получено %v", *m.Value)
+	}
+
+	// Относительное изменение применяется к значению из прошлого интервала
+	a.Add(Sample{Name: "queue", Type: TypeGauge, Value: -5, SampleRate: 1, Relative: true})
+	if m := flushToMap(a)["queue"]; *m.Value != 15 {
+		t.Errorf("Ожидалось значение 15, получено %v", *m.Value)
+	}
+}
+
+func TestAggregatorGaugesTTL(t *testing.T) {
+	a := NewAggregator()
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	a.Add(Sample{Name: "queue", Type: TypeGauge, Value: 10, SampleRate: 1})
+	a.Add(Sample{Name: "workers", Type: TypeGauge, Value: 4, SampleRate: 1})
+	a.Flush()
+
+	// Обновляемый gauge сохраняется, остальные забываются после DefaultGaugeTTL
+	now = now.Add(DefaultGaugeTTL / 2)
+	a.Add(Sample{Name: "queue", Type: TypeGauge, Value: 1, SampleRate: 1, Relative: true})
+	a.Flush()
+	now = now.Add(DefaultGaugeTTL/2 + time.Second)
+	a.Flush()
+
+	if len(a.gauges) != 1 {
+		t.Fatalf("Ожидался 1 сохранённый gauge, получено %d", len(a.gauges))
+	}
+	a.Add(Sample{Name: "queue", Type: TypeGauge, Value: 1, SampleRate: 1, Relative: true})
+	a.Add(Sample{Name: "workers", Type: TypeGauge, Value: 1, SampleRate: 1, Relative: true})
+	metrics := flushToMap(a)
+	if m := metrics["queue"]; *m.Value != 12 {
+		t.Errorf("Ожидалось значение 12, получено %v", *m.Value)
+	}
+	if m := metrics["workers"]; *m.Value != 1 {
+		t.Errorf("Относительное изменение забытого gauge должно применяться к нулю, получено %v", *m.Value)
+	}
+}
+
+func TestAggregatorTimers(t *testing.T) {
+	a := NewAggregator()
+	for i := 1; i <= 100; i++ {
+		a.Add(Sample{Name: "latency", Type: TypeTimer, Value: float64(i), SampleRate: 1, Labels: map[string]string{"route": "/"}})
+	}
+
+	metrics := flushToMap(a)
+	labels := map[string]string{"route": "/"}
+	expectedGauges := map[string]float64{
+		"latency.min":  1,
+		"latency.max":  100,
+		"latency.mean": 50.5,
+		"latency.p50":  50,
+		"latency.p90":  90,
+		"latency.p99":  99,
+	}
+	for name, want := range expectedGauges {
+		m, ok := metrics[models.FormatSeriesID(name, labels)]
+		if !ok || m.MType != "gauge" || *m.Value != want {
+			t.Errorf("%s: ожидалось %v, получено %+v", name, want, m)
+		}
+	}
+
+	count := metrics[models.FormatSeriesID("latency.count", labels)]
+	if count.MType != "counter" || *count.Delta != 100 {
+		t.Errorf("Ожидалось количество 100, получено %+v", count)
+	}
+}
+
+func TestAggregatorSets(t *testing.T) {
+	a := NewAggregator()
+	for _, user := range []string{"alice", "bob", "alice"} {
+		a.Add(Sample{Name: "users", Type: TypeSet, SetMember: user, SampleRate: 1})
+	}
+
+	if m := flushToMap(a)["users"]; *m.Value != 2 {
+		t.Errorf("Ожидалось 2 уникальных значения, получено %v", *m.Value)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	if Percentile(nil, 50) != 0 {
+		t.Error("Перцентиль пустого набора должен быть 0")
+	}
+	values := []float64{1, 2, 3, 4}
+	if p := Percentile(values, 50); p != 2 {
+		t.Errorf("Ожидалось 2, получено %v", p)
+	}
+	if p := Percentile(values, 100); p != 4 {
+		t.Errorf("Ожидалось 4, получено %v", p)
+	}
+}
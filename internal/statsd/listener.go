@@ -0,0 +1,106 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// maxPacketSize максимальный размер UDP пакета
+const maxPacketSize = 65535
+
+// malformedLogEvery как часто логируются некорректные строки: первая и каждая N-я
+const malformedLogEvery = 100
+
+// Listener принимает пакеты StatsD по UDP и передаёт разобранные значения обработчику
+type Listener struct {
+	addr    string
+	handler func(Sample)
+
+	conn      net.PacketConn
+	wg        sync.WaitGroup
+	received  atomic.Int64
+	malformed atomic.Int64
+}
+
+// NewListener создаёт listener для адреса addr. Обработчик вызывается для каждого
+// корректного значения из одной горутины.
+func NewListener(addr string, handler func(Sample)) *Listener {
+	return &Listener{
+		addr:    addr,
+		handler: handler,
+	}
+}
+
+// Start открывает UDP сокет и запускает чтение пакетов
+func (l *Listener) Start() error {
+	conn, err := net.ListenPacket("udp", l.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen statsd on %s: %w", l.addr, err)
+	}
+	l.conn = conn
+
+	l.wg.Add(1)
+	go l.serve()
+
+	log.Printf("StatsD listener запущен на %s", conn.LocalAddr())
+	return nil
+}
+
+// Addr возвращает фактический адрес сокета
+func (l *Listener) Addr() net.Addr {
+	if l.conn == nil {
+		return nil
+	}
+	return l.conn.LocalAddr()
+}
+
+// Received возвращает количество принятых корректных значений
+func (l *Listener) Received() int64 {
+	return l.received.Load()
+}
+
+// Malformed возвращает количество некорректных строк
+func (l *Listener) Malformed() int64 {
+	return l.malformed.Load()
+}
+
+// Close закрывает сокет и ожидает завершения чтения
+func (l *Listener) Close() error {
+	if l.conn == nil {
+		return nil
+	}
+	err := l.conn.Close()
+	l.wg.Wait()
+	return err
+}
+
+func (l *Listener) serve() {
+	defer l.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Ошибка чтения StatsD пакета: %v", err)
+			continue
+		}
+
+		samples, errs := ParsePacket(buf[:n])
+		for _, parseErr := range errs {
+			if count := l.malformed.Add(1); count == 1 || count%malformedLogEvery == 0 {
+				log.Printf("Некорректная StatsD строка (всего %d): %v", count, parseErr)
+			}
+		}
+		for _, s := range samples {
+			l.received.Add(1)
+			l.handler(s)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package statsd
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	var mu sync.Mutex
+	var received []Sample
+
+	l := NewListener("127.0.0.1:0", func(s Sample) {
+		mu.Lock()
+		received = append(received, s)
+		mu.Unlock()
+	})
+	if err := l.Start(); err != nil {
+		t.Fatalf("Не удалось запустить listener: %v", err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("udp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Не удалось подключиться: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hits:1|c\nqueue:5|g\nbad line")); err != nil {
+		t.Fatalf("Не удалось отправить пакет: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if l.Received() == 2 && l.Malformed() == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Errorf("Ожидалось 2 значения, получено %d", len(received))
+	}
+	if l.Malformed() != 1 {
+		t.Errorf("Ожидалась 1 некорректная строка, получено %d", l.Malformed())
+	}
+}
+
+func TestListenerInvalidAddress(t *testing.T) {
+	l := NewListener("invalid-address", func(Sample) {})
+	if err := l.Start(); err == nil {
+		l.Close()
+		t.Error("Ожидалась ошибка для некорректного адреса")
+	}
+}
@@ -0,0 +1,118 @@
+// Package statsd предоставляет разбор протокола StatsD, агрегацию значений
+// за интервал и UDP listener для приёма пакетов.
+package statsd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Типы значений StatsD
+const (
+	TypeCounter = "c"
+	TypeGauge   = "g"
+	TypeTimer   = "ms"
+	TypeHisto   = "h"
+	TypeSet     = "s"
+)
+
+// Sample одно значение из строки StatsD
+type Sample struct {
+	Name       string
+	Labels     map[string]string
+	Type       string
+	Value      float64
+	SampleRate float64
+	// Relative true для gauge вида +5 или -3, которые изменяют текущее значение
+	Relative bool
+	// SetMember значение для типа s
+	SetMember string
+}
+
+// ParsePacket разбирает пакет, содержащий одну или несколько строк через перевод строки.
+// Возвращает корректно разобранные значения и ошибки для некорректных строк.
+func ParsePacket(packet []byte) ([]Sample, []error) {
+	var samples []Sample
+	var errs []error
+
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sample, err := ParseLine(line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, errs
+}
+
+// ParseLine разбирает строку вида name:value|type[|@rate][|#tag1:v1,tag2:v2]
+func ParseLine(line string) (Sample, error) {
+	sample := Sample{SampleRate: 1}
+
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 {
+		return sample, fmt.Errorf("invalid statsd line %q: missing name", line)
+	}
+	sample.Name = line[:colon]
+
+	parts := strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 {
+		return sample, fmt.Errorf("invalid statsd line %q: missing type", line)
+	}
+
+	rawValue := parts[0]
+	sample.Type = parts[1]
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return sample, fmt.Errorf("invalid statsd line %q: bad sample rate", line)
+			}
+			sample.SampleRate = rate
+		case strings.HasPrefix(part, "#"):
+			sample.Labels = parseTags(part[1:])
+		}
+	}
+
+	switch sample.Type {
+	case TypeSet:
+		sample.SetMember = rawValue
+		return sample, nil
+	case TypeGauge:
+		sample.Relative = strings.HasPrefix(rawValue, "+") || strings.HasPrefix(rawValue, "-")
+	case TypeCounter, TypeTimer, TypeHisto:
+	default:
+		return sample, fmt.Errorf("invalid statsd line %q: unknown type %q", line, sample.Type)
+	}
+
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return sample, fmt.Errorf("invalid statsd line %q: bad value", line)
+	}
+	sample.Value = value
+
+	return sample, nil
+}
+
+// parseTags разбирает теги в формате DogStatsD: key:value,key2:value2
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(tag, ":")
+		tags[key] = value
+	}
+	return tags
+}
@@ -0,0 +1,93 @@
+package statsd
+
+import (
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected Sample
+		hasError bool
+	}{
+		{
+			name:     "counter",
+			line:     "requests:1|c",
+			expected: Sample{Name: "requests", Type: TypeCounter, Value: 1, SampleRate: 1},
+		},
+		{
+			name:     "counter with sample rate",
+			line:     "requests:2|c|@0.5",
+			expected: Sample{Name: "requests", Type: TypeCounter, Value: 2, SampleRate: 0.5},
+		},
+		{
+			name:     "gauge",
+			line:     "queue:42.5|g",
+			expected: Sample{Name: "queue", Type: TypeGauge, Value: 42.5, SampleRate: 1},
+		},
+		{
+			name:     "relative gauge",
+			line:     "queue:-3|g",
+			expected: Sample{Name: "queue", Type: TypeGauge, Value: -3, SampleRate: 1, Relative: true},
+		},
+		{
+			name:     "timer",
+			line:     "latency:320|ms",
+			expected: Sample{Name: "latency", Type: TypeTimer, Value: 320, SampleRate: 1},
+		},
+		{
+			name:     "set",
+			line:     "users:alice|s",
+			expected: Sample{Name: "users", Type: TypeSet, SampleRate: 1, SetMember: "alice"},
+		},
+		{name: "missing type", line: "requests:1", hasError: true},
+		{name: "missing name", line: ":1|c", hasError: true},
+		{name: "unknown type", line: "requests:1|x", hasError: true},
+		{name: "bad value", line: "requests:abc|c", hasError: true},
+		{name: "NaN gauge", line: "x:NaN|g", hasError: true},
+		{name: "Inf gauge", line: "x:+Inf|g", hasError: true},
+		{name: "Inf timer", line: "latency:Inf|ms", hasError: true},
+		{name: "bad sample rate", line: "requests:1|c|@2", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Ожидалась ошибка для %q", tt.line)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if got.Name != tt.expected.Name || got.Type != tt.expected.Type || got.Value != tt.expected.Value ||
+				got.SampleRate != tt.expected.SampleRate || got.Relative != tt.expected.Relative ||
+				got.SetMember != tt.expected.SetMember {
+				t.Errorf("Ожидалось %+v, получено %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseLineTags(t *testing.T) {
+	s, err := ParseLine("requests:1|c|#host:web1,env:prod")
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if s.Labels["host"] != "web1" || s.Labels["env"] != "prod" {
+		t.Errorf("Теги разобраны некорректно: %v", s.Labels)
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	samples, errs := ParsePacket([]byte("a:1|c\nbroken\n\nb:2|g\n"))
+	if len(samples) != 2 {
+		t.Errorf("Ожидалось 2 значения, получено %d", len(samples))
+	}
+	if len(errs) != 1 {
+		t.Errorf("Ожидалась 1 ошибка, получено %d", len(errs))
+	}
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// ErrInvalidMetric возвращается UpdateBatch, если в батче есть метрика с неизвестным типом
+// или без значения. В этом случае не применяется ни одна метрика батча.
+var ErrInvalidMetric = errors.New("invalid metric")
+
+// ValidateBatch проверяет, что у каждой метрики батча известный тип и задано значение
+func ValidateBatch(metrics []models.Metrics) error {
+	for _, m := range metrics {
+		switch m.MType {
+		case string(Gauge):
+			if m.Value == nil {
+				return fmt.Errorf("%w: gauge metric %s has nil value", ErrInvalidMetric, m.ID)
+			}
+		case string(Counter):
+			if m.Delta == nil {
+				return fmt.Errorf("%w: counter metric %s has nil delta", ErrInvalidMetric, m.ID)
+			}
+		default:
+			return fmt.Errorf("%w: unknown metric type %q for metric %s", ErrInvalidMetric, m.MType, m.ID)
+		}
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ViktorBystrov72/go-metrics/internal/models"
@@ -15,6 +17,8 @@ import (
 // DatabaseStorage реализует интерфейс Storage для PostgreSQL
 type DatabaseStorage struct {
 	db *pgxpool.Pool
+	// breaker прекращает обращения к базе, пока она недоступна
+	breaker *utils.CircuitBreaker
 }
 
 // NewDatabaseStorage создает новое подключение к PostgreSQL
@@ -30,7 +34,15 @@ func NewDatabaseStorage(dsn string) (*DatabaseStorage, error) {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	return &DatabaseStorage{db: db}, nil
+	return &DatabaseStorage{
+		db:      db,
+		breaker: utils.NewCircuitBreaker(utils.DefaultCircuitBreakerConfig("database")),
+	}, nil
+}
+
+// retryConfig возвращает настройки retry с общим для всех запросов circuit breaker
+func (d *DatabaseStorage) retryConfig() utils.RetryConfig {
+	return utils.DefaultBackoffRetryConfig().WithBreaker(d.breaker)
 }
 
 // Ping проверяет соединение с базой данных
@@ -59,7 +71,7 @@ func (d *DatabaseStorage) UpdateGauge(name string, value float64) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err := utils.Retry(ctx, utils.DefaultRetryConfig(), func() error {
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
 		_, err := d.db.Exec(ctx, query, name, value)
 		return err
 	})
@@ -82,7 +94,7 @@ func (d *DatabaseStorage) UpdateCounter(name string, value int64) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err := utils.Retry(ctx, utils.DefaultRetryConfig(), func() error {
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
 		_, err := d.db.Exec(ctx, query, name, value)
 		return err
 	})
@@ -100,7 +112,7 @@ func (d *DatabaseStorage) GetGauge(name string) (float64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err := utils.Retry(ctx, utils.DefaultRetryConfig(), func() error {
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
 		return d.db.QueryRow(ctx, query, name).Scan(&value)
 	})
 
@@ -119,7 +131,7 @@ func (d *DatabaseStorage) GetCounter(name string) (int64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err := utils.Retry(ctx, utils.DefaultRetryConfig(), func() error {
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
 		return d.db.QueryRow(ctx, query, name).Scan(&value)
 	})
 
@@ -203,10 +215,15 @@ func (d *DatabaseStorage) UpdateBatch(metrics []models.Metrics) error {
 		return nil
 	}
 
+	// Проверяем батч до начала транзакции: повтор некорректного батча не поможет
+	if err := ValidateBatch(metrics); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	return utils.Retry(ctx, utils.DefaultRetryConfig(), func() error {
+	return utils.Retry(ctx, d.retryConfig(), func() error {
 		tx, err := d.db.Begin(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to begin transaction: %w", err)
@@ -262,7 +279,7 @@ func (d *DatabaseStorage) GetAllMetrics() map[string]interface{} {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err := utils.Retry(ctx, utils.DefaultRetryConfig(), func() error {
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
 		rows, err := d.db.Query(ctx, query)
 		if err != nil {
 			return err
@@ -295,3 +312,406 @@ func (d *DatabaseStorage) GetAllMetrics() map[string]interface{} {
 
 	return metrics
 }
+
+// ListMetrics возвращает страницу метрик. Фильтры, сортировка и курсор
+// выполняются в SQL, в Go передаётся только одна страница.
+func (d *DatabaseStorage) ListMetrics(query MetricsQuery) (MetricsPage, error) {
+	if err := query.Normalize(); err != nil {
+		return MetricsPage{}, err
+	}
+	sqlQuery, args := buildListQuery(query)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var metrics []models.Metrics
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
+		metrics = metrics[:0]
+		rows, err := d.db.Query(ctx, sqlQuery, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var m models.Metrics
+			var value sql.NullFloat64
+			var delta sql.NullInt64
+			if err := rows.Scan(&m.ID, &m.MType, &value, &delta); err != nil {
+				return err
+			}
+			if value.Valid {
+				m.Value = &value.Float64
+			}
+			if delta.Valid {
+				m.Delta = &delta.Int64
+			}
+			metrics = append(metrics, m)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return MetricsPage{}, fmt.Errorf("failed to list metrics: %w", err)
+	}
+
+	// Запрашивается на одну строку больше лимита, чтобы узнать о следующей странице
+	page := MetricsPage{Metrics: metrics}
+	if len(metrics) > query.Limit {
+		page.Metrics = metrics[:query.Limit]
+		page.NextCursor = encodeCursor(cursorFor(page.Metrics[query.Limit-1]))
+	}
+	return page, nil
+}
+
+// sqlArgs накапливает аргументы запроса и возвращает их плейсхолдеры
+type sqlArgs []any
+
+func (a *sqlArgs) add(v any) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// buildFilterConditions формирует условия WHERE для фильтров запроса
+func buildFilterConditions(query MetricsQuery, args *sqlArgs) []string {
+	var where []string
+
+	if len(query.Types) > 0 {
+		where = append(where, "type = ANY("+args.add(query.Types)+")")
+	}
+	if query.NamePrefix != "" {
+		where = append(where, `name LIKE `+args.add(escapeLike(query.NamePrefix)+"%")+` ESCAPE '\'`)
+	}
+	if query.NameRegex != "" {
+		where = append(where, "name ~ "+args.add(query.NameRegex))
+	}
+
+	// Метка ищется в идентификаторе серии после { или запятой
+	labelKeys := make([]string, 0, len(query.Labels))
+	for k := range query.Labels {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+	for _, k := range labelKeys {
+		p := args.add(labelPattern(k, query.Labels[k]))
+		where = append(where, fmt.Sprintf("(strpos(name, '{' || %s) > 0 OR strpos(name, ',' || %s) > 0)", p, p))
+	}
+
+	return where
+}
+
+// buildListQuery формирует SQL запрос списка метрик для нормализованного запроса.
+// Имена сравниваются в порядке байтов (COLLATE "C"), как при сортировке в памяти,
+// чтобы курсор одинаково работал для всех хранилищ.
+func buildListQuery(query MetricsQuery) (string, []any) {
+	var args sqlArgs
+	arg := args.add
+	where := buildFilterConditions(query, &args)
+
+	name := `name COLLATE "C"`
+	typ := `type COLLATE "C"`
+	var keys []string
+	switch query.Sort {
+	case SortByType:
+		keys = []string{typ, name}
+	case SortByValue:
+		keys = []string{"COALESCE(value, delta::double precision)", name, typ}
+	default:
+		keys = []string{name, typ}
+	}
+
+	if query.Cursor != "" {
+		c, _ := decodeCursor(query.Cursor)
+		var values []string
+		switch query.Sort {
+		case SortByType:
+			values = []string{arg(c.Type), arg(c.Name)}
+		case SortByValue:
+			values = []string{arg(c.Value), arg(c.Name), arg(c.Type)}
+		default:
+			values = []string{arg(c.Name), arg(c.Type)}
+		}
+		op := ">"
+		if query.Desc {
+			op = "<"
+		}
+		where = append(where, fmt.Sprintf("(%s) %s (%s)", strings.Join(keys, ", "), op, strings.Join(values, ", ")))
+	}
+
+	direction := " ASC"
+	if query.Desc {
+		direction = " DESC"
+	}
+	order := make([]string, len(keys))
+	for i, k := range keys {
+		order[i] = k + direction
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT name, type, value, delta FROM metrics")
+	if len(where) > 0 {
+		b.WriteString(" WHERE ")
+		b.WriteString(strings.Join(where, " AND "))
+	}
+	b.WriteString(" ORDER BY ")
+	b.WriteString(strings.Join(order, ", "))
+	b.WriteString(" LIMIT ")
+	b.WriteString(arg(query.Limit + 1))
+	return b.String(), args
+}
+
+// DeleteMetric удаляет метрику из базы данных
+func (d *DatabaseStorage) DeleteMetric(mType, name string) error {
+	if mType != string(Gauge) && mType != string(Counter) {
+		return fmt.Errorf("unknown metric type: %s", mType)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var affected int64
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
+		tag, err := d.db.Exec(ctx, `DELETE FROM metrics WHERE name = $1 AND type = $2`, name, mType)
+		if err != nil {
+			return err
+		}
+		affected = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s metric %s: %w", mType, name, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s metric %s: %w", mType, name, ErrMetricNotFound)
+	}
+	return nil
+}
+
+// DeleteMetrics удаляет метрики, подходящие под фильтры запроса, одним SQL запросом
+func (d *DatabaseStorage) DeleteMetrics(query MetricsQuery) (int, error) {
+	if err := query.Normalize(); err != nil {
+		return 0, err
+	}
+
+	var args sqlArgs
+	sqlQuery := "DELETE FROM metrics"
+	if where := buildFilterConditions(query, &args); len(where) > 0 {
+		sqlQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var affected int64
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
+		tag, err := d.db.Exec(ctx, sqlQuery, args...)
+		if err != nil {
+			return err
+		}
+		affected = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete metrics: %w", err)
+	}
+	return int(affected), nil
+}
+
+// ResetCounter обнуляет counter метрику в базе данных
+func (d *DatabaseStorage) ResetCounter(name string) error {
+	query := `UPDATE metrics SET delta = 0, created_at = CURRENT_TIMESTAMP WHERE name = $1 AND type = 'counter'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var affected int64
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
+		tag, err := d.db.Exec(ctx, query, name)
+		if err != nil {
+			return err
+		}
+		affected = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset counter metric %s: %w", name, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("counter metric %s: %w", name, ErrMetricNotFound)
+	}
+	return nil
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// ExpireMetrics удаляет метрики типа mType, не обновлявшиеся дольше ttl.
+// Время последнего обновления хранится в колонке created_at, которая
+// обновляется при каждой записи метрики.
+func (d *DatabaseStorage) ExpireMetrics(mType string, ttl time.Duration) (int, error) {
+	if mType != string(Gauge) && mType != string(Counter) {
+		return 0, fmt.Errorf("unknown metric type: %s", mType)
+	}
+	query := `DELETE FROM metrics WHERE type = $1 AND created_at < CURRENT_TIMESTAMP - make_interval(secs => $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var affected int64
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
+		tag, err := d.db.Exec(ctx, query, mType, ttl.Seconds())
+		if err != nil {
+			return err
+		}
+		affected = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire %s metrics: %w", mType, err)
+	}
+	return int(affected), nil
+}
+
+// rollupInsertRows количество точек в одном INSERT: 9 параметров на точку
+// не должны превышать ограничение PostgreSQL в 65535 параметров запроса
+const rollupInsertRows = 1000
+
+// WriteRollups записывает точки tier в таблицу metric_rollups одной транзакцией
+// многострочными INSERT, заменяя точки с теми же типом, именем и началом
+func (d *DatabaseStorage) WriteRollups(tier string, points []RollupPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	// ON CONFLICT DO UPDATE не может изменить одну строку дважды в одном запросе,
+	// поэтому из повторяющихся точек остаётся последняя
+	type rollupKey struct {
+		mType, id string
+		start     time.Time
+	}
+	index := make(map[rollupKey]int, len(points))
+	unique := make([]RollupPoint, 0, len(points))
+	for _, p := range points {
+		key := rollupKey{p.Type, p.ID, p.Start.UTC()}
+		if i, ok := index[key]; ok {
+			unique[i] = p
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, p)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return utils.Retry(ctx, d.retryConfig(), func() error {
+		tx, err := d.db.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback(ctx)
+
+		for start := 0; start < len(unique); start += rollupInsertRows {
+			chunk := unique[start:min(start+rollupInsertRows, len(unique))]
+			query, args := rollupInsertQuery(tier, chunk)
+			if _, err := tx.Exec(ctx, query, args...); err != nil {
+				return fmt.Errorf("failed to write %d %s rollups: %w", len(chunk), tier, err)
+			}
+		}
+		return tx.Commit(ctx)
+	})
+}
+
+// rollupInsertQuery строит многострочный INSERT точек tier с заменой существующих
+func rollupInsertQuery(tier string, points []RollupPoint) (string, []any) {
+	var b strings.Builder
+	b.WriteString(`INSERT INTO metric_rollups (tier, type, name, bucket_start, count, min, max, sum, last) VALUES `)
+	args := make([]any, 0, len(points)*9)
+	for i, p := range points {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&b, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9)
+		args = append(args, tier, p.Type, p.ID, p.Start.UTC(), p.Count, p.Min, p.Max, p.Sum, p.Last)
+	}
+	b.WriteString(` ON CONFLICT (tier, type, name, bucket_start)
+	DO UPDATE SET count = EXCLUDED.count, min = EXCLUDED.min, max = EXCLUDED.max,
+		sum = EXCLUDED.sum, last = EXCLUDED.last`)
+	return b.String(), args
+}
+
+// queryRollups выполняет запрос к metric_rollups и читает точки в порядке выдачи
+func (d *DatabaseStorage) queryRollups(query string, args ...any) ([]RollupPoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var points []RollupPoint
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
+		points = points[:0]
+		rows, err := d.db.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p RollupPoint
+			if err := rows.Scan(&p.Type, &p.ID, &p.Start, &p.Count, &p.Min, &p.Max, &p.Sum, &p.Last); err != nil {
+				return err
+			}
+			p.Start = p.Start.UTC()
+			points = append(points, p)
+		}
+		return rows.Err()
+	})
+	return points, err
+}
+
+// ReadRollups возвращает точки серии с началом в [from, to) по возрастанию времени
+func (d *DatabaseStorage) ReadRollups(tier, mType, id string, from, to time.Time) ([]RollupPoint, error) {
+	points, err := d.queryRollups(`
+	SELECT type, name, bucket_start, count, min, max, sum, last FROM metric_rollups
+	WHERE tier = $1 AND type = $2 AND name = $3 AND bucket_start >= $4 AND bucket_start < $5
+	ORDER BY bucket_start
+	`, tier, mType, id, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s rollups of %s: %w", tier, id, err)
+	}
+	return points, nil
+}
+
+// ReadRollupRange возвращает точки всех серий tier с началом в [from, to),
+// упорядоченные по типу, имени и времени
+func (d *DatabaseStorage) ReadRollupRange(tier string, from, to time.Time) ([]RollupPoint, error) {
+	points, err := d.queryRollups(`
+	SELECT type, name, bucket_start, count, min, max, sum, last FROM metric_rollups
+	WHERE tier = $1 AND bucket_start >= $2 AND bucket_start < $3
+	ORDER BY type, name, bucket_start
+	`, tier, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s rollups: %w", tier, err)
+	}
+	return points, nil
+}
+
+// DeleteRollups удаляет точки tier с началом раньше before
+func (d *DatabaseStorage) DeleteRollups(tier string, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var affected int64
+	err := utils.Retry(ctx, d.retryConfig(), func() error {
+		tag, err := d.db.Exec(ctx, `DELETE FROM metric_rollups WHERE tier = $1 AND bucket_start < $2`, tier, before.UTC())
+		if err != nil {
+			return err
+		}
+		affected = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete %s rollups: %w", tier, err)
+	}
+	return int(affected), nil
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ViktorBystrov72/go-metrics/internal/models"
 )
@@ -14,13 +17,26 @@ type MemStorage struct {
 	mu       sync.RWMutex // RWMutex для лучшей производительности при чтении
 	gauges   map[string]float64
 	counters map[string]int64
+
+	// Время последнего обновления метрик для удаления устаревших (TTL)
+	gaugeUpdated   map[string]time.Time
+	counterUpdated map[string]time.Time
+	now            func() time.Time
+
+	// Агрегаты по tier хранятся отдельно от текущих значений и не сохраняются в файл
+	rollupMu sync.RWMutex
+	rollups  map[string]map[rollupKey][]RollupPoint
 }
 
 // NewMemStorage создает новый экземпляр хранилища в памяти
 func NewMemStorage() *MemStorage {
 	return &MemStorage{
-		gauges:   make(map[string]float64),
-		counters: make(map[string]int64),
+		gauges:         make(map[string]float64),
+		counters:       make(map[string]int64),
+		gaugeUpdated:   make(map[string]time.Time),
+		counterUpdated: make(map[string]time.Time),
+		now:            time.Now,
+		rollups:        make(map[string]map[rollupKey][]RollupPoint),
 	}
 }
 
@@ -29,6 +45,7 @@ func (s *MemStorage) UpdateGauge(name string, value float64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.gauges[name] = value
+	s.gaugeUpdated[name] = s.now()
 }
 
 // UpdateCounter обновляет значение counter метрики
@@ -36,6 +53,7 @@ func (s *MemStorage) UpdateCounter(name string, value int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.counters[name] += value
+	s.counterUpdated[name] = s.now()
 }
 
 // GetGauge возвращает значение gauge метрики
@@ -134,13 +152,20 @@ func (s *MemStorage) LoadFromFile(filename string) error {
 	}
 
 	s.mu.Lock()
+	// Время обновления в файл не сохраняется: восстановленные метрики
+	// считаются обновлёнными при загрузке и получают полный TTL
+	now := s.now()
 	s.gauges = make(map[string]float64, len(dump.Gauges))
+	s.gaugeUpdated = make(map[string]time.Time, len(dump.Gauges))
 	for k, v := range dump.Gauges {
 		s.gauges[k] = v
+		s.gaugeUpdated[k] = now
 	}
 	s.counters = make(map[string]int64, len(dump.Counters))
+	s.counterUpdated = make(map[string]time.Time, len(dump.Counters))
 	for k, v := range dump.Counters {
 		s.counters[k] = v
+		s.counterUpdated[k] = now
 	}
 	s.mu.Unlock()
 	return nil
@@ -168,25 +193,223 @@ func (s *MemStorage) UpdateBatch(metrics []models.Metrics) error {
 		return nil
 	}
 
+	// Проверяем весь батч до изменений, чтобы ошибка не оставила его применённым частично
+	if err := ValidateBatch(metrics); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := s.now()
 	for _, m := range metrics {
 		switch m.MType {
-		case "gauge":
-			if m.Value == nil {
-				return fmt.Errorf("gauge metric %s has nil value", m.ID)
-			}
+		case string(Gauge):
 			s.gauges[m.ID] = *m.Value
-		case "counter":
-			if m.Delta == nil {
-				return fmt.Errorf("counter metric %s has nil delta", m.ID)
-			}
+			s.gaugeUpdated[m.ID] = now
+		case string(Counter):
 			s.counters[m.ID] += *m.Delta
-		default:
-			return fmt.Errorf("unknown metric type: %s", m.MType)
+			s.counterUpdated[m.ID] = now
+		}
+	}
+
+	return nil
+}
+
+// ListMetrics возвращает страницу метрик, отобранных и отсортированных по запросу
+func (s *MemStorage) ListMetrics(query MetricsQuery) (MetricsPage, error) {
+	s.mu.RLock()
+	metrics := make([]models.Metrics, 0, len(s.gauges)+len(s.counters))
+	for name, value := range s.gauges {
+		v := value
+		metrics = append(metrics, models.Metrics{ID: name, MType: string(Gauge), Value: &v})
+	}
+	for name, delta := range s.counters {
+		d := delta
+		metrics = append(metrics, models.Metrics{ID: name, MType: string(Counter), Delta: &d})
+	}
+	s.mu.RUnlock()
+
+	return QueryMetrics(metrics, query)
+}
+
+// DeleteMetric удаляет метрику
+func (s *MemStorage) DeleteMetric(mType, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch MetricType(mType) {
+	case Gauge:
+		if _, ok := s.gauges[name]; !ok {
+			return fmt.Errorf("gauge metric %s: %w", name, ErrMetricNotFound)
+		}
+		delete(s.gauges, name)
+		delete(s.gaugeUpdated, name)
+	case Counter:
+		if _, ok := s.counters[name]; !ok {
+			return fmt.Errorf("counter metric %s: %w", name, ErrMetricNotFound)
+		}
+		delete(s.counters, name)
+		delete(s.counterUpdated, name)
+	default:
+		return fmt.Errorf("unknown metric type: %s", mType)
+	}
+	return nil
+}
+
+// DeleteMetrics удаляет метрики, подходящие под фильтры запроса
+func (s *MemStorage) DeleteMetrics(query MetricsQuery) (int, error) {
+	if err := query.Normalize(); err != nil {
+		return 0, err
+	}
+	match := query.matcher()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for name, value := range s.gauges {
+		v := value
+		if match(models.Metrics{ID: name, MType: string(Gauge), Value: &v}) {
+			delete(s.gauges, name)
+			delete(s.gaugeUpdated, name)
+			deleted++
+		}
+	}
+	for name, delta := range s.counters {
+		d := delta
+		if match(models.Metrics{ID: name, MType: string(Counter), Delta: &d}) {
+			delete(s.counters, name)
+			delete(s.counterUpdated, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// ResetCounter обнуляет counter метрику
+func (s *MemStorage) ResetCounter(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.counters[name]; !ok {
+		return fmt.Errorf("counter metric %s: %w", name, ErrMetricNotFound)
+	}
+	s.counters[name] = 0
+	s.counterUpdated[name] = s.now()
+	return nil
+}
+
+// ExpireMetrics удаляет метрики типа mType, не обновлявшиеся дольше ttl
+func (s *MemStorage) ExpireMetrics(mType string, ttl time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-ttl)
+	expire := func(updated map[string]time.Time, remove func(string)) int {
+		expired := 0
+		for name, t := range updated {
+			if t.Before(cutoff) {
+				remove(name)
+				delete(updated, name)
+				expired++
+			}
 		}
+		return expired
 	}
 
+	switch MetricType(mType) {
+	case Gauge:
+		return expire(s.gaugeUpdated, func(name string) { delete(s.gauges, name) }), nil
+	case Counter:
+		return expire(s.counterUpdated, func(name string) { delete(s.counters, name) }), nil
+	default:
+		return 0, fmt.Errorf("unknown metric type: %s", mType)
+	}
+}
+
+// WriteRollups записывает точки tier, заменяя точки с теми же типом, именем и началом
+func (s *MemStorage) WriteRollups(tier string, points []RollupPoint) error {
+	s.rollupMu.Lock()
+	defer s.rollupMu.Unlock()
+
+	series, ok := s.rollups[tier]
+	if !ok {
+		series = make(map[rollupKey][]RollupPoint)
+		s.rollups[tier] = series
+	}
+	for _, p := range points {
+		p.Start = p.Start.UTC()
+		key := rollupKey{mType: p.Type, id: p.ID}
+		stored := series[key]
+		i := sort.Search(len(stored), func(i int) bool { return !stored[i].Start.Before(p.Start) })
+		if i < len(stored) && stored[i].Start.Equal(p.Start) {
+			stored[i] = p
+			continue
+		}
+		series[key] = slices.Insert(stored, i, p)
+	}
 	return nil
 }
+
+// rollupsBetween возвращает точки с началом в [from, to) из упорядоченного по времени среза
+func rollupsBetween(points []RollupPoint, from, to time.Time) []RollupPoint {
+	i := sort.Search(len(points), func(i int) bool { return !points[i].Start.Before(from) })
+	j := sort.Search(len(points), func(i int) bool { return !points[i].Start.Before(to) })
+	if i >= j {
+		return nil
+	}
+	return slices.Clone(points[i:j])
+}
+
+// ReadRollups возвращает точки серии с началом в [from, to) по возрастанию времени
+func (s *MemStorage) ReadRollups(tier, mType, id string, from, to time.Time) ([]RollupPoint, error) {
+	s.rollupMu.RLock()
+	defer s.rollupMu.RUnlock()
+
+	return rollupsBetween(s.rollups[tier][rollupKey{mType: mType, id: id}], from, to), nil
+}
+
+// ReadRollupRange возвращает точки всех серий tier с началом в [from, to),
+// упорядоченные по типу, имени и времени
+func (s *MemStorage) ReadRollupRange(tier string, from, to time.Time) ([]RollupPoint, error) {
+	s.rollupMu.RLock()
+	defer s.rollupMu.RUnlock()
+
+	series := s.rollups[tier]
+	keys := make([]rollupKey, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].mType != keys[j].mType {
+			return keys[i].mType < keys[j].mType
+		}
+		return keys[i].id < keys[j].id
+	})
+
+	var result []RollupPoint
+	for _, key := range keys {
+		result = append(result, rollupsBetween(series[key], from, to)...)
+	}
+	return result, nil
+}
+
+// DeleteRollups удаляет точки tier с началом раньше before
+func (s *MemStorage) DeleteRollups(tier string, before time.Time) (int, error) {
+	s.rollupMu.Lock()
+	defer s.rollupMu.Unlock()
+
+	deleted := 0
+	series := s.rollups[tier]
+	for key, points := range series {
+		i := sort.Search(len(points), func(i int) bool { return !points[i].Start.Before(before) })
+		deleted += i
+		if i == len(points) {
+			delete(series, key)
+			continue
+		}
+		series[key] = points[i:]
+	}
+	return deleted, nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"os"
 	"testing"
 
@@ -189,5 +190,27 @@ func TestMemStorage_UpdateBatch_Error(t *testing.T) {
 	}
 }
 
+func TestMemStorage_UpdateBatch_Atomic(t *testing.T) {
+	s := NewMemStorage()
+	s.UpdateCounter("c1", 1)
+
+	// Некорректная метрика в конце батча не должна оставить применёнными предыдущие
+	metrics := []models.Metrics{
+		{ID: "g1", MType: "gauge", Value: floatPtr(1.23)},
+		{ID: "c1", MType: "counter", Delta: intPtr(10)},
+		{ID: "g2", MType: "gauge"},
+	}
+	err := s.UpdateBatch(metrics)
+	if !errors.Is(err, ErrInvalidMetric) {
+		t.Fatalf("Ожидалась ошибка ErrInvalidMetric, получено %v", err)
+	}
+	if _, err := s.GetGauge("g1"); err == nil {
+		t.Error("gauge g1 не должен сохраняться при ошибке в батче")
+	}
+	if v, _ := s.GetCounter("c1"); v != 1 {
+		t.Errorf("counter c1 не должен изменяться при ошибке в батче, получено %d", v)
+	}
+}
+
 func floatPtr(f float64) *float64 { return &f }
 func intPtr(i int64) *int64       { return &i }
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// Поля сортировки списка метрик
+const (
+	// SortByName сортировка по идентификатору метрики
+	SortByName = "name"
+	// SortByType сортировка по типу, внутри типа - по идентификатору
+	SortByType = "type"
+	// SortByValue сортировка по значению gauge или накопленному значению counter
+	SortByValue = "value"
+)
+
+const (
+	// DefaultListLimit размер страницы, если лимит не задан
+	DefaultListLimit = 100
+	// MaxListLimit максимальный размер страницы
+	MaxListLimit = 1000
+)
+
+// ErrInvalidQuery возвращается при некорректных параметрах запроса списка метрик
+var ErrInvalidQuery = errors.New("invalid metrics query")
+
+// ErrMetricNotFound возвращается при удалении или сбросе несуществующей метрики
+var ErrMetricNotFound = errors.New("metric not found")
+
+// MetricsQuery фильтры, сортировка и пагинация списка метрик
+type MetricsQuery struct {
+	// Types типы метрик, пустой список - все типы
+	Types []string
+	// NamePrefix префикс идентификатора метрики
+	NamePrefix string
+	// NameRegex регулярное выражение для идентификатора метрики
+	NameRegex string
+	// Labels метки, которые должны присутствовать в идентификаторе серии с точно такими значениями
+	Labels map[string]string
+	// Sort поле сортировки, по умолчанию SortByName
+	Sort string
+	// Desc сортировка по убыванию
+	Desc bool
+	// Limit размер страницы, по умолчанию DefaultListLimit
+	Limit int
+	// Cursor курсор из MetricsPage.NextCursor предыдущей страницы
+	Cursor string
+}
+
+// MetricsPage страница списка метрик
+type MetricsPage struct {
+	Metrics []models.Metrics `json:"metrics"`
+	// NextCursor курсор следующей страницы, пустой на последней странице
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// listCursor позиция последней метрики страницы: значение поля сортировки и ключ метрики
+type listCursor struct {
+	Value float64 `json:"v,omitempty"`
+	Name  string  `json:"n"`
+	Type  string  `json:"t"`
+}
+
+// Normalize проверяет запрос и подставляет значения по умолчанию
+func (q *MetricsQuery) Normalize() error {
+	for _, t := range q.Types {
+		if t != string(Gauge) && t != string(Counter) {
+			return fmt.Errorf("%w: unknown metric type %q", ErrInvalidQuery, t)
+		}
+	}
+	if q.NameRegex != "" {
+		if _, err := regexp.Compile(q.NameRegex); err != nil {
+			return fmt.Errorf("%w: name regex: %v", ErrInvalidQuery, err)
+		}
+	}
+	switch q.Sort {
+	case "":
+		q.Sort = SortByName
+	case SortByName, SortByType, SortByValue:
+	default:
+		return fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, q.Sort)
+	}
+	switch {
+	case q.Limit < 0:
+		return fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	case q.Limit == 0:
+		q.Limit = DefaultListLimit
+	case q.Limit > MaxListLimit:
+		q.Limit = MaxListLimit
+	}
+	if q.Cursor != "" {
+		if _, err := decodeCursor(q.Cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeCursor формирует непрозрачный курсор для метрики
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor разбирает курсор, сформированный encodeCursor
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return c, nil
+}
+
+// cursorFor возвращает курсор, указывающий на метрику m
+func cursorFor(m models.Metrics) listCursor {
+	return listCursor{Value: m.FloatValue(), Name: m.ID, Type: m.MType}
+}
+
+// labelPattern возвращает фрагмент идентификатора серии для метки, например code="200".
+// Значение экранируется так же, как в models.FormatSeriesID.
+func labelPattern(key, value string) string {
+	id := models.FormatSeriesID("", map[string]string{key: value})
+	return id[1 : len(id)-1]
+}
+
+// matchLabels проверяет, что серия содержит все метки запроса
+func matchLabels(id string, labels map[string]string) bool {
+	if len(labels) == 0 {
+		return true
+	}
+	_, seriesLabels := models.ParseSeriesID(id)
+	for k, v := range labels {
+		if got, ok := seriesLabels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// compare сравнивает метрику с позицией курсора в порядке сортировки по возрастанию
+func compare(sortBy string, a, b listCursor) int {
+	keys := func(c listCursor) (string, string) {
+		if sortBy == SortByType {
+			return c.Type, c.Name
+		}
+		return c.Name, c.Type
+	}
+
+	if sortBy == SortByValue && a.Value != b.Value {
+		if a.Value < b.Value {
+			return -1
+		}
+		return 1
+	}
+
+	a1, a2 := keys(a)
+	b1, b2 := keys(b)
+	if c := strings.Compare(a1, b1); c != 0 {
+		return c
+	}
+	return strings.Compare(a2, b2)
+}
+
+// HasFilter возвращает true, если в запросе задан хотя бы один фильтр
+func (q MetricsQuery) HasFilter() bool {
+	return len(q.Types) > 0 || q.NamePrefix != "" || q.NameRegex != "" || len(q.Labels) > 0
+}
+
+// matcher возвращает функцию проверки метрики по фильтрам нормализованного запроса
+func (q MetricsQuery) matcher() func(models.Metrics) bool {
+	var nameRegex *regexp.Regexp
+	if q.NameRegex != "" {
+		nameRegex = regexp.MustCompile(q.NameRegex)
+	}
+
+	return func(m models.Metrics) bool {
+		if len(q.Types) > 0 && !slices.Contains(q.Types, m.MType) {
+			return false
+		}
+		if !strings.HasPrefix(m.ID, q.NamePrefix) {
+			return false
+		}
+		if nameRegex != nil && !nameRegex.MatchString(m.ID) {
+			return false
+		}
+		return matchLabels(m.ID, q.Labels)
+	}
+}
+
+// QueryMetrics применяет запрос к полному списку метрик. Используется хранилищами,
+// которые держат метрики в памяти.
+func QueryMetrics(metrics []models.Metrics, query MetricsQuery) (MetricsPage, error) {
+	if err := query.Normalize(); err != nil {
+		return MetricsPage{}, err
+	}
+	match := query.matcher()
+
+	var after *listCursor
+	if query.Cursor != "" {
+		c, _ := decodeCursor(query.Cursor)
+		after = &c
+	}
+
+	direction := 1
+	if query.Desc {
+		direction = -1
+	}
+
+	filtered := make([]models.Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		if !match(m) {
+			continue
+		}
+		if after != nil && direction*compare(query.Sort, cursorFor(m), *after) <= 0 {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return direction*compare(query.Sort, cursorFor(filtered[i]), cursorFor(filtered[j])) < 0
+	})
+
+	page := MetricsPage{Metrics: filtered}
+	if len(filtered) > query.Limit {
+		page.Metrics = filtered[:query.Limit]
+		page.NextCursor = encodeCursor(cursorFor(page.Metrics[query.Limit-1]))
+	}
+	return page, nil
+}
@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+func newQueryStorage() *MemStorage {
+	s := NewMemStorage()
+	s.UpdateGauge("Alloc", 30)
+	s.UpdateGauge("HeapAlloc", 10)
+	s.UpdateGauge("HeapInuse", 20)
+	s.UpdateCounter("PollCount", 5)
+	s.UpdateCounter(`http_requests{code="200",method="GET"}`, 7)
+	s.UpdateCounter(`http_requests{code="500",method="GET"}`, 1)
+	return s
+}
+
+func ids(metrics []models.Metrics) []string {
+	result := make([]string, len(metrics))
+	for i, m := range metrics {
+		result[i] = m.ID
+	}
+	return result
+}
+
+func TestMemStorage_ListMetrics(t *testing.T) {
+	s := newQueryStorage()
+
+	tests := []struct {
+		name  string
+		query MetricsQuery
+		want  []string
+	}{
+		{
+			name:  "все метрики по имени",
+			query: MetricsQuery{},
+			want:  []string{"Alloc", "HeapAlloc", "HeapInuse", "PollCount", `http_requests{code="200",method="GET"}`, `http_requests{code="500",method="GET"}`},
+		},
+		{
+			name:  "по типу",
+			query: MetricsQuery{Types: []string{"gauge"}},
+			want:  []string{"Alloc", "HeapAlloc", "HeapInuse"},
+		},
+		{
+			name:  "по префиксу",
+			query: MetricsQuery{NamePrefix: "Heap"},
+			want:  []string{"HeapAlloc", "HeapInuse"},
+		},
+		{
+			name:  "по регулярному выражению",
+			query: MetricsQuery{NameRegex: "Alloc$"},
+			want:  []string{"Alloc", "HeapAlloc"},
+		},
+		{
+			name:  "по метке",
+			query: MetricsQuery{Labels: map[string]string{"code": "500"}},
+			want:  []string{`http_requests{code="500",method="GET"}`},
+		},
+		{
+			name:  "по значению по убыванию",
+			query: MetricsQuery{Sort: SortByValue, Desc: true, Limit: 3},
+			want:  []string{"Alloc", "HeapInuse", "HeapAlloc"},
+		},
+		{
+			name:  "сортировка по типу",
+			query: MetricsQuery{Sort: SortByType, Limit: 2},
+			want:  []string{"PollCount", `http_requests{code="200",method="GET"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.ListMetrics(tt.query)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if got := ids(page.Metrics); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ожидалось %v, получено %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestMemStorage_ListMetricsPagination проверяет, что страницы по курсору покрывают
+// все метрики без повторов при любой сортировке
+func TestMemStorage_ListMetricsPagination(t *testing.T) {
+	s := newQueryStorage()
+
+	for _, sortBy := range []string{SortByName, SortByType, SortByValue} {
+		for _, desc := range []bool{false, true} {
+			full, err := s.ListMetrics(MetricsQuery{Sort: sortBy, Desc: desc})
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+
+			var paged []string
+			query := MetricsQuery{Sort: sortBy, Desc: desc, Limit: 4}
+			for i := 0; ; i++ {
+				page, err := s.ListMetrics(query)
+				if err != nil {
+					t.Fatalf("Неожиданная ошибка: %v", err)
+				}
+				paged = append(paged, ids(page.Metrics)...)
+				if page.NextCursor == "" {
+					break
+				}
+				if i > 5 {
+					t.Fatal("Пагинация не завершилась")
+				}
+				query.Cursor = page.NextCursor
+			}
+
+			if want := ids(full.Metrics); !reflect.DeepEqual(paged, want) {
+				t.Errorf("sort=%s desc=%v: ожидалось %v, получено %v", sortBy, desc, want, paged)
+			}
+		}
+	}
+}
+
+func TestMetricsQuery_Normalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		query MetricsQuery
+	}{
+		{name: "неизвестный тип", query: MetricsQuery{Types: []string{"histogram"}}},
+		{name: "некорректное выражение", query: MetricsQuery{NameRegex: "("}},
+		{name: "неизвестная сортировка", query: MetricsQuery{Sort: "created"}},
+		{name: "отрицательный лимит", query: MetricsQuery{Limit: -1}},
+		{name: "повреждённый курсор", query: MetricsQuery{Cursor: "!!!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.query.Normalize(); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("Ожидалась ErrInvalidQuery, получено %v", err)
+			}
+		})
+	}
+
+	q := MetricsQuery{Limit: MaxListLimit + 1}
+	if err := q.Normalize(); err != nil || q.Limit != MaxListLimit || q.Sort != SortByName {
+		t.Errorf("Ожидались значения по умолчанию, получено %+v, %v", q, err)
+	}
+}
+
+func TestBuildListQuery(t *testing.T) {
+	query := MetricsQuery{
+		Types:      []string{"counter"},
+		NamePrefix: "http_",
+		NameRegex:  "^http",
+		Labels:     map[string]string{"code": "200"},
+		Sort:       SortByValue,
+		Desc:       true,
+		Limit:      10,
+		Cursor:     encodeCursor(listCursor{Value: 7, Name: "b", Type: "counter"}),
+	}
+
+	sql, args := buildListQuery(query)
+
+	for _, part := range []string{
+		"type = ANY($1)",
+		`name LIKE $2 ESCAPE '\'`,
+		"name ~ $3",
+		"strpos(name, '{' || $4) > 0 OR strpos(name, ',' || $4) > 0",
+		`(COALESCE(value, delta::double precision), name COLLATE "C", type COLLATE "C") < ($5, $6, $7)`,
+		`ORDER BY COALESCE(value, delta::double precision) DESC, name COLLATE "C" DESC, type COLLATE "C" DESC`,
+		"LIMIT $8",
+	} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("Запрос должен содержать %q: %s", part, sql)
+		}
+	}
+
+	want := []any{[]string{"counter"}, `http\_%`, "^http", `code="200"`, 7.0, "b", "counter", 11}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Ожидались аргументы %v, получено %v", want, args)
+	}
+}
+
+func TestMemStorage_DeleteMetric(t *testing.T) {
+	s := newQueryStorage()
+
+	if err := s.DeleteMetric("gauge", "Alloc"); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if _, err := s.GetGauge("Alloc"); err == nil {
+		t.Error("Метрика должна быть удалена")
+	}
+	if err := s.DeleteMetric("gauge", "Alloc"); !errors.Is(err, ErrMetricNotFound) {
+		t.Errorf("Ожидалась ErrMetricNotFound, получено %v", err)
+	}
+	// Counter с тем же именем не затрагивается удалением gauge
+	if err := s.DeleteMetric("counter", "HeapAlloc"); !errors.Is(err, ErrMetricNotFound) {
+		t.Errorf("Ожидалась ErrMetricNotFound, получено %v", err)
+	}
+	if err := s.DeleteMetric("histogram", "HeapAlloc"); err == nil || errors.Is(err, ErrMetricNotFound) {
+		t.Errorf("Ожидалась ошибка неизвестного типа, получено %v", err)
+	}
+}
+
+func TestMemStorage_DeleteMetrics(t *testing.T) {
+	s := newQueryStorage()
+
+	deleted, err := s.DeleteMetrics(MetricsQuery{Types: []string{"counter"}, Labels: map[string]string{"method": "GET"}})
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Ожидалось удаление 2 метрик, удалено %d", deleted)
+	}
+
+	page, err := s.ListMetrics(MetricsQuery{})
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if want := []string{"Alloc", "HeapAlloc", "HeapInuse", "PollCount"}; !reflect.DeepEqual(ids(page.Metrics), want) {
+		t.Errorf("Ожидалось %v, получено %v", want, ids(page.Metrics))
+	}
+
+	if _, err := s.DeleteMetrics(MetricsQuery{NameRegex: "("}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Ожидалась ErrInvalidQuery, получено %v", err)
+	}
+}
+
+func TestMemStorage_ResetCounter(t *testing.T) {
+	s := newQueryStorage()
+
+	if err := s.ResetCounter("PollCount"); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if v, err := s.GetCounter("PollCount"); err != nil || v != 0 {
+		t.Errorf("Ожидалось значение 0, получено %d, %v", v, err)
+	}
+
+	s.UpdateCounter("PollCount", 2)
+	if v, _ := s.GetCounter("PollCount"); v != 2 {
+		t.Errorf("После сброса counter должен накапливаться с нуля, получено %d", v)
+	}
+
+	if err := s.ResetCounter("Alloc"); !errors.Is(err, ErrMetricNotFound) {
+		t.Errorf("Ожидалась ErrMetricNotFound для gauge, получено %v", err)
+	}
+}
+
+func TestMemStorage_ExpireMetrics(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemStorage()
+	s.now = func() time.Time { return now }
+
+	s.UpdateGauge("stale", 1)
+	s.UpdateCounter("stale", 1)
+	now = now.Add(time.Hour)
+	s.UpdateGauge("fresh", 2)
+	v := 3.0
+	if err := s.UpdateBatch([]models.Metrics{{ID: "batch", MType: "gauge", Value: &v}}); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	now = now.Add(time.Minute)
+
+	expired, err := s.ExpireMetrics("gauge", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if expired != 1 {
+		t.Errorf("Ожидалось удаление 1 gauge, удалено %d", expired)
+	}
+	if _, ok := s.GetAllGauges()["stale"]; ok {
+		t.Error("Устаревший gauge должен быть удалён")
+	}
+	if len(s.GetAllGauges()) != 2 {
+		t.Errorf("Обновлённые gauge должны остаться: %v", s.GetAllGauges())
+	}
+	// TTL применяется только к указанному типу
+	if _, err := s.GetCounter("stale"); err != nil {
+		t.Error("Counter не должен удаляться при истечении TTL gauge")
+	}
+
+	// Обновление продлевает жизнь метрики
+	s.UpdateCounter("stale", 1)
+	now = now.Add(10 * time.Minute)
+	if expired, _ := s.ExpireMetrics("counter", 30*time.Minute); expired != 0 {
+		t.Errorf("Обновлённый counter не должен удаляться, удалено %d", expired)
+	}
+
+	if _, err := s.ExpireMetrics("histogram", time.Minute); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного типа")
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import "time"
+
+// RollupPoint агрегат значений серии за интервал tier, начинающийся в Start
+type RollupPoint struct {
+	Type  string
+	ID    string
+	Start time.Time
+	// Count количество исходных значений в интервале
+	Count int64
+	// Min и Max наименьшее и наибольшее значение: gauge или накопленное значение counter
+	Min float64
+	Max float64
+	// Sum сумма значений gauge или прирост counter за интервал
+	Sum float64
+	// Last последнее значение в интервале
+	Last float64
+}
+
+// RollupStore хранит агрегаты значений метрик по tier (raw, 1m, 1h, 1d).
+// Реализуется MemStorage и DatabaseStorage.
+type RollupStore interface {
+	// WriteRollups записывает точки tier, заменяя точки с теми же типом, именем и началом
+	WriteRollups(tier string, points []RollupPoint) error
+
+	// ReadRollups возвращает точки серии с началом в [from, to) по возрастанию времени
+	ReadRollups(tier, mType, id string, from, to time.Time) ([]RollupPoint, error)
+
+	// ReadRollupRange возвращает точки всех серий tier с началом в [from, to),
+	// упорядоченные по типу, имени и времени
+	ReadRollupRange(tier string, from, to time.Time) ([]RollupPoint, error)
+
+	// DeleteRollups удаляет точки tier с началом раньше before и возвращает их количество
+	DeleteRollups(tier string, before time.Time) (int, error)
+}
+
+// rollupKey серия в хранилище агрегатов MemStorage
+type rollupKey struct {
+	mType string
+	id    string
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemStorage_Rollups(t *testing.T) {
+	s := NewMemStorage()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	point := func(mType, id string, minute int, last float64) RollupPoint {
+		return RollupPoint{Type: mType, ID: id, Start: base.Add(time.Duration(minute) * time.Minute), Count: 1,
+			Min: last, Max: last, Sum: last, Last: last}
+	}
+
+	// Точки записываются не по порядку, точка с тем же началом заменяется
+	if err := s.WriteRollups("1m", []RollupPoint{
+		point("gauge", "Alloc", 2, 3),
+		point("gauge", "Alloc", 0, 1),
+		point("counter", "PollCount", 1, 5),
+		point("gauge", "Alloc", 1, 2),
+		point("gauge", "Alloc", 1, 20),
+	}); err != nil {
+		t.Fatalf("WriteRollups() error: %v", err)
+	}
+
+	got, err := s.ReadRollups("1m", "gauge", "Alloc", base, base.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("ReadRollups() error: %v", err)
+	}
+	if len(got) != 2 || got[0].Last != 1 || got[1].Last != 20 {
+		t.Errorf("Ожидались точки 1 и 20 за [0m, 2m), получено %+v", got)
+	}
+
+	all, err := s.ReadRollupRange("1m", base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ReadRollupRange() error: %v", err)
+	}
+	var order []string
+	for _, p := range all {
+		order = append(order, p.Type+"/"+p.ID+"@"+p.Start.Format("04"))
+	}
+	want := []string{"counter/PollCount@01", "gauge/Alloc@00", "gauge/Alloc@01", "gauge/Alloc@02"}
+	if len(order) != len(want) {
+		t.Fatalf("Ожидалось %v, получено %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("Ожидалось %v, получено %v", want, order)
+			break
+		}
+	}
+
+	// Другой tier хранится отдельно
+	if other, _ := s.ReadRollupRange("1h", base, base.Add(time.Hour)); len(other) != 0 {
+		t.Errorf("Tier 1h должен быть пустым, получено %+v", other)
+	}
+
+	deleted, err := s.DeleteRollups("1m", base.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteRollups() error: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("Ожидалось удаление 3 точек, удалено %d", deleted)
+	}
+	if rest, _ := s.ReadRollupRange("1m", time.Time{}, base.Add(time.Hour)); len(rest) != 1 || rest[0].Last != 3 {
+		t.Errorf("Должна остаться одна точка Alloc@02, получено %+v", rest)
+	}
+}
+
+func TestRollupInsertQuery(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []RollupPoint{
+		{Type: "gauge", ID: "a", Start: start, Count: 1, Min: 1, Max: 1, Sum: 1, Last: 1},
+		{Type: "counter", ID: "b", Start: start, Count: 2, Sum: 5, Last: 10},
+	}
+
+	query, args := rollupInsertQuery("raw", points)
+	if !strings.Contains(query, "($1, $2, $3, $4, $5, $6, $7, $8, $9), ($10, $11, $12, $13, $14, $15, $16, $17, $18) ON CONFLICT") {
+		t.Errorf("Ожидался INSERT из двух строк, получено %s", query)
+	}
+	if len(args) != 18 || args[0] != "raw" || args[10] != "counter" || args[11] != "b" {
+		t.Errorf("Неверные параметры запроса: %v", args)
+	}
+}
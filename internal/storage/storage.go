@@ -1,6 +1,10 @@
 package storage
 
-import "github.com/ViktorBystrov72/go-metrics/internal/models"
+import (
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
 
 // Storage интерфейс для хранения метрик
 type Storage interface {
@@ -37,6 +41,24 @@ type Storage interface {
 	// IsAvailable возвращает true, если хранилище доступно
 	IsAvailable() bool
 
-	// UpdateBatch обновляет множество метрик в одной операции
+	// UpdateBatch обновляет множество метрик в одной операции атомарно: если в батче есть
+	// некорректная метрика (ErrInvalidMetric) или произошла ошибка, не применяется ни одна метрика
 	UpdateBatch(metrics []models.Metrics) error
+
+	// ListMetrics возвращает страницу метрик, отобранных и отсортированных по запросу
+	ListMetrics(query MetricsQuery) (MetricsPage, error)
+
+	// DeleteMetric удаляет метрику, возвращает ErrMetricNotFound, если её нет
+	DeleteMetric(mType, name string) error
+
+	// DeleteMetrics удаляет метрики, подходящие под фильтры запроса, и возвращает их количество.
+	// Сортировка, лимит и курсор запроса не учитываются.
+	DeleteMetrics(query MetricsQuery) (int, error)
+
+	// ResetCounter обнуляет counter метрику, возвращает ErrMetricNotFound, если её нет
+	ResetCounter(name string) error
+
+	// ExpireMetrics удаляет метрики типа mType, не обновлявшиеся дольше ttl,
+	// и возвращает их количество
+	ExpireMetrics(mType string, ttl time.Duration) (int, error)
 }
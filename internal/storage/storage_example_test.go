@@ -231,7 +231,7 @@ func ExampleMemStorage_UpdateBatch_withErrors() {
 	fmt.Printf("Update successful\n")
 
 	// Output:
-	// Error: unknown metric type: invalid_type
+	// Error: invalid metric: unknown metric type "invalid_type" for metric invalid_metric
 }
 
 // ExampleMemStorage_Ping демонстрирует проверку доступности хранилища.
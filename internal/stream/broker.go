@@ -0,0 +1,279 @@
+// Package stream рассылает обновления метрик подписчикам в реальном времени.
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// Политики обработки переполненного буфера подписчика
+const (
+	// DropOldest удаляет самое старое обновление из буфера, чтобы освободить место для нового
+	DropOldest = "drop-oldest"
+	// DropNewest отбрасывает новое обновление, буфер не меняется
+	DropNewest = "drop-newest"
+	// Disconnect закрывает подписку медленного клиента
+	Disconnect = "disconnect"
+)
+
+// DefaultBufferSize размер буфера подписчика по умолчанию
+const DefaultBufferSize = 256
+
+// ValidateDropPolicy проверяет название политики переполнения буфера
+func ValidateDropPolicy(policy string) error {
+	switch policy {
+	case "", DropOldest, DropNewest, Disconnect:
+		return nil
+	}
+	return fmt.Errorf("неизвестная политика переполнения буфера %q, ожидается %s, %s или %s",
+		policy, DropOldest, DropNewest, Disconnect)
+}
+
+// Filter отбирает обновления для подписчика. Пустые списки не ограничивают выборку.
+type Filter struct {
+	// Names имена метрик; имя с суффиксом "*" задаёт префикс
+	Names []string
+	// Types типы метрик: gauge, counter
+	Types []string
+}
+
+// Match проверяет, подходит ли метрика под фильтр
+func (f Filter) Match(m models.Metrics) bool {
+	if len(f.Types) > 0 && !contains(f.Types, m.MType) {
+		return false
+	}
+	if len(f.Names) == 0 {
+		return true
+	}
+	for _, name := range f.Names {
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			if strings.HasPrefix(m.ID, prefix) {
+				return true
+			}
+		} else if m.ID == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription подписка на обновления метрик
+type Subscription struct {
+	filter Filter
+	policy string
+	// client подписка клиента SSE, закрывается CloseClients
+	client bool
+	ch     chan models.Metrics
+	done   chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped uint64
+}
+
+// Updates возвращает канал обновлений. Канал закрывается при отписке,
+// остановке Broker или отключении медленного клиента.
+func (s *Subscription) Updates() <-chan models.Metrics {
+	return s.ch
+}
+
+// Done закрывается вместе с подпиской
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// TakeDropped возвращает количество отброшенных обновлений с прошлого вызова
+func (s *Subscription) TakeDropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped
+}
+
+// send передаёт обновление подписчику без блокировки с учётом политики переполнения.
+// Возвращает false, если подписку нужно закрыть.
+func (s *Subscription) send(m models.Metrics) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+
+	select {
+	case s.ch <- m:
+		return true
+	default:
+	}
+
+	switch s.policy {
+	case Disconnect:
+		return false
+	case DropNewest:
+		s.dropped++
+	default:
+		// Отправка выполняется под блокировкой подписки, поэтому после чтения место освободится
+		select {
+		case <-s.ch:
+		default:
+		}
+		s.ch <- m
+		s.dropped++
+	}
+	return true
+}
+
+// close закрывает каналы подписки
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.ch)
+	close(s.done)
+}
+
+// Broker рассылает опубликованные обновления метрик подписчикам
+type Broker struct {
+	bufferSize int
+	policy     string
+
+	mu            sync.RWMutex
+	subs          map[*Subscription]struct{}
+	closed        bool
+	clientsClosed bool
+}
+
+// NewBroker создаёт Broker. bufferSize - размер буфера каждого подписчика,
+// policy - политика обработки переполненного буфера.
+func NewBroker(bufferSize int, policy string) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	if policy == "" {
+		policy = DropOldest
+	}
+	return &Broker{
+		bufferSize: bufferSize,
+		policy:     policy,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe создаёт подписку с фильтром. После остановки Broker
+// возвращается уже закрытая подписка.
+func (b *Broker) Subscribe(filter Filter) *Subscription {
+	return b.subscribe(filter, false)
+}
+
+// SubscribeClient создаёт подписку внешнего клиента, например SSE соединения.
+// Такие подписки закрываются CloseClients, а после него создаются уже закрытыми.
+func (b *Broker) SubscribeClient(filter Filter) *Subscription {
+	return b.subscribe(filter, true)
+}
+
+func (b *Broker) subscribe(filter Filter, client bool) *Subscription {
+	sub := &Subscription{
+		filter: filter,
+		policy: b.policy,
+		client: client,
+		ch:     make(chan models.Metrics, b.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || (client && b.clientsClosed) {
+		sub.close()
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe удаляет подписку и закрывает её канал
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+	sub.close()
+}
+
+// Publish рассылает обновления подписчикам без блокировки
+func (b *Broker) Publish(metrics ...models.Metrics) {
+	var slow []*Subscription
+
+	b.mu.RLock()
+	for sub := range b.subs {
+		for _, m := range metrics {
+			if !sub.filter.Match(m) {
+				continue
+			}
+			if !sub.send(m) {
+				slow = append(slow, sub)
+				break
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range slow {
+		b.Unsubscribe(sub)
+	}
+}
+
+// Subscribers возвращает количество активных подписок
+func (b *Broker) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// CloseClients закрывает подписки клиентов, созданные SubscribeClient. Остальные
+// подписки продолжают получать обновления до Close: при остановке сервера потоковые
+// соединения закрываются до остановки HTTP сервера, а webhooks получают обновления
+// запросов, которые ещё обрабатываются.
+func (b *Broker) CloseClients() {
+	var clients []*Subscription
+
+	b.mu.Lock()
+	b.clientsClosed = true
+	for sub := range b.subs {
+		if sub.client {
+			clients = append(clients, sub)
+			delete(b.subs, sub)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, sub := range clients {
+		sub.close()
+	}
+}
+
+// Close закрывает все подписки. Новые подписки после этого сразу закрыты.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = make(map[*Subscription]struct{})
+	b.closed = true
+	b.mu.Unlock()
+
+	for sub := range subs {
+		sub.close()
+	}
+}
@@ -0,0 +1,162 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+func gauge(name string, v float64) models.Metrics {
+	return models.Metrics{ID: name, MType: "gauge", Value: &v}
+}
+
+func counter(name string, d int64) models.Metrics {
+	return models.Metrics{ID: name, MType: "counter", Delta: &d}
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		metric   models.Metrics
+		expected bool
+	}{
+		{name: "empty filter", filter: Filter{}, metric: gauge("Alloc", 1), expected: true},
+		{name: "exact name", filter: Filter{Names: []string{"Alloc"}}, metric: gauge("Alloc", 1), expected: true},
+		{name: "other name", filter: Filter{Names: []string{"Alloc"}}, metric: gauge("HeapAlloc", 1), expected: false},
+		{name: "prefix", filter: Filter{Names: []string{"Heap*"}}, metric: gauge("HeapAlloc", 1), expected: true},
+		{name: "type", filter: Filter{Types: []string{"counter"}}, metric: gauge("Alloc", 1), expected: false},
+		{name: "name and type", filter: Filter{Names: []string{"Poll*"}, Types: []string{"counter"}}, metric: counter("PollCount", 1), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.metric); got != tt.expected {
+				t.Errorf("Match() = %v, ожидалось %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBrokerPublishFiltered(t *testing.T) {
+	b := NewBroker(10, DropOldest)
+	all := b.Subscribe(Filter{})
+	counters := b.Subscribe(Filter{Types: []string{"counter"}})
+
+	b.Publish(gauge("Alloc", 1), counter("PollCount", 2))
+
+	if len(all.Updates()) != 2 {
+		t.Errorf("Подписчик без фильтра должен получить 2 обновления, получено %d", len(all.Updates()))
+	}
+	if len(counters.Updates()) != 1 {
+		t.Fatalf("Подписчик на counter должен получить 1 обновление, получено %d", len(counters.Updates()))
+	}
+	if m := <-counters.Updates(); m.ID != "PollCount" {
+		t.Errorf("Ожидалась метрика PollCount, получено %s", m.ID)
+	}
+}
+
+func TestBrokerDropPolicies(t *testing.T) {
+	t.Run("drop oldest", func(t *testing.T) {
+		b := NewBroker(2, DropOldest)
+		sub := b.Subscribe(Filter{})
+		b.Publish(gauge("a", 1), gauge("b", 2), gauge("c", 3))
+
+		if m := <-sub.Updates(); m.ID != "b" {
+			t.Errorf("Самое старое обновление должно быть отброшено, первым получено %s", m.ID)
+		}
+		if dropped := sub.TakeDropped(); dropped != 1 {
+			t.Errorf("Ожидалось 1 отброшенное обновление, получено %d", dropped)
+		}
+		if dropped := sub.TakeDropped(); dropped != 0 {
+			t.Errorf("Счётчик отброшенных обновлений должен сбрасываться, получено %d", dropped)
+		}
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		b := NewBroker(2, DropNewest)
+		sub := b.Subscribe(Filter{})
+		b.Publish(gauge("a", 1), gauge("b", 2), gauge("c", 3))
+
+		if m := <-sub.Updates(); m.ID != "a" {
+			t.Errorf("Новое обновление должно быть отброшено, первым получено %s", m.ID)
+		}
+		if dropped := sub.TakeDropped(); dropped != 1 {
+			t.Errorf("Ожидалось 1 отброшенное обновление, получено %d", dropped)
+		}
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		b := NewBroker(1, Disconnect)
+		sub := b.Subscribe(Filter{})
+		b.Publish(gauge("a", 1), gauge("b", 2))
+
+		select {
+		case <-sub.Done():
+		default:
+			t.Fatal("Подписка медленного клиента должна быть закрыта")
+		}
+		if b.Subscribers() != 0 {
+			t.Errorf("Закрытая подписка должна быть удалена, подписок %d", b.Subscribers())
+		}
+	})
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(1, DropOldest)
+	sub := b.Subscribe(Filter{})
+	b.Close()
+
+	if _, ok := <-sub.Updates(); ok {
+		t.Error("Канал подписки должен быть закрыт")
+	}
+
+	late := b.Subscribe(Filter{})
+	select {
+	case <-late.Done():
+	default:
+		t.Error("Подписка после остановки Broker должна быть закрыта")
+	}
+
+	// Публикация и отписка после остановки не должны паниковать
+	b.Publish(gauge("a", 1))
+	b.Unsubscribe(sub)
+}
+
+func TestBrokerCloseClients(t *testing.T) {
+	b := NewBroker(1, DropOldest)
+	client := b.SubscribeClient(Filter{})
+	internal := b.Subscribe(Filter{})
+	b.CloseClients()
+
+	if _, ok := <-client.Updates(); ok {
+		t.Error("Подписка клиента должна быть закрыта")
+	}
+	select {
+	case <-b.SubscribeClient(Filter{}).Done():
+	default:
+		t.Error("Подписка клиента после CloseClients должна быть закрыта")
+	}
+
+	// Остальные подписки получают обновления до Close
+	b.Publish(gauge("a", 1))
+	if m, ok := <-internal.Updates(); !ok || m.ID != "a" {
+		t.Errorf("Подписка должна получать обновления после CloseClients, получено %v, %v", m, ok)
+	}
+	if late := b.Subscribe(Filter{}); b.Subscribers() != 2 {
+		t.Errorf("Ожидалось 2 подписки, получено %d", b.Subscribers())
+	} else {
+		b.Unsubscribe(late)
+	}
+}
+
+func TestValidateDropPolicy(t *testing.T) {
+	for _, policy := range []string{"", DropOldest, DropNewest, Disconnect} {
+		if err := ValidateDropPolicy(policy); err != nil {
+			t.Errorf("ValidateDropPolicy(%q) неожиданная ошибка: %v", policy, err)
+		}
+	}
+	if err := ValidateDropPolicy("block"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной политики")
+	}
+}
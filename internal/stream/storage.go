@@ -0,0 +1,50 @@
+package stream
+
+import (
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+// PublishingStorage декоратор storage.Storage, публикующий каждое
+// успешно применённое обновление в Broker
+type PublishingStorage struct {
+	storage.Storage
+	broker *Broker
+}
+
+// NewPublishingStorage оборачивает хранилище
+func NewPublishingStorage(s storage.Storage, broker *Broker) *PublishingStorage {
+	return &PublishingStorage{Storage: s, broker: broker}
+}
+
+// Unwrap возвращает исходное хранилище
+func (p *PublishingStorage) Unwrap() storage.Storage {
+	return p.Storage
+}
+
+// UpdateGauge обновляет gauge метрику и публикует новое значение
+func (p *PublishingStorage) UpdateGauge(name string, value float64) {
+	p.Storage.UpdateGauge(name, value)
+	p.broker.Publish(models.Metrics{ID: name, MType: string(storage.Gauge), Value: &value})
+}
+
+// UpdateCounter обновляет counter метрику и публикует применённое приращение
+func (p *PublishingStorage) UpdateCounter(name string, value int64) {
+	p.Storage.UpdateCounter(name, value)
+	p.broker.Publish(models.Metrics{ID: name, MType: string(storage.Counter), Delta: &value})
+}
+
+// UpdateBatch обновляет метрики и публикует их, если обновление выполнено успешно
+func (p *PublishingStorage) UpdateBatch(metrics []models.Metrics) error {
+	if err := p.Storage.UpdateBatch(metrics); err != nil {
+		return err
+	}
+
+	published := make([]models.Metrics, len(metrics))
+	for i, m := range metrics {
+		m.Hash = ""
+		published[i] = m
+	}
+	p.broker.Publish(published...)
+	return nil
+}
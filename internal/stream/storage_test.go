@@ -0,0 +1,51 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/storage"
+)
+
+func TestPublishingStorage(t *testing.T) {
+	b := NewBroker(10, DropOldest)
+	sub := b.Subscribe(Filter{})
+	s := NewPublishingStorage(storage.NewMemStorage(), b)
+
+	s.UpdateGauge("Alloc", 1.5)
+	s.UpdateCounter("PollCount", 3)
+
+	if v, _ := s.GetGauge("Alloc"); v != 1.5 {
+		t.Errorf("Обновление должно применяться к хранилищу, получено %v", v)
+	}
+
+	m := <-sub.Updates()
+	if m.ID != "Alloc" || m.Value == nil || *m.Value != 1.5 {
+		t.Errorf("Неверное обновление gauge: %+v", m)
+	}
+	m = <-sub.Updates()
+	if m.ID != "PollCount" || m.Delta == nil || *m.Delta != 3 {
+		t.Errorf("Неверное обновление counter: %+v", m)
+	}
+
+	delta := int64(1)
+	err := s.UpdateBatch([]models.Metrics{{ID: "PollCount", MType: "counter", Delta: &delta, Hash: "abc"}})
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if m := <-sub.Updates(); m.Hash != "" {
+		t.Error("Подпись агента не должна публиковаться")
+	}
+
+	// Неуспешное обновление не публикуется
+	if err := s.UpdateBatch([]models.Metrics{{ID: "bad", MType: "unknown"}}); err == nil {
+		t.Fatal("Ожидалась ошибка")
+	}
+	if len(sub.Updates()) != 0 {
+		t.Error("Неуспешное обновление не должно публиковаться")
+	}
+
+	if s.Unwrap() == nil {
+		t.Error("Unwrap должен возвращать исходное хранилище")
+	}
+}
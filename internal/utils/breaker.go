@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается, когда circuit breaker открыт и вызовы отклоняются без выполнения
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState состояние circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed вызовы выполняются, ошибки подсчитываются
+	CircuitClosed CircuitState = iota
+	// CircuitOpen вызовы отклоняются до истечения OpenTimeout
+	CircuitOpen
+	// CircuitHalfOpen разрешено ограниченное число пробных вызовов
+	CircuitHalfOpen
+)
+
+// String возвращает название состояния
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig содержит настройки circuit breaker
+type CircuitBreakerConfig struct {
+	Name             string        // Имя для логов
+	FailureThreshold int           // Количество ошибок подряд для перехода в open
+	OpenTimeout      time.Duration // Время в состоянии open до перехода в half-open
+	HalfOpenMaxCalls int           // Количество одновременных пробных вызовов в half-open
+}
+
+// DefaultCircuitBreakerConfig возвращает стандартные настройки circuit breaker
+func DefaultCircuitBreakerConfig(name string) CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		Name:             name,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenMaxCalls: 1,
+	}
+}
+
+// CircuitBreaker защищает ресурс от повторных обращений, пока он недоступен.
+// Может разделяться между несколькими горутинами и вызовами Retry.
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mu               sync.Mutex
+	state            CircuitState
+	failures         int
+	openedAt         time.Time
+	halfOpenInFlight int
+}
+
+// NewCircuitBreaker создаёт circuit breaker в состоянии closed
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	if config.HalfOpenMaxCalls <= 0 {
+		config.HalfOpenMaxCalls = 1
+	}
+	return &CircuitBreaker{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// State возвращает текущее состояние с учётом истечения OpenTimeout
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.advance()
+	return cb.state
+}
+
+// Allow проверяет, можно ли выполнить вызов. Если вызов разрешён,
+// его результат должен быть передан в Record.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.advance()
+
+	switch cb.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if cb.halfOpenInFlight >= cb.config.HalfOpenMaxCalls {
+			return ErrCircuitOpen
+		}
+		cb.halfOpenInFlight++
+	}
+	return nil
+}
+
+// Record учитывает результат разрешённого вызова. failed должен быть true
+// только для ошибок недоступности ресурса, а не для ошибок в данных запроса.
+func (cb *CircuitBreaker) Record(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitHalfOpen && cb.halfOpenInFlight > 0 {
+		cb.halfOpenInFlight--
+	}
+
+	if !failed {
+		if cb.state != CircuitClosed {
+			log.Printf("Circuit breaker %s: %s -> closed", cb.config.Name, cb.state)
+		}
+		cb.state = CircuitClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.config.FailureThreshold {
+		if cb.state != CircuitOpen {
+			log.Printf("Circuit breaker %s: %s -> open после %d ошибок подряд", cb.config.Name, cb.state, cb.failures)
+		}
+		cb.state = CircuitOpen
+		cb.openedAt = cb.now()
+		cb.halfOpenInFlight = 0
+	}
+}
+
+// advance переводит breaker из open в half-open по истечении OpenTimeout.
+// Вызывается под блокировкой.
+func (cb *CircuitBreaker) advance() {
+	if cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.config.OpenTimeout {
+		cb.state = CircuitHalfOpen
+		cb.halfOpenInFlight = 0
+	}
+}
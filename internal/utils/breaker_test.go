@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestBreaker создаёт breaker с управляемым временем
+func newTestBreaker(threshold int, timeout time.Duration) (*CircuitBreaker, *time.Time) {
+	now := time.Now()
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		Name:             "test",
+		FailureThreshold: threshold,
+		OpenTimeout:      timeout,
+		HalfOpenMaxCalls: 1,
+	})
+	cb.now = func() time.Time { return now }
+	return cb, &now
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	cb, now := newTestBreaker(2, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if err := cb.Allow(); err != nil {
+			t.Fatalf("Вызов %d должен быть разрешён: %v", i, err)
+		}
+		cb.Record(true)
+	}
+	if cb.State() != CircuitOpen {
+		t.Fatalf("Ожидалось состояние open, получено %s", cb.State())
+	}
+	if err := cb.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Ожидалась ошибка ErrCircuitOpen, получено %v", err)
+	}
+
+	// По истечении таймаута разрешается один пробный вызов
+	*now = now.Add(time.Minute)
+	if cb.State() != CircuitHalfOpen {
+		t.Fatalf("Ожидалось состояние half-open, получено %s", cb.State())
+	}
+	if err := cb.Allow(); err != nil {
+		t.Fatalf("Пробный вызов должен быть разрешён: %v", err)
+	}
+	if err := cb.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("Второй пробный вызов должен быть отклонён")
+	}
+
+	// Ошибка пробного вызова снова открывает breaker
+	cb.Record(true)
+	if cb.State() != CircuitOpen {
+		t.Fatalf("Ожидалось состояние open, получено %s", cb.State())
+	}
+
+	// Успешный пробный вызов закрывает breaker
+	*now = now.Add(time.Minute)
+	if err := cb.Allow(); err != nil {
+		t.Fatalf("Пробный вызов должен быть разрешён: %v", err)
+	}
+	cb.Record(false)
+	if cb.State() != CircuitClosed {
+		t.Fatalf("Ожидалось состояние closed, получено %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	cb, _ := newTestBreaker(2, time.Minute)
+
+	cb.Record(true)
+	cb.Record(false)
+	cb.Record(true)
+	if cb.State() != CircuitClosed {
+		t.Errorf("Ошибки не подряд не должны открывать breaker, состояние %s", cb.State())
+	}
+}
+
+func TestRetryWithOpenBreaker(t *testing.T) {
+	cb, _ := newTestBreaker(1, time.Minute)
+	config := RetryConfig{MaxAttempts: 3, Delays: []time.Duration{time.Millisecond, time.Millisecond}}.WithBreaker(cb)
+
+	attempts := 0
+	err := Retry(context.Background(), config, func() error {
+		attempts++
+		return NewRetriableError(errors.New("connection refused"))
+	})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Ожидалась ошибка ErrCircuitOpen, получено %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("После открытия breaker попытки должны прекратиться, выполнено %d", attempts)
+	}
+
+	// Пока breaker открыт, функция не вызывается
+	err = Retry(context.Background(), config, func() error {
+		attempts++
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) || attempts != 1 {
+		t.Errorf("Ожидался быстрый отказ без вызова функции, err=%v attempts=%d", err, attempts)
+	}
+}
+
+func TestRetryNonRetriableErrorDoesNotOpenBreaker(t *testing.T) {
+	cb, _ := newTestBreaker(1, time.Minute)
+	config := RetryConfig{MaxAttempts: 1}.WithBreaker(cb)
+
+	_ = Retry(context.Background(), config, func() error {
+		return errors.New("invalid metric")
+	})
+	if cb.State() != CircuitClosed {
+		t.Errorf("Ошибка в данных не должна открывать breaker, состояние %s", cb.State())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"strings"
 )
 
 // CalculateHash вычисляет HMAC-SHA256 хеш от данных с использованием ключа.
@@ -31,3 +32,30 @@ func VerifyHash(data []byte, key string, expectedHash string) bool {
 	calculatedHash := CalculateHash(data, key)
 	return hmac.Equal([]byte(calculatedHash), []byte(expectedHash))
 }
+
+// agentTokenLabel отделяет подпись идентификатора агента от подписей тел запросов тем же ключом
+const agentTokenLabel = "go-metrics agent id\x00"
+
+// AgentToken возвращает значение заголовка идентификации агента "<id>.<подпись>", где подпись -
+// HMAC-SHA256 идентификатора под отдельной меткой. Заголовок не раскрывает хеш ключа,
+// а сервер с тем же ключом проверяет его без чтения тела запроса.
+// Если ключ или идентификатор пустые, возвращает пустую строку.
+func AgentToken(agentID, key string) string {
+	if agentID == "" || key == "" {
+		return ""
+	}
+	return agentID + "." + CalculateHash([]byte(agentTokenLabel+agentID), key)
+}
+
+// VerifyAgentToken проверяет подпись значения AgentToken ключом key и возвращает идентификатор агента
+func VerifyAgentToken(token, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return "", false
+	}
+	agentID := token[:i]
+	return agentID, hmac.Equal([]byte(token), []byte(AgentToken(agentID, key)))
+}
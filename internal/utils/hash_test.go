@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -95,3 +96,28 @@ func TestVerifyHash(t *testing.T) {
 		})
 	}
 }
+
+func TestAgentToken(t *testing.T) {
+	token := AgentToken("host.example", "secret")
+	if !strings.HasPrefix(token, "host.example.") {
+		t.Fatalf("Токен должен начинаться с идентификатора агента, получено %q", token)
+	}
+	if strings.Contains(token, CalculateHash([]byte("host.example"), "secret")) {
+		t.Error("Подпись идентификатора должна отличаться от подписи тела с теми же данными")
+	}
+	if AgentToken("", "secret") != "" || AgentToken("host", "") != "" {
+		t.Error("Без идентификатора или ключа токен должен быть пустым")
+	}
+
+	if id, ok := VerifyAgentToken(token, "secret"); !ok || id != "host.example" {
+		t.Errorf("Ожидался идентификатор host.example, получено %q, %v", id, ok)
+	}
+	for _, bad := range []string{"", "host", ".abc", "host.example.00", AgentToken("host.example", "other")} {
+		if _, ok := VerifyAgentToken(bad, "secret"); ok {
+			t.Errorf("%q: токен не должен проходить проверку", bad)
+		}
+	}
+	if _, ok := VerifyAgentToken(token, ""); ok {
+		t.Error("Без ключа сервера токен не должен проходить проверку")
+	}
+}
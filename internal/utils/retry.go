@@ -2,20 +2,35 @@ package utils
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net"
-	"strings"
+	"math/rand"
 	"time"
+)
+
+// JitterMode способ рандомизации задержек экспоненциального backoff
+type JitterMode int
 
-	"github.com/jackc/pgerrcode"
-	"github.com/jackc/pgx/v5/pgconn"
+const (
+	// JitterNone экспоненциальная задержка без рандомизации
+	JitterNone JitterMode = iota
+	// JitterFull случайная задержка в диапазоне [0, min(MaxDelay, BaseDelay*2^attempt))
+	JitterFull
+	// JitterDecorrelated случайная задержка в диапазоне [BaseDelay, предыдущая*3), не больше MaxDelay
+	JitterDecorrelated
 )
 
 // RetryConfig содержит настройки для retry логики
 type RetryConfig struct {
 	MaxAttempts int             // Максимальное количество попыток (включая первую)
 	Delays      []time.Duration // Задержки между попытками
+
+	// Экспоненциальный backoff используется вместо Delays, если задан BaseDelay
+	BaseDelay time.Duration // Начальная задержка
+	MaxDelay  time.Duration // Максимальная задержка, 0 - без ограничения
+	Jitter    JitterMode    // Способ рандомизации задержек
+
+	MaxElapsedTime time.Duration   // Максимальное общее время retry, 0 - без ограничения
+	Breaker        *CircuitBreaker // Общий circuit breaker, nil - не используется
 }
 
 // DefaultRetryConfig возвращает стандартную конфигурацию retry
@@ -26,72 +41,112 @@ func DefaultRetryConfig() RetryConfig {
 	}
 }
 
-// IsRetriableError проверяет, является ли ошибка retriable
-func IsRetriableError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	// Проверяем сетевые ошибки
-	var netErr net.Error
-	if errors.As(err, &netErr) {
-		return netErr.Timeout()
+// DefaultBackoffRetryConfig возвращает конфигурацию с экспоненциальным backoff и full jitter,
+// чтобы клиенты не повторяли запросы синхронно после восстановления сервера
+func DefaultBackoffRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    4,
+		BaseDelay:      1 * time.Second,
+		MaxDelay:       5 * time.Second,
+		Jitter:         JitterFull,
+		MaxElapsedTime: 30 * time.Second,
 	}
+}
 
-	// Проверяем PostgreSQL ошибки класса 08 (Connection Exception)
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) {
-		return pgerrcode.IsConnectionException(pgErr.Code)
-	}
+// WithBreaker возвращает копию конфигурации с общим circuit breaker
+func (c RetryConfig) WithBreaker(breaker *CircuitBreaker) RetryConfig {
+	c.Breaker = breaker
+	return c
+}
 
-	// Проверяем общие сетевые ошибки по тексту
-	errText := err.Error()
-	switch {
-	case errors.Is(err, net.ErrClosed):
-		return true
-	}
+// backoff рассчитывает задержки между попытками
+type backoff struct {
+	config RetryConfig
+	rnd    func(int64) int64
+	prev   time.Duration
+}
 
-	// Проверяем по тексту ошибки
-	retriablePatterns := []string{
-		"connection refused",
-		"connection reset",
-		"broken pipe",
-		"no route to host",
-		"network is unreachable",
-		"timeout",
-		"temporary failure",
-		"server overloaded",
-		"too many connections",
-		"connection limit exceeded",
-		"temporary error",
+// next возвращает задержку перед попыткой attempt+1
+func (b *backoff) next(attempt int) time.Duration {
+	c := b.config
+	if c.BaseDelay <= 0 {
+		if attempt < len(c.Delays) {
+			return c.Delays[attempt]
+		}
+		return 0
 	}
 
-	for _, pattern := range retriablePatterns {
-		if strings.Contains(strings.ToLower(errText), strings.ToLower(pattern)) {
-			return true
+	switch c.Jitter {
+	case JitterDecorrelated:
+		if b.prev < c.BaseDelay {
+			b.prev = c.BaseDelay
 		}
+		upper := b.prev * 3
+		if c.MaxDelay > 0 && upper > c.MaxDelay {
+			upper = c.MaxDelay
+		}
+		delay := c.BaseDelay
+		if upper > c.BaseDelay {
+			delay += time.Duration(b.rnd(int64(upper - c.BaseDelay)))
+		}
+		b.prev = delay
+		return delay
+	default:
+		delay := c.BaseDelay << min(attempt, 30)
+		if c.MaxDelay > 0 && (delay > c.MaxDelay || delay <= 0) {
+			delay = c.MaxDelay
+		}
+		if c.Jitter == JitterFull && delay > 0 {
+			delay = time.Duration(b.rnd(int64(delay)))
+		}
+		return delay
 	}
+}
 
-	return false
+// IsRetriableError проверяет, можно ли повторить запрос после ошибки.
+// Ошибки класса throttled также считаются retriable.
+func IsRetriableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return ClassifyError(err) != ErrorClassPermanent
 }
 
-// Retry выполняет функцию с retry логикой
+// Retry выполняет функцию с retry логикой.
+// Если задан Breaker, при открытом breaker вызов завершается сразу с ErrCircuitOpen,
+// а retriable ошибки учитываются breaker как отказы ресурса. Для throttled ошибок
+// задержка перед повтором не меньше Retry-After, breaker их отказами не считает.
 func Retry(ctx context.Context, config RetryConfig, fn func() error) error {
 	var lastErr error
+	start := time.Now()
+	bo := &backoff{config: config, rnd: rand.Int63n}
 
 	for attempt := 0; attempt < config.MaxAttempts; attempt++ {
 		if ctx.Err() != nil {
 			return fmt.Errorf("context cancelled: %w", ctx.Err())
 		}
 
+		if config.Breaker != nil {
+			if err := config.Breaker.Allow(); err != nil {
+				if lastErr != nil {
+					return fmt.Errorf("%w, last error: %w", err, lastErr)
+				}
+				return err
+			}
+		}
+
 		err := fn()
+		class := ClassifyError(err)
+		if config.Breaker != nil {
+			config.Breaker.Record(err != nil && class == ErrorClassRetriable)
+		}
 		if err == nil {
 			return nil // Успех
 		}
 
 		lastErr = err
 
-		if !IsRetriableError(err) {
+		if class == ErrorClassPermanent {
 			return fmt.Errorf("non-retriable error: %w", err)
 		}
 
@@ -99,7 +154,14 @@ func Retry(ctx context.Context, config RetryConfig, fn func() error) error {
 			return fmt.Errorf("max attempts reached (%d), last error: %w", config.MaxAttempts, err)
 		}
 
-		delay := config.Delays[attempt]
+		delay := bo.next(attempt)
+		if retryAfter := RetryAfter(err); retryAfter > delay {
+			delay = retryAfter
+		}
+		if config.MaxElapsedTime > 0 && time.Since(start)+delay > config.MaxElapsedTime {
+			return fmt.Errorf("max elapsed time reached (%v), last error: %w", config.MaxElapsedTime, err)
+		}
+
 		select {
 		case <-time.After(delay):
 		case <-ctx.Done():
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrorClass класс ошибки с точки зрения retry логики
+type ErrorClass int
+
+const (
+	// ErrorClassPermanent повтор не поможет: ошибка в запросе или данных
+	ErrorClassPermanent ErrorClass = iota
+	// ErrorClassRetriable временная недоступность ресурса, запрос можно повторить
+	ErrorClassRetriable
+	// ErrorClassThrottled ресурс доступен, но просит повторить запрос позже
+	ErrorClassThrottled
+)
+
+// String возвращает название класса ошибки
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassPermanent:
+		return "permanent"
+	case ErrorClassRetriable:
+		return "retriable"
+	case ErrorClassThrottled:
+		return "throttled"
+	}
+	return "unknown"
+}
+
+// ClassifiedError ошибка с явно заданным классом и необязательной задержкой Retry-After
+type ClassifiedError struct {
+	Class      ErrorClass
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error возвращает текст исходной ошибки
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap возвращает исходную ошибку
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// NewRetriableError помечает ошибку как временную
+func NewRetriableError(err error) error {
+	return &ClassifiedError{Class: ErrorClassRetriable, Err: err}
+}
+
+// NewPermanentError помечает ошибку как постоянную
+func NewPermanentError(err error) error {
+	return &ClassifiedError{Class: ErrorClassPermanent, Err: err}
+}
+
+// NewThrottledError помечает ошибку как ограничение частоты запросов.
+// retryAfter - задержка, запрошенная ресурсом, 0 - не задана.
+func NewThrottledError(err error, retryAfter time.Duration) error {
+	return &ClassifiedError{Class: ErrorClassThrottled, RetryAfter: retryAfter, Err: err}
+}
+
+// ClassifyError определяет класс ошибки по её типу. Явно классифицированные ошибки
+// имеют приоритет, затем проверяются сетевые ошибки и ошибки PostgreSQL.
+// Неизвестные ошибки считаются постоянными.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassPermanent
+	}
+
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassPermanent
+	}
+
+	// Ошибки соединения
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, net.ErrClosed):
+		return ErrorClassRetriable
+	}
+
+	// Сервер закрыл соединение, не отправив ответ
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return ErrorClassRetriable
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTemporary || dnsErr.IsTimeout {
+			return ErrorClassRetriable
+		}
+		return ErrorClassPermanent
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassRetriable
+	}
+
+	// Ошибки PostgreSQL: класс 08 (Connection Exception), перегрузка сервера
+	// и конфликты транзакций, которые безопасно повторить
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgerrcode.IsConnectionException(pgErr.Code),
+			pgErr.Code == pgerrcode.TooManyConnections,
+			pgErr.Code == pgerrcode.CannotConnectNow,
+			pgErr.Code == pgerrcode.SerializationFailure,
+			pgErr.Code == pgerrcode.DeadlockDetected:
+			return ErrorClassRetriable
+		}
+		return ErrorClassPermanent
+	}
+
+	if pgconn.Timeout(err) || pgconn.SafeToRetry(err) {
+		return ErrorClassRetriable
+	}
+
+	return ErrorClassPermanent
+}
+
+// RetryAfter возвращает задержку Retry-After из ошибки или 0, если она не задана
+func RetryAfter(err error) time.Duration {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.RetryAfter
+	}
+	return 0
+}
+
+// HTTPStatusError ошибка ответа HTTP сервера с неуспешным статусом
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+// Error возвращает текст ошибки
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// CheckHTTPResponse классифицирует ответ HTTP сервера:
+// 2xx - успех, 429 - throttled, 503 с Retry-After - throttled,
+// 408 и остальные 5xx - retriable, остальные 4xx - permanent.
+func CheckHTTPResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err := &HTTPStatusError{StatusCode: resp.StatusCode}
+	retryAfter := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return NewThrottledError(err, retryAfter)
+	case resp.StatusCode == http.StatusServiceUnavailable && retryAfter > 0:
+		return NewThrottledError(err, retryAfter)
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode >= 500:
+		return NewRetriableError(err)
+	}
+	return NewPermanentError(err)
+}
+
+// MaxRetryAfter максимальная задержка Retry-After, которую соблюдает клиент.
+// Большие значения ограничиваются, чтобы сервер не мог остановить отправку надолго.
+const MaxRetryAfter = 5 * time.Minute
+
+// ParseRetryAfter разбирает значение заголовка Retry-After в секундах или в формате HTTP-даты.
+// Возвращает 0 для пустого или некорректного значения, задержка не превышает MaxRetryAfter.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		if seconds > int64(MaxRetryAfter/time.Second) {
+			return MaxRetryAfter
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return min(d, MaxRetryAfter)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckHTTPResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		class      ErrorClass
+		delay      time.Duration
+	}{
+		{name: "bad request", status: http.StatusBadRequest, class: ErrorClassPermanent},
+		{name: "not found", status: http.StatusNotFound, class: ErrorClassPermanent},
+		{name: "request timeout", status: http.StatusRequestTimeout, class: ErrorClassRetriable},
+		{name: "internal error", status: http.StatusInternalServerError, class: ErrorClassRetriable},
+		{name: "bad gateway", status: http.StatusBadGateway, class: ErrorClassRetriable},
+		{name: "unavailable without retry-after", status: http.StatusServiceUnavailable, class: ErrorClassRetriable},
+		{name: "unavailable with retry-after", status: http.StatusServiceUnavailable, retryAfter: "7", class: ErrorClassThrottled, delay: 7 * time.Second},
+		{name: "too many requests", status: http.StatusTooManyRequests, retryAfter: "2", class: ErrorClassThrottled, delay: 2 * time.Second},
+		{name: "too many requests without retry-after", status: http.StatusTooManyRequests, class: ErrorClassThrottled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			err := CheckHTTPResponse(resp)
+			if err == nil {
+				t.Fatal("Ожидалась ошибка")
+			}
+			if class := ClassifyError(err); class != tt.class {
+				t.Errorf("Ожидался класс %s, получено %s", tt.class, class)
+			}
+			if delay := RetryAfter(err); delay != tt.delay {
+				t.Errorf("Ожидалась задержка %v, получено %v", tt.delay, delay)
+			}
+
+			var statusErr *HTTPStatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+				t.Errorf("Ошибка должна содержать код ответа %d", tt.status)
+			}
+		})
+	}
+
+	if err := CheckHTTPResponse(&http.Response{StatusCode: http.StatusOK}); err != nil {
+		t.Errorf("Успешный ответ не должен быть ошибкой: %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-30 * time.Second).Format(http.TimeFormat), 0},
+		{"86400", MaxRetryAfter},
+		{"99999999999999999", MaxRetryAfter},
+		{now.Add(24 * time.Hour).Format(http.TimeFormat), MaxRetryAfter},
+	}
+
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("ParseRetryAfter(%q) = %v, ожидалось %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	config := RetryConfig{MaxAttempts: 2, Delays: []time.Duration{time.Millisecond}}
+
+	attempts := 0
+	start := time.Now()
+	err := Retry(context.Background(), config, func() error {
+		attempts++
+		if attempts == 1 {
+			return NewThrottledError(errors.New("rate limited"), 50*time.Millisecond)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Повтор должен выполняться не раньше Retry-After, прошло %v", elapsed)
+	}
+}
+
+func TestRetryThrottledDoesNotOpenBreaker(t *testing.T) {
+	cb, _ := newTestBreaker(1, time.Minute)
+	config := RetryConfig{MaxAttempts: 1}.WithBreaker(cb)
+
+	_ = Retry(context.Background(), config, func() error {
+		return NewThrottledError(errors.New("rate limited"), 0)
+	})
+	if cb.State() != CircuitClosed {
+		t.Errorf("Ограничение частоты не должно открывать breaker, состояние %s", cb.State())
+	}
+}
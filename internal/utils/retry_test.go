@@ -3,8 +3,16 @@ package utils
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // MockError - мок ошибка для тестирования
@@ -33,6 +41,13 @@ func (e *MockRetriableError) Timeout() bool {
 	return false
 }
 
+// MockTimeoutError - мок сетевой ошибки таймаута
+type MockTimeoutError struct{}
+
+func (e *MockTimeoutError) Error() string   { return "i/o timeout" }
+func (e *MockTimeoutError) Timeout() bool   { return true }
+func (e *MockTimeoutError) Temporary() bool { return true }
+
 func TestIsRetriableError(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -50,55 +65,75 @@ func TestIsRetriableError(t *testing.T) {
 			expected: false,
 		},
 		{
-			name:     "connection refused text",
-			err:      errors.New("connection refused"),
-			expected: true,
+			name:     "timeout text without type",
+			err:      errors.New("invalid timeout value"),
+			expected: false,
 		},
 		{
-			name:     "connection reset text",
-			err:      errors.New("connection reset"),
+			name:     "connection refused",
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
 			expected: true,
 		},
 		{
-			name:     "broken pipe text",
-			err:      errors.New("broken pipe"),
+			name:     "connection reset",
+			err:      fmt.Errorf("read: %w", syscall.ECONNRESET),
 			expected: true,
 		},
 		{
-			name:     "timeout text",
-			err:      errors.New("timeout"),
+			name:     "broken pipe",
+			err:      fmt.Errorf("write: %w", syscall.EPIPE),
 			expected: true,
 		},
 		{
-			name:     "server overloaded text",
-			err:      errors.New("server overloaded"),
+			name:     "network timeout",
+			err:      &url.Error{Op: "Post", URL: "http://localhost", Err: &MockTimeoutError{}},
 			expected: true,
 		},
 		{
-			name:     "too many connections text",
-			err:      errors.New("too many connections"),
+			name:     "temporary dns error",
+			err:      &net.DNSError{Err: "server misbehaving", IsTemporary: true},
 			expected: true,
 		},
 		{
-			name:     "temporary error text",
-			err:      errors.New("temporary error"),
+			name:     "dns not found",
+			err:      &net.DNSError{Err: "no such host", IsNotFound: true},
+			expected: false,
+		},
+		{
+			name:     "too many connections",
+			err:      &pgconn.PgError{Code: pgerrcode.TooManyConnections},
 			expected: true,
 		},
 		{
-			name:     "case insensitive connection refused",
-			err:      errors.New("CONNECTION REFUSED"),
+			name:     "connection exception",
+			err:      &pgconn.PgError{Code: pgerrcode.ConnectionFailure},
 			expected: true,
 		},
 		{
-			name:     "case insensitive timeout",
-			err:      errors.New("TIMEOUT ERROR"),
+			name:     "unique violation",
+			err:      &pgconn.PgError{Code: pgerrcode.UniqueViolation},
+			expected: false,
+		},
+		{
+			name:     "explicit retriable",
+			err:      NewRetriableError(errors.New("temporary error")),
 			expected: true,
 		},
 		{
-			name:     "mixed case server overloaded",
-			err:      errors.New("Server Overloaded"),
+			name:     "explicit permanent over network error",
+			err:      NewPermanentError(fmt.Errorf("dial: %w", syscall.ECONNREFUSED)),
+			expected: false,
+		},
+		{
+			name:     "throttled",
+			err:      NewThrottledError(errors.New("rate limited"), time.Second),
 			expected: true,
 		},
+		{
+			name:     "context cancelled",
+			err:      fmt.Errorf("request: %w", context.Canceled),
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -116,7 +151,7 @@ func TestRetry_Success(t *testing.T) {
 	fn := func() error {
 		attempts++
 		if attempts < 3 {
-			return errors.New("temporary error")
+			return NewRetriableError(errors.New("temporary error"))
 		}
 		return nil
 	}
@@ -142,7 +177,7 @@ func TestRetry_MaxAttemptsReached(t *testing.T) {
 	attempts := 0
 	fn := func() error {
 		attempts++
-		return errors.New("temporary error")
+		return NewRetriableError(errors.New("temporary error"))
 	}
 
 	config := RetryConfig{
@@ -193,7 +228,7 @@ func TestRetry_NonRetriableError(t *testing.T) {
 
 func TestRetry_ContextCancelled(t *testing.T) {
 	fn := func() error {
-		return errors.New("temporary error")
+		return NewRetriableError(errors.New("temporary error"))
 	}
 
 	config := RetryConfig{
@@ -235,7 +270,7 @@ func TestRetryWithBackoff(t *testing.T) {
 	fn := func() error {
 		attempts++
 		if attempts < 3 {
-			return errors.New("temporary error")
+			return NewRetriableError(errors.New("temporary error"))
 		}
 		return nil
 	}
@@ -302,7 +337,7 @@ func TestRetryWithBackoffSuccess(t *testing.T) {
 	err := RetryWithBackoff(ctx, 3, 100*time.Millisecond, func() error {
 		attempts++
 		if attempts < 3 {
-			return errors.New("temporary error")
+			return NewRetriableError(errors.New("temporary error"))
 		}
 		return nil // Успех на третьей попытке
 	})
@@ -424,3 +459,65 @@ func TestRetryWithBackoffZeroMaxAttempts(t *testing.T) {
 		return errors.New("test error")
 	})
 }
+
+func TestBackoffFullJitter(t *testing.T) {
+	config := RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond, Jitter: JitterFull}
+	// Случайная функция возвращает максимум диапазона, чтобы проверить верхнюю границу
+	bo := &backoff{config: config, rnd: func(n int64) int64 { return n - 1 }}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for attempt, want := range expected {
+		if got := bo.next(attempt); got != want-1 {
+			t.Errorf("Попытка %d: ожидалась задержка %v, получено %v", attempt, want-1, got)
+		}
+	}
+
+	bo.rnd = func(int64) int64 { return 0 }
+	if got := bo.next(5); got != 0 {
+		t.Errorf("Full jitter допускает нулевую задержку, получено %v", got)
+	}
+}
+
+func TestBackoffDecorrelatedJitter(t *testing.T) {
+	config := RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: JitterDecorrelated}
+	bo := &backoff{config: config, rnd: func(n int64) int64 { return n }}
+
+	// С максимальным значением случайной функции задержка растёт втрое до MaxDelay
+	expected := []time.Duration{300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second}
+	for attempt, want := range expected {
+		if got := bo.next(attempt); got != want {
+			t.Errorf("Попытка %d: ожидалась задержка %v, получено %v", attempt, want, got)
+		}
+	}
+}
+
+func TestBackoffFixedDelays(t *testing.T) {
+	bo := &backoff{config: DefaultRetryConfig()}
+	if got := bo.next(1); got != 3*time.Second {
+		t.Errorf("Без BaseDelay должны использоваться фиксированные задержки, получено %v", got)
+	}
+}
+
+func TestRetryMaxElapsedTime(t *testing.T) {
+	config := RetryConfig{
+		MaxAttempts:    10,
+		BaseDelay:      50 * time.Millisecond,
+		MaxElapsedTime: 100 * time.Millisecond,
+	}
+
+	attempts := 0
+	start := time.Now()
+	err := Retry(context.Background(), config, func() error {
+		attempts++
+		return NewRetriableError(errors.New("connection refused"))
+	})
+	if err == nil {
+		t.Fatal("Ожидалась ошибка")
+	}
+	if attempts != 2 {
+		t.Errorf("Ожидалось 2 попытки до превышения MaxElapsedTime, выполнено %d", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > config.MaxElapsedTime {
+		t.Errorf("Retry превысил MaxElapsedTime: %v", elapsed)
+	}
+}
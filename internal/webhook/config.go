@@ -0,0 +1,91 @@
+// Package webhook отправляет принятые обновления метрик на HTTP адреса внешних систем.
+//
+// Обновления приходят из stream.Broker, поэтому webhooks получают метрики от всех
+// обработчиков приёма: HTTP API, OTLP, InfluxDB, remote_write, Graphite и StatsD.
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+)
+
+// Значения по умолчанию
+const (
+	DefaultBatchSize     = 100
+	DefaultBatchInterval = time.Second
+	DefaultQueueSize     = 100
+	DefaultMaxAttempts   = 4
+	DefaultTimeout       = 10 * time.Second
+)
+
+// HashHeader заголовок с подписью тела запроса, как у агента
+const HashHeader = "HashSHA256"
+
+// Config настройки одного webhook
+type Config struct {
+	// Name имя webhook в логах и dead-letter записях, по умолчанию URL
+	Name string
+	// URL адрес, на который отправляются обновления POST запросом
+	URL string
+	// Filter отбор обновлений по имени и типу метрики
+	Filter stream.Filter
+	// Secret ключ подписи тела запроса utils.CalculateHash, пустой - без подписи
+	Secret string
+	// BatchSize максимальное количество обновлений в одном запросе
+	BatchSize int
+	// BatchInterval максимальное время ожидания неполного батча
+	BatchInterval time.Duration
+	// QueueSize количество батчей, ожидающих отправки. Батч, не поместившийся
+	// в очередь, сразу записывается в dead-letter лог
+	QueueSize int
+	// MaxAttempts количество попыток отправки одного батча
+	MaxAttempts int
+	// Timeout таймаут одной попытки отправки
+	Timeout time.Duration
+	// DeadLetterFile файл для батчей, которые не удалось отправить, пустой - стандартный лог
+	DeadLetterFile string
+}
+
+// withDefaults возвращает конфигурацию с заполненными значениями по умолчанию
+func (c Config) withDefaults() Config {
+	if c.Name == "" {
+		c.Name = c.URL
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.BatchInterval == 0 {
+		c.BatchInterval = DefaultBatchInterval
+	}
+	if c.QueueSize == 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = DefaultMaxAttempts
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	return c
+}
+
+// Validate проверяет конфигурацию webhook, нулевые значения заменяются значениями по умолчанию
+func (c Config) Validate() error {
+	c = c.withDefaults()
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook %s: некорректный URL %q", c.Name, c.URL)
+	}
+	for _, t := range c.Filter.Types {
+		if t != "gauge" && t != "counter" {
+			return fmt.Errorf("webhook %s: неизвестный тип метрики %q", c.Name, t)
+		}
+	}
+	if c.BatchSize < 0 || c.BatchInterval < 0 || c.QueueSize < 0 || c.MaxAttempts < 0 || c.Timeout < 0 {
+		return fmt.Errorf("webhook %s: размер батча, интервал, очередь, попытки и таймаут не могут быть отрицательными", c.Name)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+)
+
+// DeadLetter запись о батче, который не удалось отправить
+type DeadLetter struct {
+	Time    time.Time        `json:"time"`
+	Webhook string           `json:"webhook"`
+	Error   string           `json:"error"`
+	Metrics []models.Metrics `json:"metrics"`
+}
+
+// deadLetterLog пишет неотправленные батчи строками JSON в файл или в стандартный лог
+type deadLetterLog struct {
+	mu   sync.Mutex
+	file *os.File
+	w    io.Writer
+}
+
+// openDeadLetterLog открывает файл на дозапись, для пустого пути записи идут в стандартный лог
+func openDeadLetterLog(path string) (*deadLetterLog, error) {
+	if path == "" {
+		return &deadLetterLog{}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть dead-letter лог: %w", err)
+	}
+	return &deadLetterLog{file: file, w: file}, nil
+}
+
+// Write записывает батч в dead-letter лог
+func (d *deadLetterLog) Write(entry DeadLetter) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Webhook %s: ошибка кодирования dead-letter записи: %v", entry.Webhook, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.w == nil {
+		log.Printf("Webhook dead-letter: %s", data)
+		return
+	}
+	if _, err := d.w.Write(append(data, '\n')); err != nil {
+		log.Printf("Webhook %s: ошибка записи dead-letter лога: %v, запись: %s", entry.Webhook, err, data)
+	}
+}
+
+// Close закрывает файл dead-letter лога
+func (d *deadLetterLog) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file, d.w = nil, nil
+	return err
+}
@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+)
+
+// Manager управляет фоновой отправкой webhooks
+type Manager struct {
+	broker      *stream.Broker
+	webhooks    []*webhook
+	deadLetters map[string]*deadLetterLog
+
+	// Поля для graceful shutdown: collect останавливает чтение обновлений,
+	// send прерывает отправку, если очередь не успела опустеть
+	collectCtx    context.Context
+	collectCancel context.CancelFunc
+	sendCtx       context.Context
+	sendCancel    context.CancelFunc
+	collectWG     sync.WaitGroup
+	sendWG        sync.WaitGroup
+	mu            sync.Mutex
+	started       bool
+	stopped       bool
+}
+
+// NewManager создаёт менеджер webhooks, проверяя конфигурации и открывая dead-letter логи.
+// Webhooks с одинаковым DeadLetterFile пишут в общий файл.
+func NewManager(broker *stream.Broker, configs []Config) (*Manager, error) {
+	m := &Manager{
+		broker:      broker,
+		deadLetters: make(map[string]*deadLetterLog),
+	}
+	m.collectCtx, m.collectCancel = context.WithCancel(context.Background())
+	m.sendCtx, m.sendCancel = context.WithCancel(context.Background())
+
+	for _, cfg := range configs {
+		cfg = cfg.withDefaults()
+		if err := cfg.Validate(); err != nil {
+			m.closeDeadLetters()
+			return nil, err
+		}
+		deadLetter, ok := m.deadLetters[cfg.DeadLetterFile]
+		if !ok {
+			var err error
+			if deadLetter, err = openDeadLetterLog(cfg.DeadLetterFile); err != nil {
+				m.closeDeadLetters()
+				return nil, err
+			}
+			m.deadLetters[cfg.DeadLetterFile] = deadLetter
+		}
+		m.webhooks = append(m.webhooks, newWebhook(broker, cfg, deadLetter))
+	}
+	return m, nil
+}
+
+// Start подписывает webhooks на обновления и запускает отправку
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started || m.stopped {
+		return
+	}
+	m.started = true
+
+	for _, w := range m.webhooks {
+		log.Printf("Webhook %s: отправка обновлений на %s батчами до %d", w.cfg.Name, w.cfg.URL, w.cfg.BatchSize)
+		sub := m.broker.Subscribe(w.cfg.Filter)
+		m.collectWG.Add(1)
+		go func(w *webhook) {
+			defer m.collectWG.Done()
+			w.collectLoop(m.collectCtx, sub)
+		}(w)
+		m.sendWG.Add(1)
+		go func(w *webhook) {
+			defer m.sendWG.Done()
+			w.sendLoop(m.sendCtx)
+		}(w)
+	}
+}
+
+// Stop отписывает webhooks, отправляет накопленные батчи и ожидает отправки очередей
+// до истечения ctx. Неотправленные батчи записываются в dead-letter лог.
+func (m *Manager) Stop(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	if !m.started {
+		m.closeDeadLetters()
+		return
+	}
+
+	log.Printf("Остановка webhooks...")
+	m.collectCancel()
+	m.collectWG.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		m.sendWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Webhooks не успели отправить очереди: %v", ctx.Err())
+		m.sendCancel()
+		<-done
+	}
+	m.sendCancel()
+	m.closeDeadLetters()
+
+	for _, s := range m.Stats() {
+		log.Printf("Webhook %s остановлен: отправлено %d, в dead-letter %d, отброшено %d",
+			s.Name, s.Sent, s.DeadLettered, s.Dropped)
+	}
+}
+
+// closeDeadLetters закрывает файлы dead-letter логов
+func (m *Manager) closeDeadLetters() {
+	for path, d := range m.deadLetters {
+		if err := d.Close(); err != nil {
+			log.Printf("Ошибка закрытия dead-letter лога %s: %v", path, err)
+		}
+	}
+}
+
+// Stats возвращает статистику webhooks
+func (m *Manager) Stats() []Stats {
+	stats := make([]Stats, 0, len(m.webhooks))
+	for _, w := range m.webhooks {
+		stats = append(stats, w.stats())
+	}
+	return stats
+}
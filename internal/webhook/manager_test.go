@@ -0,0 +1,266 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// receiver тестовый получатель webhooks
+type receiver struct {
+	mu      sync.Mutex
+	batches [][]models.Metrics
+	hashes  []string
+	bodies  [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	var batch []models.Metrics
+	if err := json.Unmarshal(body, &batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.batches = append(r.batches, batch)
+	r.hashes = append(r.hashes, req.Header.Get(HashHeader))
+	r.bodies = append(r.bodies, body)
+	r.mu.Unlock()
+}
+
+func (r *receiver) received() [][]models.Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]models.Metrics(nil), r.batches...)
+}
+
+func gauge(id string, v float64) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &v}
+}
+
+func counter(id string, d int64) models.Metrics {
+	return models.Metrics{ID: id, MType: "counter", Delta: &d}
+}
+
+func fastRetry(m *Manager) {
+	for _, w := range m.webhooks {
+		w.retry.BaseDelay = time.Millisecond
+		w.retry.MaxDelay = time.Millisecond
+	}
+}
+
+func TestManager_BatchingAndSigning(t *testing.T) {
+	recv := &receiver{}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	broker := stream.NewBroker(0, "")
+	m, err := NewManager(broker, []Config{{
+		URL:           srv.URL,
+		Secret:        "secret",
+		BatchSize:     2,
+		BatchInterval: time.Hour,
+		Filter:        stream.Filter{Names: []string{"Heap*"}, Types: []string{"gauge"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+
+	broker.Publish(gauge("HeapAlloc", 1), counter("HeapCount", 1), gauge("Alloc", 2), gauge("HeapInuse", 3), gauge("HeapSys", 4))
+	// Неполный батч отправляется при остановке
+	m.Stop(context.Background())
+
+	batches := recv.received()
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("Ожидались батчи из 2 и 1 обновления, получено %v", batches)
+	}
+	if batches[0][0].ID != "HeapAlloc" || batches[0][1].ID != "HeapInuse" || batches[1][0].ID != "HeapSys" {
+		t.Errorf("Фильтр пропустил лишние обновления: %v", batches)
+	}
+	for i, hash := range recv.hashes {
+		if !utils.VerifyHash(recv.bodies[i], "secret", hash) {
+			t.Errorf("Батч %d: неверная подпись %q", i, hash)
+		}
+	}
+	if s := m.Stats()[0]; s.Sent != 2 || s.DeadLettered != 0 {
+		t.Errorf("Неожиданная статистика: %+v", s)
+	}
+}
+
+func TestManager_BatchInterval(t *testing.T) {
+	recv := &receiver{}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	broker := stream.NewBroker(0, "")
+	m, err := NewManager(broker, []Config{{URL: srv.URL, BatchInterval: 20 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	defer m.Stop(context.Background())
+
+	broker.Publish(counter("PollCount", 5))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recv.received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Неполный батч не отправлен по интервалу")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if recv.hashes[0] != "" {
+		t.Errorf("Без секрета подпись не ожидается, получено %q", recv.hashes[0])
+	}
+}
+
+func TestManager_Retry(t *testing.T) {
+	var requests atomic.Int32
+	recv := &receiver{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		recv.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	broker := stream.NewBroker(0, "")
+	m, err := NewManager(broker, []Config{{URL: srv.URL, BatchInterval: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fastRetry(m)
+	m.Start()
+	broker.Publish(gauge("Alloc", 1))
+	m.Stop(context.Background())
+
+	if requests.Load() != 2 || len(recv.received()) != 1 {
+		t.Errorf("Ожидалась доставка со второй попытки, запросов %d", requests.Load())
+	}
+}
+
+func TestManager_DeadLetter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	broker := stream.NewBroker(0, "")
+	m, err := NewManager(broker, []Config{{Name: "hook", URL: srv.URL, BatchInterval: time.Hour, DeadLetterFile: path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	broker.Publish(gauge("Alloc", 1), counter("PollCount", 2))
+	m.Stop(context.Background())
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []DeadLetter
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Некорректная dead-letter запись: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Ожидалась одна dead-letter запись, получено %d", len(entries))
+	}
+	if entries[0].Webhook != "hook" || len(entries[0].Metrics) != 2 || entries[0].Error == "" {
+		t.Errorf("Неожиданная dead-letter запись: %+v", entries[0])
+	}
+	if s := m.Stats()[0]; s.Sent != 0 || s.DeadLettered != 1 {
+		t.Errorf("Неожиданная статистика: %+v", s)
+	}
+}
+
+func TestManager_StopDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	broker := stream.NewBroker(0, "")
+	m, err := NewManager(broker, []Config{{URL: srv.URL, BatchInterval: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	broker.Publish(gauge("Alloc", 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	m.Stop(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Stop занял %v", elapsed)
+	}
+	if s := m.Stats()[0]; s.DeadLettered != 1 {
+		t.Errorf("Неотправленный батч должен попасть в dead-letter лог: %+v", s)
+	}
+}
+
+func TestManager_BrokerClosed(t *testing.T) {
+	broker := stream.NewBroker(0, "")
+	m, err := NewManager(broker, []Config{{URL: "http://127.0.0.1:1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	broker.Close()
+
+	done := make(chan struct{})
+	go func() {
+		m.Stop(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop не завершился после закрытия брокера")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"корректный", Config{URL: "https://hooks.example.com/metrics"}, false},
+		{"без схемы", Config{URL: "hooks.example.com"}, true},
+		{"неизвестный тип", Config{URL: "http://h", Filter: stream.Filter{Types: []string{"histogram"}}}, true},
+		{"отрицательный батч", Config{URL: "http://h", BatchSize: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
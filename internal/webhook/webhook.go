@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/models"
+	"github.com/ViktorBystrov72/go-metrics/internal/stream"
+	"github.com/ViktorBystrov72/go-metrics/internal/utils"
+)
+
+// Stats статистика webhook
+type Stats struct {
+	Name string `json:"name"`
+	// Sent успешно отправленные батчи
+	Sent int64 `json:"sent"`
+	// DeadLettered батчи, записанные в dead-letter лог
+	DeadLettered int64 `json:"dead_lettered"`
+	// Dropped обновления, отброшенные брокером из-за переполнения буфера подписки
+	Dropped int64 `json:"dropped"`
+}
+
+// webhook собирает обновления из подписки в батчи и отправляет их из очереди
+// отдельной горутиной, чтобы медленный получатель не переполнял буфер подписки
+type webhook struct {
+	cfg        Config
+	broker     *stream.Broker
+	deadLetter *deadLetterLog
+	client     *http.Client
+	retry      utils.RetryConfig
+	queue      chan []models.Metrics
+
+	sent         atomic.Int64
+	deadLettered atomic.Int64
+	dropped      atomic.Int64
+}
+
+// newWebhook создаёт webhook по проверенной конфигурации
+func newWebhook(broker *stream.Broker, cfg Config, deadLetter *deadLetterLog) *webhook {
+	w := &webhook{
+		cfg:        cfg,
+		broker:     broker,
+		deadLetter: deadLetter,
+		client:     &http.Client{},
+		queue:      make(chan []models.Metrics, cfg.QueueSize),
+	}
+	w.retry = utils.DefaultBackoffRetryConfig()
+	w.retry.MaxAttempts = cfg.MaxAttempts
+	return w
+}
+
+// collectLoop читает обновления из подписки и формирует батчи до отмены ctx.
+// При выходе отправляет накопленный батч и закрывает очередь.
+func (w *webhook) collectLoop(ctx context.Context, sub *stream.Subscription) {
+	defer close(w.queue)
+
+	ticker := time.NewTicker(w.cfg.BatchInterval)
+	defer ticker.Stop()
+
+	var batch []models.Metrics
+	flush := func() {
+		if dropped := sub.TakeDropped(); dropped > 0 {
+			w.dropped.Add(int64(dropped))
+			log.Printf("Webhook %s: брокер отбросил %d обновлений из-за переполнения буфера", w.cfg.Name, dropped)
+		}
+		if len(batch) > 0 {
+			w.enqueue(batch)
+			batch = nil
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.broker.Unsubscribe(sub)
+			// Забираем обновления, уже попавшие в буфер подписки
+			for m := range sub.Updates() {
+				batch = append(batch, m)
+				if len(batch) >= w.cfg.BatchSize {
+					flush()
+				}
+			}
+			flush()
+			return
+		case m, ok := <-sub.Updates():
+			if !ok {
+				flush()
+				// Подписка закрыта брокером: при остановке брокера выходим,
+				// при отключении из-за переполнения подписываемся заново
+				sub = w.broker.Subscribe(w.cfg.Filter)
+				select {
+				case <-sub.Done():
+					return
+				default:
+				}
+				log.Printf("Webhook %s: подписка на обновления восстановлена", w.cfg.Name)
+				continue
+			}
+			batch = append(batch, m)
+			if len(batch) >= w.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// enqueue ставит батч в очередь отправки, при переполнении записывает его в dead-letter лог
+func (w *webhook) enqueue(batch []models.Metrics) {
+	select {
+	case w.queue <- batch:
+	default:
+		w.writeDeadLetter(batch, fmt.Errorf("очередь отправки переполнена"))
+	}
+}
+
+// sendLoop отправляет батчи из очереди до её закрытия.
+// После отмены ctx оставшиеся батчи записываются в dead-letter лог.
+func (w *webhook) sendLoop(ctx context.Context) {
+	for batch := range w.queue {
+		if err := w.send(ctx, batch); err != nil {
+			w.writeDeadLetter(batch, err)
+			continue
+		}
+		w.sent.Add(1)
+	}
+}
+
+// send отправляет батч JSON массивом метрик с подписью и повторами
+func (w *webhook) send(ctx context.Context, batch []models.Metrics) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	return utils.Retry(ctx, w.retry, func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(attemptCtx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+		if err != nil {
+			return utils.NewPermanentError(fmt.Errorf("failed to create request: %w", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if w.cfg.Secret != "" {
+			req.Header.Set(HashHeader, utils.CalculateHash(body, w.cfg.Secret))
+		}
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		return utils.CheckHTTPResponse(resp)
+	})
+}
+
+// writeDeadLetter записывает неотправленный батч в dead-letter лог
+func (w *webhook) writeDeadLetter(batch []models.Metrics, err error) {
+	w.deadLettered.Add(1)
+	log.Printf("Webhook %s: батч из %d обновлений не отправлен: %v", w.cfg.Name, len(batch), err)
+	w.deadLetter.Write(DeadLetter{
+		Time:    time.Now(),
+		Webhook: w.cfg.Name,
+		Error:   err.Error(),
+		Metrics: batch,
+	})
+}
+
+// stats возвращает статистику webhook
+func (w *webhook) stats() Stats {
+	return Stats{
+		Name:         w.cfg.Name,
+		Sent:         w.sent.Load(),
+		DeadLettered: w.deadLettered.Load(),
+		Dropped:      w.dropped.Load(),
+	}
+}
@@ -0,0 +1,95 @@
+// Package wsproto описывает протокол приёма метрик через WebSocket.
+//
+// Агент держит одно соединение с сервером и отправляет в нём батчи метрик
+// текстовыми сообщениями Batch. Сервер подтверждает каждый батч сообщением Ack
+// с тем же порядковым номером. Подтверждения могут приходить в любом порядке.
+package wsproto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rsa"
+	"fmt"
+	"io"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/crypto"
+)
+
+// Path путь для установки WebSocket соединения
+const Path = "/ws/updates"
+
+// Кодирование полезной нагрузки батча, аналогично заголовку Content-Encoding
+const (
+	// EncodingIdentity JSON без сжатия
+	EncodingIdentity = ""
+	// EncodingGzip JSON, сжатый gzip
+	EncodingGzip = "gzip"
+	// EncodingEncrypted JSON, сжатый gzip и зашифрованный публичным ключом сервера
+	EncodingEncrypted = "encrypted"
+)
+
+// Статусы подтверждения батча
+const (
+	// StatusOK батч применён
+	StatusOK = "ok"
+	// StatusRejected батч отклонён из-за ошибки в данных, повтор не поможет
+	StatusRejected = "rejected"
+	// StatusError временная ошибка сервера, батч можно отправить повторно
+	StatusError = "error"
+	// StatusThrottled превышено ограничение частоты, повтор не раньше RetryAfterMs
+	StatusThrottled = "throttled"
+)
+
+// Batch сообщение агента с батчем метрик
+type Batch struct {
+	// Seq порядковый номер батча в соединении
+	Seq uint64 `json:"seq"`
+	// Encoding кодирование Payload
+	Encoding string `json:"encoding,omitempty"`
+	// Hash HMAC-SHA256 JSON массива метрик до сжатия, если задан ключ
+	Hash string `json:"hash,omitempty"`
+	// Payload JSON массив models.Metrics с учётом Encoding
+	Payload []byte `json:"payload"`
+}
+
+// Ack подтверждение сервера
+type Ack struct {
+	Seq          uint64 `json:"seq"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+// DecodePayload возвращает JSON массив метрик из батча, выполняя дешифрование и распаковку
+func DecodePayload(b Batch, privateKey *rsa.PrivateKey) ([]byte, error) {
+	payload := b.Payload
+
+	switch b.Encoding {
+	case EncodingIdentity:
+		return payload, nil
+	case EncodingEncrypted:
+		if privateKey == nil {
+			return nil, fmt.Errorf("encrypted payload is not supported: private key is not configured")
+		}
+		decrypted, err := crypto.DecryptLargeData(payload, privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt payload: %w", err)
+		}
+		payload = decrypted
+	case EncodingGzip:
+	default:
+		return nil, fmt.Errorf("unknown payload encoding: %s", b.Encoding)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("gzip payload: %w", err)
+	}
+	defer gz.Close()
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("gzip payload: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,72 @@
+package wsproto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/ViktorBystrov72/go-metrics/internal/crypto"
+)
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatalf("Ошибка сжатия: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Ошибка сжатия: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodePayload(t *testing.T) {
+	body := []byte(`[{"id":"test","type":"gauge","value":1.5}]`)
+
+	privateKey, publicKey, err := crypto.GenerateKeyPair(2048)
+	if err != nil {
+		t.Fatalf("Ошибка генерации ключей: %v", err)
+	}
+	encrypted, err := crypto.EncryptLargeData(gzipData(t, body), publicKey)
+	if err != nil {
+		t.Fatalf("Ошибка шифрования: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		batch   Batch
+		key     bool
+		wantErr bool
+	}{
+		{name: "без сжатия", batch: Batch{Payload: body}},
+		{name: "gzip", batch: Batch{Encoding: EncodingGzip, Payload: gzipData(t, body)}},
+		{name: "зашифрованный", batch: Batch{Encoding: EncodingEncrypted, Payload: encrypted}, key: true},
+		{name: "зашифрованный без ключа", batch: Batch{Encoding: EncodingEncrypted, Payload: encrypted}, wantErr: true},
+		{name: "неизвестное кодирование", batch: Batch{Encoding: "br", Payload: body}, wantErr: true},
+		{name: "повреждённый gzip", batch: Batch{Encoding: EncodingGzip, Payload: body}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := privateKey
+			if !tt.key {
+				key = nil
+			}
+
+			data, err := DecodePayload(tt.batch, key)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Ожидалась ошибка")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if !bytes.Equal(data, body) {
+				t.Errorf("Ожидалось %s, получено %s", body, data)
+			}
+		})
+	}
+}
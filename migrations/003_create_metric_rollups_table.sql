@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS metric_rollups (
+    tier VARCHAR(10) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    bucket_start TIMESTAMPTZ NOT NULL,
+    count BIGINT NOT NULL,
+    min DOUBLE PRECISION NOT NULL,
+    max DOUBLE PRECISION NOT NULL,
+    sum DOUBLE PRECISION NOT NULL,
+    last DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (tier, type, name, bucket_start)
+);
+CREATE INDEX IF NOT EXISTS idx_metric_rollups_tier_start ON metric_rollups(tier, bucket_start);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS metric_rollups;
+-- +goose StatementEnd
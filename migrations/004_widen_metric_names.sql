@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE metrics ALTER COLUMN name TYPE TEXT;
+ALTER TABLE metric_rollups ALTER COLUMN name TYPE TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE metric_rollups ALTER COLUMN name TYPE VARCHAR(255);
+ALTER TABLE metrics ALTER COLUMN name TYPE VARCHAR(255);
+-- +goose StatementEnd